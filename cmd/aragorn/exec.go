@@ -74,6 +74,9 @@ func (cmd *execCommand) Run(args []string) error {
 	if cmd.wait {
 		select {}
 	}
+	for _, suite := range suites {
+		suite.Close()
+	}
 	return err
 }
 
@@ -95,6 +98,22 @@ func (cmd *execCommand) exec(ctx context.Context, suites []*server.Suite, n noti
 }
 
 func getSuitesFromArgs(args []string, options ...server.SuiteOption) ([]*server.Suite, error) {
+	paths, err := getSuitePathsFromArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	suites := make([]*server.Suite, len(paths))
+	for i, path := range paths {
+		s, err := server.NewSuiteFromFile(path, options...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		suites[i] = s
+	}
+	return suites, nil
+}
+
+func getSuitePathsFromArgs(args []string) ([]string, error) {
 	if len(args) == 0 {
 		args = []string{"."}
 	}
@@ -123,13 +142,5 @@ func getSuitesFromArgs(args []string, options ...server.SuiteOption) ([]*server.
 			paths = append(paths, arg)
 		}
 	}
-	suites := make([]*server.Suite, len(paths))
-	for i, path := range paths {
-		s, err := server.NewSuiteFromFile(path, options...)
-		if err != nil {
-			return nil, fmt.Errorf("%s: %v", path, err)
-		}
-		suites[i] = s
-	}
-	return suites, nil
+	return paths, nil
 }
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/blippar/aragorn/importer"
+	"github.com/blippar/aragorn/pkg/util/json"
+	"github.com/blippar/aragorn/plugin"
+	"github.com/blippar/aragorn/server"
+)
+
+const importShortHelp = `Generate test suites from an API description`
+const importLongHelp = `Generate test suites from an API description
+
+The first argument is the format of the description and the second one the
+path to the file to import. A .suite.json file is created in the output
+directory for each generated test suite.
+`
+
+var nonAlnum = regexp.MustCompile(`[^0-9A-Za-z]+`)
+
+type importCommand struct {
+	dir  string
+	name string
+	url  string
+	env  string
+}
+
+func (*importCommand) Name() string { return "import" }
+func (*importCommand) Args() string {
+	return fmt.Sprintf("(%s) <file>", availableImporters(" | "))
+}
+func (*importCommand) ShortHelp() string { return importShortHelp }
+func (*importCommand) LongHelp() string  { return importLongHelp }
+func (*importCommand) Hidden() bool      { return false }
+
+func (cmd *importCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.dir, "dir", ".", "Output directory of the generated test suites")
+	fs.StringVar(&cmd.name, "name", "", "Name of the generated test suite")
+	fs.StringVar(&cmd.url, "url", "", "Base URL of the generated test suite")
+	fs.StringVar(&cmd.env, "env", "", "File defining the variables of the description (postman environment)")
+}
+
+func (cmd *importCommand) Run(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: aragorn import %s", cmd.Args())
+	}
+	typ, path := args[0], args[1]
+	r := plugin.Get(plugin.ImporterPlugin, typ)
+	if r == nil {
+		return fmt.Errorf("%q is not a valid import format (supported: %s)", typ, availableImporters(", "))
+	}
+	ic := plugin.NewContext(r, path)
+	opts := ic.Config.(*importer.Options)
+	opts.Name = cmd.name
+	opts.URL = cmd.url
+	opts.Env = cmd.env
+	imp, err := r.Init(ic)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	suites, err := imp.(importer.Importer).Import(f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	for _, s := range suites {
+		if err := cmd.writeSuite(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cmd *importCommand) writeSuite(s *server.SuiteConfig) error {
+	name := strings.Trim(strings.ToLower(nonAlnum.ReplaceAllString(s.Name, "-")), "-")
+	if name == "" {
+		name = "imported"
+	}
+	path := filepath.Join(cmd.dir, name+testSuiteJSONSuffix)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := json.NewEncoder(f)
+	w.SetIndent("", "  ")
+	if err := w.Encode(s); err != nil {
+		return err
+	}
+	fmt.Println(path)
+	return nil
+}
+
+func availableImporters(sep string) string {
+	rs := plugin.ForType(plugin.ImporterPlugin)
+	ss := make([]string, len(rs))
+	for i, r := range rs {
+		ss[i] = r.ID
+	}
+	sort.Strings(ss)
+	return strings.Join(ss, sep)
+}
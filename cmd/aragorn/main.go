@@ -18,9 +18,25 @@ import (
 	_ "expvar"
 	_ "net/http/pprof"
 
+	// Drivers of the SQL suites and steps.
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+
+	_ "github.com/blippar/aragorn/importer/curl"
+	_ "github.com/blippar/aragorn/importer/har"
+	_ "github.com/blippar/aragorn/importer/openapi"
+	_ "github.com/blippar/aragorn/importer/postman"
 	_ "github.com/blippar/aragorn/notifier/slack"
+	_ "github.com/blippar/aragorn/notifier/webhook"
+	_ "github.com/blippar/aragorn/testsuite/dnsexpect"
+	_ "github.com/blippar/aragorn/testsuite/execexpect"
+	_ "github.com/blippar/aragorn/testsuite/graphqlexpect"
 	_ "github.com/blippar/aragorn/testsuite/grpcexpect"
 	_ "github.com/blippar/aragorn/testsuite/httpexpect"
+	_ "github.com/blippar/aragorn/testsuite/sqlexpect"
+	_ "github.com/blippar/aragorn/testsuite/tcpexpect"
+	_ "github.com/blippar/aragorn/testsuite/tlsexpect"
+	_ "github.com/blippar/aragorn/testsuite/wsexpect"
 )
 
 const testSuiteJSONSuffix = ".suite.json"
@@ -62,9 +78,12 @@ func run() int {
 	commands := []command{
 		&initCommand{},
 		&listCommand{},
+		&validateCommand{},
 		&execCommand{},
 		&watchCommand{},
 		&runCommand{},
+		&importCommand{},
+		&mockCommand{},
 		&versionCommand{},
 	}
 
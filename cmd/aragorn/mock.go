@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go.uber.org/zap"
+
+	"github.com/blippar/aragorn/log"
+	"github.com/blippar/aragorn/server"
+)
+
+const mockShortHelp = `Serve a mock of the service tested by a test suite`
+const mockLongHelp = `Serve a mock of the service tested by a test suite
+
+The mock answers the requests of each test with its expected response.
+HTTP requests are matched on their method and path, templated path segments
+matching any value. GRPC suites must use a protoset file to be mocked.
+`
+
+type mockCommand struct {
+	addr string
+}
+
+func (*mockCommand) Name() string      { return "mock" }
+func (*mockCommand) Args() string      { return "<file>" }
+func (*mockCommand) ShortHelp() string { return mockShortHelp }
+func (*mockCommand) LongHelp() string  { return mockLongHelp }
+func (*mockCommand) Hidden() bool      { return false }
+
+func (cmd *mockCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.addr, "addr", "localhost:8080", "Address the mock server listens on")
+}
+
+func (cmd *mockCommand) Run(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: aragorn mock %s", cmd.Args())
+	}
+	suite, err := server.NewSuiteFromFile(args[0])
+	if err != nil {
+		return fmt.Errorf("%s: %v", args[0], err)
+	}
+	srv, err := suite.Mock()
+	if err != nil {
+		return fmt.Errorf("%s: %v", args[0], err)
+	}
+	l, err := net.Listen("tcp", cmd.addr)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve(l)
+	}()
+	log.Info("serving mock", zap.String("suite", suite.Name()), zap.String("type", suite.Type()), zap.String("addr", l.Addr().String()))
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-errc:
+		return err
+	case s := <-sigCh:
+		log.Debug("received signal", zap.String("signal", s.String()))
+	}
+	return nil
+}
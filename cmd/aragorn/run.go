@@ -60,6 +60,9 @@ func (cmd *runCommand) Run(args []string) error {
 		defer cancel()
 	}
 	srv.Stop(ctx)
+	for _, suite := range suites {
+		suite.Close()
+	}
 	return nil
 }
 
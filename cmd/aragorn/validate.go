@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/blippar/aragorn/server"
+)
+
+const validateShortHelp = `Validate the test suites`
+const validateLongHelp = `Validate the test suites without running them.
+
+Every test suite is loaded and all the errors are reported, e.g. GraphQL queries
+not matching the schema of their endpoint.` + fileHelp
+
+type validateCommand struct{}
+
+func (*validateCommand) Name() string { return "validate" }
+func (*validateCommand) Args() string {
+	return "[file ...]"
+}
+func (*validateCommand) ShortHelp() string { return validateShortHelp }
+func (*validateCommand) LongHelp() string  { return validateLongHelp }
+func (*validateCommand) Hidden() bool      { return false }
+
+func (*validateCommand) Register(fs *flag.FlagSet) {}
+
+func (*validateCommand) Run(args []string) error {
+	paths, err := getSuitePathsFromArgs(args)
+	if err != nil {
+		return err
+	}
+	var failed bool
+	for _, path := range paths {
+		s, err := server.NewSuiteFromFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			failed = true
+			continue
+		}
+		s.Close()
+	}
+	if failed {
+		return errSomethingWentWrong
+	}
+	return nil
+}
@@ -91,6 +91,7 @@ func (cmd *watchCommand) runSuiteFromFile(path string) {
 		fmt.Fprintf(os.Stderr, "%s: %v", path, err)
 		return
 	}
+	defer s.Close()
 	s.Run(ctx)
 }
 
@@ -0,0 +1,464 @@
+package curl
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/blippar/aragorn/importer"
+	"github.com/blippar/aragorn/pkg/util/json"
+	"github.com/blippar/aragorn/plugin"
+	"github.com/blippar/aragorn/server"
+	"github.com/blippar/aragorn/testsuite"
+	"github.com/blippar/aragorn/testsuite/httpexpect"
+)
+
+var _ importer.Importer = (*Importer)(nil)
+
+var (
+	errNoCommand      = errors.New("no curl command found")
+	errUnterminated   = errors.New("unterminated quoted string")
+	errMissingURL     = errors.New("missing URL")
+	errMissingFlagArg = errors.New("missing flag argument")
+	errDataFile       = errors.New("data read from a file (@file) is not supported, inline it")
+	errUnsupported    = errors.New("unsupported flag")
+)
+
+// ignoredFlags are the curl flags without effect on the request, mapped to
+// whether they take an argument.
+var ignoredFlags = map[string]bool{
+	"-o": true, "--output": true,
+	"-m": true, "--max-time": true,
+	"-w": true, "--write-out": true,
+	"-c": true, "--cookie-jar": true,
+	"--connect-timeout": true, "--retry": true,
+	"-s": false, "--silent": false,
+	"-S": false, "--show-error": false,
+	"-v": false, "--verbose": false,
+	"-i": false, "--include": false,
+	"-f": false, "--fail": false,
+	"-N": false, "--no-buffer": false,
+	"--compressed": false,
+}
+
+// shortFlagsWithArg are the letters of the short flags taking an argument,
+// which may be attached to the flag (e.g. -XPOST).
+const shortFlagsWithArg = "XHdFuAebxEomwc"
+
+// Importer generates an HTTP test suite from curl command lines.
+type Importer struct {
+	opts *importer.Options
+}
+
+// New returns an Importer.
+func New(opts *importer.Options) *Importer {
+	return &Importer{opts: opts}
+}
+
+// Import generates one test per curl command read from r. Commands can span
+// several lines using backslashes; blank lines and comments are ignored.
+func (imp *Importer) Import(r io.Reader) ([]*server.SuiteConfig, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	cmds, err := splitCommands(string(b))
+	if err != nil {
+		return nil, err
+	}
+	if len(cmds) == 0 {
+		return nil, errNoCommand
+	}
+	cfg := &httpexpect.Config{
+		Base: httpexpect.Base{URL: imp.opts.URL},
+	}
+	for i, args := range cmds {
+		t, err := newTest(cfg, args)
+		if err != nil {
+			return nil, fmt.Errorf("command %d: %v", i+1, err)
+		}
+		importer.AddTest(cfg, t)
+	}
+	name := imp.opts.Name
+	if name == "" {
+		name = cfg.Base.URL
+	}
+	s, err := importer.NewSuiteConfig(name, "HTTP", cfg)
+	if err != nil {
+		return nil, err
+	}
+	return []*server.SuiteConfig{s}, nil
+}
+
+func newTest(cfg *httpexpect.Config, args []string) (*httpexpect.Test, error) {
+	var (
+		method    string
+		rawurl    string
+		header    = testsuite.Header{}
+		data      []string
+		form      map[string]string
+		getData   bool
+		follow    bool
+		maxRedirs *httpexpect.RedirectLimit
+	)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			rawurl = arg
+			continue
+		}
+		if parts := splitFlag(arg); len(parts) > 1 {
+			args = append(args[:i:i], append(parts, args[i+1:]...)...)
+			arg = args[i]
+		}
+		next := func() (string, error) {
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("%s: %v", arg, errMissingFlagArg)
+			}
+			i++
+			return args[i], nil
+		}
+		var (
+			v   string
+			err error
+		)
+		switch arg {
+		case "-X", "--request":
+			method, err = next()
+		case "--url":
+			rawurl, err = next()
+		case "-H", "--header":
+			if v, err = next(); err == nil {
+				if idx := strings.Index(v, ":"); idx > 0 {
+					header[strings.TrimSpace(v[:idx])] = strings.TrimSpace(v[idx+1:])
+				}
+			}
+		case "--data-raw":
+			if v, err = next(); err == nil {
+				data = append(data, v)
+			}
+		case "-d", "--data", "--data-binary", "--data-ascii":
+			if v, err = next(); err == nil {
+				if strings.HasPrefix(v, "@") {
+					return nil, fmt.Errorf("%s: %v", arg, errDataFile)
+				}
+				data = append(data, v)
+			}
+		case "--data-urlencode":
+			if v, err = next(); err == nil {
+				// The content of name@file or @file is read from the file.
+				if idx := strings.IndexAny(v, "=@"); idx >= 0 && v[idx] == '@' {
+					return nil, fmt.Errorf("%s: %v", arg, errDataFile)
+				}
+				if idx := strings.Index(v, "="); idx >= 0 {
+					v = v[:idx+1] + url.QueryEscape(v[idx+1:])
+				} else {
+					v = url.QueryEscape(v)
+				}
+				data = append(data, v)
+			}
+		case "-F", "--form":
+			if v, err = next(); err == nil {
+				if form == nil {
+					form = make(map[string]string)
+				}
+				if idx := strings.Index(v, "="); idx > 0 {
+					form[v[:idx]] = v[idx+1:]
+				}
+			}
+		case "-u", "--user":
+			if v, err = next(); err == nil {
+				header["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(v))
+			}
+		case "-A", "--user-agent":
+			if v, err = next(); err == nil {
+				header["User-Agent"] = v
+			}
+		case "-e", "--referer":
+			if v, err = next(); err == nil {
+				header["Referer"] = v
+			}
+		case "-b", "--cookie":
+			if v, err = next(); err == nil {
+				header["Cookie"] = v
+			}
+		case "-G", "--get":
+			getData = true
+		case "-I", "--head":
+			method = "HEAD"
+		case "-k", "--insecure":
+			cfg.Base.Insecure = true
+		case "-x", "--proxy":
+			cfg.Base.Proxy, err = next()
+		case "--cacert":
+			v, err = next()
+			baseTLS(cfg).CAPath = v
+		case "-E", "--cert":
+			v, err = next()
+			baseTLS(cfg).CertPath = v
+		case "--key":
+			v, err = next()
+			baseTLS(cfg).KeyPath = v
+		case "-L", "--location":
+			follow = true
+		case "--max-redirs":
+			if v, err = next(); err == nil {
+				var n int
+				if n, err = strconv.Atoi(v); err == nil && n >= 0 {
+					l := httpexpect.RedirectLimit(n)
+					maxRedirs = &l
+				} else {
+					err = fmt.Errorf("%s: invalid number %q", arg, v)
+				}
+			}
+		default:
+			withArg, ok := ignoredFlags[arg]
+			switch {
+			case !ok:
+				err = fmt.Errorf("%s: %v", arg, errUnsupported)
+			case withArg:
+				_, err = next()
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if rawurl == "" {
+		return nil, errMissingURL
+	}
+	if getData && len(data) > 0 {
+		sep := "?"
+		if strings.Contains(rawurl, "?") {
+			sep = "&"
+		}
+		rawurl += sep + strings.Join(data, "&")
+		data = nil
+	}
+	if method == "" {
+		method = "GET"
+		if len(data) > 0 || form != nil {
+			method = "POST"
+		}
+	}
+	// Unlike curl, the tests follow the redirects by default.
+	redirects := maxRedirs
+	if !follow {
+		redirects = new(httpexpect.RedirectLimit)
+	}
+	t := &httpexpect.Test{
+		Request: httpexpect.Request{
+			Method:          method,
+			Multipart:       form,
+			FollowRedirects: redirects,
+		},
+	}
+	importer.SetRequestURL(cfg, &t.Request, rawurl)
+	t.Name = method + " " + t.Request.Path
+	if len(data) > 0 {
+		setBody(&t.Request, header, strings.Join(data, "&"))
+	}
+	if len(header) > 0 {
+		t.Request.Header = header
+	}
+	return t, nil
+}
+
+func baseTLS(cfg *httpexpect.Config) *httpexpect.TLSConfig {
+	if cfg.Base.TLS == nil {
+		cfg.Base.TLS = &httpexpect.TLSConfig{}
+	}
+	return cfg.Base.TLS
+}
+
+// splitFlag splits a --flag=value argument in two and a group of short flags
+// (e.g. -sL or -XPOST) in one argument per flag, followed by the value attached
+// to the last one.
+func splitFlag(arg string) []string {
+	if strings.HasPrefix(arg, "--") {
+		if idx := strings.Index(arg, "="); idx > 0 {
+			return []string{arg[:idx], arg[idx+1:]}
+		}
+		return []string{arg}
+	}
+	var parts []string
+	for i := 1; i < len(arg); i++ {
+		parts = append(parts, "-"+arg[i:i+1])
+		if strings.IndexByte(shortFlagsWithArg, arg[i]) >= 0 && i+1 < len(arg) {
+			return append(parts, arg[i+1:])
+		}
+	}
+	return parts
+}
+
+// setBody sets the request body from the curl data: JSON documents are kept
+// as such, form data is used when no content type is set.
+func setBody(req *httpexpect.Request, header testsuite.Header, data string) {
+	cntType := ""
+	for k, v := range header {
+		if strings.EqualFold(k, "Content-Type") {
+			cntType = v
+		}
+	}
+	var doc interface{}
+	switch {
+	case strings.Contains(cntType, "json") && json.Unmarshal([]byte(data), &doc) == nil:
+		req.Body = doc
+	case cntType == "" || strings.HasPrefix(cntType, "application/x-www-form-urlencoded"):
+		if values, err := url.ParseQuery(data); err == nil {
+			req.FormData = make(map[string]string, len(values))
+			for k := range values {
+				req.FormData[k] = values.Get(k)
+			}
+			return
+		}
+		fallthrough
+	default:
+		req.Body = map[string]interface{}{"$raw": data}
+	}
+}
+
+// splitCommands splits s into the arguments of each curl command, following
+// the shell quoting rules.
+func splitCommands(s string) ([][]string, error) {
+	var (
+		cmds [][]string
+		args []string
+		word bytes.Buffer
+		// inWord is set when a word is started, even if it is empty (e.g. '').
+		inWord bool
+	)
+	endWord := func() {
+		if inWord {
+			args = append(args, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+	endCommand := func() {
+		endWord()
+		if len(args) > 0 && args[0] == "curl" {
+			cmds = append(cmds, args[1:])
+		}
+		args = nil
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			if s[i] == '\r' && i+1 < len(s) && s[i+1] == '\n' {
+				i++
+			}
+			if s[i] != '\n' {
+				word.WriteByte(s[i])
+				inWord = true
+			}
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, errUnterminated
+			}
+			word.WriteString(s[i+1 : i+1+end])
+			inWord = true
+			i += end + 1
+		case c == '$' && i+1 < len(s) && s[i+1] == '\'':
+			n, err := readANSIString(s[i+2:], &word)
+			if err != nil {
+				return nil, err
+			}
+			inWord = true
+			i += n + 1
+		case c == '"':
+			n, err := readDoubleQuoted(s[i+1:], &word)
+			if err != nil {
+				return nil, err
+			}
+			inWord = true
+			i += n
+		case c == '#' && !inWord:
+			if end := strings.IndexByte(s[i:], '\n'); end >= 0 {
+				i += end - 1
+			} else {
+				i = len(s)
+			}
+		case c == '\n' || c == ';':
+			endCommand()
+		case c == ' ' || c == '\t' || c == '\r':
+			endWord()
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	endCommand()
+	return cmds, nil
+}
+
+// readDoubleQuoted reads a double quoted string up to its closing quote and
+// returns the number of bytes read.
+func readDoubleQuoted(s string, w *bytes.Buffer) (int, error) {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return i + 1, nil
+		case '\\':
+			if i+1 < len(s) && strings.IndexByte("\"\\$`\n", s[i+1]) >= 0 {
+				i++
+				if s[i] != '\n' {
+					w.WriteByte(s[i])
+				}
+				continue
+			}
+			w.WriteByte(c)
+		default:
+			w.WriteByte(c)
+		}
+	}
+	return 0, errUnterminated
+}
+
+// readANSIString reads a $'...' string up to its closing quote and returns
+// the number of bytes read.
+func readANSIString(s string, w *bytes.Buffer) (int, error) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\'' {
+			return i + 1, nil
+		}
+		if c != '\\' || i+1 >= len(s) {
+			w.WriteByte(c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			w.WriteByte('\n')
+		case 't':
+			w.WriteByte('\t')
+		case 'r':
+			w.WriteByte('\r')
+		default:
+			w.WriteByte(s[i])
+		}
+	}
+	return 0, errUnterminated
+}
+
+func init() {
+	plugin.Register(&plugin.Registration{
+		Type:   plugin.ImporterPlugin,
+		ID:     "curl",
+		Config: (*importer.Options)(nil),
+		InitFn: func(ctx *plugin.InitContext) (interface{}, error) {
+			opts := ctx.Config.(*importer.Options)
+			return New(opts), nil
+		},
+	})
+}
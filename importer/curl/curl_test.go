@@ -0,0 +1,120 @@
+package curl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/blippar/aragorn/importer"
+	"github.com/blippar/aragorn/pkg/util/json"
+)
+
+func TestSplitCommands(t *testing.T) {
+	tt := []struct {
+		name string
+		in   string
+		want [][]string
+		err  error
+	}{
+		{"simple", "curl http://localhost/", [][]string{{"http://localhost/"}}, nil},
+		{
+			"multiline",
+			"# comment\ncurl -X POST \\\n  -H 'Content-Type: application/json' \\\n  -d \"{\\\"a\\\": 1}\" http://localhost/\n\ncurl $'http://localhost/\\'quoted\\'' -k",
+			[][]string{
+				{"-X", "POST", "-H", "Content-Type: application/json", "-d", `{"a": 1}`, "http://localhost/"},
+				{"http://localhost/'quoted'", "-k"},
+			},
+			nil,
+		},
+		{"empty quotes", "curl -d '' http://localhost/", [][]string{{"-d", "", "http://localhost/"}}, nil},
+		{"not curl", "wget http://localhost/", nil, nil},
+		{"unterminated", "curl 'http://localhost/", nil, errUnterminated},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := splitCommands(tc.in)
+			if err != tc.err {
+				t.Fatalf("invalid error (got %v; want %v)", err, tc.err)
+			}
+			if !cmp.Equal(got, tc.want) {
+				t.Fatalf("invalid commands (got %q; want %q)", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestImport(t *testing.T) {
+	cmds := `
+curl -sXPOST http://localhost:8080/todos -H 'Content-Type: application/json' --data-raw '{"title": "Buy milk"}'
+curl -G http://localhost:8080/todos -d limit=10 -u john:doe -L
+curl https://example.com/login -k --cacert ca.pem -x socks5://proxy:1080 --data 'user=john&password=doe' -L --max-redirs=3
+curl -F name=john -F avatar=@avatar.png http://localhost:8080/upload -o /dev/null
+`
+	suites, err := New(&importer.Options{Name: "Todo"}).Import(strings.NewReader(cmds))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	want := `{"name":"Todo","type":"HTTP","suite":{"base":{"url":"http://localhost:8080","insecure":true,"tls":{"caPath":"ca.pem"},"proxy":"socks5://proxy:1080"},"tests":[` +
+		`{"name":"POST /todos","request":{"path":"/todos","method":"POST","header":{"Content-Type":"application/json"},"followRedirects":0,"body":{"title":"Buy milk"}},"expect":{}},` +
+		`{"name":"GET /todos?limit=10","request":{"path":"/todos?limit=10","method":"GET","header":{"Authorization":"Basic am9objpkb2U="}},"expect":{}},` +
+		`{"name":"POST /login","request":{"url":"https://example.com","path":"/login","method":"POST","followRedirects":3,"formData":{"password":"doe","user":"john"}},"expect":{}},` +
+		`{"name":"POST /upload","request":{"path":"/upload","method":"POST","followRedirects":0,"multipart":{"avatar":"@avatar.png","name":"john"}},"expect":{}}]}}`
+	got, err := jsonString(suites[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("invalid suite\ngot:  %s\nwant: %s", got, want)
+	}
+}
+
+func TestImportDataFile(t *testing.T) {
+	tt := []struct {
+		cmd  string
+		want string
+	}{
+		{"curl -d @todo.json http://localhost/todos", "command 1: -d: " + errDataFile.Error()},
+		{"curl --data-binary @todo.bin http://localhost/todos", "command 1: --data-binary: " + errDataFile.Error()},
+		{"curl --data-urlencode title@title.txt http://localhost/todos", "command 1: --data-urlencode: " + errDataFile.Error()},
+		{"curl -XPOST -d@todo.json http://localhost/todos", "command 1: -d: " + errDataFile.Error()},
+	}
+	for _, tc := range tt {
+		if _, err := New(&importer.Options{}).Import(strings.NewReader(tc.cmd)); err == nil || err.Error() != tc.want {
+			t.Errorf("%s: invalid error (got %v; want %s)", tc.cmd, err, tc.want)
+		}
+	}
+	suites, err := New(&importer.Options{}).Import(strings.NewReader("curl --data-raw @todo http://localhost/todos --data-urlencode 'mail=john@example.com'"))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	got, err := jsonString(suites[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"formData":{"@todo":"","mail":"john@example.com"}`; !strings.Contains(got, want) {
+		t.Errorf("invalid suite (got %s; want %s)", got, want)
+	}
+}
+
+func TestImportUnsupportedFlag(t *testing.T) {
+	tt := []struct {
+		cmd  string
+		want string
+	}{
+		{"curl -T todo.json http://localhost/todos", "command 1: -T: " + errUnsupported.Error()},
+		{"curl -sT todo.json http://localhost/todos", "command 1: -T: " + errUnsupported.Error()},
+		{"curl --upload-file=todo.json http://localhost/todos", "command 1: --upload-file: " + errUnsupported.Error()},
+		{"curl --max-redirs -1 http://localhost/todos", `command 1: --max-redirs: invalid number "-1"`},
+	}
+	for _, tc := range tt {
+		if _, err := New(&importer.Options{}).Import(strings.NewReader(tc.cmd)); err == nil || err.Error() != tc.want {
+			t.Errorf("%s: invalid error (got %v; want %s)", tc.cmd, err, tc.want)
+		}
+	}
+}
+
+func jsonString(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
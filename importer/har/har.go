@@ -0,0 +1,168 @@
+package har
+
+import (
+	gojson "encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/blippar/aragorn/importer"
+	"github.com/blippar/aragorn/pkg/util/json"
+	"github.com/blippar/aragorn/plugin"
+	"github.com/blippar/aragorn/server"
+	"github.com/blippar/aragorn/testsuite"
+	"github.com/blippar/aragorn/testsuite/httpexpect"
+)
+
+var _ importer.Importer = (*Importer)(nil)
+
+var errNoEntries = errors.New("the HAR file does not contain any entry")
+
+// skippedHeaders are the request headers set by the browser or the HTTP
+// client that should not be replayed.
+var skippedHeaders = map[string]bool{
+	"Host":                      true,
+	"Content-Length":            true,
+	"Connection":                true,
+	"Cookie":                    true,
+	"Accept-Encoding":           true,
+	"Upgrade-Insecure-Requests": true,
+}
+
+// Importer generates an HTTP test suite from an HTTP Archive (HAR) file.
+type Importer struct {
+	opts *importer.Options
+}
+
+// New returns an Importer.
+func New(opts *importer.Options) *Importer {
+	return &Importer{opts: opts}
+}
+
+type archive struct {
+	Log struct {
+		Entries []entry `json:"entries"`
+	} `json:"log"`
+}
+
+type entry struct {
+	Request  request  `json:"request"`
+	Response response `json:"response"`
+}
+
+type request struct {
+	Method   string      `json:"method"`
+	URL      string      `json:"url"`
+	Headers  []nameValue `json:"headers"`
+	PostData *postData   `json:"postData"`
+}
+
+type postData struct {
+	MimeType string      `json:"mimeType"`
+	Text     string      `json:"text"`
+	Params   []nameValue `json:"params"`
+}
+
+type response struct {
+	Status  int         `json:"status"`
+	Headers []nameValue `json:"headers"`
+}
+
+type nameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Import generates one test per entry of the archive read from r. The
+// recorded status code and content type are expected.
+func (imp *Importer) Import(r io.Reader) ([]*server.SuiteConfig, error) {
+	var a archive
+	if err := gojson.NewDecoder(r).Decode(&a); err != nil {
+		return nil, fmt.Errorf("could not decode HAR file: %v", err)
+	}
+	if len(a.Log.Entries) == 0 {
+		return nil, errNoEntries
+	}
+	cfg := &httpexpect.Config{
+		Base: httpexpect.Base{URL: imp.opts.URL},
+	}
+	for _, e := range a.Log.Entries {
+		importer.AddTest(cfg, newTest(cfg, e))
+	}
+	name := imp.opts.Name
+	if name == "" {
+		name = cfg.Base.URL
+	}
+	s, err := importer.NewSuiteConfig(name, "HTTP", cfg)
+	if err != nil {
+		return nil, err
+	}
+	return []*server.SuiteConfig{s}, nil
+}
+
+func newTest(cfg *httpexpect.Config, e entry) *httpexpect.Test {
+	t := &httpexpect.Test{
+		Request: httpexpect.Request{
+			Method: e.Request.Method,
+		},
+		Expect: httpexpect.Expect{
+			StatusCode: e.Response.Status,
+		},
+	}
+	importer.SetRequestURL(cfg, &t.Request, e.Request.URL)
+	t.Name = t.Request.Method + " " + t.Request.Path
+	for _, h := range e.Request.Headers {
+		// HTTP/2 pseudo-headers start with a colon.
+		name := http.CanonicalHeaderKey(h.Name)
+		if strings.HasPrefix(name, ":") || skippedHeaders[name] {
+			continue
+		}
+		if t.Request.Header == nil {
+			t.Request.Header = testsuite.Header{}
+		}
+		t.Request.Header[name] = h.Value
+	}
+	if pd := e.Request.PostData; pd != nil {
+		setBody(&t.Request, pd)
+	}
+	for _, h := range e.Response.Headers {
+		if strings.EqualFold(h.Name, "Content-Type") {
+			t.Expect.Header = testsuite.Header{"Content-Type": h.Value}
+		}
+	}
+	return t
+}
+
+func setBody(req *httpexpect.Request, pd *postData) {
+	if strings.HasPrefix(pd.MimeType, "application/x-www-form-urlencoded") && len(pd.Params) > 0 {
+		req.FormData = make(map[string]string, len(pd.Params))
+		for _, p := range pd.Params {
+			req.FormData[p.Name] = p.Value
+		}
+		delete(req.Header, "Content-Type")
+		return
+	}
+	if pd.Text == "" {
+		return
+	}
+	var doc interface{}
+	if strings.Contains(pd.MimeType, "json") && json.Unmarshal([]byte(pd.Text), &doc) == nil {
+		req.Body = doc
+		return
+	}
+	req.Body = map[string]interface{}{"$raw": pd.Text}
+}
+
+func init() {
+	plugin.Register(&plugin.Registration{
+		Type:   plugin.ImporterPlugin,
+		ID:     "har",
+		Config: (*importer.Options)(nil),
+		InitFn: func(ctx *plugin.InitContext) (interface{}, error) {
+			opts := ctx.Config.(*importer.Options)
+			return New(opts), nil
+		},
+	})
+}
@@ -0,0 +1,77 @@
+package har
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/blippar/aragorn/importer"
+	"github.com/blippar/aragorn/pkg/util/json"
+)
+
+func TestImport(t *testing.T) {
+	const archive = `{"log": {"entries": [
+  {
+    "request": {
+      "method": "GET",
+      "url": "https://example.com/todos?limit=10&done=true",
+      "headers": [
+        {"name": ":authority", "value": "example.com"},
+        {"name": "host", "value": "example.com"},
+        {"name": "cookie", "value": "session=1"},
+        {"name": "accept", "value": "application/json"},
+        {"name": "x-request-id", "value": "42"}
+      ]
+    },
+    "response": {"status": 200, "headers": [{"name": "content-type", "value": "application/json"}]}
+  },
+  {
+    "request": {
+      "method": "POST",
+      "url": "https://example.com/todos",
+      "headers": [{"name": "Content-Type", "value": "application/json"}],
+      "postData": {"mimeType": "application/json", "text": "{\"title\": \"Buy milk\"}"}
+    },
+    "response": {"status": 201, "headers": []}
+  },
+  {
+    "request": {
+      "method": "POST",
+      "url": "https://example.com/login",
+      "headers": [{"name": "Content-Type", "value": "application/x-www-form-urlencoded"}],
+      "postData": {"mimeType": "application/x-www-form-urlencoded", "params": [{"name": "user", "value": "john"}, {"name": "password", "value": "doe"}]}
+    },
+    "response": {"status": 302, "headers": [{"name": "Location", "value": "/"}]}
+  },
+  {
+    "request": {
+      "method": "PUT",
+      "url": "https://api.example.com/notes/1",
+      "headers": [],
+      "postData": {"mimeType": "text/plain", "text": "hello"}
+    },
+    "response": {"status": 204, "headers": []}
+  }
+]}}`
+	suites, err := New(&importer.Options{}).Import(strings.NewReader(archive))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	want := `{"name":"https://example.com","type":"HTTP","suite":{"base":{"url":"https://example.com"},"tests":[` +
+		`{"name":"GET /todos?limit=10\u0026done=true","request":{"path":"/todos?limit=10\u0026done=true","method":"GET","header":{"Accept":"application/json","X-Request-Id":"42"}},"expect":{"statusCode":200,"header":{"Content-Type":"application/json"}}},` +
+		`{"name":"POST /todos","request":{"path":"/todos","method":"POST","header":{"Content-Type":"application/json"},"body":{"title":"Buy milk"}},"expect":{"statusCode":201}},` +
+		`{"name":"POST /login","request":{"path":"/login","method":"POST","formData":{"password":"doe","user":"john"}},"expect":{"statusCode":302}},` +
+		`{"name":"PUT /notes/1","request":{"url":"https://api.example.com","path":"/notes/1","method":"PUT","body":{"$raw":"hello"}},"expect":{"statusCode":204}}]}}`
+	b, err := json.Marshal(suites[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); got != want {
+		t.Errorf("invalid suite\ngot:  %s\nwant: %s", got, want)
+	}
+}
+
+func TestImportNoEntries(t *testing.T) {
+	if _, err := New(&importer.Options{}).Import(strings.NewReader(`{"log": {"entries": []}}`)); err != errNoEntries {
+		t.Fatalf("invalid error (got %v; want %v)", err, errNoEntries)
+	}
+}
@@ -0,0 +1,83 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/blippar/aragorn/pkg/util/json"
+	"github.com/blippar/aragorn/server"
+	"github.com/blippar/aragorn/testsuite/httpexpect"
+)
+
+// Importer converts an external API description into test suites.
+type Importer interface {
+	Import(r io.Reader) ([]*server.SuiteConfig, error)
+}
+
+// Options is the configuration given to every importer plugin.
+type Options struct {
+	Name string `json:"name,omitempty"` // Name of the generated suite, overrides the one found in the source.
+	URL  string `json:"url,omitempty"`  // Base URL of the generated suite, overrides the one found in the source.
+	Env  string `json:"env,omitempty"`  // Path of a file defining the variables of the source (e.g. a Postman environment).
+}
+
+// NewSuiteConfig returns a suite config of type typ describing the test suite cfg.
+func NewSuiteConfig(name, typ string, cfg interface{}) (*server.SuiteConfig, error) {
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &server.SuiteConfig{
+		Name:  name,
+		Type:  typ,
+		Suite: b,
+	}, nil
+}
+
+// SplitURL splits rawurl into its origin (scheme and host) and the remaining
+// path and query. The origin is empty if rawurl is not absolute.
+func SplitURL(rawurl string) (origin, path string) {
+	u, err := url.Parse(rawurl)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", rawurl
+	}
+	origin = u.Scheme + "://" + u.Host
+	path = strings.TrimPrefix(rawurl, origin)
+	if path == "" || path[0] == '?' {
+		path = "/" + path
+	}
+	return origin, path
+}
+
+// SetRequestURL sets the path of req from rawurl. The origin of rawurl becomes
+// the base URL of cfg if it is not set yet, otherwise the request URL overrides
+// the base URL when they differ.
+func SetRequestURL(cfg *httpexpect.Config, req *httpexpect.Request, rawurl string) {
+	origin, path := SplitURL(rawurl)
+	if cfg.Base.URL == "" {
+		cfg.Base.URL = origin
+	} else if origin != "" && origin != cfg.Base.URL {
+		req.URL = origin
+	}
+	req.Path = path
+}
+
+// AddTest appends t to the tests of cfg, suffixing its name if it is already used.
+func AddTest(cfg *httpexpect.Config, t *httpexpect.Test) {
+	name := t.Name
+	for i := 2; hasTest(cfg, t.Name); i++ {
+		t.Name = fmt.Sprintf("%s (%d)", name, i)
+	}
+	cfg.Tests = append(cfg.Tests, t)
+}
+
+func hasTest(cfg *httpexpect.Config, name string) bool {
+	for _, t := range cfg.Tests {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
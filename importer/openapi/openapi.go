@@ -0,0 +1,128 @@
+package openapi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/blippar/aragorn/importer"
+	oas "github.com/blippar/aragorn/pkg/openapi"
+	"github.com/blippar/aragorn/plugin"
+	"github.com/blippar/aragorn/server"
+	"github.com/blippar/aragorn/testsuite"
+	"github.com/blippar/aragorn/testsuite/httpexpect"
+)
+
+var _ importer.Importer = (*Importer)(nil)
+
+var errNoServer = errors.New("the specification does not declare any server, set the base URL")
+
+var pathParam = regexp.MustCompile(`{([^{}/]+)}`)
+
+// Importer generates an HTTP test suite from an OpenAPI specification.
+type Importer struct {
+	opts *importer.Options
+}
+
+// New returns an Importer.
+func New(opts *importer.Options) *Importer {
+	return &Importer{opts: opts}
+}
+
+// Import generates one test per operation of the specification read from r.
+// Examples are used for the request parameters and bodies and the response
+// schema of the lowest 2xx status code is expected.
+func (imp *Importer) Import(r io.Reader) ([]*server.SuiteConfig, error) {
+	spec, err := oas.Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse OpenAPI specification: %v", err)
+	}
+	baseURL := imp.opts.URL
+	if baseURL == "" {
+		if len(spec.Servers) == 0 {
+			return nil, errNoServer
+		}
+		baseURL = strings.TrimSuffix(spec.Servers[0], "/")
+	}
+	cfg := &httpexpect.Config{
+		Base: httpexpect.Base{URL: baseURL},
+	}
+	for _, op := range spec.Operations {
+		t, err := newTest(op)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %v", op.Method, op.Path, err)
+		}
+		importer.AddTest(cfg, t)
+	}
+	name := imp.opts.Name
+	if name == "" {
+		name = spec.Title
+	}
+	s, err := importer.NewSuiteConfig(name, "HTTP", cfg)
+	if err != nil {
+		return nil, err
+	}
+	return []*server.SuiteConfig{s}, nil
+}
+
+func newTest(op *oas.Operation) (*httpexpect.Test, error) {
+	code := op.SuccessCode()
+	schema, err := op.ResponseSchema(code)
+	if err != nil {
+		return nil, err
+	}
+	t := &httpexpect.Test{
+		Name: op.Name(),
+		Request: httpexpect.Request{
+			Method: op.Method,
+			Path:   op.Path,
+			Body:   op.Body,
+		},
+		Expect: httpexpect.Expect{
+			StatusCode: code,
+			JSONSchema: schema,
+		},
+	}
+	query := url.Values{}
+	for _, p := range op.Parameters {
+		if p.Example == nil {
+			continue
+		}
+		v := fmt.Sprint(p.Example)
+		switch p.In {
+		case "path":
+			t.Request.Path = strings.Replace(t.Request.Path, "{"+p.Name+"}", url.PathEscape(v), -1)
+		case "query":
+			if p.Required {
+				query.Set(p.Name, v)
+			}
+		case "header":
+			if t.Request.Header == nil {
+				t.Request.Header = testsuite.Header{}
+			}
+			t.Request.Header[p.Name] = v
+		}
+	}
+	// The path parameters without example are left as placeholders, to be
+	// replaced with values saved by a previous test.
+	t.Request.Path = pathParam.ReplaceAllString(t.Request.Path, "{{${1}}}")
+	if len(query) > 0 {
+		t.Request.Path += "?" + query.Encode()
+	}
+	return t, nil
+}
+
+func init() {
+	plugin.Register(&plugin.Registration{
+		Type:   plugin.ImporterPlugin,
+		ID:     "openapi",
+		Config: (*importer.Options)(nil),
+		InitFn: func(ctx *plugin.InitContext) (interface{}, error) {
+			opts := ctx.Config.(*importer.Options)
+			return New(opts), nil
+		},
+	})
+}
@@ -0,0 +1,46 @@
+package openapi
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/blippar/aragorn/importer"
+	"github.com/blippar/aragorn/pkg/util/json"
+)
+
+func TestImport(t *testing.T) {
+	const spec = `openapi: 3.0.0
+info:
+  title: Todo API
+servers:
+  - url: http://localhost:8080/v1
+paths:
+  /users/{userID}/todos/{id}:
+    get:
+      operationId: getTodo
+      parameters:
+        - name: userID
+          in: path
+          required: true
+          example: 42
+        - name: id
+          in: path
+          required: true
+      responses:
+        '204':
+          description: OK
+`
+	suites, err := New(&importer.Options{}).Import(strings.NewReader(spec))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	want := `{"name":"Todo API","type":"HTTP","suite":{"base":{"url":"http://localhost:8080/v1"},"tests":[` +
+		`{"name":"getTodo","request":{"path":"/users/42/todos/{{id}}","method":"GET"},"expect":{"statusCode":204}}]}}`
+	b, err := json.Marshal(suites[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); got != want {
+		t.Errorf("invalid suite\ngot:  %s\nwant: %s", got, want)
+	}
+}
@@ -0,0 +1,494 @@
+package postman
+
+import (
+	"bytes"
+	"encoding/base64"
+	gojson "encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+	"golang.org/x/oauth2/clientcredentials"
+
+	"github.com/blippar/aragorn/importer"
+	"github.com/blippar/aragorn/log"
+	"github.com/blippar/aragorn/pkg/util/json"
+	"github.com/blippar/aragorn/plugin"
+	"github.com/blippar/aragorn/server"
+	"github.com/blippar/aragorn/testsuite"
+	"github.com/blippar/aragorn/testsuite/httpexpect"
+)
+
+var _ importer.Importer = (*Importer)(nil)
+
+var (
+	varsTmpl    = regexp.MustCompile(`{{[^{}]+}}`)
+	baseVarTmpl = regexp.MustCompile(`^{{[^{}]+}}`)
+
+	errNoBaseURL = errors.New("the base URL is a variable without value, set the base URL")
+)
+
+// Importer generates HTTP test suites from a Postman collection (v2.0 or v2.1).
+type Importer struct {
+	opts *importer.Options
+}
+
+// New returns an Importer.
+func New(opts *importer.Options) *Importer {
+	return &Importer{opts: opts}
+}
+
+type collection struct {
+	Info     info       `json:"info"`
+	Item     []item     `json:"item"`
+	Auth     *auth      `json:"auth"`
+	Variable []variable `json:"variable"`
+}
+
+type info struct {
+	Name string `json:"name"`
+}
+
+type item struct {
+	Name     string     `json:"name"`
+	Item     []item     `json:"item"`
+	Auth     *auth      `json:"auth"`
+	Request  *request   `json:"request"`
+	Response []response `json:"response"`
+	Variable []variable `json:"variable"`
+}
+
+type request struct {
+	Method string            `json:"method"`
+	Header []keyValue        `json:"header"`
+	URL    gojson.RawMessage `json:"url"`
+	Body   *body             `json:"body"`
+	Auth   *auth             `json:"auth"`
+}
+
+type urlObject struct {
+	Raw      string     `json:"raw"`
+	Protocol string     `json:"protocol"`
+	Host     []string   `json:"host"`
+	Path     []string   `json:"path"`
+	Query    []keyValue `json:"query"`
+}
+
+type body struct {
+	Mode       string     `json:"mode"`
+	Raw        string     `json:"raw"`
+	URLEncoded []keyValue `json:"urlencoded"`
+	FormData   []keyValue `json:"formdata"`
+}
+
+type response struct {
+	Code   int        `json:"code"`
+	Header []keyValue `json:"header"`
+	Body   string     `json:"body"`
+}
+
+type auth struct {
+	Type   string     `json:"type"`
+	Basic  []keyValue `json:"basic"`
+	Bearer []keyValue `json:"bearer"`
+	APIKey []keyValue `json:"apikey"`
+	OAuth2 []keyValue `json:"oauth2"`
+}
+
+type keyValue struct {
+	Key      string      `json:"key"`
+	Value    interface{} `json:"value"`
+	Src      interface{} `json:"src"`
+	Type     string      `json:"type"`
+	Disabled bool        `json:"disabled"`
+}
+
+type variable struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+type environment struct {
+	Values []struct {
+		Key     string      `json:"key"`
+		Value   interface{} `json:"value"`
+		Enabled *bool       `json:"enabled"`
+	} `json:"values"`
+}
+
+// Import generates a test suite for the requests at the root of the
+// collection read from r and one for each folder. The variables of the
+// environment file, of the collection and of the enclosing folders are
+// replaced by their value. The other variables are kept as templates, to be
+// set in the generated suites, and a warning lists them unless they are of the
+// form {{id.field}}, templates of the values saved by a previous test.
+func (imp *Importer) Import(r io.Reader) ([]*server.SuiteConfig, error) {
+	var c collection
+	// Postman exports contain many fields we don't use, so they are decoded leniently.
+	if err := gojson.NewDecoder(r).Decode(&c); err != nil {
+		return nil, fmt.Errorf("could not decode Postman collection: %v", err)
+	}
+	name := imp.opts.Name
+	if name == "" {
+		name = c.Info.Name
+	}
+	g := &generator{
+		opts: imp.opts,
+		vars: make(map[string]string),
+		env:  make(map[string]string),
+	}
+	if imp.opts.Env != "" {
+		if err := g.loadEnv(imp.opts.Env); err != nil {
+			return nil, err
+		}
+	}
+	g.addVars(c.Variable)
+	if err := g.walk(name, c.Item, c.Auth); err != nil {
+		return nil, err
+	}
+	if names := unresolvedVars(g.suites); len(names) > 0 {
+		log.Warn("variables without value kept as templates, set them in an environment file", zap.Strings("variables", names))
+	}
+	return g.suites, nil
+}
+
+type generator struct {
+	opts   *importer.Options
+	vars   map[string]string
+	env    map[string]string // Override the variables of the collection.
+	suites []*server.SuiteConfig
+}
+
+// loadEnv loads the enabled variables of the Postman environment file at path.
+func (g *generator) loadEnv(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var env environment
+	if err := gojson.NewDecoder(f).Decode(&env); err != nil {
+		return fmt.Errorf("could not decode Postman environment: %v", err)
+	}
+	for _, v := range env.Values {
+		if v.Enabled == nil || *v.Enabled {
+			g.env[v.Key] = str(v.Value)
+		}
+	}
+	return nil
+}
+
+// unresolvedVars returns the names of the variables left in the suites, other
+// than the templates of saved values.
+func unresolvedVars(suites []*server.SuiteConfig) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, s := range suites {
+		for _, v := range varsTmpl.FindAllString(string(s.Suite), -1) {
+			name := strings.TrimSpace(v[2 : len(v)-2])
+			if strings.Contains(name, ".") || seen[name] {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (g *generator) addVars(vars []variable) {
+	for _, v := range vars {
+		g.vars[v.Key] = str(v.Value)
+	}
+}
+
+// walk generates the suite of the requests in items and recursively the ones
+// of the sub folders.
+func (g *generator) walk(name string, items []item, a *auth) error {
+	cfg := &httpexpect.Config{
+		Base: httpexpect.Base{URL: g.opts.URL},
+	}
+	if err := g.setBaseAuth(&cfg.Base, a); err != nil {
+		return fmt.Errorf("%s: auth: %v", name, err)
+	}
+	var folders []item
+	for _, it := range items {
+		if it.Request == nil {
+			folders = append(folders, it)
+			continue
+		}
+		t, err := g.newTest(cfg, it)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %v", name, it.Name, err)
+		}
+		importer.AddTest(cfg, t)
+	}
+	if len(cfg.Tests) > 0 {
+		s, err := importer.NewSuiteConfig(name, "HTTP", cfg)
+		if err != nil {
+			return err
+		}
+		g.suites = append(g.suites, s)
+	}
+	for _, f := range folders {
+		// The variables of a folder are only visible to its own items.
+		vars := g.vars
+		g.vars = make(map[string]string, len(vars)+len(f.Variable))
+		for k, v := range vars {
+			g.vars[k] = v
+		}
+		g.addVars(f.Variable)
+		fa := a
+		if f.Auth != nil {
+			fa = f.Auth
+		}
+		if err := g.walk(name+" - "+f.Name, f.Item, fa); err != nil {
+			return err
+		}
+		g.vars = vars
+	}
+	return nil
+}
+
+func (g *generator) newTest(cfg *httpexpect.Config, it item) (*httpexpect.Test, error) {
+	req := it.Request
+	t := &httpexpect.Test{
+		Name: it.Name,
+		Request: httpexpect.Request{
+			Method: strings.ToUpper(req.Method),
+			Header: g.header(req.Header),
+		},
+	}
+	rawurl, err := g.rawURL(req.URL)
+	if err != nil {
+		return nil, err
+	}
+	importer.SetRequestURL(cfg, &t.Request, rawurl)
+	if cfg.Base.URL == "" {
+		return nil, errNoBaseURL
+	}
+	if req.Auth != nil {
+		h, err := g.authHeader(req.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("auth: %v", err)
+		}
+		t.Request.Header = testsuite.MergeHeaders(t.Request.Header, h)
+	}
+	if req.Body != nil {
+		g.setBody(&t.Request, req.Body)
+	}
+	if len(it.Response) > 0 {
+		t.Expect = g.expect(it.Response[0])
+	}
+	return t, nil
+}
+
+// rawURL returns the URL of a request where the variables are replaced. A
+// leading variable without value is considered as the base URL.
+func (g *generator) rawURL(b gojson.RawMessage) (string, error) {
+	var raw string
+	if err := gojson.Unmarshal(b, &raw); err != nil {
+		var u urlObject
+		if err := gojson.Unmarshal(b, &u); err != nil {
+			return "", fmt.Errorf("invalid url: %v", err)
+		}
+		raw = u.Raw
+		if raw == "" {
+			raw = u.build()
+		}
+	}
+	raw = g.replaceVars(raw)
+	if loc := baseVarTmpl.FindStringIndex(raw); loc != nil {
+		raw = strings.TrimSuffix(g.opts.URL, "/") + raw[loc[1]:]
+	}
+	return raw, nil
+}
+
+func (u *urlObject) build() string {
+	var b bytes.Buffer
+	if u.Protocol != "" {
+		b.WriteString(u.Protocol + "://")
+	}
+	b.WriteString(strings.Join(u.Host, "."))
+	if len(u.Path) > 0 {
+		b.WriteString("/" + strings.Join(u.Path, "/"))
+	}
+	sep := "?"
+	for _, q := range u.Query {
+		if !q.Disabled {
+			b.WriteString(sep + q.Key + "=" + str(q.Value))
+			sep = "&"
+		}
+	}
+	return b.String()
+}
+
+// replaceVars replaces the known variables in s by their value.
+func (g *generator) replaceVars(s string) string {
+	return varsTmpl.ReplaceAllStringFunc(s, func(v string) string {
+		name := strings.TrimSpace(v[2 : len(v)-2])
+		if val, ok := g.env[name]; ok {
+			return val
+		}
+		if val, ok := g.vars[name]; ok {
+			return val
+		}
+		return v
+	})
+}
+
+func (g *generator) header(kvs []keyValue) testsuite.Header {
+	if len(kvs) == 0 {
+		return nil
+	}
+	h := testsuite.Header{}
+	for _, kv := range kvs {
+		if !kv.Disabled {
+			h[kv.Key] = g.replaceVars(str(kv.Value))
+		}
+	}
+	return h
+}
+
+func (g *generator) setBody(req *httpexpect.Request, b *body) {
+	switch b.Mode {
+	case "raw":
+		raw := g.replaceVars(b.Raw)
+		var doc interface{}
+		if err := json.Unmarshal([]byte(raw), &doc); err == nil {
+			req.Body = doc
+		} else if raw != "" {
+			req.Body = map[string]interface{}{"$raw": raw}
+		}
+	case "urlencoded":
+		req.FormData = g.values(b.URLEncoded)
+	case "formdata":
+		req.Multipart = g.values(b.FormData)
+	}
+}
+
+// values returns the enabled key-values, files are prefixed by a @.
+func (g *generator) values(kvs []keyValue) map[string]string {
+	m := make(map[string]string)
+	for _, kv := range kvs {
+		if kv.Disabled {
+			continue
+		}
+		if kv.Type == "file" {
+			m[kv.Key] = "@" + str(kv.Src)
+			continue
+		}
+		m[kv.Key] = g.replaceVars(str(kv.Value))
+	}
+	return m
+}
+
+func (g *generator) expect(resp response) httpexpect.Expect {
+	e := httpexpect.Expect{StatusCode: resp.Code}
+	for _, h := range resp.Header {
+		if strings.EqualFold(h.Key, "Content-Type") {
+			e.Header = testsuite.Header{"Content-Type": str(h.Value)}
+		}
+	}
+	if resp.Body == "" {
+		return e
+	}
+	var doc interface{}
+	if err := json.Unmarshal([]byte(resp.Body), &doc); err == nil {
+		e.Document = doc
+	} else {
+		e.Document = map[string]interface{}{"$raw": resp.Body}
+	}
+	return e
+}
+
+// setBaseAuth sets the authentication of a collection or folder on base:
+// client credentials OAuth2 flows are supported natively, the other schemes
+// are converted to headers.
+func (g *generator) setBaseAuth(base *httpexpect.Base, a *auth) error {
+	if a == nil {
+		return nil
+	}
+	if a.Type == "oauth2" {
+		params := g.params(a.OAuth2)
+		if params["grant_type"] == "client_credentials" {
+			base.OAUTH2 = &clientcredentials.Config{
+				ClientID:     params["clientId"],
+				ClientSecret: params["clientSecret"],
+				TokenURL:     params["accessTokenUrl"],
+			}
+			if scope := params["scope"]; scope != "" {
+				base.OAUTH2.Scopes = strings.Fields(scope)
+			}
+			return nil
+		}
+	}
+	h, err := g.authHeader(a)
+	if err != nil {
+		return err
+	}
+	if len(h) > 0 {
+		base.Header = h
+	}
+	return nil
+}
+
+// authHeader returns the headers implementing the authentication a.
+func (g *generator) authHeader(a *auth) (testsuite.Header, error) {
+	switch a.Type {
+	case "noauth", "":
+		return nil, nil
+	case "basic":
+		p := g.params(a.Basic)
+		cred := base64.StdEncoding.EncodeToString([]byte(p["username"] + ":" + p["password"]))
+		return testsuite.Header{"Authorization": "Basic " + cred}, nil
+	case "bearer":
+		return testsuite.Header{"Authorization": "Bearer " + g.params(a.Bearer)["token"]}, nil
+	case "apikey":
+		p := g.params(a.APIKey)
+		if p["in"] == "query" {
+			return nil, errors.New("API keys in query are not supported")
+		}
+		return testsuite.Header{p["key"]: p["value"]}, nil
+	case "oauth2":
+		if token := g.params(a.OAuth2)["accessToken"]; token != "" {
+			return testsuite.Header{"Authorization": "Bearer " + token}, nil
+		}
+		return nil, errors.New("only client_credentials OAuth2 flows can be set on a collection or folder")
+	}
+	return nil, fmt.Errorf("unsupported auth type %q", a.Type)
+}
+
+func (g *generator) params(kvs []keyValue) map[string]string {
+	m := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		m[kv.Key] = g.replaceVars(str(kv.Value))
+	}
+	return m
+}
+
+// str returns the string representation of a decoded JSON value, nil being empty.
+func str(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func init() {
+	plugin.Register(&plugin.Registration{
+		Type:   plugin.ImporterPlugin,
+		ID:     "postman",
+		Config: (*importer.Options)(nil),
+		InitFn: func(ctx *plugin.InitContext) (interface{}, error) {
+			opts := ctx.Config.(*importer.Options)
+			return New(opts), nil
+		},
+	})
+}
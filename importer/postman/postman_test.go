@@ -0,0 +1,175 @@
+package postman
+
+import (
+	"bytes"
+	gojson "encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/oauth2/clientcredentials"
+
+	"github.com/blippar/aragorn/importer"
+	"github.com/blippar/aragorn/log"
+	"github.com/blippar/aragorn/testsuite"
+	"github.com/blippar/aragorn/testsuite/httpexpect"
+)
+
+func TestMain(m *testing.M) {
+	// The variables without value are logged.
+	if err := log.Init("fatal", false); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestImport(t *testing.T) {
+	f, err := os.Open("testdata/todo.postman_collection.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	suites, err := New(&importer.Options{}).Import(f)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if len(suites) != 2 {
+		t.Fatalf("invalid number of suites (got %d; want 2)", len(suites))
+	}
+	want := []*httpexpect.Config{
+		{
+			Base: httpexpect.Base{
+				URL:    "http://localhost:8080",
+				Header: testsuite.Header{"Authorization": "Bearer secret"},
+			},
+			Tests: []*httpexpect.Test{
+				{
+					Name: "List todos",
+					Request: httpexpect.Request{
+						Method: "GET",
+						Path:   "/todos?limit=10",
+					},
+					Expect: httpexpect.Expect{
+						StatusCode: 200,
+						Header:     testsuite.Header{"Content-Type": "application/json"},
+						Document: []interface{}{
+							map[string]interface{}{"id": gojson.Number("1"), "title": "Buy milk"},
+						},
+					},
+				},
+			},
+		},
+		{
+			Base: httpexpect.Base{
+				URL: "http://localhost:8080",
+				OAUTH2: &clientcredentials.Config{
+					ClientID:     "id",
+					ClientSecret: "secret",
+					TokenURL:     "http://localhost:8080/token",
+					Scopes:       []string{"read", "write"},
+				},
+			},
+			Tests: []*httpexpect.Test{
+				{
+					Name: "Create todo",
+					Request: httpexpect.Request{
+						Method: "POST",
+						Path:   "/todos",
+						Header: testsuite.Header{"Content-Type": "application/json"},
+						Body:   map[string]interface{}{"title": "Buy milk"},
+					},
+				},
+				{
+					Name: "Get todo",
+					Request: httpexpect.Request{
+						Method: "GET",
+						Path:   "/todos/{{todo.id}}",
+						Header: testsuite.Header{"Authorization": "Basic am9objpkb2U="},
+					},
+				},
+			},
+		},
+	}
+	names := []string{"Todo", "Todo - Admin"}
+	for i, s := range suites {
+		if s.Name != names[i] || s.Type != "HTTP" {
+			t.Errorf("invalid suite %d (got %s %s; want %s HTTP)", i, s.Name, s.Type, names[i])
+		}
+		var cfg httpexpect.Config
+		dec := gojson.NewDecoder(bytes.NewReader(s.Suite))
+		dec.UseNumber()
+		if err := dec.Decode(&cfg); err != nil {
+			t.Fatalf("decode suite: %v", err)
+		}
+		if !cmp.Equal(&cfg, want[i]) {
+			t.Errorf("invalid suite %d: %s", i, cmp.Diff(want[i], &cfg))
+		}
+	}
+}
+
+func TestImportUndefinedBaseURL(t *testing.T) {
+	c := `{"item": [{"name": "a", "request": {"method": "GET", "url": "{{baseUrl}}/a"}}]}`
+	imp := New(&importer.Options{})
+	if _, err := imp.Import(strings.NewReader(c)); err == nil || err.Error() != ": a: "+errNoBaseURL.Error() {
+		t.Fatalf("invalid error (got %v; want %v)", err, errNoBaseURL)
+	}
+	imp = New(&importer.Options{URL: "http://example.com"})
+	suites, err := imp.Import(strings.NewReader(c))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	want := `{"base":{"url":"http://example.com"},"tests":[{"name":"a","request":{"path":"/a","method":"GET"},"expect":{}}]}`
+	if got := string(suites[0].Suite); got != want {
+		t.Errorf("invalid suite (got %s; want %s)", got, want)
+	}
+}
+
+func TestImportVariables(t *testing.T) {
+	c := `{
+  "variable": [{"key": "host", "value": "http://localhost:8080"}],
+  "item": [{"name": "a", "request": {"method": "GET", "url": "{{host}}/a/{{a.id}}", "header": [{"key": "X-Api-Key", "value": "{{apiKey}}"}]}}]
+}`
+	suites, err := New(&importer.Options{}).Import(strings.NewReader(c))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	want := `{"base":{"url":"http://localhost:8080"},"tests":[{"name":"a","request":{"path":"/a/{{a.id}}","method":"GET","header":{"X-Api-Key":"{{apiKey}}"}},"expect":{}}]}`
+	if got := string(suites[0].Suite); got != want {
+		t.Errorf("invalid suite (got %s; want %s)", got, want)
+	}
+	suites, err = New(&importer.Options{Env: "testdata/todo.postman_environment.json"}).Import(strings.NewReader(c))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	want = `{"base":{"url":"http://localhost:9090"},"tests":[{"name":"a","request":{"path":"/a/{{a.id}}","method":"GET","header":{"X-Api-Key":"s3cr3t"}},"expect":{}}]}`
+	if got := string(suites[0].Suite); got != want {
+		t.Errorf("invalid suite (got %s; want %s)", got, want)
+	}
+}
+
+func TestImportFolderVariables(t *testing.T) {
+	c := `{
+  "variable": [{"key": "version", "value": "v1"}],
+  "item": [
+    {"name": "a", "variable": [{"key": "version", "value": "v2"}, {"key": "id", "value": "1"}], "item": [{"name": "get", "request": {"method": "GET", "url": "http://localhost/{{version}}/a/{{id}}"}}]},
+    {"name": "b", "item": [{"name": "get", "request": {"method": "GET", "url": "http://localhost/{{version}}/b/{{id}}"}}]}
+  ]
+}`
+	suites, err := New(&importer.Options{}).Import(strings.NewReader(c))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	want := []string{
+		`{"base":{"url":"http://localhost"},"tests":[{"name":"get","request":{"path":"/v2/a/1","method":"GET"},"expect":{}}]}`,
+		`{"base":{"url":"http://localhost"},"tests":[{"name":"get","request":{"path":"/v1/b/{{id}}","method":"GET"},"expect":{}}]}`,
+	}
+	if len(suites) != len(want) {
+		t.Fatalf("invalid number of suites (got %d; want %d)", len(suites), len(want))
+	}
+	for i, s := range suites {
+		if got := string(s.Suite); got != want[i] {
+			t.Errorf("invalid suite %d (got %s; want %s)", i, got, want[i])
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/blippar/aragorn/log"
+	"github.com/blippar/aragorn/notifier"
+	"github.com/blippar/aragorn/pkg/auth"
+	ujson "github.com/blippar/aragorn/pkg/util/json"
+	"github.com/blippar/aragorn/plugin"
+)
+
+const (
+	defaultTimeout    = 10 * time.Second
+	defaultRetryDelay = time.Second
+	defaultMaxRetry   = time.Minute
+)
+
+// Config describes the hooks notified of the reports.
+type Config struct {
+	Hooks []HookConfig `json:"hooks,omitempty"`
+}
+
+// HookConfig describes an HTTP request sent with a report.
+type HookConfig struct {
+	URL          string            `json:"url,omitempty"`
+	Method       string            `json:"method,omitempty"`       // (default: POST).
+	Header       map[string]string `json:"header,omitempty"`       // Header fields of the request (default: Content-Type: application/json).
+	Template     string            `json:"template,omitempty"`     // text/template of the body, executed with the report (default: the report in JSON).
+	TemplatePath string            `json:"templatePath,omitempty"` // File containing the template. Exclusive with template.
+	HMAC         *auth.HMACConfig  `json:"hmac,omitempty"`         // Signature of the request.
+	Timeout      ujson.Duration    `json:"timeout,omitempty"`      // Timeout of each attempt (default: 10s).
+	Retries      int               `json:"retries,omitempty"`      // Number of attempts after a failed one.
+	RetryDelay   ujson.Duration    `json:"retryDelay,omitempty"`   // Delay before the first retry, doubled after each one (default: 1s).
+	MaxRetryTime ujson.Duration    `json:"maxRetryTime,omitempty"` // Total time spent sending a report, attempts and delays included (default: 1m).
+	Verbose      bool              `json:"verbose,omitempty"`      // Notify the reports without failed tests.
+}
+
+// Notifier sends the reports to webhooks.
+type Notifier struct {
+	hooks []*hook
+}
+
+type hook struct {
+	cfg          HookConfig
+	tmpl         *template.Template // Encodes the report in JSON, if nil.
+	signer       auth.Signer
+	client       *http.Client
+	retryDelay   time.Duration
+	maxRetryTime time.Duration
+}
+
+// Report is the report as seen by the templates and encoded by default.
+type Report struct {
+	Suite    string       `json:"suite"`
+	Type     string       `json:"type"`
+	FailFast bool         `json:"failFast"`
+	Start    time.Time    `json:"start"`
+	Duration string       `json:"duration"`
+	NbFailed int          `json:"nbFailed"`
+	Tests    []TestReport `json:"tests"`
+}
+
+// TestReport is the report of a test as seen by the templates.
+type TestReport struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Start       time.Time `json:"start"`
+	Duration    string    `json:"duration"`
+	Failed      bool      `json:"failed"`
+	Errors      []string  `json:"errors"`
+}
+
+var funcs = template.FuncMap{
+	// json encodes v, e.g. to quote strings in JSON templates.
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// New returns a Notifier. Relative template paths are resolved from root.
+func New(cfg *Config, root string) (*Notifier, error) {
+	if len(cfg.Hooks) == 0 {
+		return nil, errors.New("at least one hook is required")
+	}
+	n := &Notifier{hooks: make([]*hook, len(cfg.Hooks))}
+	for i, hcfg := range cfg.Hooks {
+		h, err := newHook(hcfg, root)
+		if err != nil {
+			return nil, fmt.Errorf("hook %d: %v", i, err)
+		}
+		n.hooks[i] = h
+	}
+	return n, nil
+}
+
+func newHook(cfg HookConfig, root string) (*hook, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("url is required")
+	}
+	if cfg.Method == "" {
+		cfg.Method = http.MethodPost
+	}
+	if cfg.Retries < 0 {
+		return nil, errors.New("retries must not be negative")
+	}
+	h := &hook{
+		cfg:          cfg,
+		client:       &http.Client{Timeout: defaultTimeout},
+		retryDelay:   defaultRetryDelay,
+		maxRetryTime: defaultMaxRetry,
+	}
+	if cfg.Timeout > 0 {
+		h.client.Timeout = time.Duration(cfg.Timeout)
+	}
+	if cfg.RetryDelay > 0 {
+		h.retryDelay = time.Duration(cfg.RetryDelay)
+	}
+	if cfg.MaxRetryTime > 0 {
+		h.maxRetryTime = time.Duration(cfg.MaxRetryTime)
+	}
+	text := cfg.Template
+	if cfg.TemplatePath != "" {
+		if text != "" {
+			return nil, errors.New("templatePath can't be set with template")
+		}
+		path := cfg.TemplatePath
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(root, path)
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read template: %v", err)
+		}
+		text = string(b)
+	}
+	if text != "" {
+		var err error
+		if h.tmpl, err = template.New("webhook").Funcs(funcs).Parse(text); err != nil {
+			return nil, fmt.Errorf("could not parse template: %v", err)
+		}
+	}
+	if cfg.HMAC != nil {
+		var err error
+		if h.signer, err = (&auth.Config{HMAC: cfg.HMAC}).NewSigner(root, h.client); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// Notify sends the report to each hook.
+func (n *Notifier) Notify(r *notifier.Report) {
+	report := newReport(r)
+	for _, h := range n.hooks {
+		if report.NbFailed == 0 && !h.cfg.Verbose {
+			continue
+		}
+		h.notify(report)
+	}
+}
+
+func newReport(r *notifier.Report) *Report {
+	report := &Report{
+		Suite:    r.Suite.Name(),
+		Type:     r.Suite.Type(),
+		FailFast: r.Suite.FailFast(),
+		Start:    r.Start,
+		Duration: r.Duration.String(),
+		Tests:    make([]TestReport, len(r.TestReports)),
+	}
+	for i, tr := range r.TestReports {
+		errs := make([]string, len(tr.Errs))
+		for j, err := range tr.Errs {
+			errs[j] = err.Error()
+		}
+		if len(errs) > 0 {
+			report.NbFailed++
+		}
+		report.Tests[i] = TestReport{
+			Name:        tr.Test.Name(),
+			Description: tr.Test.Description(),
+			Start:       tr.Start,
+			Duration:    tr.Duration.String(),
+			Failed:      len(errs) > 0,
+			Errors:      errs,
+		}
+	}
+	return report
+}
+
+func (h *hook) notify(r *Report) {
+	var body []byte
+	if h.tmpl != nil {
+		var buf bytes.Buffer
+		if err := h.tmpl.Execute(&buf, r); err != nil {
+			log.Error("could not execute webhook template", zap.String("url", h.cfg.URL), zap.Error(err))
+			return
+		}
+		body = buf.Bytes()
+	} else {
+		var err error
+		if body, err = json.Marshal(r); err != nil {
+			log.Error("could not marshal webhook report", zap.String("url", h.cfg.URL), zap.Error(err))
+			return
+		}
+	}
+	// Notify is called synchronously after each run, the attempts and the
+	// delays between them are bounded by maxRetryTime.
+	ctx, cancel := context.WithTimeout(context.Background(), h.maxRetryTime)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	delay := h.retryDelay
+	for i := 0; ; i++ {
+		retry, err := h.send(ctx, body)
+		if err == nil {
+			return
+		}
+		if !retry || i >= h.cfg.Retries || time.Until(deadline) < delay {
+			log.Error("could not send webhook notification", zap.String("url", h.cfg.URL), zap.Int("attempts", i+1), zap.Error(err))
+			return
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// send sends the request and reports whether it should be retried if it
+// failed, i.e. unless the server rejected it.
+func (h *hook) send(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequest(h.cfg.Method, h.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range h.cfg.Header {
+		req.Header.Set(k, v)
+	}
+	if h.signer != nil {
+		if err := h.signer.Sign(req, body); err != nil {
+			return false, err
+		}
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("wrong status code %d: %s", resp.StatusCode, respBody)
+	}
+	return false, nil
+}
+
+func init() {
+	plugin.Register(&plugin.Registration{
+		Type:   plugin.NotifierPlugin,
+		ID:     "webhook",
+		Config: (*Config)(nil),
+		InitFn: func(ctx *plugin.InitContext) (interface{}, error) {
+			cfg := ctx.Config.(*Config)
+			return New(cfg, ctx.Root)
+		},
+	})
+}
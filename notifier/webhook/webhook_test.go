@@ -0,0 +1,235 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/blippar/aragorn/log"
+	"github.com/blippar/aragorn/notifier"
+	"github.com/blippar/aragorn/pkg/auth"
+	ujson "github.com/blippar/aragorn/pkg/util/json"
+	"github.com/blippar/aragorn/testsuite"
+)
+
+func TestMain(m *testing.M) {
+	// The failed notifications are logged.
+	if err := log.Init("fatal", false); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+type request struct {
+	method string
+	header http.Header
+	body   string
+}
+
+func newTestServer(statuses ...int) (*httptest.Server, *[]request) {
+	var reqs []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		reqs = append(reqs, request{method: r.Method, header: r.Header, body: string(b)})
+		if len(statuses) >= len(reqs) {
+			w.WriteHeader(statuses[len(reqs)-1])
+		}
+	}))
+	return srv, &reqs
+}
+
+func TestNotifyDefault(t *testing.T) {
+	srv, reqs := newTestServer()
+	defer srv.Close()
+	n, err := New(&Config{Hooks: []HookConfig{{URL: srv.URL}}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Notify(newTestReport(nil))
+	if len(*reqs) != 0 {
+		t.Fatalf("report without failed tests notified")
+	}
+	n.Notify(newTestReport(errors.New("wrong status code")))
+	if len(*reqs) != 1 {
+		t.Fatalf("wrong number of requests (got %d; want 1)", len(*reqs))
+	}
+	req := (*reqs)[0]
+	if req.method != http.MethodPost || req.header.Get("Content-Type") != "application/json" {
+		t.Errorf("wrong request (got %s %s)", req.method, req.header.Get("Content-Type"))
+	}
+	var got Report
+	if err := json.Unmarshal([]byte(req.body), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := Report{
+		Suite:    "Suite",
+		Type:     "HTTP",
+		Start:    testStart,
+		Duration: "1s",
+		NbFailed: 1,
+		Tests: []TestReport{
+			{Name: "Test", Description: "GET /", Start: testStart, Duration: "1s", Failed: true, Errors: []string{"wrong status code"}},
+		},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("wrong report (-got +want):\n%s", diff)
+	}
+}
+
+func TestNotifyTemplate(t *testing.T) {
+	srv, reqs := newTestServer()
+	defer srv.Close()
+	cfg := &Config{Hooks: []HookConfig{{
+		URL:      srv.URL,
+		Method:   http.MethodPut,
+		Header:   map[string]string{"Content-Type": "text/plain", "X-Source": "aragorn"},
+		Template: `{{.Suite}}:{{range .Tests}} {{.Name}} {{json .Errors}}{{end}}`,
+		HMAC:     &auth.HMACConfig{Secret: "s3cr3t"},
+		Verbose:  true,
+	}}}
+	n, err := New(cfg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Notify(newTestReport(nil))
+	if len(*reqs) != 1 {
+		t.Fatalf("wrong number of requests (got %d; want 1)", len(*reqs))
+	}
+	req := (*reqs)[0]
+	if want := `Suite: Test []`; req.body != want {
+		t.Errorf("wrong body (got %q; want %q)", req.body, want)
+	}
+	if req.method != http.MethodPut || req.header.Get("Content-Type") != "text/plain" || req.header.Get("X-Source") != "aragorn" {
+		t.Errorf("wrong request (got %s %v)", req.method, req.header)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cr3t"))
+	mac.Write([]byte(req.body))
+	if got, want := req.header.Get("X-Signature"), hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Errorf("wrong signature (got %q; want %q)", got, want)
+	}
+}
+
+func TestNotifyRetries(t *testing.T) {
+	tt := []struct {
+		statuses []int
+		want     int
+	}{
+		{statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}, want: 3},
+		{statuses: []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}, want: 3},
+		{statuses: []int{http.StatusBadRequest}, want: 1},
+	}
+	for _, tc := range tt {
+		srv, reqs := newTestServer(tc.statuses...)
+		cfg := &Config{Hooks: []HookConfig{{URL: srv.URL, Retries: 2, RetryDelay: 1}}}
+		n, err := New(cfg, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		n.Notify(newTestReport(errors.New("failed")))
+		if len(*reqs) != tc.want {
+			t.Errorf("wrong number of attempts for %v (got %d; want %d)", tc.statuses, len(*reqs), tc.want)
+		}
+		srv.Close()
+	}
+}
+
+func TestNotifyMaxRetryTime(t *testing.T) {
+	srv, reqs := newTestServer(http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+	defer srv.Close()
+	cfg := &Config{Hooks: []HookConfig{{
+		URL:          srv.URL,
+		Retries:      2,
+		RetryDelay:   ujson.Duration(50 * time.Millisecond),
+		MaxRetryTime: ujson.Duration(120 * time.Millisecond),
+	}}}
+	n, err := New(cfg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	n.Notify(newTestReport(errors.New("failed")))
+	// The second retry would wait 100ms, past maxRetryTime.
+	if len(*reqs) != 2 {
+		t.Errorf("wrong number of attempts (got %d; want 2)", len(*reqs))
+	}
+	if d := time.Since(start); d > 120*time.Millisecond {
+		t.Errorf("notify took %v (want at most 120ms)", d)
+	}
+}
+
+func TestNotifyMaxRetryTimeSlowHook(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer srv.Close()
+	defer close(done)
+	cfg := &Config{Hooks: []HookConfig{{URL: srv.URL, MaxRetryTime: ujson.Duration(50 * time.Millisecond)}}}
+	n, err := New(cfg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	n.Notify(newTestReport(errors.New("failed")))
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("notify took %v (want about 50ms)", d)
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	tt := []struct {
+		cfg  *Config
+		want string
+	}{
+		{cfg: &Config{}, want: "at least one hook is required"},
+		{cfg: &Config{Hooks: []HookConfig{{}}}, want: "hook 0: url is required"},
+		{cfg: &Config{Hooks: []HookConfig{{URL: "http://localhost", Template: "{{.Suite"}}}, want: `hook 0: could not parse template: template: webhook:1: unclosed action`},
+		{cfg: &Config{Hooks: []HookConfig{{URL: "http://localhost", Template: "{{.Suite}}", TemplatePath: "webhook.tmpl"}}}, want: "hook 0: templatePath can't be set with template"},
+		{cfg: &Config{Hooks: []HookConfig{{URL: "http://localhost", HMAC: &auth.HMACConfig{}}}}, want: "hook 0: hmac: a secret is required"},
+	}
+	for _, tc := range tt {
+		if _, err := New(tc.cfg, ""); err == nil || err.Error() != tc.want {
+			t.Errorf("wrong error (got %v; want %v)", err, tc.want)
+		}
+	}
+}
+
+var testStart = time.Date(2018, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestReport returns the report of a suite with one test, failed with err
+// if not nil.
+func newTestReport(err error) *notifier.Report {
+	s := testSuite{}
+	r := &notifier.Report{Suite: s, Start: testStart, Duration: time.Second}
+	tr := r.NewTestReport(testTest{})
+	tr.Start, tr.Duration = testStart, time.Second
+	if err != nil {
+		tr.Error(err)
+		r.NbFailed++
+	}
+	return r
+}
+
+type testSuite struct{}
+
+func (testSuite) Name() string            { return "Suite" }
+func (testSuite) Type() string            { return "HTTP" }
+func (testSuite) FailFast() bool          { return false }
+func (testSuite) Tests() []testsuite.Test { return []testsuite.Test{testTest{}} }
+
+type testTest struct{}
+
+func (testTest) Name() string                                     { return "Test" }
+func (testTest) Description() string                              { return "GET /" }
+func (testTest) Run(ctx context.Context, logger testsuite.Logger) {}
@@ -0,0 +1,123 @@
+// Package auth authenticates the requests of the test suites.
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"path/filepath"
+
+	"golang.org/x/oauth2"
+)
+
+var errManySchemes = errors.New("only one authentication scheme can be set")
+
+// Config describes how the requests are authenticated. Only one scheme can
+// be set.
+type Config struct {
+	Basic          *BasicConfig     `json:"basic,omitempty"`
+	Bearer         string           `json:"bearer,omitempty"`         // Static bearer token.
+	OAuth2Password *PasswordConfig  `json:"oauth2Password,omitempty"` // OAuth2 resource owner password grant.
+	OAuth2JWT      *JWTBearerConfig `json:"oauth2JWT,omitempty"`      // OAuth2 JWT bearer grant.
+	JWT            *JWTConfig       `json:"jwt,omitempty"`            // Locally signed JWT.
+	HMAC           *HMACConfig      `json:"hmac,omitempty"`           // HMAC request signature.
+	AWS            *AWSConfig       `json:"aws,omitempty"`            // AWS Signature Version 4.
+}
+
+// RequireTransportSecurity reports whether the scheme sends credentials, which
+// must not be sent in clear. The request signatures of the hmac and aws
+// schemes do not disclose their secrets.
+func (cfg *Config) RequireTransportSecurity() bool {
+	return cfg.Basic != nil || cfg.Bearer != "" || cfg.OAuth2Password != nil || cfg.OAuth2JWT != nil || cfg.JWT != nil
+}
+
+// BasicConfig describes an HTTP basic authentication.
+type BasicConfig struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// A Signer authenticates requests. body is the content of the request body.
+type Signer interface {
+	Sign(req *http.Request, body []byte) error
+}
+
+// SignerFunc is an adapter to use functions as Signers.
+type SignerFunc func(req *http.Request, body []byte) error
+
+// Sign calls f(req, body).
+func (f SignerFunc) Sign(req *http.Request, body []byte) error { return f(req, body) }
+
+// NewSigner returns the Signer of the scheme set in cfg or nil if none is
+// set. Relative key paths are resolved from root and tokens are requested
+// with client.
+func (cfg *Config) NewSigner(root string, client *http.Client) (Signer, error) {
+	n := 0
+	for _, set := range []bool{
+		cfg.Basic != nil,
+		cfg.Bearer != "",
+		cfg.OAuth2Password != nil,
+		cfg.OAuth2JWT != nil,
+		cfg.JWT != nil,
+		cfg.HMAC != nil,
+		cfg.AWS != nil,
+	} {
+		if set {
+			n++
+		}
+	}
+	if n > 1 {
+		return nil, errManySchemes
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, client)
+	switch {
+	case cfg.Basic != nil:
+		cred := base64.StdEncoding.EncodeToString([]byte(cfg.Basic.Username + ":" + cfg.Basic.Password))
+		return headerSigner("Basic " + cred), nil
+	case cfg.Bearer != "":
+		return headerSigner("Bearer " + cfg.Bearer), nil
+	case cfg.OAuth2Password != nil:
+		return TokenSigner(cfg.OAuth2Password.tokenSource(ctx)), nil
+	case cfg.OAuth2JWT != nil:
+		ts, err := cfg.OAuth2JWT.tokenSource(ctx, root)
+		if err != nil {
+			return nil, err
+		}
+		return TokenSigner(ts), nil
+	case cfg.JWT != nil:
+		return cfg.JWT.newSigner(root)
+	case cfg.HMAC != nil:
+		return cfg.HMAC.newSigner()
+	case cfg.AWS != nil:
+		return cfg.AWS.newSigner()
+	}
+	return nil, nil
+}
+
+// headerSigner sets the Authorization header to value.
+func headerSigner(value string) Signer {
+	return SignerFunc(func(req *http.Request, body []byte) error {
+		req.Header.Set("Authorization", value)
+		return nil
+	})
+}
+
+// TokenSigner sets the Authorization header from the tokens of ts.
+func TokenSigner(ts oauth2.TokenSource) Signer {
+	return SignerFunc(func(req *http.Request, body []byte) error {
+		tok, err := ts.Token()
+		if err != nil {
+			return err
+		}
+		tok.SetAuthHeader(req)
+		return nil
+	})
+}
+
+func filePath(root, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(root, path)
+}
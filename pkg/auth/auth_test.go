@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewSignerManySchemes(t *testing.T) {
+	cfg := &Config{Bearer: "token", Basic: &BasicConfig{Username: "john"}}
+	if _, err := cfg.NewSigner("", http.DefaultClient); err != errManySchemes {
+		t.Fatalf("wrong error (got %v; want %v)", err, errManySchemes)
+	}
+}
+
+func TestHeaderSigners(t *testing.T) {
+	tt := []struct {
+		cfg  *Config
+		want string
+	}{
+		{cfg: &Config{Basic: &BasicConfig{Username: "john", Password: "doe"}}, want: "Basic am9objpkb2U="},
+		{cfg: &Config{Bearer: "s3cr3t"}, want: "Bearer s3cr3t"},
+	}
+	for _, tc := range tt {
+		got := sign(t, tc.cfg, "GET", "http://localhost/", nil).Header.Get("Authorization")
+		if got != tc.want {
+			t.Errorf("wrong Authorization header (got %q; want %q)", got, tc.want)
+		}
+	}
+}
+
+func TestOAuth2Signers(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		token := ""
+		switch r.Form.Get("grant_type") {
+		case "password":
+			token = r.Form.Get("username") + ":" + r.Form.Get("password")
+		case "urn:ietf:params:oauth:grant-type:jwt-bearer":
+			if r.Form.Get("assertion") != "" {
+				token = "jwt"
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":%q,"token_type":"bearer","expires_in":3600}`, token)
+	}))
+	defer ts.Close()
+	tt := []struct {
+		cfg  *Config
+		want string
+	}{
+		{
+			cfg:  &Config{OAuth2Password: &PasswordConfig{TokenURL: ts.URL, ClientID: "aragorn", Username: "john", Password: "doe"}},
+			want: "Bearer john:doe",
+		},
+		{
+			cfg:  &Config{OAuth2JWT: &JWTBearerConfig{TokenURL: ts.URL, Issuer: "aragorn", PrivateKeyPath: "rsa.key"}},
+			want: "Bearer jwt",
+		},
+	}
+	for _, tc := range tt {
+		got := sign(t, tc.cfg, "GET", "http://localhost/", nil).Header.Get("Authorization")
+		if got != tc.want {
+			t.Errorf("wrong Authorization header (got %q; want %q)", got, tc.want)
+		}
+	}
+}
+
+func TestJWTSigner(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	tt := []struct {
+		cfg    *JWTConfig
+		header string
+		verify func(data, sig []byte) error
+	}{
+		{
+			cfg:    &JWTConfig{Algorithm: "HS256", Secret: "s3cr3t", Claims: map[string]interface{}{"sub": "john"}},
+			header: `{"alg":"HS256","typ":"JWT"}`,
+			verify: func(data, sig []byte) error {
+				mac := hmac.New(sha256.New, []byte("s3cr3t"))
+				mac.Write(data)
+				if !hmac.Equal(sig, mac.Sum(nil)) {
+					return fmt.Errorf("invalid signature")
+				}
+				return nil
+			},
+		},
+		{
+			cfg:    &JWTConfig{Algorithm: "RS256", PrivateKeyPath: "rsa.key", KeyID: "1", Claims: map[string]interface{}{"sub": "john"}},
+			header: `{"alg":"RS256","kid":"1","typ":"JWT"}`,
+			verify: func(data, sig []byte) error {
+				key, err := readRSAKey("testdata/rsa.key")
+				if err != nil {
+					return err
+				}
+				h := sha256.Sum256(data)
+				return rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, h[:], sig)
+			},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.cfg.Algorithm, func(t *testing.T) {
+			s, err := tc.cfg.newSigner("testdata")
+			if err != nil {
+				t.Fatalf("new signer: %v", err)
+			}
+			s.(*jwtSigner).now = func() time.Time { return now }
+			req := httptest.NewRequest("GET", "http://localhost/", nil)
+			if err := s.Sign(req, nil); err != nil {
+				t.Fatalf("sign: %v", err)
+			}
+			parts := strings.Split(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "), ".")
+			if len(parts) != 3 {
+				t.Fatalf("invalid token: %q", req.Header.Get("Authorization"))
+			}
+			dec := func(s string) []byte {
+				b, err := base64.RawURLEncoding.DecodeString(s)
+				if err != nil {
+					t.Fatalf("invalid token encoding: %v", err)
+				}
+				return b
+			}
+			if got := string(dec(parts[0])); got != tc.header {
+				t.Errorf("wrong header (got %s; want %s)", got, tc.header)
+			}
+			var claims map[string]interface{}
+			json.Unmarshal(dec(parts[1]), &claims)
+			want := map[string]interface{}{"sub": "john", "iat": float64(1500000000), "exp": float64(1500003600)}
+			if !cmp.Equal(claims, want) {
+				t.Errorf("wrong claims (got %v; want %v)", claims, want)
+			}
+			if err := tc.verify([]byte(parts[0]+"."+parts[1]), dec(parts[2])); err != nil {
+				t.Errorf("could not verify signature: %v", err)
+			}
+		})
+	}
+}
+
+func TestHMACSigner(t *testing.T) {
+	tt := []struct {
+		cfg    *HMACConfig
+		header string
+		want   string
+	}{
+		{
+			cfg:    &HMACConfig{Secret: "s3cr3t", Header: "X-Hub-Signature-256", Prefix: "sha256="},
+			header: "X-Hub-Signature-256",
+			want:   "sha256=d42927434049e0b8c73ce887062238cc1c6bb6644bfe66e66d8dd0f30b85679e",
+		},
+		{
+			cfg: &HMACConfig{
+				Secret:          "s3cr3t",
+				Encoding:        "base64",
+				Message:         "{{method}}\n{{path}}\n{{timestamp}}\n{{body}}",
+				TimestampHeader: "X-Timestamp",
+			},
+			header: "X-Signature",
+			want:   "gesYrX6hfJG5kAHr0OEHSynkk+x7i0RyTxNsXYU3pXk=",
+		},
+	}
+	for _, tc := range tt {
+		s, err := tc.cfg.newSigner()
+		if err != nil {
+			t.Fatalf("new signer: %v", err)
+		}
+		s.(*hmacSigner).now = func() time.Time { return time.Unix(1500000000, 0) }
+		req := httptest.NewRequest("POST", "http://localhost/hooks?x=1", nil)
+		if err := s.Sign(req, []byte(`{"a":1}`)); err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		if got := req.Header.Get(tc.header); got != tc.want {
+			t.Errorf("wrong signature (got %q; want %q)", got, tc.want)
+		}
+		if tc.cfg.TimestampHeader != "" && req.Header.Get(tc.cfg.TimestampHeader) != "1500000000" {
+			t.Errorf("missing timestamp header")
+		}
+	}
+}
+
+// TestSigV4Signer uses the examples of the AWS Signature Version 4 test suite.
+func TestSigV4Signer(t *testing.T) {
+	tt := []struct {
+		url  string
+		want string
+	}{
+		{
+			url:  "https://example.amazonaws.com/",
+			want: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			url:  "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			want: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+	}
+	cfg := &AWSConfig{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Region:          "us-east-1",
+		Service:         "service",
+	}
+	s, err := cfg.newSigner()
+	if err != nil {
+		t.Fatalf("new signer: %v", err)
+	}
+	s.(*sigV4Signer).now = func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) }
+	for _, tc := range tt {
+		req := httptest.NewRequest("GET", tc.url, nil)
+		if err := s.Sign(req, nil); err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		if got := req.Header.Get("Authorization"); got != tc.want {
+			t.Errorf("%s: wrong Authorization header\ngot:  %s\nwant: %s", tc.url, got, tc.want)
+		}
+	}
+}
+
+func sign(t *testing.T, cfg *Config, method, url string, body []byte) *http.Request {
+	s, err := cfg.NewSigner("testdata", http.DefaultClient)
+	if err != nil {
+		t.Fatalf("new signer: %v", err)
+	}
+	req := httptest.NewRequest(method, url, nil)
+	if err := s.Sign(req, body); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return req
+}
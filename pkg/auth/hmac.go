@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultHMACHeader  = "X-Signature"
+	defaultHMACMessage = "{{body}}"
+)
+
+var hmacHashes = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// HMACConfig describes a request signature computed with an HMAC. The signed
+// message is a template where {{method}}, {{path}} (with the query),
+// {{timestamp}} (Unix time in seconds) and {{body}} are replaced.
+type HMACConfig struct {
+	Secret          string `json:"secret,omitempty"`
+	Algorithm       string `json:"algorithm,omitempty"`       // sha1, sha256 or sha512 (default: sha256).
+	Header          string `json:"header,omitempty"`          // Header of the signature (default: X-Signature).
+	Prefix          string `json:"prefix,omitempty"`          // Prefix of the signature, e.g. "sha256=".
+	Encoding        string `json:"encoding,omitempty"`        // hex or base64 (default: hex).
+	Message         string `json:"message,omitempty"`         // Signed message (default: {{body}}).
+	TimestampHeader string `json:"timestampHeader,omitempty"` // Header of the timestamp, if any.
+}
+
+type hmacSigner struct {
+	cfg  HMACConfig
+	hash func() hash.Hash
+	now  func() time.Time
+}
+
+func (c *HMACConfig) newSigner() (Signer, error) {
+	s := &hmacSigner{cfg: *c, now: time.Now}
+	if s.cfg.Secret == "" {
+		return nil, errors.New("hmac: a secret is required")
+	}
+	if s.cfg.Algorithm == "" {
+		s.cfg.Algorithm = "sha256"
+	}
+	var ok bool
+	if s.hash, ok = hmacHashes[s.cfg.Algorithm]; !ok {
+		return nil, fmt.Errorf("hmac: unsupported algorithm %q", s.cfg.Algorithm)
+	}
+	switch s.cfg.Encoding {
+	case "":
+		s.cfg.Encoding = "hex"
+	case "hex", "base64":
+	default:
+		return nil, fmt.Errorf("hmac: unsupported encoding %q", s.cfg.Encoding)
+	}
+	if s.cfg.Header == "" {
+		s.cfg.Header = defaultHMACHeader
+	}
+	if s.cfg.Message == "" {
+		s.cfg.Message = defaultHMACMessage
+	}
+	return s, nil
+}
+
+func (s *hmacSigner) Sign(req *http.Request, body []byte) error {
+	ts := strconv.FormatInt(s.now().Unix(), 10)
+	msg := strings.NewReplacer(
+		"{{method}}", req.Method,
+		"{{path}}", req.URL.RequestURI(),
+		"{{timestamp}}", ts,
+		"{{body}}", string(body),
+	).Replace(s.cfg.Message)
+	mac := hmac.New(s.hash, []byte(s.cfg.Secret))
+	mac.Write([]byte(msg))
+	sum := mac.Sum(nil)
+	var sig string
+	if s.cfg.Encoding == "base64" {
+		sig = base64.StdEncoding.EncodeToString(sum)
+	} else {
+		sig = hex.EncodeToString(sum)
+	}
+	req.Header.Set(s.cfg.Header, s.cfg.Prefix+sig)
+	if s.cfg.TimestampHeader != "" {
+		req.Header.Set(s.cfg.TimestampHeader, ts)
+	}
+	return nil
+}
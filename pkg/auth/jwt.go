@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	ajson "github.com/blippar/aragorn/pkg/util/json"
+)
+
+const defaultJWTExpiry = time.Hour
+
+// JWTConfig describes a JWT signed locally and sent as a bearer token.
+type JWTConfig struct {
+	Algorithm      string                 `json:"algorithm,omitempty"`      // HS256 or RS256.
+	Secret         string                 `json:"secret,omitempty"`         // HS256 secret.
+	PrivateKeyPath string                 `json:"privateKeyPath,omitempty"` // RS256 PEM private key.
+	KeyID          string                 `json:"keyID,omitempty"`
+	Claims         map[string]interface{} `json:"claims,omitempty"`
+	Expiry         ajson.Duration         `json:"expiry,omitempty"` // Lifetime of the tokens (default: 1h).
+}
+
+type jwtSigner struct {
+	header []byte
+	claims map[string]interface{}
+	expiry time.Duration
+	sign   func(data []byte) ([]byte, error)
+	now    func() time.Time
+}
+
+func (c *JWTConfig) newSigner(root string) (Signer, error) {
+	s := &jwtSigner{
+		claims: c.Claims,
+		expiry: time.Duration(c.Expiry),
+		now:    time.Now,
+	}
+	if s.expiry == 0 {
+		s.expiry = defaultJWTExpiry
+	}
+	switch c.Algorithm {
+	case "HS256":
+		if c.Secret == "" {
+			return nil, errors.New("jwt: a secret is required by HS256")
+		}
+		key := []byte(c.Secret)
+		s.sign = func(data []byte) ([]byte, error) {
+			mac := hmac.New(sha256.New, key)
+			mac.Write(data)
+			return mac.Sum(nil), nil
+		}
+	case "RS256":
+		key, err := readRSAKey(filePath(root, c.PrivateKeyPath))
+		if err != nil {
+			return nil, fmt.Errorf("jwt: %v", err)
+		}
+		s.sign = func(data []byte) ([]byte, error) {
+			h := sha256.Sum256(data)
+			return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h[:])
+		}
+	default:
+		return nil, fmt.Errorf("jwt: unsupported algorithm %q", c.Algorithm)
+	}
+	header := map[string]string{"alg": c.Algorithm, "typ": "JWT"}
+	if c.KeyID != "" {
+		header["kid"] = c.KeyID
+	}
+	s.header, _ = json.Marshal(header)
+	return s, nil
+}
+
+func (s *jwtSigner) Sign(req *http.Request, body []byte) error {
+	tok, err := s.token()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+tok)
+	return nil
+}
+
+// token returns a new token expiring after s.expiry.
+func (s *jwtSigner) token() (string, error) {
+	now := s.now()
+	claims := make(map[string]interface{}, len(s.claims)+2)
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(s.expiry).Unix()
+	for k, v := range s.claims {
+		claims[k] = v
+	}
+	b, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	data := enc.EncodeToString(s.header) + "." + enc.EncodeToString(b)
+	sig, err := s.sign([]byte(data))
+	if err != nil {
+		return "", err
+	}
+	return data + "." + enc.EncodeToString(sig), nil
+}
+
+func readRSAKey(path string) (*rsa.PrivateKey, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read private key: %v", err)
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("private key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse private key: %v", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/jwt"
+)
+
+// PasswordConfig describes an OAuth2 resource owner password credentials grant.
+type PasswordConfig struct {
+	TokenURL     string   `json:"tokenURL,omitempty"`
+	ClientID     string   `json:"clientID,omitempty"`
+	ClientSecret string   `json:"clientSecret,omitempty"`
+	Username     string   `json:"username,omitempty"`
+	Password     string   `json:"password,omitempty"`
+	Scopes       []string `json:"scopes,omitempty"`
+}
+
+func (c *PasswordConfig) tokenSource(ctx context.Context) oauth2.TokenSource {
+	conf := &oauth2.Config{
+		ClientID:     c.ClientID,
+		ClientSecret: c.ClientSecret,
+		Endpoint:     oauth2.Endpoint{TokenURL: c.TokenURL},
+		Scopes:       c.Scopes,
+	}
+	return oauth2.ReuseTokenSource(nil, &passwordSource{ctx: ctx, conf: conf, c: c})
+}
+
+// passwordSource requests a new token with the password grant each time.
+type passwordSource struct {
+	ctx  context.Context
+	conf *oauth2.Config
+	c    *PasswordConfig
+}
+
+func (s *passwordSource) Token() (*oauth2.Token, error) {
+	return s.conf.PasswordCredentialsToken(s.ctx, s.c.Username, s.c.Password)
+}
+
+// JWTBearerConfig describes an OAuth2 JWT bearer grant (RFC 7523) where the
+// assertion is signed with an RSA private key.
+type JWTBearerConfig struct {
+	TokenURL       string   `json:"tokenURL,omitempty"`
+	Issuer         string   `json:"issuer,omitempty"`
+	Subject        string   `json:"subject,omitempty"`
+	PrivateKeyPath string   `json:"privateKeyPath,omitempty"` // PEM RSA private key.
+	KeyID          string   `json:"keyID,omitempty"`
+	Scopes         []string `json:"scopes,omitempty"`
+}
+
+func (c *JWTBearerConfig) tokenSource(ctx context.Context, root string) (oauth2.TokenSource, error) {
+	key, err := ioutil.ReadFile(filePath(root, c.PrivateKeyPath))
+	if err != nil {
+		return nil, fmt.Errorf("oauth2JWT: could not read private key: %v", err)
+	}
+	conf := &jwt.Config{
+		Email:        c.Issuer,
+		Subject:      c.Subject,
+		PrivateKey:   key,
+		PrivateKeyID: c.KeyID,
+		Scopes:       c.Scopes,
+		TokenURL:     c.TokenURL,
+	}
+	return conf.TokenSource(ctx), nil
+}
@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat = "20060102T150405Z"
+)
+
+// AWSConfig describes an AWS Signature Version 4. The credentials default to
+// the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+// environment variables.
+type AWSConfig struct {
+	AccessKeyID     string `json:"accessKeyID,omitempty"`
+	SecretAccessKey string `json:"secretAccessKey,omitempty"`
+	SessionToken    string `json:"sessionToken,omitempty"`
+	Region          string `json:"region,omitempty"`
+	Service         string `json:"service,omitempty"`
+}
+
+type sigV4Signer struct {
+	cfg AWSConfig
+	now func() time.Time
+}
+
+func (c *AWSConfig) newSigner() (Signer, error) {
+	s := &sigV4Signer{cfg: *c, now: time.Now}
+	if s.cfg.AccessKeyID == "" {
+		s.cfg.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		s.cfg.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		s.cfg.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if s.cfg.AccessKeyID == "" || s.cfg.SecretAccessKey == "" {
+		return nil, errors.New("aws: missing credentials")
+	}
+	if s.cfg.Region == "" || s.cfg.Service == "" {
+		return nil, errors.New("aws: region and service are required")
+	}
+	return s, nil
+}
+
+func (s *sigV4Signer) Sign(req *http.Request, body []byte) error {
+	t := s.now().UTC()
+	date := t.Format(sigV4TimeFormat)
+	req.Header.Set("X-Amz-Date", date)
+	if s.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.cfg.SessionToken)
+	}
+	payloadHash := hashHex(body)
+	if s.cfg.Service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+	headers, signedHeaders := canonicalHeaders(req)
+	canonReq := strings.Join([]string{
+		req.Method,
+		s.canonicalURI(req),
+		canonicalQuery(req),
+		headers,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := strings.Join([]string{date[:8], s.cfg.Region, s.cfg.Service, "aws4_request"}, "/")
+	strToSign := strings.Join([]string{sigV4Algorithm, date, scope, hashHex([]byte(canonReq))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretAccessKey), date[:8])
+	for _, v := range []string{s.cfg.Region, s.cfg.Service, "aws4_request"} {
+		key = hmacSHA256(key, v)
+	}
+	sig := hex.EncodeToString(hmacSHA256(key, strToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, s.cfg.AccessKeyID, scope, signedHeaders, sig))
+	return nil
+}
+
+// canonicalURI returns the URI encoded path. Except for S3, the segments
+// are encoded twice.
+func (s *sigV4Signer) canonicalURI(req *http.Request) string {
+	path := req.URL.EscapedPath()
+	if s.cfg.Service == "s3" {
+		path = req.URL.Path
+	}
+	if path == "" {
+		return "/"
+	}
+	return uriEncode(path, false)
+}
+
+func canonicalQuery(req *http.Request) string {
+	q := req.URL.Query()
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vs := append([]string(nil), q[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// canonicalHeaders returns the canonical headers and the list of signed
+// headers. The host and the headers set by the signer are signed.
+func canonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	values := map[string]string{"host": host}
+	names := []string{"host"}
+	for k := range req.Header {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "x-amz-") || lk == "content-type" {
+			values[lk] = strings.Join(req.Header[k], ",")
+			names = append(names, lk)
+		}
+	}
+	sort.Strings(names)
+	var b bytes.Buffer
+	for _, name := range names {
+		b.WriteString(name + ":" + strings.Join(strings.Fields(values[name]), " ") + "\n")
+	}
+	return b.String(), strings.Join(names, ";")
+}
+
+// uriEncode encodes s as described by the AWS documentation: only the
+// unreserved characters are kept, and the slashes unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hashHex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
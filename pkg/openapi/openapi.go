@@ -0,0 +1,530 @@
+// Package openapi loads OpenAPI 3 specifications written in YAML or JSON.
+package openapi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/blippar/aragorn/pkg/util/json"
+)
+
+var (
+	errNotOpenAPI3  = errors.New("only OpenAPI 3 specifications are supported")
+	errInvalidRef   = errors.New("only local references (#/...) are supported")
+	errRefNotFound  = errors.New("reference not found")
+	errRefCycle     = errors.New("references form a cycle")
+	errInvalidPaths = errors.New("paths must be an object")
+)
+
+var methods = []string{
+	http.MethodGet,
+	http.MethodPut,
+	http.MethodPost,
+	http.MethodDelete,
+	http.MethodOptions,
+	http.MethodHead,
+	http.MethodPatch,
+	http.MethodTrace,
+}
+
+// Spec is a parsed OpenAPI specification.
+type Spec struct {
+	Title      string
+	Servers    []string
+	Operations []*Operation
+
+	doc map[string]interface{}
+}
+
+// Operation describes a single API operation on a path.
+type Operation struct {
+	ID         string
+	Summary    string
+	Method     string
+	Path       string // Templated path, e.g. /users/{id}.
+	Parameters []*Parameter
+	Body       interface{} // Example of the JSON request body.
+	Responses  map[string]*Response
+
+	spec *Spec
+}
+
+// Parameter describes a path, query or header parameter of an operation.
+type Parameter struct {
+	Name     string
+	In       string
+	Required bool
+	Example  interface{}
+}
+
+// Response describes a response of an operation.
+type Response struct {
+	Description string
+	Schema      map[string]interface{} // JSON schema of the application/json content.
+	Example     interface{}
+}
+
+// Load parses the OpenAPI specification stored in the file at path.
+func Load(path string) (*Spec, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse parses an OpenAPI specification in YAML or JSON from r.
+func Parse(r io.Reader) (*Spec, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var y interface{}
+	if err := yaml.Unmarshal(b, &y); err != nil {
+		return nil, err
+	}
+	// Go through JSON so numbers are decoded as json.Number like the rest of the suite documents.
+	jb, err := json.Marshal(fromYAML(y))
+	if err != nil {
+		return nil, err
+	}
+	s := &Spec{}
+	if err := json.Unmarshal(jb, &s.doc); err != nil {
+		return nil, err
+	}
+	if v, _ := s.doc["openapi"].(string); !strings.HasPrefix(v, "3.") {
+		return nil, errNotOpenAPI3
+	}
+	if err := s.parse(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Spec) parse() error {
+	info, _ := s.doc["info"].(map[string]interface{})
+	s.Title, _ = info["title"].(string)
+	servers, _ := s.doc["servers"].([]interface{})
+	for _, v := range servers {
+		srv, _ := v.(map[string]interface{})
+		if u, ok := srv["url"].(string); ok {
+			s.Servers = append(s.Servers, u)
+		}
+	}
+	paths, ok := s.doc["paths"].(map[string]interface{})
+	if !ok {
+		return errInvalidPaths
+	}
+	keys := make([]string, 0, len(paths))
+	for k := range paths {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, path := range keys {
+		item, err := s.resolveObject(paths[path])
+		if err != nil {
+			return fmt.Errorf("paths.%s: %v", path, err)
+		}
+		for _, method := range methods {
+			v, ok := item[strings.ToLower(method)]
+			if !ok {
+				continue
+			}
+			op, err := s.parseOperation(method, path, item, v)
+			if err != nil {
+				return fmt.Errorf("paths.%s.%s: %v", path, strings.ToLower(method), err)
+			}
+			s.Operations = append(s.Operations, op)
+		}
+	}
+	return nil
+}
+
+func (s *Spec) parseOperation(method, path string, item map[string]interface{}, v interface{}) (*Operation, error) {
+	m, err := s.resolveObject(v)
+	if err != nil {
+		return nil, err
+	}
+	op := &Operation{
+		Method:    method,
+		Path:      path,
+		Responses: make(map[string]*Response),
+		spec:      s,
+	}
+	op.ID, _ = m["operationId"].(string)
+	op.Summary, _ = m["summary"].(string)
+	params, _ := item["parameters"].([]interface{})
+	opParams, _ := m["parameters"].([]interface{})
+	for _, p := range append(params, opParams...) {
+		param, err := s.parseParameter(p)
+		if err != nil {
+			return nil, fmt.Errorf("parameters: %v", err)
+		}
+		op.setParameter(param)
+	}
+	if rb, ok := m["requestBody"]; ok {
+		body, err := s.resolveObject(rb)
+		if err != nil {
+			return nil, fmt.Errorf("requestBody: %v", err)
+		}
+		if mt, ok := jsonMediaType(body); ok {
+			op.Body = s.example(mt)
+		}
+	}
+	resps, _ := m["responses"].(map[string]interface{})
+	for code, r := range resps {
+		resp, err := s.parseResponse(r)
+		if err != nil {
+			return nil, fmt.Errorf("responses.%s: %v", code, err)
+		}
+		op.Responses[strings.ToUpper(code)] = resp
+	}
+	return op, nil
+}
+
+func (s *Spec) parseParameter(v interface{}) (*Parameter, error) {
+	m, err := s.resolveObject(v)
+	if err != nil {
+		return nil, err
+	}
+	p := &Parameter{}
+	p.Name, _ = m["name"].(string)
+	p.In, _ = m["in"].(string)
+	p.Required, _ = m["required"].(bool)
+	p.Example = s.example(m)
+	return p, nil
+}
+
+func (s *Spec) parseResponse(v interface{}) (*Response, error) {
+	m, err := s.resolveObject(v)
+	if err != nil {
+		return nil, err
+	}
+	r := &Response{}
+	r.Description, _ = m["description"].(string)
+	if mt, ok := jsonMediaType(m); ok {
+		r.Schema, _ = mt["schema"].(map[string]interface{})
+		r.Example = s.example(mt)
+	}
+	return r, nil
+}
+
+// setParameter adds p to the operation parameters, replacing a path level
+// parameter with the same name and location.
+func (op *Operation) setParameter(p *Parameter) {
+	for i, param := range op.Parameters {
+		if param.Name == p.Name && param.In == p.In {
+			op.Parameters[i] = p
+			return
+		}
+	}
+	op.Parameters = append(op.Parameters, p)
+}
+
+// example returns the example of a media type or parameter object: the
+// example field, the first of the examples field or the schema example.
+func (s *Spec) example(m map[string]interface{}) interface{} {
+	if v, ok := m["example"]; ok {
+		return v
+	}
+	if examples, ok := m["examples"].(map[string]interface{}); ok && len(examples) > 0 {
+		keys := make([]string, 0, len(examples))
+		for k := range examples {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		if ex, err := s.resolveObject(examples[keys[0]]); err == nil {
+			return ex["value"]
+		}
+	}
+	if schema, err := s.resolveObject(m["schema"]); err == nil {
+		if v, ok := schema["example"]; ok {
+			return v
+		}
+		if v, ok := schema["default"]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+func jsonMediaType(m map[string]interface{}) (map[string]interface{}, bool) {
+	content, _ := m["content"].(map[string]interface{})
+	for k, v := range content {
+		if strings.HasPrefix(k, "application/json") || strings.HasSuffix(k, "+json") {
+			mt, ok := v.(map[string]interface{})
+			return mt, ok
+		}
+	}
+	return nil, false
+}
+
+// BasePath returns the path of the first server URL.
+func (s *Spec) BasePath() string {
+	if len(s.Servers) == 0 {
+		return ""
+	}
+	u, err := url.Parse(s.Servers[0])
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(u.Path, "/")
+}
+
+// FindOperation returns the operation matching the method and path. The path
+// may be prefixed by the base path of the first server. Path parameters match
+// any segment; when several operations match, the one with the most literal
+// segments is returned.
+func (s *Spec) FindOperation(method, path string) *Operation {
+	if bp := s.BasePath(); bp != "" && (path == bp || strings.HasPrefix(path, bp+"/")) {
+		path = path[len(bp):]
+	}
+	segs := splitPath(path)
+	var (
+		best      *Operation
+		bestScore = -1
+	)
+	for _, op := range s.Operations {
+		if op.Method != method {
+			continue
+		}
+		if score := matchPath(splitPath(op.Path), segs); score > bestScore {
+			best, bestScore = op, score
+		}
+	}
+	return best
+}
+
+// matchPath returns the number of literal segments of tmpl matching segs, or
+// -1 if they do not match.
+func matchPath(tmpl, segs []string) int {
+	if len(tmpl) != len(segs) {
+		return -1
+	}
+	score := 0
+	for i, t := range tmpl {
+		switch {
+		case strings.HasPrefix(t, "{") && strings.HasSuffix(t, "}"):
+		case t == segs[i]:
+			score++
+		default:
+			return -1
+		}
+	}
+	return score
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// Name returns a human readable name for the operation.
+func (op *Operation) Name() string {
+	if op.ID != "" {
+		return op.ID
+	}
+	if op.Summary != "" {
+		return op.Summary
+	}
+	return op.Method + " " + op.Path
+}
+
+// SuccessCode returns the lowest documented 2xx status code of the operation.
+func (op *Operation) SuccessCode() int {
+	code := 0
+	for k := range op.Responses {
+		if c, err := strconv.Atoi(k); err == nil && c >= 200 && c < 300 && (code == 0 || c < code) {
+			code = c
+		}
+	}
+	if code == 0 {
+		return http.StatusOK
+	}
+	return code
+}
+
+// ResponseKey returns the key of the response documented for the status code,
+// falling back on the range (e.g. 2XX) and default responses.
+func (op *Operation) ResponseKey(code int) (string, bool) {
+	c := strconv.Itoa(code)
+	for _, k := range []string{c, c[:1] + "XX", "DEFAULT"} {
+		if _, ok := op.Responses[k]; ok {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// ResponseSchema returns a self-contained JSON schema for the response
+// documented for the status code. It returns nil if no JSON schema is documented.
+func (op *Operation) ResponseSchema(code int) (map[string]interface{}, error) {
+	k, ok := op.ResponseKey(code)
+	if !ok || op.Responses[k].Schema == nil {
+		return nil, nil
+	}
+	return op.spec.Schema(op.Responses[k].Schema)
+}
+
+// Schema converts an OpenAPI schema object into a self-contained JSON schema:
+// nullable types are converted and every referenced component is embedded so
+// local references can be resolved.
+func (s *Spec) Schema(schema map[string]interface{}) (map[string]interface{}, error) {
+	refs := make(map[string]interface{})
+	if err := s.collectRefs(schema, refs); err != nil {
+		return nil, err
+	}
+	res := convertSchema(schema).(map[string]interface{})
+	for ref, v := range refs {
+		setPointer(res, ref, convertSchema(v))
+	}
+	return res, nil
+}
+
+// collectRefs adds the targets of the references found in i to refs. A target
+// is added before its own references are collected, so that recursive schemas
+// are only walked once.
+func (s *Spec) collectRefs(i interface{}, refs map[string]interface{}) error {
+	switch v := i.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if err := s.collectRefs(item, refs); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		for k, item := range v {
+			if ref, ok := item.(string); ok && k == "$ref" {
+				if _, ok := refs[ref]; ok {
+					continue
+				}
+				target, err := s.lookupRef(ref)
+				if err != nil {
+					return fmt.Errorf("%s: %v", ref, err)
+				}
+				refs[ref] = target
+				if err := s.collectRefs(target, refs); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := s.collectRefs(item, refs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// convertSchema returns a copy of the schema where OpenAPI nullable types are
+// converted to JSON schema type lists.
+func convertSchema(i interface{}) interface{} {
+	switch v := i.(type) {
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, item := range v {
+			res[i] = convertSchema(item)
+		}
+		return res
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			res[k] = convertSchema(item)
+		}
+		if nullable, _ := v["nullable"].(bool); nullable {
+			delete(res, "nullable")
+			if typ, ok := v["type"].(string); ok {
+				res["type"] = []interface{}{typ, "null"}
+			}
+		}
+		return res
+	}
+	return i
+}
+
+func setPointer(m map[string]interface{}, ref string, val interface{}) {
+	keys := strings.Split(strings.TrimPrefix(ref, "#/"), "/")
+	for _, k := range keys[:len(keys)-1] {
+		k = unescapePointer(k)
+		sub, ok := m[k].(map[string]interface{})
+		if !ok {
+			sub = make(map[string]interface{})
+			m[k] = sub
+		}
+		m = sub
+	}
+	m[unescapePointer(keys[len(keys)-1])] = val
+}
+
+// resolveObject follows the local references of v if any and returns the
+// referenced object.
+func (s *Spec) resolveObject(v interface{}) (map[string]interface{}, error) {
+	seen := make(map[string]bool)
+	for {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("must be an object")
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return m, nil
+		}
+		if seen[ref] {
+			return nil, fmt.Errorf("%s: %v", ref, errRefCycle)
+		}
+		seen[ref] = true
+		target, err := s.lookupRef(ref)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", ref, err)
+		}
+		v = target
+	}
+}
+
+func (s *Spec) lookupRef(ref string) (interface{}, error) {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, errInvalidRef
+	}
+	var v interface{} = s.doc
+	for _, k := range strings.Split(ref[2:], "/") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, errRefNotFound
+		}
+		if v, ok = m[unescapePointer(k)]; !ok {
+			return nil, errRefNotFound
+		}
+	}
+	return v, nil
+}
+
+func unescapePointer(s string) string {
+	return strings.Replace(strings.Replace(s, "~1", "/", -1), "~0", "~", -1)
+}
+
+// fromYAML converts the maps decoded by the yaml package into JSON objects.
+func fromYAML(i interface{}) interface{} {
+	switch v := i.(type) {
+	case []interface{}:
+		for i, item := range v {
+			v[i] = fromYAML(item)
+		}
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[fmt.Sprint(k)] = fromYAML(item)
+		}
+		return m
+	}
+	return i
+}
@@ -0,0 +1,176 @@
+package openapi
+
+import (
+	gojson "encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoad(t *testing.T) {
+	spec, err := Load("testdata/todo.yaml")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got, want := spec.Title, "Todo API"; got != want {
+		t.Errorf("invalid title (got %q; want %q)", got, want)
+	}
+	if got, want := spec.BasePath(), "/v1"; got != want {
+		t.Errorf("invalid base path (got %q; want %q)", got, want)
+	}
+	var names []string
+	for _, op := range spec.Operations {
+		names = append(names, op.Name())
+	}
+	want := []string{"listTodos", "Create a todo", "listDoneTodos", "GET /todos/{id}"}
+	if !cmp.Equal(names, want) {
+		t.Errorf("invalid operations (got %v; want %v)", names, want)
+	}
+	op := spec.Operations[1]
+	if got, want := op.SuccessCode(), 201; got != want {
+		t.Errorf("invalid success code (got %d; want %d)", got, want)
+	}
+	if got, want := op.Body, map[string]interface{}{"title": "Buy milk"}; !cmp.Equal(got, want) {
+		t.Errorf("invalid body example (got %v; want %v)", got, want)
+	}
+	op = spec.Operations[3]
+	if len(op.Parameters) != 1 || op.Parameters[0].Example != gojson.Number("42") {
+		t.Errorf("invalid parameters: %v", op.Parameters)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tt := []struct {
+		name string
+		spec string
+		err  string
+	}{
+		{"swagger 2", "swagger: '2.0'", "only OpenAPI 3 specifications are supported"},
+		{"no paths", "openapi: 3.0.0", "paths must be an object"},
+		{"remote ref", "openapi: 3.0.0\npaths:\n  /a:\n    $ref: 'other.yaml#/paths/a'", "paths./a: other.yaml#/paths/a: only local references (#/...) are supported"},
+		{"missing ref", "openapi: 3.0.0\npaths:\n  /a:\n    $ref: '#/components/a'", "paths./a: #/components/a: reference not found"},
+		{"ref cycle", "openapi: 3.0.0\npaths:\n  /a:\n    $ref: '#/paths/~1b'\n  /b:\n    $ref: '#/paths/~1a'", "paths./a: #/paths/~1b: references form a cycle"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tc.spec))
+			if err == nil || err.Error() != tc.err {
+				t.Fatalf("invalid error (got %v; want %v)", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestFindOperation(t *testing.T) {
+	spec, err := Load("testdata/todo.yaml")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	tt := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{"GET", "/v1/todos", "listTodos"},
+		{"GET", "/todos/", "listTodos"},
+		{"GET", "/v1/todos/12", "GET /todos/{id}"},
+		{"GET", "/v1/todos/{{add_todo.id}}", "GET /todos/{id}"},
+		{"GET", "/v1/todos/done", "listDoneTodos"},
+		{"DELETE", "/v1/todos/12", ""},
+		{"GET", "/v1/users", ""},
+		{"GET", "/v1todos", ""},
+	}
+	for _, tc := range tt {
+		op := spec.FindOperation(tc.method, tc.path)
+		got := ""
+		if op != nil {
+			got = op.Name()
+		}
+		if got != tc.want {
+			t.Errorf("%s %s: invalid operation (got %q; want %q)", tc.method, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestResponseSchema(t *testing.T) {
+	spec, err := Load("testdata/todo.yaml")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	op := spec.FindOperation("GET", "/v1/todos/1")
+	schema, err := op.ResponseSchema(404)
+	if err != nil {
+		t.Fatalf("response schema: %v", err)
+	}
+	want := map[string]interface{}{
+		"$ref": "#/components/schemas/Error",
+		"components": map[string]interface{}{
+			"schemas": map[string]interface{}{
+				"Error": map[string]interface{}{
+					"type":     "object",
+					"required": []interface{}{"message"},
+					"properties": map[string]interface{}{
+						"message": map[string]interface{}{"type": "string"},
+					},
+				},
+			},
+		},
+	}
+	if !cmp.Equal(schema, want) {
+		t.Errorf("invalid schema (got %v; want %v)", schema, want)
+	}
+	schema, err = op.ResponseSchema(200)
+	if err != nil {
+		t.Fatalf("response schema: %v", err)
+	}
+	schemas := schema["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	if _, ok := schemas["User"]; !ok {
+		t.Errorf("nested reference User not embedded: %v", schemas)
+	}
+	note := schemas["Todo"].(map[string]interface{})["properties"].(map[string]interface{})["note"]
+	if got, want := note, map[string]interface{}{"type": []interface{}{"string", "null"}}; !cmp.Equal(got, want) {
+		t.Errorf("invalid nullable conversion (got %v; want %v)", got, want)
+	}
+	op = spec.FindOperation("GET", "/v1/todos/done")
+	if schema, err := op.ResponseSchema(200); schema != nil || err != nil {
+		t.Errorf("unexpected schema %v (err: %v)", schema, err)
+	}
+}
+
+func TestResponseSchemaRecursive(t *testing.T) {
+	const doc = `openapi: 3.0.0
+paths:
+  /nodes:
+    get:
+      responses:
+        '200':
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Node'
+components:
+  schemas:
+    Node:
+      type: object
+      properties:
+        parent:
+          $ref: '#/components/schemas/Node'
+        children:
+          type: array
+          items:
+            $ref: '#/components/schemas/Node'
+`
+	spec, err := Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	schema, err := spec.FindOperation("GET", "/nodes").ResponseSchema(200)
+	if err != nil {
+		t.Fatalf("response schema: %v", err)
+	}
+	schemas := schema["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	if _, ok := schemas["Node"]; !ok || len(schemas) != 1 {
+		t.Errorf("invalid embedded schemas: %v", schemas)
+	}
+}
@@ -0,0 +1,57 @@
+package json
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidArrayIndex   = errors.New("invalid array index")
+	ErrInvalidType         = errors.New("invalid type")
+	ErrIndexOutOfBounds    = errors.New("array index out of bounds")
+	ErrObjectFieldNotFound = errors.New("object does not contain field")
+)
+
+// Query returns the value for the given query in the decoded json data.
+// The keys of the query are separated by dots, array elements are selected by
+// their index and `length` returns the length of an array.
+func Query(q string, v interface{}) (interface{}, error) {
+	var err error
+	ks := strings.Split(q, ".")
+	for i, k := range ks {
+		v, err = lookup(k, v)
+		if err != nil {
+			pq := strings.Join(ks[:i+1], ".")
+			return nil, fmt.Errorf("%s: %v", pq, err)
+		}
+	}
+	return v, nil
+}
+
+// lookup returns the value for the key in the decoded json data.
+func lookup(k string, i interface{}) (interface{}, error) {
+	switch v := i.(type) {
+	case []interface{}:
+		if k == "length" {
+			return json.Number(strconv.Itoa(len(v))), nil
+		}
+		i, err := strconv.Atoi(k)
+		if err != nil {
+			return nil, ErrInvalidArrayIndex
+		}
+		if i >= len(v) {
+			return nil, ErrIndexOutOfBounds
+		}
+		return v[i], nil
+	case map[string]interface{}:
+		val, ok := v[k]
+		if !ok {
+			return nil, ErrObjectFieldNotFound
+		}
+		return val, nil
+	}
+	return nil, ErrInvalidType
+}
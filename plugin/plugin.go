@@ -26,6 +26,8 @@ const (
 	TestSuitePlugin Type = "aragorn.testsuite.v1"
 	// NotifierPlugin implements a notifier
 	NotifierPlugin Type = "aragorn.notifier.v1"
+	// ImporterPlugin implements an importer
+	ImporterPlugin Type = "aragorn.importer.v1"
 )
 
 // Registration contains information for registering a plugin
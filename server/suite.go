@@ -32,6 +32,7 @@ type Suite struct {
 	timeout    time.Duration
 	failfast   bool
 	tests      []testsuite.Test
+	suite      testsuite.Suite
 }
 
 func (s *Suite) Path() string            { return s.path }
@@ -106,7 +107,17 @@ func NewSuiteFromReader(r io.Reader, options ...SuiteOption) (*Suite, error) {
 		return nil, err
 	}
 	ts := suite.(testsuite.Suite)
-	return NewSuite(path, cfg.Type, ts.Tests(), cfg)
+	s, err := NewSuite(path, cfg.Type, ts.Tests(), cfg)
+	if err != nil {
+		// Releases the resources, such as pooled connections, held since the
+		// suite was initialized.
+		if c, ok := ts.(testsuite.Closer); ok {
+			c.Close()
+		}
+		return nil, err
+	}
+	s.suite = ts
+	return s, nil
 }
 
 func NewSuiteFromFile(path string, options ...SuiteOption) (*Suite, error) {
@@ -118,6 +129,23 @@ func NewSuiteFromFile(path string, options ...SuiteOption) (*Suite, error) {
 	return NewSuiteFromReader(f, options...)
 }
 
+// Close releases the resources held by the suite. It must not be run after.
+func (s *Suite) Close() error {
+	if c, ok := s.suite.(testsuite.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Mock returns a server mocking the service tested by the suite.
+func (s *Suite) Mock() (testsuite.MockServer, error) {
+	m, ok := s.suite.(testsuite.Mocker)
+	if !ok {
+		return nil, fmt.Errorf("%s test suites can't be mocked", s.typ)
+	}
+	return m.Mock()
+}
+
 func (s *Suite) Run(ctx context.Context) *notifier.Report {
 	log.Info("running suite", zap.String("file", s.path), zap.String("suite", s.name), zap.String("type", s.typ))
 	span, ctx := ot.StartSpanFromContext(ctx, s.name)
@@ -138,6 +166,9 @@ func (s *Suite) Run(ctx context.Context) *notifier.Report {
 func (s *Suite) runTests(ctx context.Context, span ot.Span) *notifier.Report {
 	report := notifier.NewReport(s)
 	defer report.Done()
+	if r, ok := s.suite.(testsuite.Resetter); ok {
+		r.Reset()
+	}
 	ctx = testsuite.NewMDContext(ctx, testsuite.NewMD())
 	for _, t := range s.tests {
 		ok := s.runTestWithRetry(ctx, t, report)
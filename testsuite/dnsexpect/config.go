@@ -0,0 +1,219 @@
+package dnsexpect
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/blippar/aragorn/pkg/util/json"
+	"github.com/blippar/aragorn/testsuite"
+)
+
+// resolvConfPath is the file where the system resolver is looked up.
+const resolvConfPath = "/etc/resolv.conf"
+
+var types = map[string]dnsmessage.Type{
+	"A":     dnsmessage.TypeA,
+	"AAAA":  dnsmessage.TypeAAAA,
+	"CNAME": dnsmessage.TypeCNAME,
+	"MX":    dnsmessage.TypeMX,
+	"TXT":   dnsmessage.TypeTXT,
+	"SRV":   dnsmessage.TypeSRV,
+}
+
+var rcodes = map[string]dnsmessage.RCode{
+	"NOERROR":  dnsmessage.RCodeSuccess,
+	"FORMERR":  dnsmessage.RCodeFormatError,
+	"SERVFAIL": dnsmessage.RCodeServerFailure,
+	"NXDOMAIN": dnsmessage.RCodeNameError,
+	"NOTIMP":   dnsmessage.RCodeNotImplemented,
+	"REFUSED":  dnsmessage.RCodeRefused,
+}
+
+type Config struct {
+	Path     string       `json:"path,omitempty"`
+	Root     string       `json:"root,omitempty"`
+	Resolver string       `json:"resolver,omitempty"` // host:port of the resolver (default: the first nameserver of /etc/resolv.conf).
+	Network  string       `json:"network,omitempty"`  // udp or tcp (default: udp).
+	Tests    []TestConfig `json:"tests,omitempty"`
+}
+
+type TestConfig struct {
+	Name     string       `json:"name,omitempty"`
+	Resolver string       `json:"resolver,omitempty"` // If set, will overwrite the suite resolver.
+	Question string       `json:"question,omitempty"` // Queried domain name.
+	Type     string       `json:"type,omitempty"`     // A, AAAA, CNAME, MX, TXT or SRV (default: A).
+	Expect   ExpectConfig `json:"expect,omitempty"`
+}
+
+// ExpectConfig describes the expected response. Only the answers of the
+// queried type are checked, e.g. the CNAME records answering an A question are
+// ignored.
+type ExpectConfig struct {
+	RCode    string        `json:"rcode,omitempty"`    // Response code (default: NOERROR).
+	Answers  []string      `json:"answers,omitempty"`  // Exact set of answers.
+	Contains []string      `json:"contains,omitempty"` // Answers that must be present. Exclusive with Answers.
+	MinTTL   json.Duration `json:"minTTL,omitempty"`   // Minimum TTL of every answer.
+	MaxTTL   json.Duration `json:"maxTTL,omitempty"`   // Maximum TTL of every answer.
+}
+
+func (*Config) Example() interface{} {
+	return &Config{
+		Resolver: "8.8.8.8:53",
+		Tests: []TestConfig{
+			{
+				Name:     "API",
+				Question: "api.example.com",
+				Type:     "CNAME",
+				Expect: ExpectConfig{
+					Answers: []string{"lb.example.com."},
+					MinTTL:  json.Duration(5 * time.Minute),
+				},
+			},
+		},
+	}
+}
+
+func (cfg *Config) genTests() ([]testsuite.Test, error) {
+	switch cfg.Network {
+	case "":
+		cfg.Network = "udp"
+	case "udp", "tcp":
+	default:
+		return nil, fmt.Errorf("unknown network %q", cfg.Network)
+	}
+	if len(cfg.Tests) == 0 {
+		return nil, errors.New("a test suite must contain at least one test")
+	}
+	tests := make([]testsuite.Test, len(cfg.Tests))
+	var errs []string
+	for i, tcfg := range cfg.Tests {
+		t, err := tcfg.prepare(cfg)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("test %q:\n%v", tcfg.Name, err))
+		}
+		tests[i] = t
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "\n"))
+	}
+	return tests, nil
+}
+
+func (tcfg *TestConfig) prepare(cfg *Config) (*test, error) {
+	t := &test{
+		name:     tcfg.Name,
+		network:  cfg.Network,
+		resolver: cfg.Resolver,
+		minTTL:   time.Duration(tcfg.Expect.MinTTL),
+		maxTTL:   time.Duration(tcfg.Expect.MaxTTL),
+	}
+	if tcfg.Resolver != "" {
+		t.resolver = tcfg.Resolver
+	}
+	var errs []string
+	if t.resolver == "" {
+		resolver, err := systemResolver()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("- resolver: %v", err))
+		}
+		t.resolver = resolver
+	} else if _, _, err := net.SplitHostPort(t.resolver); err != nil {
+		errs = append(errs, fmt.Sprintf("- resolver: %v", err))
+	}
+	name, err := dnsmessage.NewName(fqdn(tcfg.Question))
+	if tcfg.Question == "" {
+		errs = append(errs, "- question: a domain name is required")
+	} else if err != nil {
+		errs = append(errs, fmt.Sprintf("- question: %v", err))
+	}
+	t.question = dnsmessage.Question{Name: name, Class: dnsmessage.ClassINET}
+	typ := strings.ToUpper(tcfg.Type)
+	if typ == "" {
+		typ = "A"
+	}
+	var ok bool
+	if t.question.Type, ok = types[typ]; !ok {
+		errs = append(errs, fmt.Sprintf("- type: unknown record type %q", tcfg.Type))
+	}
+	t.description = fmt.Sprintf("DNS %s %s @%s", typ, name, t.resolver)
+	rcode := strings.ToUpper(tcfg.Expect.RCode)
+	if rcode == "" {
+		rcode = "NOERROR"
+	}
+	if t.rcode, ok = rcodes[rcode]; !ok {
+		errs = append(errs, fmt.Sprintf("- expect: unknown response code %q", tcfg.Expect.RCode))
+	}
+	if tcfg.Expect.Answers != nil && tcfg.Expect.Contains != nil {
+		errs = append(errs, "- expect: contains can't be set with answers")
+	}
+	if t.minTTL > 0 && t.maxTTL > 0 && t.minTTL > t.maxTTL {
+		errs = append(errs, "- expect: minTTL can't be greater than maxTTL")
+	}
+	if tcfg.Expect.Answers != nil {
+		t.answers = normalizeAnswers(t.question.Type, tcfg.Expect.Answers)
+	}
+	if tcfg.Expect.Contains != nil {
+		t.contains = normalizeAnswers(t.question.Type, tcfg.Expect.Contains)
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "\n"))
+	}
+	return t, nil
+}
+
+// normalizeAnswers returns the answers formatted like the received ones: the
+// domain names are fully qualified and lower case.
+func normalizeAnswers(typ dnsmessage.Type, answers []string) []string {
+	n := make([]string, len(answers))
+	for i, a := range answers {
+		switch typ {
+		case dnsmessage.TypeCNAME, dnsmessage.TypeMX, dnsmessage.TypeSRV:
+			fields := strings.Fields(a)
+			if len(fields) > 0 {
+				fields[len(fields)-1] = strings.ToLower(fqdn(fields[len(fields)-1]))
+			}
+			a = strings.Join(fields, " ")
+		case dnsmessage.TypeA, dnsmessage.TypeAAAA:
+			if ip := net.ParseIP(a); ip != nil {
+				a = ip.String()
+			}
+		}
+		n[i] = a
+	}
+	return n
+}
+
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// systemResolver returns the address of the first nameserver of
+// /etc/resolv.conf.
+func systemResolver() (string, error) {
+	f, err := os.Open(resolvConfPath)
+	if err != nil {
+		return "", fmt.Errorf("could not read system resolver: %v", err)
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return net.JoinHostPort(fields[1], "53"), nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", fmt.Errorf("could not read system resolver: %v", err)
+	}
+	return "", fmt.Errorf("no nameserver in %s", resolvConfPath)
+}
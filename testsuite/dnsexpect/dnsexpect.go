@@ -0,0 +1,234 @@
+package dnsexpect
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/blippar/aragorn/plugin"
+	"github.com/blippar/aragorn/testsuite"
+)
+
+var _ testsuite.Suite = (*Suite)(nil)
+
+// maxUDPSize is the size of the buffer receiving the UDP responses.
+const maxUDPSize = 64 * 1024
+
+// Suite describes a DNS test suite.
+type Suite struct {
+	tests []testsuite.Test
+}
+
+// New returns a Suite.
+func New(cfg *Config) (*Suite, error) {
+	tests, err := cfg.genTests()
+	if err != nil {
+		return nil, err
+	}
+	return &Suite{tests: tests}, nil
+}
+
+func (s *Suite) Tests() []testsuite.Test { return s.tests }
+
+type test struct {
+	name        string
+	description string
+	network     string
+	resolver    string
+	question    dnsmessage.Question
+
+	rcode    dnsmessage.RCode
+	answers  []string // Exact set of answers, if not nil.
+	contains []string
+	minTTL   time.Duration
+	maxTTL   time.Duration
+}
+
+// answer is an answer of the queried type.
+type answer struct {
+	value string
+	ttl   time.Duration
+}
+
+func (t *test) Name() string        { return t.name }
+func (t *test) Description() string { return t.description }
+
+func (t *test) Run(ctx context.Context, l testsuite.Logger) {
+	msg, err := t.query(ctx, t.network)
+	if err == nil && msg.Truncated && t.network == "udp" {
+		msg, err = t.query(ctx, "tcp")
+	}
+	if err != nil {
+		l.Errorf("could not query resolver: %v", err)
+		return
+	}
+	if msg.RCode != t.rcode {
+		l.Errorf("wrong response code (got %s; want %s)", rcodeName(msg.RCode), rcodeName(t.rcode))
+		return
+	}
+	answers, err := t.parseAnswers(msg.Answers)
+	if err != nil {
+		l.Errorf("could not parse answers: %v", err)
+		return
+	}
+	values := make([]string, len(answers))
+	for i, a := range answers {
+		values[i] = a.value
+	}
+	sort.Strings(values)
+	if t.answers != nil {
+		want := append([]string(nil), t.answers...)
+		sort.Strings(want)
+		if strings.Join(values, "\n") != strings.Join(want, "\n") {
+			l.Errorf("wrong answers (got %q; want %q)", values, want)
+		}
+	}
+	for _, c := range t.contains {
+		if i := sort.SearchStrings(values, c); i == len(values) || values[i] != c {
+			l.Errorf("missing answer %q (got %q)", c, values)
+		}
+	}
+	for _, a := range answers {
+		if t.minTTL > 0 && a.ttl < t.minTTL {
+			l.Errorf("answer %q: TTL too low (got %v; want at least %v)", a.value, a.ttl, t.minTTL)
+		}
+		if t.maxTTL > 0 && a.ttl > t.maxTTL {
+			l.Errorf("answer %q: TTL too high (got %v; want at most %v)", a.value, a.ttl, t.maxTTL)
+		}
+	}
+}
+
+// query sends the question to the resolver over network and returns the
+// response.
+func (t *test) query(ctx context.Context, network string) (*dnsmessage.Message, error) {
+	id := uint16(rand.Intn(1 << 16))
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	b.StartQuestions()
+	b.Question(t.question)
+	req, err := b.Finish()
+	if err != nil {
+		return nil, fmt.Errorf("could not build query: %v", err)
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, t.resolver)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	var resp []byte
+	if network == "tcp" {
+		resp, err = exchangeTCP(conn, req)
+	} else {
+		resp, err = exchangeUDP(conn, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var msg dnsmessage.Message
+	if err := msg.Unpack(resp); err != nil {
+		return nil, fmt.Errorf("could not parse response: %v", err)
+	}
+	if msg.ID != id || !msg.Response {
+		return nil, errors.New("response does not match the query")
+	}
+	return &msg, nil
+}
+
+func exchangeUDP(conn net.Conn, req []byte) ([]byte, error) {
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+	b := make([]byte, maxUDPSize)
+	n, err := conn.Read(b)
+	return b[:n], err
+}
+
+// exchangeTCP sends and receives the messages prefixed by their length as
+// described in RFC 1035 section 4.2.2.
+func exchangeTCP(conn net.Conn, req []byte) ([]byte, error) {
+	b := make([]byte, 2, 2+len(req))
+	binary.BigEndian.PutUint16(b, uint16(len(req)))
+	if _, err := conn.Write(append(b, req...)); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(conn, b); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(b))
+	_, err := io.ReadFull(conn, resp)
+	return resp, err
+}
+
+// parseAnswers returns the answers of the queried type formatted as in zone
+// files, e.g. "10 mail.example.com." for MX records.
+func (t *test) parseAnswers(rs []dnsmessage.Resource) ([]answer, error) {
+	var answers []answer
+	for _, r := range rs {
+		if r.Header.Type != t.question.Type {
+			continue
+		}
+		var v string
+		switch body := r.Body.(type) {
+		case *dnsmessage.AResource:
+			v = net.IP(body.A[:]).String()
+		case *dnsmessage.AAAAResource:
+			v = net.IP(body.AAAA[:]).String()
+		case *dnsmessage.CNAMEResource:
+			v = strings.ToLower(body.CNAME.String())
+		case *dnsmessage.MXResource:
+			v = fmt.Sprintf("%d %s", body.Pref, strings.ToLower(body.MX.String()))
+		case *dnsmessage.TXTResource:
+			v = strings.Join(body.TXT, "")
+		case *dnsmessage.SRVResource:
+			v = fmt.Sprintf("%d %d %d %s", body.Priority, body.Weight, body.Port, strings.ToLower(body.Target.String()))
+		default:
+			return nil, fmt.Errorf("unexpected %T for a %s record", r.Body, typeName(r.Header.Type))
+		}
+		answers = append(answers, answer{value: v, ttl: time.Duration(r.Header.TTL) * time.Second})
+	}
+	return answers, nil
+}
+
+func rcodeName(rc dnsmessage.RCode) string {
+	for name, c := range rcodes {
+		if c == rc {
+			return name
+		}
+	}
+	return fmt.Sprint(uint16(rc))
+}
+
+func typeName(typ dnsmessage.Type) string {
+	for name, t := range types {
+		if t == typ {
+			return name
+		}
+	}
+	return fmt.Sprint(uint16(typ))
+}
+
+func init() {
+	plugin.Register(&plugin.Registration{
+		Type:   plugin.TestSuitePlugin,
+		ID:     "DNS",
+		Config: (*Config)(nil),
+		InitFn: func(ctx *plugin.InitContext) (interface{}, error) {
+			cfg := ctx.Config.(*Config)
+			cfg.Path = ctx.Path
+			cfg.Root = ctx.Root
+			return New(cfg)
+		},
+	})
+}
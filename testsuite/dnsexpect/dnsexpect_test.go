@@ -0,0 +1,291 @@
+package dnsexpect
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/blippar/aragorn/pkg/util/json"
+)
+
+type mockLogger struct {
+	errs []string
+}
+
+func (tr *mockLogger) Error(args ...interface{}) {
+	tr.errs = append(tr.errs, fmt.Sprint(args...))
+}
+
+func (tr *mockLogger) Errorf(format string, args ...interface{}) {
+	tr.errs = append(tr.errs, fmt.Sprintf(format, args...))
+}
+
+func mustName(s string) dnsmessage.Name {
+	n, err := dnsmessage.NewName(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+func rr(name string, ttl uint32, body dnsmessage.ResourceBody) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: mustName(name), Class: dnsmessage.ClassINET, TTL: ttl},
+		Body:   body,
+	}
+}
+
+// zone is served by the DNS stand-in, indexed by question name and type.
+var zone = map[string][]dnsmessage.Resource{
+	"api.example.com. A": {
+		rr("api.example.com.", 300, &dnsmessage.CNAMEResource{CNAME: mustName("lb.example.com.")}),
+		rr("lb.example.com.", 60, &dnsmessage.AResource{A: [4]byte{10, 0, 0, 1}}),
+		rr("lb.example.com.", 60, &dnsmessage.AResource{A: [4]byte{10, 0, 0, 2}}),
+	},
+	"api.example.com. CNAME": {
+		rr("api.example.com.", 300, &dnsmessage.CNAMEResource{CNAME: mustName("LB.example.com.")}),
+	},
+	"v6.example.com. AAAA": {
+		rr("v6.example.com.", 60, &dnsmessage.AAAAResource{AAAA: [16]byte{15: 1}}),
+	},
+	"example.com. MX": {
+		rr("example.com.", 3600, &dnsmessage.MXResource{Pref: 10, MX: mustName("mail.example.com.")}),
+	},
+	"example.com. TXT": {
+		rr("example.com.", 3600, &dnsmessage.TXTResource{TXT: []string{"v=spf1 ", "-all"}}),
+	},
+	"_sip._tcp.example.com. SRV": {
+		rr("_sip._tcp.example.com.", 3600, &dnsmessage.SRVResource{Priority: 10, Weight: 5, Port: 5060, Target: mustName("sip.example.com.")}),
+	},
+	"big.example.com. TXT": {
+		rr("big.example.com.", 60, &dnsmessage.TXTResource{TXT: []string{"tcp only"}}),
+	},
+}
+
+// respond returns the response to the query. Over UDP, big.example.com is
+// truncated.
+func respond(t *testing.T, req []byte, udp bool) []byte {
+	var p dnsmessage.Parser
+	h, err := p.Start(req)
+	if err != nil {
+		t.Errorf("could not parse query: %v", err)
+		return nil
+	}
+	q, err := p.Question()
+	if err != nil {
+		t.Errorf("could not parse question: %v", err)
+		return nil
+	}
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: h.ID, Response: true, RecursionDesired: h.RecursionDesired},
+		Questions: []dnsmessage.Question{q},
+	}
+	key := fmt.Sprintf("%s %s", q.Name, typeName(q.Type))
+	answers, ok := zone[key]
+	switch {
+	case !ok:
+		msg.RCode = dnsmessage.RCodeNameError
+	case udp && q.Name.String() == "big.example.com.":
+		msg.Truncated = true
+	default:
+		msg.Answers = answers
+	}
+	b, err := msg.Pack()
+	if err != nil {
+		t.Errorf("could not pack response: %v", err)
+	}
+	return b
+}
+
+// newServer starts a DNS stand-in serving zone over UDP and TCP and returns
+// its address.
+func newServer(t *testing.T) (string, func()) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	l, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		pc.Close()
+		t.Fatalf("could not listen: %v", err)
+	}
+	go func() {
+		b := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			pc.WriteTo(respond(t, b[:n], true), addr)
+		}
+	}()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			var n uint16
+			if err := binary.Read(conn, binary.BigEndian, &n); err == nil {
+				req := make([]byte, n)
+				if _, err := io.ReadFull(conn, req); err == nil {
+					resp := respond(t, req, false)
+					binary.Write(conn, binary.BigEndian, uint16(len(resp)))
+					conn.Write(resp)
+				}
+			}
+			conn.Close()
+		}
+	}()
+	return pc.LocalAddr().String(), func() {
+		pc.Close()
+		l.Close()
+	}
+}
+
+func TestSuiteRunTest(t *testing.T) {
+	addr, stop := newServer(t)
+	defer stop()
+	tt := []struct {
+		name string
+		test TestConfig
+		errs []string
+	}{
+		{
+			name: "A through CNAME",
+			test: TestConfig{
+				Question: "api.example.com",
+				Expect: ExpectConfig{
+					Answers: []string{"10.0.0.2", "10.0.0.1"},
+					MaxTTL:  json.Duration(time.Minute),
+				},
+			},
+		},
+		{
+			name: "CNAME",
+			test: TestConfig{
+				Question: "api.example.com",
+				Type:     "CNAME",
+				Expect: ExpectConfig{
+					Answers: []string{"lb.example.com"},
+					MinTTL:  json.Duration(time.Hour),
+				},
+			},
+			errs: []string{`answer "lb.example.com.": TTL too low (got 5m0s; want at least 1h0m0s)`},
+		},
+		{
+			name: "AAAA",
+			test: TestConfig{
+				Question: "v6.example.com",
+				Type:     "aaaa",
+				Expect:   ExpectConfig{Answers: []string{"0:0::1"}},
+			},
+		},
+		{
+			name: "MX",
+			test: TestConfig{
+				Question: "example.com",
+				Type:     "MX",
+				Expect:   ExpectConfig{Answers: []string{"20 mail.example.com."}},
+			},
+			errs: []string{`wrong answers (got ["10 mail.example.com."]; want ["20 mail.example.com."])`},
+		},
+		{
+			name: "TXT",
+			test: TestConfig{
+				Question: "example.com",
+				Type:     "TXT",
+				Expect:   ExpectConfig{Contains: []string{"v=spf1 -all", "google-site-verification"}},
+			},
+			errs: []string{`missing answer "google-site-verification" (got ["v=spf1 -all"])`},
+		},
+		{
+			name: "SRV",
+			test: TestConfig{
+				Question: "_sip._tcp.example.com",
+				Type:     "SRV",
+				Expect:   ExpectConfig{Answers: []string{"10 5 5060 sip.example.com"}},
+			},
+		},
+		{
+			name: "truncated",
+			test: TestConfig{
+				Question: "big.example.com",
+				Type:     "TXT",
+				Expect:   ExpectConfig{Answers: []string{"tcp only"}},
+			},
+		},
+		{
+			name: "NXDOMAIN",
+			test: TestConfig{
+				Question: "old.example.com",
+				Expect:   ExpectConfig{RCode: "NXDOMAIN"},
+			},
+		},
+		{
+			name: "wrong rcode",
+			test: TestConfig{Question: "old.example.com"},
+			errs: []string{"wrong response code (got NXDOMAIN; want NOERROR)"},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test.Name = tc.name
+			cfg := &Config{
+				Resolver: addr,
+				Tests:    []TestConfig{tc.test},
+			}
+			suite, err := New(cfg)
+			if err != nil {
+				t.Fatalf("can't create suite: %v", err)
+			}
+			l := &mockLogger{}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			suite.Tests()[0].Run(ctx, l)
+			if !cmp.Equal(l.errs, tc.errs) {
+				t.Fatalf("wrong errors (got %q; want %q)", l.errs, tc.errs)
+			}
+		})
+	}
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	cfg := &Config{
+		Resolver: "127.0.0.1",
+		Tests: []TestConfig{
+			{
+				Name: "test",
+				Type: "PTR",
+				Expect: ExpectConfig{
+					RCode:    "NOPE",
+					Answers:  []string{},
+					Contains: []string{},
+					MinTTL:   json.Duration(time.Hour),
+					MaxTTL:   json.Duration(time.Minute),
+				},
+			},
+		},
+	}
+	want := "test \"test\":\n" +
+		"- resolver: address 127.0.0.1: missing port in address\n" +
+		"- question: a domain name is required\n" +
+		"- type: unknown record type \"PTR\"\n" +
+		"- expect: unknown response code \"NOPE\"\n" +
+		"- expect: contains can't be set with answers\n" +
+		"- expect: minTTL can't be greater than maxTTL"
+	_, err := New(cfg)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if diff := cmp.Diff(want, err.Error()); diff != "" {
+		t.Fatalf("wrong error (-want +got):\n%s", diff)
+	}
+}
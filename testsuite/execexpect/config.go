@@ -0,0 +1,178 @@
+package execexpect
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blippar/aragorn/pkg/util/json"
+	"github.com/blippar/aragorn/testsuite"
+)
+
+type Config struct {
+	Path  string  `json:"path,omitempty"`
+	Root  string  `json:"root,omitempty"`
+	Base  Base    `json:"base,omitempty"`
+	Tests []*Test `json:"tests,omitempty"`
+}
+
+type Base struct {
+	Dir     string            `json:"dir,omitempty"`     // Working directory of the commands (default: directory of the suite file).
+	Env     map[string]string `json:"env,omitempty"`     // Environment variables added to the environment of aragorn.
+	Timeout json.Duration     `json:"timeout,omitempty"` // Time after which the commands are killed.
+}
+
+type Test struct {
+	Name    string            `json:"name,omitempty"`
+	Command string            `json:"command,omitempty"` // Name of the executable in $PATH or path relative to dir.
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`     // Will be merged with the base env.
+	Dir     string            `json:"dir,omitempty"`     // If set, will overwrite the base dir.
+	Stdin   string            `json:"stdin,omitempty"`   // Data written to the standard input.
+	Timeout json.Duration     `json:"timeout,omitempty"` // If set, will overwrite the base timeout.
+	Expect  Expect            `json:"expect,omitempty"`
+}
+
+type Expect struct {
+	ExitCode    int           `json:"exitCode,omitempty"`
+	Stdout      *Output       `json:"stdout,omitempty"`
+	Stderr      *Output       `json:"stderr,omitempty"`
+	MaxDuration json.Duration `json:"maxDuration,omitempty"` // Maximum time taken by the command.
+}
+
+// Output describes the expected content of an output of the command.
+type Output struct {
+	Text       *string                `json:"text,omitempty"`       // Exact content.
+	Regex      string                 `json:"regex,omitempty"`      // Regular expression the content must match.
+	JSONValues map[string]interface{} `json:"jsonValues,omitempty"` // Required JSON values of the content.
+}
+
+func (*Config) Example() interface{} {
+	empty := ""
+	return &Config{
+		Base: Base{
+			Env:     map[string]string{"PGHOST": "localhost"},
+			Timeout: json.Duration(time.Minute),
+		},
+		Tests: []*Test{
+			{
+				Name:    "Migrations up to date",
+				Command: "./migrate",
+				Args:    []string{"-dry-run", "-format", "json"},
+				Expect: Expect{
+					Stdout: &Output{
+						JSONValues: map[string]interface{}{"pending": 0},
+					},
+					Stderr:      &Output{Text: &empty},
+					MaxDuration: json.Duration(10 * time.Second),
+				},
+			},
+		},
+	}
+}
+
+func (cfg *Config) genTests() ([]testsuite.Test, error) {
+	if len(cfg.Tests) == 0 {
+		return nil, errors.New("a test suite must contain at least one test")
+	}
+	ts := make([]testsuite.Test, len(cfg.Tests))
+	var errs []string
+	for i, testcfg := range cfg.Tests {
+		t, err := testcfg.prepare(cfg)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("test %q:\n%v", testcfg.Name, err))
+		}
+		ts[i] = t
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "\n"))
+	}
+	return ts, nil
+}
+
+func (t *Test) prepare(cfg *Config) (*test, error) {
+	test := &test{
+		name:        t.Name,
+		command:     t.Command,
+		args:        t.Args,
+		dir:         cfg.getFilePath(cfg.Base.Dir),
+		stdin:       t.Stdin,
+		timeout:     time.Duration(cfg.Base.Timeout),
+		exitCode:    t.Expect.ExitCode,
+		maxDuration: time.Duration(t.Expect.MaxDuration),
+	}
+	test.description = strings.Join(append([]string{t.Command}, t.Args...), " ")
+	if t.Dir != "" {
+		test.dir = cfg.getFilePath(t.Dir)
+	}
+	if t.Timeout != 0 {
+		test.timeout = time.Duration(t.Timeout)
+	}
+	test.env = mergeEnv(cfg.Base.Env, t.Env)
+
+	var errs []string
+	if t.Command == "" {
+		errs = append(errs, "- command: a command is required")
+	}
+	if t.Expect.ExitCode < 0 {
+		errs = append(errs, "- expect: exitCode can't be negative")
+	}
+	var err error
+	if test.stdout, err = t.Expect.Stdout.prepare(); err != nil {
+		errs = append(errs, fmt.Sprintf("- expect: stdout: %v", err))
+	}
+	if test.stderr, err = t.Expect.Stderr.prepare(); err != nil {
+		errs = append(errs, fmt.Sprintf("- expect: stderr: %v", err))
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "\n"))
+	}
+	return test, nil
+}
+
+func (o *Output) prepare() (*output, error) {
+	if o == nil {
+		return nil, nil
+	}
+	out := &output{text: o.Text, jsonValues: o.JSONValues}
+	if o.Text == nil && o.Regex == "" && o.JSONValues == nil {
+		return nil, errors.New("at least one of text, regex or jsonValues must be set")
+	}
+	if o.Regex != "" {
+		re, err := regexp.Compile(o.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %v", err)
+		}
+		out.regex = re
+	}
+	return out, nil
+}
+
+// mergeEnv returns the variables of base overwritten by the ones of env in the
+// KEY=value form, sorted by key.
+func mergeEnv(base, env map[string]string) []string {
+	vars := make(map[string]string, len(base)+len(env))
+	for k, v := range base {
+		vars[k] = v
+	}
+	for k, v := range env {
+		vars[k] = v
+	}
+	kvs := make([]string, 0, len(vars))
+	for k, v := range vars {
+		kvs = append(kvs, k+"="+v)
+	}
+	sort.Strings(kvs)
+	return kvs
+}
+
+func (cfg *Config) getFilePath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(cfg.Root, path)
+}
@@ -0,0 +1,159 @@
+package execexpect
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/blippar/aragorn/pkg/util/json"
+	"github.com/blippar/aragorn/plugin"
+	"github.com/blippar/aragorn/testsuite"
+)
+
+var _ testsuite.Suite = (*Suite)(nil)
+
+// Suite describes an EXEC test suite.
+type Suite struct {
+	tests []testsuite.Test
+}
+
+// New returns a Suite.
+func New(cfg *Config) (*Suite, error) {
+	tests, err := cfg.genTests()
+	if err != nil {
+		return nil, err
+	}
+	return &Suite{tests: tests}, nil
+}
+
+func (s *Suite) Tests() []testsuite.Test { return s.tests }
+
+type test struct {
+	name        string
+	description string
+	command     string
+	args        []string
+	env         []string // Added to the environment of aragorn.
+	dir         string
+	stdin       string
+	timeout     time.Duration
+
+	exitCode    int
+	stdout      *output
+	stderr      *output
+	maxDuration time.Duration
+}
+
+// output holds the expectations on an output of the command.
+type output struct {
+	text       *string
+	regex      *regexp.Regexp
+	jsonValues map[string]interface{}
+}
+
+func (t *test) Name() string        { return t.name }
+func (t *test) Description() string { return t.description }
+
+func (t *test) Run(ctx context.Context, l testsuite.Logger) {
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, t.command, t.args...)
+	cmd.Env = append(os.Environ(), t.env...)
+	cmd.Dir = t.dir
+	cmd.Stdin = strings.NewReader(t.stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	start := time.Now()
+	err := cmd.Run()
+	d := time.Since(start)
+	if ctx.Err() == context.DeadlineExceeded {
+		l.Errorf("command killed: %v", ctx.Err())
+		return
+	}
+	exitCode := 0
+	if err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			l.Errorf("could not run command: %v", err)
+			return
+		}
+		status, ok := exitErr.Sys().(syscall.WaitStatus)
+		if !ok || !status.Exited() {
+			l.Errorf("command failed: %v", err)
+			return
+		}
+		exitCode = status.ExitStatus()
+	}
+	if exitCode != t.exitCode {
+		l.Errorf("wrong exit code (got %d; want %d)", exitCode, t.exitCode)
+	}
+	if t.stdout != nil {
+		t.stdout.check("stdout", stdout.Bytes(), l)
+	}
+	if t.stderr != nil {
+		t.stderr.check("stderr", stderr.Bytes(), l)
+	}
+	if t.maxDuration > 0 && d > t.maxDuration {
+		l.Errorf("command too slow (got %v; want at most %v)", d.Round(time.Millisecond), t.maxDuration)
+	}
+}
+
+// check checks that b, the content of the output called name, matches the
+// expectations.
+func (o *output) check(name string, b []byte, l testsuite.Logger) {
+	if o.text != nil && string(b) != *o.text {
+		l.Errorf("%s: wrong output (got %q; want %q)", name, b, *o.text)
+	}
+	if o.regex != nil && !o.regex.Match(b) {
+		l.Errorf("%s: output does not match %q (got %q)", name, o.regex, b)
+	}
+	if o.jsonValues == nil {
+		return
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		l.Errorf("%s: could not decode json output: %v", name, err)
+		return
+	}
+	queries := make([]string, 0, len(o.jsonValues))
+	for query := range o.jsonValues {
+		queries = append(queries, query)
+	}
+	sort.Strings(queries)
+	for _, query := range queries {
+		val, err := json.Query(query, v)
+		if err != nil {
+			l.Errorf("%s: could not get value for query %q: %v", name, query, err)
+			continue
+		}
+		if expected := o.jsonValues[query]; !cmp.Equal(val, expected) {
+			l.Errorf("%s: wrong value for query %q (got %v; want %v)", name, query, val, expected)
+		}
+	}
+}
+
+func init() {
+	plugin.Register(&plugin.Registration{
+		Type:   plugin.TestSuitePlugin,
+		ID:     "EXEC",
+		Config: (*Config)(nil),
+		InitFn: func(ctx *plugin.InitContext) (interface{}, error) {
+			cfg := ctx.Config.(*Config)
+			cfg.Path = ctx.Path
+			cfg.Root = ctx.Root
+			return New(cfg)
+		},
+	})
+}
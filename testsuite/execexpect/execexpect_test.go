@@ -0,0 +1,184 @@
+package execexpect
+
+import (
+	"context"
+	gojson "encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/blippar/aragorn/pkg/util/json"
+)
+
+type mockLogger struct {
+	errs []string
+}
+
+func (tr *mockLogger) Error(args ...interface{}) {
+	tr.errs = append(tr.errs, fmt.Sprint(args...))
+}
+
+func (tr *mockLogger) Errorf(format string, args ...interface{}) {
+	tr.errs = append(tr.errs, fmt.Sprintf(format, args...))
+}
+
+func text(s string) *string { return &s }
+
+func TestSuiteRunTest(t *testing.T) {
+	tt := []struct {
+		name string
+		test Test
+		errs []string
+	}{
+		{
+			name: "stdout",
+			test: Test{
+				Command: "echo",
+				Args:    []string{"hello", "world"},
+				Expect:  Expect{Stdout: &Output{Text: text("hello world\n")}, Stderr: &Output{Text: text("")}},
+			},
+		},
+		{
+			name: "env and stdin",
+			test: Test{
+				Command: "sh",
+				Args:    []string{"-c", `printf "%s %s" "$GREETING" "$(cat)"`},
+				Env:     map[string]string{"GREETING": "hello"},
+				Stdin:   "world",
+				Expect:  Expect{Stdout: &Output{Text: text("hello world")}},
+			},
+		},
+		{
+			name: "script in dir",
+			test: Test{
+				Command: "./hello.sh",
+				Expect:  Expect{Stdout: &Output{Regex: "^hello from testdata\n$"}},
+			},
+		},
+		{
+			name: "json",
+			test: Test{
+				Command: "echo",
+				Args:    []string{`{"pending": 0, "applied": ["init", "users"]}`},
+				Expect: Expect{Stdout: &Output{JSONValues: map[string]interface{}{
+					"pending":   gojson.Number("0"),
+					"applied.1": "orders",
+				}}},
+			},
+			errs: []string{`stdout: wrong value for query "applied.1" (got users; want orders)`},
+		},
+		{
+			name: "invalid json",
+			test: Test{
+				Command: "echo",
+				Args:    []string{"OK"},
+				Expect:  Expect{Stdout: &Output{JSONValues: map[string]interface{}{"ok": true}}},
+			},
+			errs: []string{"stdout: could not decode json output: invalid character 'O' looking for beginning of value\n" +
+				"Error at line 1, column 1 (file offset 1):\n    1: O\n      ^\n"},
+		},
+		{
+			name: "exit code",
+			test: Test{
+				Command: "sh",
+				Args:    []string{"-c", "echo 'warning: disk almost full' >&2; exit 3"},
+				Expect:  Expect{ExitCode: 3, Stderr: &Output{Regex: "^warning: "}},
+			},
+		},
+		{
+			name: "wrong exit code and output",
+			test: Test{
+				Command: "sh",
+				Args:    []string{"-c", "echo 'error: no space left' >&2; exit 1"},
+				Expect:  Expect{Stderr: &Output{Text: text(""), Regex: "^warning: "}},
+			},
+			errs: []string{
+				"wrong exit code (got 1; want 0)",
+				`stderr: wrong output (got "error: no space left\n"; want "")`,
+				`stderr: output does not match "^warning: " (got "error: no space left\n")`,
+			},
+		},
+		{
+			name: "timeout",
+			test: Test{
+				Command: "sleep",
+				Args:    []string{"5"},
+				Timeout: json.Duration(50 * time.Millisecond),
+			},
+			errs: []string{"command killed: context deadline exceeded"},
+		},
+		{
+			name: "unknown command",
+			test: Test{Command: "aragorn-unknown-command"},
+			errs: []string{`could not run command: exec: "aragorn-unknown-command": executable file not found in $PATH`},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test.Name = tc.name
+			cfg := &Config{
+				Root:  "testdata",
+				Tests: []*Test{&tc.test},
+			}
+			suite, err := New(cfg)
+			if err != nil {
+				t.Fatalf("can't create suite: %v", err)
+			}
+			l := &mockLogger{}
+			suite.Tests()[0].Run(context.Background(), l)
+			if !cmp.Equal(l.errs, tc.errs) {
+				t.Fatalf("wrong errors (got %q; want %q)", l.errs, tc.errs)
+			}
+		})
+	}
+}
+
+func TestSuiteRunTestMaxDuration(t *testing.T) {
+	cfg := &Config{
+		Tests: []*Test{{
+			Name:    "slow",
+			Command: "sleep",
+			Args:    []string{"0.1"},
+			Expect:  Expect{MaxDuration: json.Duration(time.Millisecond)},
+		}},
+	}
+	suite, err := New(cfg)
+	if err != nil {
+		t.Fatalf("can't create suite: %v", err)
+	}
+	l := &mockLogger{}
+	suite.Tests()[0].Run(context.Background(), l)
+	if len(l.errs) != 1 || !strings.HasPrefix(l.errs[0], "command too slow (got ") {
+		t.Fatalf("wrong errors (got %q; want a single too slow error)", l.errs)
+	}
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	cfg := &Config{
+		Tests: []*Test{
+			{
+				Name: "test",
+				Expect: Expect{
+					ExitCode: -1,
+					Stdout:   &Output{},
+					Stderr:   &Output{Regex: "("},
+				},
+			},
+		},
+	}
+	want := "test \"test\":\n" +
+		"- command: a command is required\n" +
+		"- expect: exitCode can't be negative\n" +
+		"- expect: stdout: at least one of text, regex or jsonValues must be set\n" +
+		"- expect: stderr: invalid regex: error parsing regexp: missing closing ): `(`"
+	_, err := New(cfg)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if diff := cmp.Diff(want, err.Error()); diff != "" {
+		t.Fatalf("wrong error (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,198 @@
+package graphqlexpect
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/vektah/gqlparser"
+	"github.com/vektah/gqlparser/ast"
+	"golang.org/x/oauth2/clientcredentials"
+
+	"github.com/blippar/aragorn/pkg/auth"
+	"github.com/blippar/aragorn/pkg/util/json"
+	"github.com/blippar/aragorn/testsuite"
+	"github.com/blippar/aragorn/testsuite/httpexpect"
+)
+
+type Config struct {
+	Path       string                    `json:"path,omitempty"`
+	Root       string                    `json:"root,omitempty"`
+	URL        string                    `json:"url,omitempty"`    // URL of the GraphQL endpoint.
+	Header     testsuite.Header          `json:"header,omitempty"` // Base set of headers added to all requests.
+	OAUTH2     *clientcredentials.Config `json:"oauth2,omitempty"`
+	Auth       *auth.Config              `json:"auth,omitempty"`
+	Insecure   bool                      `json:"insecure,omitempty"`
+	TLS        *httpexpect.TLSConfig     `json:"tls,omitempty"`
+	SchemaPath string                    `json:"schemaPath,omitempty"` // Schema in the GraphQL schema language or introspection result (.json).
+	Introspect bool                      `json:"introspect,omitempty"` // Fetch the schema from the endpoint when the suite is loaded.
+	Tests      []TestConfig              `json:"tests,omitempty"`
+
+	IntrospectTimeout json.Duration `json:"introspectTimeout,omitempty"` // Time to fetch the schema (default: 10s).
+}
+
+type TestConfig struct {
+	Name    string        `json:"name,omitempty"`
+	Request RequestConfig `json:"request,omitempty"`
+	Expect  ExpectConfig  `json:"expect,omitempty"`
+}
+
+type RequestConfig struct {
+	Query         string                 `json:"query,omitempty"`
+	QueryPath     string                 `json:"queryPath,omitempty"` // File containing the query. Exclusive with Query.
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	Header        testsuite.Header       `json:"header,omitempty"`
+}
+
+type ExpectConfig struct {
+	Data       interface{}            `json:"data,omitempty"`       // Exact data to match.
+	JSONValues map[string]interface{} `json:"jsonValues,omitempty"` // Required JSON values of the data. Exclusive with Data.
+	Errors     []ExpectError          `json:"errors,omitempty"`     // Expected GraphQL errors. By default, no error is expected.
+}
+
+// ExpectError describes an expected GraphQL error. Only the fields that are
+// set are checked.
+type ExpectError struct {
+	Message string `json:"message,omitempty"`
+	Path    string `json:"path,omitempty"` // Path of the field in error, e.g. user.friends.0.name.
+}
+
+func (*Config) Example() interface{} {
+	return &Config{
+		URL:        "http://localhost:8000/graphql",
+		Introspect: true,
+		Tests: []TestConfig{
+			{
+				Name: "User",
+				Request: RequestConfig{
+					Query:     "query User($id: ID!) { user(id: $id) { name } }",
+					Variables: map[string]interface{}{"id": "1"},
+				},
+				Expect: ExpectConfig{
+					JSONValues: map[string]interface{}{"user.name": "John Doe"},
+				},
+			},
+		},
+	}
+}
+
+func (cfg *Config) genTests(client *http.Client, signer auth.Signer) ([]testsuite.Test, error) {
+	if len(cfg.Tests) == 0 {
+		return nil, errors.New("a test suite must contain at least one test")
+	}
+	var schema *ast.Schema
+	switch {
+	case cfg.SchemaPath != "" && cfg.Introspect:
+		return nil, errors.New("only one of schemaPath or introspect can be set at once")
+	case cfg.SchemaPath != "":
+		var err error
+		if schema, err = loadSchemaFile(cfg.getFilePath(cfg.SchemaPath)); err != nil {
+			return nil, fmt.Errorf("could not load schema: %v", err)
+		}
+	case cfg.Introspect:
+		var err error
+		if schema, err = introspect(client, signer, cfg); err != nil {
+			return nil, fmt.Errorf("could not introspect schema: %v", err)
+		}
+	}
+	tests := make([]testsuite.Test, len(cfg.Tests))
+	var errs []string
+	for i, tcfg := range cfg.Tests {
+		t, err := tcfg.prepare(cfg, client, signer, schema)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("test %q:\n%v", tcfg.Name, err))
+		}
+		tests[i] = t
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "\n"))
+	}
+	return tests, nil
+}
+
+func (tcfg *TestConfig) prepare(cfg *Config, client *http.Client, signer auth.Signer, schema *ast.Schema) (*test, error) {
+	t := &test{
+		name:        tcfg.Name,
+		description: "GRAPHQL " + cfg.URL,
+		client:      client,
+		signer:      signer,
+		data:        tcfg.Expect.Data,
+		jsonValues:  tcfg.Expect.JSONValues,
+		errors:      tcfg.Expect.Errors,
+	}
+	var errs []string
+	query := tcfg.Request.Query
+	if tcfg.Request.QueryPath != "" {
+		if query != "" {
+			errs = append(errs, "- request: only one of query or queryPath can be set at once")
+		}
+		b, err := ioutil.ReadFile(cfg.getFilePath(tcfg.Request.QueryPath))
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("- request: could not read query: %v", err))
+		}
+		query = string(b)
+	}
+	if query == "" && len(errs) == 0 {
+		errs = append(errs, "- request: a query is required")
+	}
+	if schema != nil && query != "" {
+		if err := validateQuery(schema, query, tcfg.Request.OperationName); err != nil {
+			errs = append(errs, fmt.Sprintf("- request: invalid query: %v", err))
+		}
+	}
+	if tcfg.Expect.Data != nil && tcfg.Expect.JSONValues != nil {
+		errs = append(errs, "- expect: jsonValues can't be set with data")
+	}
+	body, err := json.Marshal(&request{
+		Query:         query,
+		OperationName: tcfg.Request.OperationName,
+		Variables:     tcfg.Request.Variables,
+	})
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("- request: could not encode request: %v", err))
+	}
+	t.body = body
+	if req, err := newRequest(cfg, tcfg.Request.Header, body); err != nil {
+		errs = append(errs, fmt.Sprintf("- request: could not create HTTP request: %v", err))
+	} else {
+		t.req = req
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "\n"))
+	}
+	if op := tcfg.Request.OperationName; op != "" {
+		t.description += " " + op
+	}
+	return t, nil
+}
+
+// validateQuery validates the query against the schema and checks that the
+// operation exists.
+func validateQuery(schema *ast.Schema, query, operationName string) error {
+	doc, errs := gqlparser.LoadQuery(schema, query)
+	if len(errs) > 0 {
+		msgs := make([]string, len(errs))
+		for i, err := range errs {
+			msgs[i] = err.Error()
+		}
+		return errors.New(strings.Join(msgs, "; "))
+	}
+	if operationName != "" && doc.Operations.ForName(operationName) == nil {
+		return fmt.Errorf("unknown operation %q", operationName)
+	}
+	if operationName == "" && len(doc.Operations) > 1 {
+		return errors.New("operationName is required for documents with several operations")
+	}
+	return nil
+}
+
+func (cfg *Config) getFilePath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(cfg.Root, path)
+}
@@ -0,0 +1,274 @@
+package graphqlexpect
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	gojson "encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vektah/gqlparser/ast"
+	"golang.org/x/oauth2"
+
+	"github.com/blippar/aragorn/pkg/auth"
+	"github.com/blippar/aragorn/pkg/util/json"
+	"github.com/blippar/aragorn/plugin"
+	"github.com/blippar/aragorn/testsuite"
+)
+
+var _ testsuite.Suite = (*Suite)(nil)
+
+const defaultIntrospectTimeout = 10 * time.Second
+
+// Suite describes a GraphQL test suite.
+type Suite struct {
+	tests []testsuite.Test
+}
+
+// New returns a Suite. If the schema is introspected, the endpoint is
+// requested.
+func New(cfg *Config) (*Suite, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("URL is required")
+	}
+	if _, err := url.Parse(cfg.URL); err != nil {
+		return nil, fmt.Errorf("URL is invalid: %v", err)
+	}
+	if cfg.OAUTH2 != nil && cfg.Auth != nil {
+		return nil, errors.New("only one of oauth2 or auth can be set at once")
+	}
+	client := &http.Client{}
+	if cfg.Insecure || cfg.TLS != nil {
+		tlsCfg := &tls.Config{}
+		if cfg.TLS != nil {
+			var err error
+			if tlsCfg, err = cfg.TLS.ClientConfig(cfg.Root); err != nil {
+				return nil, fmt.Errorf("tls: %v", err)
+			}
+		}
+		tlsCfg.InsecureSkipVerify = cfg.Insecure
+		client.Transport = &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsCfg}
+	}
+	var signer auth.Signer
+	if cfg.Auth != nil {
+		var err error
+		if signer, err = cfg.Auth.NewSigner(cfg.Root, &http.Client{Transport: client.Transport}); err != nil {
+			return nil, fmt.Errorf("auth: %v", err)
+		}
+	}
+	if cfg.OAUTH2 != nil {
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, client)
+		client = cfg.OAUTH2.Client(ctx)
+	}
+	tests, err := cfg.genTests(client, signer)
+	if err != nil {
+		return nil, err
+	}
+	return &Suite{tests: tests}, nil
+}
+
+func (s *Suite) Tests() []testsuite.Test { return s.tests }
+
+// request is the body of a GraphQL request.
+type request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// response is the body of a GraphQL response.
+type response struct {
+	Data   gojson.RawMessage `json:"data"`
+	Errors []responseError   `json:"errors"`
+}
+
+type responseError struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path"`
+}
+
+func (e *responseError) path() string {
+	p := make([]string, len(e.Path))
+	for i, v := range e.Path {
+		p[i] = fmt.Sprint(v)
+	}
+	return strings.Join(p, ".")
+}
+
+func newRequest(cfg *Config, header testsuite.Header, body []byte) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodPost, cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	for k, v := range testsuite.MergeHeaders(cfg.Header, header) {
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
+
+// do sends the GraphQL request and decodes its response. Transport errors,
+// including unexpected status codes, are returned as errors.
+func do(ctx context.Context, client *http.Client, signer auth.Signer, req *http.Request, body []byte) (*response, error) {
+	req = req.WithContext(ctx)
+	h := make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		h[k] = append([]string(nil), v...)
+	}
+	req.Header = h
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if signer != nil {
+		if err := signer.Sign(req, body); err != nil {
+			return nil, fmt.Errorf("could not authenticate request: %v", err)
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not do HTTP request: %v", err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read body: %v", err)
+	}
+	// Some servers answer the requests with GraphQL errors with a 4XX status.
+	var gr response
+	if err := gojson.Unmarshal(b, &gr); err != nil || (gr.Data == nil && gr.Errors == nil) {
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("wrong http status code (got %d; expected %d)", resp.StatusCode, http.StatusOK)
+		}
+		if err == nil {
+			err = errors.New("missing data and errors")
+		}
+		return nil, fmt.Errorf("could not decode GraphQL response: %v", err)
+	}
+	return &gr, nil
+}
+
+// introspect requests the schema of the endpoint.
+func introspect(client *http.Client, signer auth.Signer, cfg *Config) (*ast.Schema, error) {
+	body, _ := json.Marshal(&request{Query: introspectionQuery})
+	req, err := newRequest(cfg, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	timeout := defaultIntrospectTimeout
+	if cfg.IntrospectTimeout > 0 {
+		timeout = time.Duration(cfg.IntrospectTimeout)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	resp, err := do(ctx, client, signer, req, body)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Errors) > 0 {
+		return nil, errors.New(resp.Errors[0].Message)
+	}
+	var in introspection
+	if err := gojson.Unmarshal(resp.Data, &in); err != nil {
+		return nil, err
+	}
+	return in.schema()
+}
+
+type test struct {
+	name        string
+	description string
+
+	client *http.Client
+	req    *http.Request
+	body   []byte
+	signer auth.Signer // Authenticates req, if set.
+
+	data       interface{}
+	jsonValues map[string]interface{}
+	errors     []ExpectError
+}
+
+func (t *test) Name() string        { return t.name }
+func (t *test) Description() string { return t.description }
+
+func (t *test) Run(ctx context.Context, l testsuite.Logger) {
+	resp, err := do(ctx, t.client, t.signer, t.req, t.body)
+	if err != nil {
+		l.Error(err)
+		return
+	}
+	t.checkErrors(resp.Errors, l)
+	if t.data == nil && t.jsonValues == nil {
+		return
+	}
+	var data interface{}
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		l.Errorf("could not decode data: %v", err)
+		return
+	}
+	if t.data != nil && !cmp.Equal(t.data, data) {
+		l.Error("data does not match expected data")
+	}
+	queries := make([]string, 0, len(t.jsonValues))
+	for query := range t.jsonValues {
+		queries = append(queries, query)
+	}
+	sort.Strings(queries)
+	for _, query := range queries {
+		val, err := json.Query(query, data)
+		if err != nil {
+			l.Errorf("could not get value for query %q: %v", query, err)
+			continue
+		}
+		if expected := t.jsonValues[query]; !cmp.Equal(val, expected) {
+			l.Errorf("wrong value for query %q (got %v; want %v)", query, val, expected)
+		}
+	}
+}
+
+func (t *test) checkErrors(errs []responseError, l testsuite.Logger) {
+	if t.errors == nil {
+		for _, e := range errs {
+			if p := e.path(); p != "" {
+				l.Errorf("unexpected GraphQL error at %s: %s", p, e.Message)
+			} else {
+				l.Errorf("unexpected GraphQL error: %s", e.Message)
+			}
+		}
+		return
+	}
+	if len(errs) != len(t.errors) {
+		l.Errorf("wrong number of GraphQL errors (got %d; want %d)", len(errs), len(t.errors))
+		return
+	}
+	for i, want := range t.errors {
+		got := errs[i]
+		if want.Message != "" && got.Message != want.Message {
+			l.Errorf("wrong message for GraphQL error %d (got %q; want %q)", i, got.Message, want.Message)
+		}
+		if want.Path != "" && got.path() != want.Path {
+			l.Errorf("wrong path for GraphQL error %d (got %q; want %q)", i, got.path(), want.Path)
+		}
+	}
+}
+
+func init() {
+	plugin.Register(&plugin.Registration{
+		Type:   plugin.TestSuitePlugin,
+		ID:     "GRAPHQL",
+		Config: (*Config)(nil),
+		InitFn: func(ctx *plugin.InitContext) (interface{}, error) {
+			cfg := ctx.Config.(*Config)
+			cfg.Path = ctx.Path
+			cfg.Root = ctx.Root
+			return New(cfg)
+		},
+	})
+}
@@ -0,0 +1,200 @@
+package graphqlexpect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	ujson "github.com/blippar/aragorn/pkg/util/json"
+)
+
+type mockLogger struct {
+	errs []string
+}
+
+func (tr *mockLogger) Error(args ...interface{}) {
+	tr.errs = append(tr.errs, fmt.Sprint(args...))
+}
+
+func (tr *mockLogger) Errorf(format string, args ...interface{}) {
+	tr.errs = append(tr.errs, fmt.Sprintf(format, args...))
+}
+
+// newServer starts a GraphQL server answering the introspection query with
+// testdata/introspection.json and the other queries by operation name.
+func newServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("could not decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.Contains(req.Query, "__schema"):
+			b, _ := ioutil.ReadFile("testdata/introspection.json")
+			w.Write(b)
+		case req.OperationName == "User" || strings.HasPrefix(req.Query, "query User"):
+			fmt.Fprintf(w, `{"data":{"user":{"name":"John %s","friends":[{"name":"Jane"}]}}}`, req.Variables["id"])
+		case req.OperationName == "Rename":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"data":{"rename":null},"errors":[{"message":"name too long","path":["rename"]}]}`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, "internal error")
+		}
+	}))
+}
+
+func TestLoadSchemaFile(t *testing.T) {
+	query := `query User($id: ID!) { user(id: $id) { name friends(first: 2) { id } } }`
+	for _, path := range []string{"testdata/schema.graphql", "testdata/introspection.json"} {
+		schema, err := loadSchemaFile(path)
+		if err != nil {
+			t.Fatalf("%s: could not load schema: %v", path, err)
+		}
+		if err := validateQuery(schema, query, ""); err != nil {
+			t.Errorf("%s: unexpected error: %v", path, err)
+		}
+		if err := validateQuery(schema, `{ user(id: 1) { email } }`, ""); err == nil {
+			t.Errorf("%s: expected an error for an unknown field", path)
+		}
+	}
+}
+
+func TestSuiteRunTest(t *testing.T) {
+	ts := newServer(t)
+	defer ts.Close()
+	tt := []struct {
+		name string
+		test TestConfig
+		errs []string
+	}{
+		{
+			name: "data",
+			test: TestConfig{
+				Request: RequestConfig{QueryPath: "user.graphql", Variables: map[string]interface{}{"id": "1"}},
+				Expect: ExpectConfig{Data: map[string]interface{}{
+					"user": map[string]interface{}{
+						"name":    "John 1",
+						"friends": []interface{}{map[string]interface{}{"name": "Jane"}},
+					},
+				}},
+			},
+		},
+		{
+			name: "json values",
+			test: TestConfig{
+				Request: RequestConfig{QueryPath: "user.graphql", Variables: map[string]interface{}{"id": "2"}},
+				Expect: ExpectConfig{JSONValues: map[string]interface{}{
+					"user.name":           "John 1",
+					"user.friends.0.name": "Jane",
+					"user.friends.length": json.Number("1"),
+				}},
+			},
+			errs: []string{`wrong value for query "user.name" (got John 2; want John 1)`},
+		},
+		{
+			name: "expected errors",
+			test: TestConfig{
+				Request: RequestConfig{
+					Query:         `mutation Rename { rename(id: 1, name: "John") { name } }`,
+					OperationName: "Rename",
+				},
+				Expect: ExpectConfig{
+					Errors:     []ExpectError{{Message: "name too long", Path: "rename"}},
+					JSONValues: map[string]interface{}{"rename": nil},
+				},
+			},
+		},
+		{
+			name: "unexpected errors",
+			test: TestConfig{
+				Request: RequestConfig{
+					Query:         `mutation Rename { rename(id: 1, name: "John") { name } }`,
+					OperationName: "Rename",
+				},
+			},
+			errs: []string{"unexpected GraphQL error at rename: name too long"},
+		},
+		{
+			name: "transport error",
+			test: TestConfig{Request: RequestConfig{Query: `query Other { user(id: 1) { id } }`}},
+			errs: []string{"wrong http status code (got 500; expected 200)"},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test.Name = tc.name
+			cfg := &Config{
+				Root:       "testdata",
+				URL:        ts.URL,
+				Introspect: true,
+				Tests:      []TestConfig{tc.test},
+			}
+			suite, err := New(cfg)
+			if err != nil {
+				t.Fatalf("can't create suite: %v", err)
+			}
+			l := &mockLogger{}
+			suite.Tests()[0].Run(context.Background(), l)
+			if !cmp.Equal(l.errs, tc.errs) {
+				t.Fatalf("wrong errors (got %q; want %q)", l.errs, tc.errs)
+			}
+		})
+	}
+}
+
+func TestNewInvalidQuery(t *testing.T) {
+	ts := newServer(t)
+	defer ts.Close()
+	cfg := &Config{
+		URL:        ts.URL,
+		Introspect: true,
+		Tests: []TestConfig{
+			{Name: "unknown field", Request: RequestConfig{Query: `{ user(id: 1) { email } }`}},
+			{Name: "unknown operation", Request: RequestConfig{Query: `query A { user(id: 1) { id } }`, OperationName: "B"}},
+		},
+	}
+	_, err := New(cfg)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	want := "test \"unknown field\":\n" +
+		"- request: invalid query: input:1: Cannot query field \"email\" on type \"User\".\n" +
+		"test \"unknown operation\":\n" +
+		"- request: invalid query: unknown operation \"B\""
+	if diff := cmp.Diff(want, err.Error()); diff != "" {
+		t.Fatalf("wrong error (-want +got):\n%s", diff)
+	}
+}
+
+func TestNewIntrospectTimeout(t *testing.T) {
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer ts.Close()
+	defer close(done)
+	cfg := &Config{
+		URL:               ts.URL,
+		Introspect:        true,
+		IntrospectTimeout: ujson.Duration(50 * time.Millisecond),
+		Tests:             []TestConfig{{Name: "user", Request: RequestConfig{Query: `{ user(id: 1) { name } }`}}},
+	}
+	start := time.Now()
+	_, err := New(cfg)
+	if err == nil || !strings.HasPrefix(err.Error(), "could not introspect schema: could not do HTTP request: ") {
+		t.Fatalf("wrong error (got %v)", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("introspection not canceled after %s", d)
+	}
+}
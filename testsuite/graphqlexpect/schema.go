@@ -0,0 +1,276 @@
+package graphqlexpect
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/vektah/gqlparser"
+	"github.com/vektah/gqlparser/ast"
+)
+
+// introspectionQuery is the query fetching the schema of an endpoint.
+const introspectionQuery = `query IntrospectionQuery {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    subscriptionType { name }
+    types { ...FullType }
+    directives {
+      name
+      locations
+      args { ...InputValue }
+    }
+  }
+}
+
+fragment FullType on __Type {
+  kind
+  name
+  fields(includeDeprecated: true) {
+    name
+    args { ...InputValue }
+    type { ...TypeRef }
+  }
+  inputFields { ...InputValue }
+  interfaces { ...TypeRef }
+  enumValues(includeDeprecated: true) { name }
+  possibleTypes { ...TypeRef }
+}
+
+fragment InputValue on __InputValue {
+  name
+  type { ...TypeRef }
+  defaultValue
+}
+
+fragment TypeRef on __Type {
+  kind
+  name
+  ofType {
+    kind
+    name
+    ofType {
+      kind
+      name
+      ofType {
+        kind
+        name
+        ofType {
+          kind
+          name
+        }
+      }
+    }
+  }
+}`
+
+var builtinTypes = map[string]bool{
+	"String":  true,
+	"Int":     true,
+	"Float":   true,
+	"Boolean": true,
+	"ID":      true,
+}
+
+var builtinDirectives = map[string]bool{
+	"skip":       true,
+	"include":    true,
+	"deprecated": true,
+}
+
+// introspection is the result of the introspection query.
+type introspection struct {
+	Schema struct {
+		QueryType        *typeRef    `json:"queryType"`
+		MutationType     *typeRef    `json:"mutationType"`
+		SubscriptionType *typeRef    `json:"subscriptionType"`
+		Types            []fullType  `json:"types"`
+		Directives       []directive `json:"directives"`
+	} `json:"__schema"`
+}
+
+type fullType struct {
+	Kind          string       `json:"kind"`
+	Name          string       `json:"name"`
+	Fields        []field      `json:"fields"`
+	InputFields   []inputValue `json:"inputFields"`
+	Interfaces    []typeRef    `json:"interfaces"`
+	EnumValues    []enumValue  `json:"enumValues"`
+	PossibleTypes []typeRef    `json:"possibleTypes"`
+}
+
+type field struct {
+	Name string       `json:"name"`
+	Args []inputValue `json:"args"`
+	Type typeRef      `json:"type"`
+}
+
+type inputValue struct {
+	Name         string  `json:"name"`
+	Type         typeRef `json:"type"`
+	DefaultValue *string `json:"defaultValue"`
+}
+
+type enumValue struct {
+	Name string `json:"name"`
+}
+
+type directive struct {
+	Name      string       `json:"name"`
+	Locations []string     `json:"locations"`
+	Args      []inputValue `json:"args"`
+}
+
+type typeRef struct {
+	Kind   string   `json:"kind"`
+	Name   string   `json:"name"`
+	OfType *typeRef `json:"ofType"`
+}
+
+func (t *typeRef) String() string {
+	switch t.Kind {
+	case "NON_NULL":
+		if t.OfType != nil {
+			return t.OfType.String() + "!"
+		}
+	case "LIST":
+		if t.OfType != nil {
+			return "[" + t.OfType.String() + "]"
+		}
+	}
+	return t.Name
+}
+
+// loadSchemaFile loads a schema from a file in the GraphQL schema language or,
+// if its extension is .json, from the result of an introspection query.
+func loadSchemaFile(path string) (*ast.Schema, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Ext(path) != ".json" {
+		return loadSchema(string(b), filepath.Base(path))
+	}
+	var resp struct {
+		introspection
+		Data *introspection `json:"data"`
+	}
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return nil, err
+	}
+	in := &resp.introspection
+	if resp.Data != nil {
+		in = resp.Data
+	}
+	return in.schema()
+}
+
+// schema returns the schema described by the result of an introspection
+// query.
+func (in *introspection) schema() (*ast.Schema, error) {
+	if in.Schema.QueryType == nil {
+		return nil, errors.New("introspection: missing query type")
+	}
+	return loadSchema(in.sdl(), "introspection")
+}
+
+// sdl returns the schema in the GraphQL schema language.
+func (in *introspection) sdl() string {
+	var b bytes.Buffer
+	s := in.Schema
+	b.WriteString("schema {\n")
+	fmt.Fprintf(&b, "  query: %s\n", s.QueryType.Name)
+	if s.MutationType != nil {
+		fmt.Fprintf(&b, "  mutation: %s\n", s.MutationType.Name)
+	}
+	if s.SubscriptionType != nil {
+		fmt.Fprintf(&b, "  subscription: %s\n", s.SubscriptionType.Name)
+	}
+	b.WriteString("}\n")
+	for _, t := range s.Types {
+		if strings.HasPrefix(t.Name, "__") || builtinTypes[t.Name] {
+			continue
+		}
+		b.WriteByte('\n')
+		switch t.Kind {
+		case "SCALAR":
+			fmt.Fprintf(&b, "scalar %s\n", t.Name)
+		case "OBJECT", "INTERFACE":
+			kw := "type"
+			if t.Kind == "INTERFACE" {
+				kw = "interface"
+			}
+			fmt.Fprintf(&b, "%s %s", kw, t.Name)
+			for i, it := range t.Interfaces {
+				if i == 0 {
+					b.WriteString(" implements ")
+				} else {
+					b.WriteString(" & ")
+				}
+				b.WriteString(it.Name)
+			}
+			b.WriteString(" {\n")
+			for _, f := range t.Fields {
+				fmt.Fprintf(&b, "  %s%s: %s\n", f.Name, args(f.Args), f.Type.String())
+			}
+			b.WriteString("}\n")
+		case "UNION":
+			names := make([]string, len(t.PossibleTypes))
+			for i, pt := range t.PossibleTypes {
+				names[i] = pt.Name
+			}
+			fmt.Fprintf(&b, "union %s = %s\n", t.Name, strings.Join(names, " | "))
+		case "ENUM":
+			fmt.Fprintf(&b, "enum %s {\n", t.Name)
+			for _, v := range t.EnumValues {
+				fmt.Fprintf(&b, "  %s\n", v.Name)
+			}
+			b.WriteString("}\n")
+		case "INPUT_OBJECT":
+			fmt.Fprintf(&b, "input %s {\n", t.Name)
+			for _, f := range t.InputFields {
+				fmt.Fprintf(&b, "  %s\n", f.String())
+			}
+			b.WriteString("}\n")
+		}
+	}
+	for _, d := range s.Directives {
+		if builtinDirectives[d.Name] {
+			continue
+		}
+		fmt.Fprintf(&b, "\ndirective @%s%s on %s\n", d.Name, args(d.Args), strings.Join(d.Locations, " | "))
+	}
+	return b.String()
+}
+
+func (v *inputValue) String() string {
+	s := v.Name + ": " + v.Type.String()
+	if v.DefaultValue != nil {
+		s += " = " + *v.DefaultValue
+	}
+	return s
+}
+
+func args(ivs []inputValue) string {
+	if len(ivs) == 0 {
+		return ""
+	}
+	a := make([]string, len(ivs))
+	for i := range ivs {
+		a[i] = ivs[i].String()
+	}
+	return "(" + strings.Join(a, ", ") + ")"
+}
+
+func loadSchema(sdl, name string) (*ast.Schema, error) {
+	schema, err := gqlparser.LoadSchema(&ast.Source{Name: name, Input: sdl})
+	if err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
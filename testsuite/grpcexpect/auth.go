@@ -0,0 +1,65 @@
+package grpcexpect
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/credentials"
+
+	"github.com/blippar/aragorn/pkg/auth"
+)
+
+var _ credentials.PerRPCCredentials = perRPCAuth{}
+
+type signerKey struct{}
+
+// perRPCAuth authenticates the calls with the signer stored in their context.
+// Since the calls are not HTTP requests, the signers see a POST request
+// without body on the URI of the service.
+type perRPCAuth struct {
+	requireTLS bool // Refuse to authenticate the calls over a connection without TLS.
+}
+
+func (perRPCAuth) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	s, ok := ctx.Value(signerKey{}).(auth.Signer)
+	if !ok {
+		return nil, nil
+	}
+	u := "/"
+	if len(uri) > 0 {
+		u = uri[0]
+	}
+	req, err := http.NewRequest(http.MethodPost, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Sign(req, nil); err != nil {
+		return nil, err
+	}
+	md := make(map[string]string, len(req.Header))
+	for k := range req.Header {
+		md[strings.ToLower(k)] = req.Header.Get(k)
+	}
+	return md, nil
+}
+
+func (a perRPCAuth) RequireTransportSecurity() bool { return a.requireTLS }
+
+// sendsCredentials reports whether the calls of the suite send credentials,
+// which must not be sent over a connection without TLS.
+func (cfg *Config) sendsCredentials() bool {
+	if cfg.OAUTH2 != nil || cfg.Auth != nil && cfg.Auth.RequireTransportSecurity() {
+		return true
+	}
+	for _, tcfg := range cfg.Tests {
+		if a := tcfg.Request.Auth; a != nil && a.RequireTransportSecurity() {
+			return true
+		}
+	}
+	return false
+}
+
+func withSigner(ctx context.Context, s auth.Signer) context.Context {
+	return context.WithValue(ctx, signerKey{}, s)
+}
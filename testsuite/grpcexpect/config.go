@@ -7,15 +7,23 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/fullstorydev/grpcurl"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
 	"golang.org/x/oauth2/clientcredentials"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 
+	"github.com/blippar/aragorn/pkg/auth"
 	"github.com/blippar/aragorn/pkg/util/json"
 	"github.com/blippar/aragorn/testsuite"
 )
@@ -25,31 +33,104 @@ type Config struct {
 	Root               string                    `json:"root,omitempty"`
 	Address            string                    `json:"address,omitempty"`
 	ProtoSetPath       string                    `json:"protoSetPath,omitempty"`
+	ProtoFiles         []string                  `json:"protoFiles,omitempty"`  // Proto sources parsed at load time. Exclusive with protoSetPath.
+	ImportPaths        []string                  `json:"importPaths,omitempty"` // Directories searched for the proto files and their imports (default: the directory of the suite).
 	TLS                bool                      `json:"tls,omitempty"`
 	CAPath             string                    `json:"caPath,omitempty"`
+	ClientCertPath     string                    `json:"clientCertPath,omitempty"`
+	ClientKeyPath      string                    `json:"clientKeyPath,omitempty"`
 	ServerHostOverride string                    `json:"serverHostOverride,omitempty"`
 	Insecure           bool                      `json:"insecure,omitempty"`
 	OAUTH2             *clientcredentials.Config `json:"oauth2,omitempty"`
+	Auth               *auth.Config              `json:"auth,omitempty"`
+	PlaintextAuth      bool                      `json:"plaintextAuth,omitempty"` // Allow sending the credentials of oauth2 and auth without TLS.
 	Header             testsuite.Header          `json:"header,omitempty"`
 	Tests              []TestConfig              `json:"tests,omitempty"`
+	Smoke              *SmokeConfig              `json:"smoke,omitempty"`             // Adds tests covering every service of the server.
+	DialTimeout        json.Duration             `json:"dialTimeout,omitempty"`       // Time to connect to the server when a test runs (default: 10s).
+	DescriptorRefresh  json.Duration             `json:"descriptorRefresh,omitempty"` // Age of the descriptors fetched by reflection after which they are fetched again (default: 5m).
+	Protocol           string                    `json:"protocol,omitempty"`          // grpc, grpc-web or connect (default: grpc).
+	Authority          string                    `json:"authority,omitempty"`         // Value of the :authority pseudo-header of the calls (default: the address).
+	Keepalive          *KeepaliveConfig          `json:"keepalive,omitempty"`
+	CallOptions        *CallConfig               `json:"callOptions,omitempty"` // Options of every call, overridden by the ones of the tests.
 }
 
 type TestConfig struct {
-	Name    string        `json:"name,omitempty"`
-	Request RequestConfig `json:"request,omitempty"`
-	Expect  ExpectConfig  `json:"expect,omitempty"`
+	ID           string        `json:"id,omitempty"` // Identifier of the saved document, referenced by {{id.field}} in the requests of the following tests.
+	Name         string        `json:"name,omitempty"`
+	Request      RequestConfig `json:"request,omitempty"`
+	Script       []ScriptStep  `json:"script,omitempty"` // Messages sent and received in turn on a bidirectional stream. Exclusive with request.document.
+	Expect       ExpectConfig  `json:"expect,omitempty"`
+	SaveDocument bool          `json:"saveDocument,omitempty"` // Save the received messages under id.
 }
 
 type RequestConfig struct {
 	Method   string           `json:"method,omitempty"`
 	Header   testsuite.Header `json:"header,omitempty"`
 	Document interface{}      `json:"document,omitempty"`
+	Auth     *auth.Config     `json:"auth,omitempty"` // If set, will overwrite the suite auth.
+
+	CallOptions *CallConfig `json:"callOptions,omitempty"` // Overrides the call options set in the suite.
 }
 
 type ExpectConfig struct {
 	Code     codes.Code       `json:"code,omitempty"`
 	Header   testsuite.Header `json:"header,omitempty"`
 	Document interface{}      `json:"document,omitempty"`
+	Stream   *StreamConfig    `json:"stream,omitempty"` // Exclusive with document.
+
+	Trailer      testsuite.Header `json:"trailer,omitempty"`
+	Message      string           `json:"message,omitempty"`      // Exact status message.
+	MessageRegex string           `json:"messageRegex,omitempty"` // Regular expression the status message must match. Exclusive with message.
+	Details      []interface{}    `json:"details,omitempty"`      // Status details that must be sent, their type set in @type.
+
+	Values map[string]interface{} `json:"values,omitempty"` // Required values of the fields of every message, by path.
+	Mode   string                 `json:"mode,omitempty"`   // exact or subset, to only compare the fields set in the expected messages (default: exact).
+	Ignore []string               `json:"ignore,omitempty"` // Paths of the fields ignored when comparing messages.
+}
+
+// StreamConfig describes the expected messages of a stream whose length or
+// order may vary.
+type StreamConfig struct {
+	MinMessages int                    `json:"minMessages,omitempty"`
+	MaxMessages int                    `json:"maxMessages,omitempty"`
+	Contains    []interface{}          `json:"contains,omitempty"` // Documents each matching at least one message.
+	At          map[string]interface{} `json:"at,omitempty"`       // Documents matching the message at an index, negative indexes counting from the end.
+}
+
+// SmokeConfig describes the tests generated from the services of the server,
+// listed using the descriptor source of the suite when they run.
+type SmokeConfig struct {
+	Health      bool     `json:"health,omitempty"`      // Check the health of the server and of each service with grpc.health.v1.
+	InvokeUnary bool     `json:"invokeUnary,omitempty"` // Call each unary method with an empty request, which must not fail with Unimplemented or Internal.
+	Exclude     []string `json:"exclude,omitempty"`     // Services or methods not checked.
+}
+
+// KeepaliveConfig describes the pings sent on the connection to the server to
+// keep it alive.
+type KeepaliveConfig struct {
+	Time                json.Duration `json:"time,omitempty"`                // Time without activity after which a ping is sent.
+	Timeout             json.Duration `json:"timeout,omitempty"`             // Time to wait for the acknowledgment of a ping before closing the connection (default: 20s).
+	PermitWithoutStream bool          `json:"permitWithoutStream,omitempty"` // Send pings even without active calls.
+}
+
+// CallConfig describes the options of the calls. Only the timeout and the
+// maximum size of the received messages are supported by the grpc-web and
+// connect protocols.
+type CallConfig struct {
+	Timeout        json.Duration `json:"timeout,omitempty"`        // Deadline of the call, propagated to the server.
+	Compression    string        `json:"compression,omitempty"`    // Compression of the sent messages: gzip or identity (default: identity).
+	MaxSendMsgSize int           `json:"maxSendMsgSize,omitempty"` // Maximum size in bytes of a sent message.
+	MaxRecvMsgSize int           `json:"maxRecvMsgSize,omitempty"` // Maximum size in bytes of a received message (default: 4MiB).
+	WaitForReady   *bool         `json:"waitForReady,omitempty"`   // Wait for the connection to be ready instead of failing the call.
+}
+
+// A ScriptStep either sends messages, receives messages or closes the sending
+// side of the stream.
+type ScriptStep struct {
+	Send      interface{} `json:"send,omitempty"`
+	Receive   interface{} `json:"receive,omitempty"`
+	CloseSend bool        `json:"closeSend,omitempty"`
 }
 
 func (*Config) Example() interface{} {
@@ -74,31 +155,118 @@ func (*Config) Example() interface{} {
 	}
 }
 
-func (cfg *Config) genTests(cc *grpc.ClientConn, descSource grpcurl.DescriptorSource) ([]testsuite.Test, error) {
+func (cfg *Config) genTests(d *dialer, web *webClient, signer auth.Signer, tokenClient *http.Client) ([]testsuite.Test, error) {
 	tests := make([]testsuite.Test, len(cfg.Tests))
+	description := "grpc://" + cfg.Address
+	if web != nil {
+		description = web.baseURL
+	}
 	for i, tcfg := range cfg.Tests {
-		reqMsgs, err := cfg.newDocToMsgs(tcfg.Request.Document)
+		callOpts, err := cfg.newCallOptions(tcfg.Request.CallOptions, web != nil)
 		if err != nil {
 			return nil, fmt.Errorf("test %d %s: request: %v", i, tcfg.Name, err)
 		}
-		expMsgs, err := cfg.newDocToMsgs(tcfg.Expect.Document)
-		if err != nil {
-			return nil, fmt.Errorf("test %d %s: expect: %v", i, tcfg.Name, err)
+		tsigner := signer
+		if tcfg.Request.Auth != nil {
+			if tsigner, err = tcfg.Request.Auth.NewSigner(cfg.Root, tokenClient); err != nil {
+				return nil, fmt.Errorf("test %d %s: request: auth: %v", i, tcfg.Name, err)
+			}
+		}
+		if tcfg.SaveDocument && tcfg.ID == "" {
+			return nil, fmt.Errorf("test %d %s: saveDocument requires an id", i, tcfg.Name)
+		}
+		var (
+			reqMsgs [][]byte
+			script  []*scriptStep
+		)
+		if tcfg.Script != nil {
+			if tcfg.Request.Document != nil {
+				return nil, fmt.Errorf("test %d %s: request: document can't be set with script", i, tcfg.Name)
+			}
+			if web != nil {
+				return nil, fmt.Errorf("test %d %s: script requires protocol grpc", i, tcfg.Name)
+			}
+			if script, err = cfg.newScript(tcfg.Script); err != nil {
+				return nil, fmt.Errorf("test %d %s: script: %v", i, tcfg.Name, err)
+			}
+		} else if reqMsgs, err = cfg.newDocToMsgs(tcfg.Request.Document); err != nil {
+			return nil, fmt.Errorf("test %d %s: request: %v", i, tcfg.Name, err)
+		}
+		var m matcher
+		switch tcfg.Expect.Mode {
+		case "", "exact":
+		case "subset":
+			m.subset = true
+		default:
+			return nil, fmt.Errorf("test %d %s: expect: unknown mode %q", i, tcfg.Name, tcfg.Expect.Mode)
+		}
+		for _, path := range tcfg.Expect.Ignore {
+			if path == "" {
+				return nil, fmt.Errorf("test %d %s: expect: ignore: empty path", i, tcfg.Name)
+			}
+			m.ignore = append(m.ignore, strings.Split(path, "."))
+		}
+		var msgRegex *regexp.Regexp
+		if tcfg.Expect.MessageRegex != "" {
+			if tcfg.Expect.Message != "" {
+				return nil, fmt.Errorf("test %d %s: expect: messageRegex can't be set with message", i, tcfg.Name)
+			}
+			if msgRegex, err = regexp.Compile(tcfg.Expect.MessageRegex); err != nil {
+				return nil, fmt.Errorf("test %d %s: expect: invalid messageRegex: %v", i, tcfg.Name, err)
+			}
+		}
+		details := make([]detail, len(tcfg.Expect.Details))
+		for j, doc := range tcfg.Expect.Details {
+			if details[j], err = cfg.newDetail(doc); err != nil {
+				return nil, fmt.Errorf("test %d %s: expect: details %d: %v", i, tcfg.Name, j, err)
+			}
+		}
+		var (
+			expMsgs [][]byte
+			stream  *streamExpect
+		)
+		if tcfg.Expect.Stream != nil {
+			if tcfg.Expect.Document != nil {
+				return nil, fmt.Errorf("test %d %s: expect: document can't be set with stream", i, tcfg.Name)
+			}
+			if stream, err = cfg.newStreamExpect(tcfg.Expect.Stream); err != nil {
+				return nil, fmt.Errorf("test %d %s: expect: stream: %v", i, tcfg.Name, err)
+			}
+		} else if tcfg.Expect.Document != nil || (script == nil && tcfg.Expect.Values == nil) {
+			// The messages received by a script are only checked by its steps
+			// and the ones with expected values by them, unless a document is
+			// expected.
+			if expMsgs, err = cfg.newDocToMsgs(tcfg.Expect.Document); err != nil {
+				return nil, fmt.Errorf("test %d %s: expect: %v", i, tcfg.Name, err)
+			}
 		}
 		tests[i] = &test{
-			cc:          cc,
-			descSource:  descSource,
+			dialer:      d,
+			web:         web,
+			signer:      tsigner,
+			callOpts:    callOpts,
 			name:        tcfg.Name,
-			description: fmt.Sprintf("grpc://%s/%s", cfg.Address, tcfg.Request.Method),
+			id:          tcfg.ID,
+			saveDoc:     tcfg.SaveDocument,
+			description: description + "/" + tcfg.Request.Method,
 			req: request{
 				methodName: tcfg.Request.Method,
 				headers:    testsuite.MergeHeaders(cfg.Header, tcfg.Request.Header).Slice(),
 				msgs:       reqMsgs,
 			},
+			script: script,
 			expect: expect{
 				code:   tcfg.Expect.Code,
 				header: testsuite.MergeHeaders(tcfg.Expect.Header),
 				msgs:   expMsgs,
+				stream: stream,
+				values: tcfg.Expect.Values,
+				match:  m,
+
+				trailer:  testsuite.MergeHeaders(tcfg.Expect.Trailer),
+				message:  tcfg.Expect.Message,
+				msgRegex: msgRegex,
+				details:  details,
 			},
 		}
 	}
@@ -112,9 +280,40 @@ func (cfg *Config) getFilePath(path string) string {
 	return filepath.Join(cfg.Root, path)
 }
 
-func (cfg *Config) transportDialOption() (grpc.DialOption, error) {
+// fileDescriptorSource returns the source of the descriptors loaded from the
+// protoset or the proto files of the suite, or nil if neither is set.
+func (cfg *Config) fileDescriptorSource() (grpcurl.DescriptorSource, error) {
+	if cfg.ProtoSetPath != "" {
+		if cfg.ProtoFiles != nil {
+			return nil, errors.New("protoFiles can't be set with protoSetPath")
+		}
+		return grpcurl.DescriptorSourceFromProtoSets(cfg.getFilePath(cfg.ProtoSetPath))
+	}
+	if cfg.ProtoFiles == nil {
+		if cfg.ImportPaths != nil {
+			return nil, errors.New("importPaths requires protoFiles")
+		}
+		return nil, nil
+	}
+	p := protoparse.Parser{ImportPaths: []string{cfg.Root}}
+	if cfg.ImportPaths != nil {
+		p.ImportPaths = make([]string, len(cfg.ImportPaths))
+		for i, path := range cfg.ImportPaths {
+			p.ImportPaths[i] = cfg.getFilePath(path)
+		}
+	}
+	fds, err := p.ParseFiles(cfg.ProtoFiles...)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse proto files: %v", err)
+	}
+	return grpcurl.DescriptorSourceFromFileDescriptorSet(desc.ToFileDescriptorSet(fds...))
+}
+
+// tlsConfig returns the TLS configuration of the connections to the server,
+// or nil if TLS is not enabled.
+func (cfg *Config) tlsConfig() (*tls.Config, error) {
 	if !cfg.TLS {
-		return grpc.WithInsecure(), nil
+		return nil, nil
 	}
 	cp := x509.NewCertPool()
 	if cfg.CAPath != "" {
@@ -132,8 +331,38 @@ func (cfg *Config) transportDialOption() (grpc.DialOption, error) {
 		RootCAs:            cp,
 		InsecureSkipVerify: cfg.Insecure,
 	}
-	tc := credentials.NewTLS(tlsCfg)
-	return grpc.WithTransportCredentials(tc), nil
+	if (cfg.ClientCertPath == "") != (cfg.ClientKeyPath == "") {
+		return nil, errors.New("clientCertPath and clientKeyPath must be set together")
+	}
+	if cfg.ClientCertPath != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.getFilePath(cfg.ClientCertPath), cfg.getFilePath(cfg.ClientKeyPath))
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %v", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	return tlsCfg, nil
+}
+
+func transportDialOption(tlsCfg *tls.Config) grpc.DialOption {
+	if tlsCfg == nil {
+		return grpc.WithInsecure()
+	}
+	return grpc.WithTransportCredentials(credentials.NewTLS(tlsCfg))
+}
+
+// webBaseURL returns the URL the methods are called under with the gRPC-Web
+// and Connect protocols: the address itself if it is a URL, or else the
+// address with a scheme depending on TLS.
+func (cfg *Config) webBaseURL() string {
+	addr := strings.TrimSuffix(cfg.Address, "/")
+	if strings.HasPrefix(addr, "http://") || strings.HasPrefix(addr, "https://") {
+		return addr
+	}
+	if cfg.TLS {
+		return "https://" + addr
+	}
+	return "http://" + addr
 }
 
 func (cfg *Config) newDocToMsgs(doc interface{}) ([][]byte, error) {
@@ -159,6 +388,113 @@ func (cfg *Config) newDocToMsgs(doc interface{}) ([][]byte, error) {
 	return res, nil
 }
 
+func (cfg *Config) newStreamExpect(sc *StreamConfig) (*streamExpect, error) {
+	if sc.MinMessages < 0 || sc.MaxMessages < 0 {
+		return nil, errors.New("minMessages and maxMessages can't be negative")
+	}
+	if sc.MaxMessages > 0 && sc.MinMessages > sc.MaxMessages {
+		return nil, errors.New("minMessages can't be greater than maxMessages")
+	}
+	e := &streamExpect{minMsgs: sc.MinMessages, maxMsgs: sc.MaxMessages}
+	if sc.Contains != nil {
+		msgs, err := cfg.newDocToMsgs(sc.Contains)
+		if err != nil {
+			return nil, fmt.Errorf("contains: %v", err)
+		}
+		e.contains = msgs
+	}
+	for k, doc := range sc.At {
+		index, err := strconv.Atoi(k)
+		if err != nil {
+			return nil, fmt.Errorf("at: invalid index %q", k)
+		}
+		msg, err := cfg.newDocToMsg(doc)
+		if err != nil {
+			return nil, fmt.Errorf("at %d: %v", index, err)
+		}
+		e.at = append(e.at, indexedMsg{index: index, msg: msg})
+	}
+	sort.Slice(e.at, func(i, j int) bool { return e.at[i].index < e.at[j].index })
+	return e, nil
+}
+
+func (cfg *Config) newScript(steps []ScriptStep) ([]*scriptStep, error) {
+	if len(steps) == 0 {
+		return nil, errors.New("at least one step is required")
+	}
+	script := make([]*scriptStep, len(steps))
+	closed := false
+	for i, s := range steps {
+		set := 0
+		for _, ok := range []bool{s.Send != nil, s.Receive != nil, s.CloseSend} {
+			if ok {
+				set++
+			}
+		}
+		if set != 1 {
+			return nil, fmt.Errorf("step %d: exactly one of send, receive or closeSend must be set", i)
+		}
+		st := &scriptStep{}
+		var err error
+		switch {
+		case s.CloseSend:
+			st.kind = closeSendStep
+		case s.Send != nil:
+			if closed {
+				return nil, fmt.Errorf("step %d: can't send after closeSend", i)
+			}
+			st.kind = sendStep
+			st.msgs, err = cfg.newDocToMsgs(s.Send)
+		default:
+			st.kind = receiveStep
+			st.msgs, err = cfg.newDocToMsgs(s.Receive)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %v", i, err)
+		}
+		closed = closed || s.CloseSend
+		script[i] = st
+	}
+	return script, nil
+}
+
+// newDetail returns the expected status detail described by doc, a message
+// whose type name or URL is set in its @type field.
+func (cfg *Config) newDetail(doc interface{}) (detail, error) {
+	d, err := loadDoc(cfg.Path, doc)
+	if err != nil {
+		return detail{}, err
+	}
+	m, ok := d.(map[string]interface{})
+	if !ok {
+		return detail{}, errors.New("invalid document type")
+	}
+	typ, _ := m["@type"].(string)
+	if typ == "" {
+		return detail{}, errors.New("@type is required")
+	}
+	fields := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if k != "@type" {
+			fields[k] = v
+		}
+	}
+	msg, _ := json.Marshal(fields)
+	return detail{typeName: typ[strings.LastIndex(typ, "/")+1:], msg: msg}, nil
+}
+
+// newDocToMsg returns the JSON form of a document describing a single message.
+func (cfg *Config) newDocToMsg(doc interface{}) ([]byte, error) {
+	if _, ok := doc.([]interface{}); ok {
+		return nil, errors.New("a single message is expected")
+	}
+	msgs, err := cfg.newDocToMsgs(doc)
+	if err != nil {
+		return nil, err
+	}
+	return msgs[0], nil
+}
+
 func loadDoc(path string, i interface{}) (interface{}, error) {
 	switch doc := i.(type) {
 	case []interface{}:
@@ -191,10 +527,7 @@ func loadDoc(path string, i interface{}) (interface{}, error) {
 			if err != nil {
 				return nil, err
 			}
-			_, isMap := v.(map[string]interface{})
-			if isMap && newVal != v {
-				doc[k] = newVal
-			}
+			doc[k] = newVal
 		}
 	}
 	return i, nil
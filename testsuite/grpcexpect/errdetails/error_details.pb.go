@@ -0,0 +1,441 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: errdetails/error_details.proto
+
+/*
+Package errdetails is a generated protocol buffer package.
+
+It is generated from these files:
+	errdetails/error_details.proto
+
+It has these top-level messages:
+	ErrorInfo
+	RetryInfo
+	DebugInfo
+	QuotaFailure
+	PreconditionFailure
+	BadRequest
+	RequestInfo
+	ResourceInfo
+	Help
+	LocalizedMessage
+*/
+package errdetails
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+import google_protobuf "github.com/golang/protobuf/ptypes/duration"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+// Describes the cause of the error with structured details.
+type ErrorInfo struct {
+	Reason   string            `protobuf:"bytes,1,opt,name=reason" json:"reason,omitempty"`
+	Domain   string            `protobuf:"bytes,2,opt,name=domain" json:"domain,omitempty"`
+	Metadata map[string]string `protobuf:"bytes,3,rep,name=metadata" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *ErrorInfo) Reset()                    { *m = ErrorInfo{} }
+func (m *ErrorInfo) String() string            { return proto.CompactTextString(m) }
+func (*ErrorInfo) ProtoMessage()               {}
+func (*ErrorInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+func (m *ErrorInfo) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+func (m *ErrorInfo) GetDomain() string {
+	if m != nil {
+		return m.Domain
+	}
+	return ""
+}
+
+func (m *ErrorInfo) GetMetadata() map[string]string {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+// Describes when the clients can retry a failed request.
+type RetryInfo struct {
+	RetryDelay *google_protobuf.Duration `protobuf:"bytes,1,opt,name=retry_delay,json=retryDelay" json:"retry_delay,omitempty"`
+}
+
+func (m *RetryInfo) Reset()                    { *m = RetryInfo{} }
+func (m *RetryInfo) String() string            { return proto.CompactTextString(m) }
+func (*RetryInfo) ProtoMessage()               {}
+func (*RetryInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *RetryInfo) GetRetryDelay() *google_protobuf.Duration {
+	if m != nil {
+		return m.RetryDelay
+	}
+	return nil
+}
+
+// Describes additional debugging info.
+type DebugInfo struct {
+	StackEntries []string `protobuf:"bytes,1,rep,name=stack_entries,json=stackEntries" json:"stack_entries,omitempty"`
+	Detail       string   `protobuf:"bytes,2,opt,name=detail" json:"detail,omitempty"`
+}
+
+func (m *DebugInfo) Reset()                    { *m = DebugInfo{} }
+func (m *DebugInfo) String() string            { return proto.CompactTextString(m) }
+func (*DebugInfo) ProtoMessage()               {}
+func (*DebugInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+func (m *DebugInfo) GetStackEntries() []string {
+	if m != nil {
+		return m.StackEntries
+	}
+	return nil
+}
+
+func (m *DebugInfo) GetDetail() string {
+	if m != nil {
+		return m.Detail
+	}
+	return ""
+}
+
+// Describes how a quota check failed.
+type QuotaFailure struct {
+	Violations []*QuotaFailure_Violation `protobuf:"bytes,1,rep,name=violations" json:"violations,omitempty"`
+}
+
+func (m *QuotaFailure) Reset()                    { *m = QuotaFailure{} }
+func (m *QuotaFailure) String() string            { return proto.CompactTextString(m) }
+func (*QuotaFailure) ProtoMessage()               {}
+func (*QuotaFailure) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *QuotaFailure) GetViolations() []*QuotaFailure_Violation {
+	if m != nil {
+		return m.Violations
+	}
+	return nil
+}
+
+type QuotaFailure_Violation struct {
+	Subject     string `protobuf:"bytes,1,opt,name=subject" json:"subject,omitempty"`
+	Description string `protobuf:"bytes,2,opt,name=description" json:"description,omitempty"`
+}
+
+func (m *QuotaFailure_Violation) Reset()                    { *m = QuotaFailure_Violation{} }
+func (m *QuotaFailure_Violation) String() string            { return proto.CompactTextString(m) }
+func (*QuotaFailure_Violation) ProtoMessage()               {}
+func (*QuotaFailure_Violation) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3, 0} }
+
+func (m *QuotaFailure_Violation) GetSubject() string {
+	if m != nil {
+		return m.Subject
+	}
+	return ""
+}
+
+func (m *QuotaFailure_Violation) GetDescription() string {
+	if m != nil {
+		return m.Description
+	}
+	return ""
+}
+
+// Describes what preconditions have failed.
+type PreconditionFailure struct {
+	Violations []*PreconditionFailure_Violation `protobuf:"bytes,1,rep,name=violations" json:"violations,omitempty"`
+}
+
+func (m *PreconditionFailure) Reset()                    { *m = PreconditionFailure{} }
+func (m *PreconditionFailure) String() string            { return proto.CompactTextString(m) }
+func (*PreconditionFailure) ProtoMessage()               {}
+func (*PreconditionFailure) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *PreconditionFailure) GetViolations() []*PreconditionFailure_Violation {
+	if m != nil {
+		return m.Violations
+	}
+	return nil
+}
+
+type PreconditionFailure_Violation struct {
+	Type        string `protobuf:"bytes,1,opt,name=type" json:"type,omitempty"`
+	Subject     string `protobuf:"bytes,2,opt,name=subject" json:"subject,omitempty"`
+	Description string `protobuf:"bytes,3,opt,name=description" json:"description,omitempty"`
+}
+
+func (m *PreconditionFailure_Violation) Reset()         { *m = PreconditionFailure_Violation{} }
+func (m *PreconditionFailure_Violation) String() string { return proto.CompactTextString(m) }
+func (*PreconditionFailure_Violation) ProtoMessage()    {}
+func (*PreconditionFailure_Violation) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{4, 0}
+}
+
+func (m *PreconditionFailure_Violation) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *PreconditionFailure_Violation) GetSubject() string {
+	if m != nil {
+		return m.Subject
+	}
+	return ""
+}
+
+func (m *PreconditionFailure_Violation) GetDescription() string {
+	if m != nil {
+		return m.Description
+	}
+	return ""
+}
+
+// Describes violations in a client request.
+type BadRequest struct {
+	FieldViolations []*BadRequest_FieldViolation `protobuf:"bytes,1,rep,name=field_violations,json=fieldViolations" json:"field_violations,omitempty"`
+}
+
+func (m *BadRequest) Reset()                    { *m = BadRequest{} }
+func (m *BadRequest) String() string            { return proto.CompactTextString(m) }
+func (*BadRequest) ProtoMessage()               {}
+func (*BadRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *BadRequest) GetFieldViolations() []*BadRequest_FieldViolation {
+	if m != nil {
+		return m.FieldViolations
+	}
+	return nil
+}
+
+type BadRequest_FieldViolation struct {
+	Field       string `protobuf:"bytes,1,opt,name=field" json:"field,omitempty"`
+	Description string `protobuf:"bytes,2,opt,name=description" json:"description,omitempty"`
+}
+
+func (m *BadRequest_FieldViolation) Reset()                    { *m = BadRequest_FieldViolation{} }
+func (m *BadRequest_FieldViolation) String() string            { return proto.CompactTextString(m) }
+func (*BadRequest_FieldViolation) ProtoMessage()               {}
+func (*BadRequest_FieldViolation) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5, 0} }
+
+func (m *BadRequest_FieldViolation) GetField() string {
+	if m != nil {
+		return m.Field
+	}
+	return ""
+}
+
+func (m *BadRequest_FieldViolation) GetDescription() string {
+	if m != nil {
+		return m.Description
+	}
+	return ""
+}
+
+// Contains metadata about the request that clients can attach when filing a
+// bug or providing other forms of feedback.
+type RequestInfo struct {
+	RequestId   string `protobuf:"bytes,1,opt,name=request_id,json=requestId" json:"request_id,omitempty"`
+	ServingData string `protobuf:"bytes,2,opt,name=serving_data,json=servingData" json:"serving_data,omitempty"`
+}
+
+func (m *RequestInfo) Reset()                    { *m = RequestInfo{} }
+func (m *RequestInfo) String() string            { return proto.CompactTextString(m) }
+func (*RequestInfo) ProtoMessage()               {}
+func (*RequestInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *RequestInfo) GetRequestId() string {
+	if m != nil {
+		return m.RequestId
+	}
+	return ""
+}
+
+func (m *RequestInfo) GetServingData() string {
+	if m != nil {
+		return m.ServingData
+	}
+	return ""
+}
+
+// Describes the resource that is being accessed.
+type ResourceInfo struct {
+	ResourceType string `protobuf:"bytes,1,opt,name=resource_type,json=resourceType" json:"resource_type,omitempty"`
+	ResourceName string `protobuf:"bytes,2,opt,name=resource_name,json=resourceName" json:"resource_name,omitempty"`
+	Owner        string `protobuf:"bytes,3,opt,name=owner" json:"owner,omitempty"`
+	Description  string `protobuf:"bytes,4,opt,name=description" json:"description,omitempty"`
+}
+
+func (m *ResourceInfo) Reset()                    { *m = ResourceInfo{} }
+func (m *ResourceInfo) String() string            { return proto.CompactTextString(m) }
+func (*ResourceInfo) ProtoMessage()               {}
+func (*ResourceInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *ResourceInfo) GetResourceType() string {
+	if m != nil {
+		return m.ResourceType
+	}
+	return ""
+}
+
+func (m *ResourceInfo) GetResourceName() string {
+	if m != nil {
+		return m.ResourceName
+	}
+	return ""
+}
+
+func (m *ResourceInfo) GetOwner() string {
+	if m != nil {
+		return m.Owner
+	}
+	return ""
+}
+
+func (m *ResourceInfo) GetDescription() string {
+	if m != nil {
+		return m.Description
+	}
+	return ""
+}
+
+// Provides links to documentation or for performing an out of band action.
+type Help struct {
+	Links []*Help_Link `protobuf:"bytes,1,rep,name=links" json:"links,omitempty"`
+}
+
+func (m *Help) Reset()                    { *m = Help{} }
+func (m *Help) String() string            { return proto.CompactTextString(m) }
+func (*Help) ProtoMessage()               {}
+func (*Help) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *Help) GetLinks() []*Help_Link {
+	if m != nil {
+		return m.Links
+	}
+	return nil
+}
+
+type Help_Link struct {
+	Description string `protobuf:"bytes,1,opt,name=description" json:"description,omitempty"`
+	Url         string `protobuf:"bytes,2,opt,name=url" json:"url,omitempty"`
+}
+
+func (m *Help_Link) Reset()                    { *m = Help_Link{} }
+func (m *Help_Link) String() string            { return proto.CompactTextString(m) }
+func (*Help_Link) ProtoMessage()               {}
+func (*Help_Link) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8, 0} }
+
+func (m *Help_Link) GetDescription() string {
+	if m != nil {
+		return m.Description
+	}
+	return ""
+}
+
+func (m *Help_Link) GetUrl() string {
+	if m != nil {
+		return m.Url
+	}
+	return ""
+}
+
+// Provides a localized error message that is safe to return to the user.
+type LocalizedMessage struct {
+	Locale  string `protobuf:"bytes,1,opt,name=locale" json:"locale,omitempty"`
+	Message string `protobuf:"bytes,2,opt,name=message" json:"message,omitempty"`
+}
+
+func (m *LocalizedMessage) Reset()                    { *m = LocalizedMessage{} }
+func (m *LocalizedMessage) String() string            { return proto.CompactTextString(m) }
+func (*LocalizedMessage) ProtoMessage()               {}
+func (*LocalizedMessage) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *LocalizedMessage) GetLocale() string {
+	if m != nil {
+		return m.Locale
+	}
+	return ""
+}
+
+func (m *LocalizedMessage) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*ErrorInfo)(nil), "google.rpc.ErrorInfo")
+	proto.RegisterType((*RetryInfo)(nil), "google.rpc.RetryInfo")
+	proto.RegisterType((*DebugInfo)(nil), "google.rpc.DebugInfo")
+	proto.RegisterType((*QuotaFailure)(nil), "google.rpc.QuotaFailure")
+	proto.RegisterType((*QuotaFailure_Violation)(nil), "google.rpc.QuotaFailure.Violation")
+	proto.RegisterType((*PreconditionFailure)(nil), "google.rpc.PreconditionFailure")
+	proto.RegisterType((*PreconditionFailure_Violation)(nil), "google.rpc.PreconditionFailure.Violation")
+	proto.RegisterType((*BadRequest)(nil), "google.rpc.BadRequest")
+	proto.RegisterType((*BadRequest_FieldViolation)(nil), "google.rpc.BadRequest.FieldViolation")
+	proto.RegisterType((*RequestInfo)(nil), "google.rpc.RequestInfo")
+	proto.RegisterType((*ResourceInfo)(nil), "google.rpc.ResourceInfo")
+	proto.RegisterType((*Help)(nil), "google.rpc.Help")
+	proto.RegisterType((*Help_Link)(nil), "google.rpc.Help.Link")
+	proto.RegisterType((*LocalizedMessage)(nil), "google.rpc.LocalizedMessage")
+}
+
+func init() { proto.RegisterFile("errdetails/error_details.proto", fileDescriptor0) }
+
+var fileDescriptor0 = []byte{
+	// 621 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x54, 0x5d, 0x6b, 0x13, 0x41,
+	0x14, 0x65, 0x9b, 0xb4, 0xba, 0x37, 0xa9, 0x96, 0xf5, 0x83, 0x18, 0xb0, 0xc4, 0x2d, 0x42, 0x45,
+	0xd8, 0x42, 0x7d, 0x91, 0xfa, 0x20, 0x94, 0xf4, 0x0b, 0x5a, 0xad, 0x8b, 0xf8, 0xa0, 0x0f, 0xcb,
+	0x64, 0xf7, 0x26, 0x8c, 0xd9, 0xcc, 0xc4, 0x99, 0xd9, 0x4a, 0xfc, 0x15, 0xbe, 0xfb, 0x07, 0xfc,
+	0x0b, 0x3e, 0xf8, 0xdf, 0x64, 0xbe, 0x92, 0x4d, 0x53, 0xc5, 0xb7, 0x39, 0x67, 0xce, 0xdc, 0x39,
+	0xf7, 0x70, 0x67, 0x60, 0x1b, 0x85, 0x28, 0x50, 0x11, 0x5a, 0xca, 0x3d, 0x14, 0x82, 0x8b, 0xcc,
+	0xa1, 0x64, 0x2a, 0xb8, 0xe2, 0x11, 0x8c, 0x38, 0x1f, 0x95, 0x98, 0x88, 0x69, 0xde, 0xdd, 0xb6,
+	0xeb, 0x3d, 0xb3, 0x33, 0xa8, 0x86, 0x7b, 0x45, 0x25, 0x88, 0xa2, 0x9c, 0x59, 0x6d, 0xfc, 0x2b,
+	0x80, 0xf0, 0x48, 0xd7, 0x38, 0x63, 0x43, 0x1e, 0x3d, 0x84, 0x0d, 0x81, 0x44, 0x72, 0xd6, 0x09,
+	0x7a, 0xc1, 0x6e, 0x98, 0x3a, 0xa4, 0xf9, 0x82, 0x4f, 0x08, 0x65, 0x9d, 0x35, 0xcb, 0x5b, 0x14,
+	0xbd, 0x86, 0xdb, 0x13, 0x54, 0xa4, 0x20, 0x8a, 0x74, 0x1a, 0xbd, 0xc6, 0x6e, 0x6b, 0x7f, 0x27,
+	0x59, 0x5c, 0x9e, 0xcc, 0x0b, 0x27, 0x17, 0x4e, 0x75, 0xc4, 0x94, 0x98, 0xa5, 0xf3, 0x43, 0xdd,
+	0x57, 0xb0, 0xb9, 0xb4, 0x15, 0x6d, 0x41, 0x63, 0x8c, 0x33, 0x77, 0xbd, 0x5e, 0x46, 0xf7, 0x61,
+	0xfd, 0x8a, 0x94, 0x15, 0xba, 0xab, 0x2d, 0x38, 0x58, 0x7b, 0x19, 0xc4, 0x27, 0x10, 0xa6, 0xa8,
+	0xc4, 0xcc, 0x58, 0x3f, 0x80, 0x96, 0xd0, 0x20, 0x2b, 0xb0, 0x24, 0xb6, 0x40, 0x6b, 0xff, 0x91,
+	0x77, 0xe3, 0xdb, 0x4f, 0xfa, 0xae, 0xfd, 0x14, 0x8c, 0xba, 0xaf, 0xc5, 0xf1, 0x29, 0x84, 0x7d,
+	0x1c, 0x54, 0x23, 0x53, 0x68, 0x07, 0x36, 0xa5, 0x22, 0xf9, 0x38, 0x43, 0xa6, 0x04, 0x45, 0xd9,
+	0x09, 0x7a, 0x8d, 0xdd, 0x30, 0x6d, 0x1b, 0xf2, 0xc8, 0x72, 0x26, 0x10, 0x93, 0xf9, 0x3c, 0x10,
+	0x83, 0xe2, 0x1f, 0x01, 0xb4, 0xdf, 0x55, 0x5c, 0x91, 0x63, 0x42, 0xcb, 0x4a, 0x60, 0x74, 0x08,
+	0x70, 0x45, 0x79, 0x69, 0xee, 0xb4, 0xa5, 0x5a, 0xfb, 0x71, 0x3d, 0xa3, 0xba, 0x3a, 0xf9, 0xe0,
+	0xa5, 0x69, 0xed, 0x54, 0xf7, 0x04, 0xc2, 0xf9, 0x46, 0xd4, 0x81, 0x5b, 0xb2, 0x1a, 0x7c, 0xc6,
+	0x5c, 0xb9, 0x90, 0x3c, 0x8c, 0x7a, 0xd0, 0x2a, 0x50, 0xe6, 0x82, 0x4e, 0xb5, 0xd0, 0x19, 0xab,
+	0x53, 0xf1, 0xef, 0x00, 0xee, 0x5d, 0x0a, 0xcc, 0x39, 0x2b, 0xa8, 0x26, 0xbc, 0xc9, 0xb3, 0x1b,
+	0x4c, 0x3e, 0xab, 0x9b, 0xbc, 0xe1, 0xd0, 0x5f, 0xbc, 0x7e, 0xaa, 0x7b, 0x8d, 0xa0, 0xa9, 0x66,
+	0x53, 0x74, 0x46, 0xcd, 0xba, 0xee, 0x7f, 0xed, 0x9f, 0xfe, 0x1b, 0xab, 0xfe, 0x7f, 0x06, 0x00,
+	0x87, 0xa4, 0x48, 0xf1, 0x4b, 0x85, 0x52, 0x45, 0x97, 0xb0, 0x35, 0xa4, 0x58, 0x16, 0xd9, 0x8a,
+	0xf9, 0xa7, 0x75, 0xf3, 0x8b, 0x13, 0xc9, 0xb1, 0x96, 0x2f, 0x8c, 0xdf, 0x1d, 0x2e, 0x61, 0xd9,
+	0x3d, 0x85, 0x3b, 0xcb, 0x12, 0x3d, 0x7d, 0x46, 0xe4, 0x7a, 0xb0, 0xe0, 0x3f, 0xa2, 0x7e, 0x0b,
+	0x2d, 0x77, 0xa9, 0x19, 0xaa, 0xc7, 0x00, 0xc2, 0xc2, 0x8c, 0xfa, 0x5a, 0xa1, 0x63, 0xce, 0x8a,
+	0xe8, 0x09, 0xb4, 0x25, 0x8a, 0x2b, 0xca, 0x46, 0x99, 0x79, 0x4b, 0xae, 0xa0, 0xe3, 0xfa, 0x44,
+	0x91, 0xf8, 0x7b, 0x00, 0xed, 0x14, 0x25, 0xaf, 0x44, 0x8e, 0x7e, 0x4e, 0x85, 0xc3, 0x59, 0x2d,
+	0xe5, 0xb6, 0x27, 0xdf, 0xeb, 0xb4, 0xeb, 0x22, 0x46, 0x26, 0xfe, 0x11, 0xcd, 0x45, 0x6f, 0xc8,
+	0x04, 0x75, 0x8f, 0xfc, 0x2b, 0x43, 0xe1, 0x22, 0xb7, 0xe0, 0x7a, 0x8f, 0xcd, 0xd5, 0x1e, 0x39,
+	0x34, 0x4f, 0xb1, 0x9c, 0x46, 0xcf, 0x61, 0xbd, 0xa4, 0x6c, 0xec, 0xc3, 0x7f, 0x50, 0x0f, 0x5f,
+	0x0b, 0x92, 0x73, 0xca, 0xc6, 0xa9, 0xd5, 0x74, 0x0f, 0xa0, 0xa9, 0xe1, 0xf5, 0xf2, 0xc1, 0x4a,
+	0x79, 0xfd, 0x15, 0x54, 0xc2, 0x3f, 0x30, 0xbd, 0x8c, 0xfb, 0xb0, 0x75, 0xce, 0x73, 0x52, 0xd2,
+	0x6f, 0x58, 0x5c, 0xa0, 0x94, 0x64, 0x84, 0xfa, 0x25, 0x96, 0x9a, 0xf3, 0xfd, 0x3b, 0xa4, 0xe7,
+	0x6c, 0x62, 0x25, 0x7e, 0xce, 0x1c, 0x3c, 0x6c, 0x7f, 0x84, 0xc5, 0x07, 0x3a, 0xd8, 0x30, 0x5f,
+	0xc3, 0x8b, 0x3f, 0x03, 0x00, 0xbb, 0x7c, 0x30, 0xae, 0x55, 0x05, 0x00, 0x00,
+}
@@ -2,87 +2,197 @@ package grpcexpect
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
-	"reflect"
+	"regexp"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/fullstorydev/grpcurl"
 	"github.com/golang/protobuf/proto"
 	otgrpc "github.com/grpc-ecosystem/go-grpc-middleware/tracing/opentracing"
 	"github.com/jhump/protoreflect/desc"
 	"github.com/jhump/protoreflect/dynamic"
-	"github.com/jhump/protoreflect/grpcreflect"
 	"github.com/opentracing-contrib/go-stdlib/nethttp"
 	"golang.org/x/oauth2"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/credentials/oauth"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/metadata"
-	reflectpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
 	"google.golang.org/grpc/status"
 
-	"github.com/blippar/aragorn/pkg/util/json"
+	"github.com/blippar/aragorn/pkg/auth"
 	"github.com/blippar/aragorn/plugin"
 	"github.com/blippar/aragorn/testsuite"
+	_ "github.com/blippar/aragorn/testsuite/grpcexpect/errdetails" // Registers the google.rpc status details.
 )
 
-var _ testsuite.Suite = (*Suite)(nil)
+var (
+	_ testsuite.Suite  = (*Suite)(nil)
+	_ testsuite.Closer = (*Suite)(nil)
+)
 
 // Suite describes a GRPC test suite.
 type Suite struct {
-	tests []testsuite.Test
+	tests      []testsuite.Test
+	fileSource grpcurl.DescriptorSource // Set when the descriptors are loaded from a protoset or proto files.
+	dialer     *dialer                  // Nil for the web protocols.
+	closeOnce  sync.Once
 }
 
-// New returns a Suite.
+// New returns a Suite. Its server is only dialed when its tests run.
 func New(cfg *Config) (*Suite, error) {
-	ctx := context.Background()
-	tcOpt, err := cfg.transportDialOption()
+	tlsCfg, err := cfg.tlsConfig()
 	if err != nil {
 		return nil, err
 	}
-	opts := []grpc.DialOption{
-		grpc.WithUnaryInterceptor(otgrpc.UnaryClientInterceptor()),
-		grpc.WithStreamInterceptor(otgrpc.StreamClientInterceptor()),
-		tcOpt,
-	}
+	httpClient := &http.Client{Transport: &nethttp.Transport{}}
+	var signer auth.Signer
 	if cfg.OAUTH2 != nil {
-		httpClient := &http.Client{Transport: &nethttp.Transport{}}
+		if cfg.Auth != nil {
+			return nil, errors.New("only one of oauth2 or auth can be set at once")
+		}
 		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, httpClient)
-		ts := &oauth.TokenSource{TokenSource: cfg.OAUTH2.TokenSource(ctx)}
-		opts = append(opts, grpc.WithPerRPCCredentials(ts))
+		signer = auth.TokenSigner(cfg.OAUTH2.TokenSource(ctx))
+	}
+	if cfg.Auth != nil {
+		if signer, err = cfg.Auth.NewSigner(cfg.Root, httpClient); err != nil {
+			return nil, fmt.Errorf("auth: %v", err)
+		}
 	}
-	cc, err := grpc.Dial(cfg.Address, opts...)
+	fileSource, err := cfg.fileDescriptorSource()
 	if err != nil {
 		return nil, err
 	}
-	var descSource grpcurl.DescriptorSource
-	if cfg.ProtoSetPath != "" {
-		psPath := cfg.getFilePath(cfg.ProtoSetPath)
-		descSource, err = grpcurl.DescriptorSourceFromProtoSets(psPath)
-		if err != nil {
-			return nil, err
+	target := cfg.Address
+	socketPath, unix := cfg.unixSocketPath()
+	if unix {
+		target = socketPath
+	}
+	var kp keepalive.ClientParameters
+	if cfg.Keepalive != nil {
+		kp = keepalive.ClientParameters{
+			Time:                time.Duration(cfg.Keepalive.Time),
+			Timeout:             time.Duration(cfg.Keepalive.Timeout),
+			PermitWithoutStream: cfg.Keepalive.PermitWithoutStream,
 		}
-	} else {
-		refClient := grpcreflect.NewClient(ctx, reflectpb.NewServerReflectionClient(cc))
-		descSource = grpcurl.DescriptorSourceFromServer(ctx, refClient)
 	}
-	tests, err := cfg.genTests(cc, descSource)
+	requireTLS := cfg.sendsCredentials() && !cfg.PlaintextAuth
+	d := &dialer{
+		key: connKey{
+			address:            target,
+			tls:                cfg.TLS,
+			caPath:             cfg.getFilePath(cfg.CAPath),
+			clientCertPath:     cfg.getFilePath(cfg.ClientCertPath),
+			clientKeyPath:      cfg.getFilePath(cfg.ClientKeyPath),
+			serverHostOverride: cfg.ServerHostOverride,
+			insecure:           cfg.Insecure,
+			authority:          cfg.Authority,
+			keepalive:          kp,
+		},
+		opts: []grpc.DialOption{
+			grpc.WithUnaryInterceptor(unaryCallOptions(otgrpc.UnaryClientInterceptor())),
+			grpc.WithStreamInterceptor(streamCallOptions(otgrpc.StreamClientInterceptor())),
+			grpc.WithPerRPCCredentials(perRPCAuth{requireTLS: requireTLS}),
+			transportDialOption(tlsCfg),
+		},
+		timeout:     defaultDialTimeout,
+		fileSource:  fileSource,
+		descRefresh: defaultDescriptorRefresh,
+	}
+	if cfg.Authority != "" {
+		d.opts = append(d.opts, grpc.WithAuthority(cfg.Authority))
+	}
+	if cfg.Keepalive != nil {
+		d.opts = append(d.opts, grpc.WithKeepaliveParams(kp))
+	}
+	if unix {
+		d.opts = append(d.opts, grpc.WithDialer(dialUnix))
+	}
+	if cfg.DialTimeout > 0 {
+		d.timeout = time.Duration(cfg.DialTimeout)
+	}
+	if cfg.DescriptorRefresh > 0 {
+		d.descRefresh = time.Duration(cfg.DescriptorRefresh)
+	}
+	var web *webClient
+	switch cfg.Protocol {
+	case "", protocolGRPC:
+	case protocolGRPCWeb, protocolConnect:
+		// The reflection API is a bidirectional stream, which can't be called
+		// through the web protocols.
+		if fileSource == nil {
+			return nil, fmt.Errorf("protocol %s requires protoSetPath or protoFiles", cfg.Protocol)
+		}
+		if cfg.Smoke != nil {
+			return nil, errors.New("smoke requires protocol grpc")
+		}
+		if cfg.Authority != "" || cfg.Keepalive != nil || unix {
+			return nil, errors.New("authority, keepalive and unix addresses require protocol grpc")
+		}
+		web = &webClient{
+			protocol: cfg.Protocol,
+			baseURL:  cfg.webBaseURL(),
+			client: &http.Client{Transport: &nethttp.Transport{
+				RoundTripper: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsCfg},
+			}},
+			descSource: fileSource,
+		}
+	default:
+		return nil, fmt.Errorf("unknown protocol %q", cfg.Protocol)
+	}
+	if secure := cfg.TLS || web != nil && strings.HasPrefix(web.baseURL, "https://"); requireTLS && !secure {
+		return nil, errors.New("auth: credentials can't be sent without TLS unless plaintextAuth is set")
+	}
+	callOpts, err := cfg.newCallOptions(nil, web != nil)
 	if err != nil {
 		return nil, err
 	}
-	return &Suite{tests: tests}, nil
+	tests, err := cfg.genTests(d, web, signer, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Smoke != nil {
+		smokeTests, err := cfg.genSmokeTests(d, signer, callOpts)
+		if err != nil {
+			return nil, err
+		}
+		tests = append(tests, smokeTests...)
+	}
+	s := &Suite{tests: tests, fileSource: fileSource}
+	if web == nil {
+		d.pc = pool.acquire(d.key)
+		s.dialer = d
+	}
+	return s, nil
 }
 
 func (s *Suite) Tests() []testsuite.Test { return s.tests }
 
+// Close releases the connection of the suite, closed once no other suite
+// shares it.
+func (s *Suite) Close() error {
+	if s.dialer != nil {
+		s.closeOnce.Do(func() { pool.release(s.dialer.key) })
+	}
+	return nil
+}
+
 type test struct {
-	cc         *grpc.ClientConn
-	descSource grpcurl.DescriptorSource
+	dialer *dialer
+	web    *webClient  // Calls the method instead of the dialed connection, if set.
+	signer auth.Signer // Authenticates the calls, if set.
 
+	callOpts    *callOptions
 	name        string
 	description string
+	id          string
+	saveDoc     bool // Save the received messages in the metadata under id.
 	req         request
+	script      []*scriptStep // Replaces the request messages, if set.
 	expect      expect
 }
 
@@ -95,15 +205,63 @@ type request struct {
 type expect struct {
 	code   codes.Code
 	header testsuite.Header
-	msgs   [][]byte
+	msgs   [][]byte // Messages expected in order, if not nil.
+	stream *streamExpect
+	values map[string]interface{}
+	match  matcher
+
+	trailer  testsuite.Header
+	message  string
+	msgRegex *regexp.Regexp
+	details  []detail
 }
 
 func (t *test) Name() string        { return t.name }
 func (t *test) Description() string { return t.description }
 
 func (t *test) Run(ctx context.Context, logger testsuite.Logger) {
-	h := &handler{reqs: t.req.msgs}
-	err := grpcurl.InvokeRpc(ctx, t.descSource, t.cc, t.req.methodName, t.req.headers, h, h.getRequestData)
+	if t.signer != nil {
+		ctx = withSigner(ctx, t.signer)
+	}
+	md, _ := testsuite.MDFromContext(ctx)
+	reqs, headers := t.req.msgs, t.req.headers
+	if md != nil {
+		var err error
+		if reqs, err = expandMsgs(reqs, md); err != nil {
+			logger.Errorf("request: %v", err)
+			return
+		}
+		if headers, err = expandHeaders(headers, md); err != nil {
+			logger.Errorf("request: header: %v", err)
+			return
+		}
+	}
+	var c *conn
+	if t.web == nil {
+		var err error
+		if c, err = t.dialer.dial(ctx); err != nil {
+			logger.Error(err)
+			return
+		}
+	}
+	ctx, cancel := t.callOpts.context(ctx)
+	defer cancel()
+	h := &handler{reqs: reqs}
+	var (
+		descSource grpcurl.DescriptorSource
+		err        error
+	)
+	switch {
+	case t.web != nil:
+		descSource = t.web.descSource
+		err = t.web.invoke(ctx, t.req.methodName, headers, t.signer, t.callOpts.maxRecvMsgSize, h)
+	case t.script != nil:
+		descSource = c.descSource
+		err = t.runScript(ctx, c, headers, h, logger)
+	default:
+		descSource = c.descSource
+		err = grpcurl.InvokeRpc(ctx, c.descSource, c.cc, t.req.methodName, headers, h, h.getRequestData)
+	}
 	if err != nil {
 		logger.Errorf("could not invoke method: %v", err)
 		return
@@ -122,30 +280,59 @@ func (t *test) Run(ctx context.Context, logger testsuite.Logger) {
 			logger.Errorf("wrong value for header %q (got %q; want %q)", k, got, want)
 		}
 	}
-	if len(t.expect.msgs) != len(h.resps) {
+	if want := t.expect.message; want != "" && h.status.Message() != want {
+		logger.Errorf("wrong status message (got %q; want %q)", h.status.Message(), want)
+	}
+	if re := t.expect.msgRegex; re != nil && !re.MatchString(h.status.Message()) {
+		logger.Errorf("status message does not match %q (got %q)", re, h.status.Message())
+	}
+	for k, want := range t.expect.trailer {
+		vs := h.trailer[k]
+		if len(vs) == 0 {
+			logger.Errorf("missing trailer %s", k)
+			continue
+		}
+		if got := vs[0]; got != want {
+			logger.Errorf("wrong value for trailer %q (got %q; want %q)", k, got, want)
+		}
+	}
+	if t.expect.details != nil {
+		t.checkDetails(descSource, h.status, logger)
+	}
+	outType := h.methodDesc.GetOutputType()
+	if t.expect.stream != nil {
+		t.expect.stream.check(outType, h.resps, &t.expect.match, logger)
+	}
+	if t.expect.msgs != nil && len(t.expect.msgs) != len(h.resps) {
 		logger.Errorf("wrong number of response (got %d; want %d)", len(h.resps), len(t.expect.msgs))
 	}
 	for i, wantRaw := range t.expect.msgs {
 		if i >= len(h.resps) {
 			break
 		}
-		got := h.resps[i]
-		var want proto.Message
-		switch v := got.(type) {
-		case *dynamic.Message:
-			gotCopy := *v
-			gotCopy.Reset()
-			want = &gotCopy
-		default:
-			wantRef := reflect.New(reflect.TypeOf(got).Elem())
-			want = wantRef.Interface().(proto.Message)
-		}
-		if err := json.Unmarshal(wantRaw, want); err != nil {
+		want, err := newMessage(outType, wantRaw)
+		if err != nil {
 			logger.Errorf("could not unmarshal expected document: %v", err)
 			continue
 		}
-		if !dynamic.MessagesEqual(got, want) {
-			logger.Errorf("wrong response\ngot: %s\nwant: %s", got, want)
+		if d, err := t.expect.match.diff(h.resps[i], want, wantRaw); err != nil {
+			logger.Error(err)
+		} else if d != "" {
+			logger.Errorf("wrong response:\n%s", d)
+		}
+	}
+	if t.expect.values != nil {
+		for i, resp := range h.resps {
+			prefix := ""
+			if h.methodDesc.IsServerStreaming() {
+				prefix = fmt.Sprintf("message %d: ", i)
+			}
+			checkValues(resp, t.expect.values, prefix, logger)
+		}
+	}
+	if t.saveDoc && md != nil {
+		if err := saveDocument(md, t.id, h.resps, h.methodDesc.IsServerStreaming()); err != nil {
+			logger.Errorf("could not save document: %v", err)
 		}
 	}
 }
@@ -157,15 +344,19 @@ type handler struct {
 
 	methodDesc *desc.MethodDescriptor
 	md         metadata.MD
+	trailer    metadata.MD
 	status     *status.Status
 	resps      []proto.Message
 }
 
-func (h *handler) OnResolveMethod(md *desc.MethodDescriptor)               { h.methodDesc = md }
-func (*handler) OnSendHeaders(md metadata.MD)                              {}
-func (h *handler) OnReceiveHeaders(md metadata.MD)                         { h.md = md }
-func (h *handler) OnReceiveTrailers(status *status.Status, md metadata.MD) { h.status = status }
-func (h *handler) OnReceiveResponse(resp proto.Message)                    { h.resps = append(h.resps, resp) }
+func (h *handler) OnResolveMethod(md *desc.MethodDescriptor) { h.methodDesc = md }
+func (*handler) OnSendHeaders(md metadata.MD)                {}
+func (h *handler) OnReceiveHeaders(md metadata.MD)           { h.md = md }
+func (h *handler) OnReceiveTrailers(status *status.Status, md metadata.MD) {
+	h.status = status
+	h.trailer = md
+}
+func (h *handler) OnReceiveResponse(resp proto.Message) { h.resps = append(h.resps, resp) }
 
 func (h *handler) getRequestData() ([]byte, error) {
 	h.mu.Lock()
@@ -178,6 +369,15 @@ func (h *handler) getRequestData() ([]byte, error) {
 	return req, nil
 }
 
+// newMessage returns a message of type md decoded from its JSON form.
+func newMessage(md *desc.MessageDescriptor, raw []byte) (*dynamic.Message, error) {
+	msg := dynamic.NewMessage(md)
+	if err := msg.UnmarshalJSON(raw); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
 func init() {
 	plugin.Register(&plugin.Registration{
 		Type:   plugin.TestSuitePlugin,
@@ -1,24 +1,50 @@
 //go:generate protoc --descriptor_set_out=grpctesting/test.protoset --include_imports --go_out=plugins=grpc:. grpctesting/test.proto
+//go:generate protoc --go_out=. errdetails/error_details.proto
 
 package grpcexpect
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	gojson "encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/duration"
 	"github.com/golang/protobuf/ptypes/empty"
 	"github.com/google/go-cmp/cmp"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
 
+	"github.com/blippar/aragorn/pkg/auth"
+	"github.com/blippar/aragorn/pkg/util/json"
 	"github.com/blippar/aragorn/testsuite"
+	"github.com/blippar/aragorn/testsuite/grpcexpect/errdetails"
 	"github.com/blippar/aragorn/testsuite/grpcexpect/grpctesting"
 )
 
@@ -64,6 +90,190 @@ func TestNewProtosetNotFound(t *testing.T) {
 	}
 }
 
+func TestNewProtoFiles(t *testing.T) {
+	l, err := newGRPCTestServer(false)
+	if err != nil {
+		t.Errorf("grpc server init: %v", err)
+	}
+	defer l.Close()
+	cfg := &Config{
+		Address:     l.Addr().String(),
+		ProtoFiles:  []string{"test.proto"},
+		ImportPaths: []string{"grpctesting"},
+		Tests: []TestConfig{
+			{
+				Name:    "Empty Call",
+				Request: RequestConfig{Method: "grpcexpect.testing.TestService/EmptyCall"},
+				Expect:  ExpectConfig{Code: codes.OK},
+			},
+			{
+				Name: "Get User",
+				Request: RequestConfig{
+					Method:   "grpcexpect.testing.TestService/GetUser",
+					Document: map[string]interface{}{"username": "bob"},
+				},
+				Expect: ExpectConfig{Values: map[string]interface{}{"addresses.0.city": "Paris"}},
+			},
+		},
+	}
+	checkSuite(t, cfg, [][]string{nil, nil})
+}
+
+func TestNewProtoFilesInvalid(t *testing.T) {
+	tt := []struct {
+		name string
+		cfg  *Config
+		err  string
+	}{
+		{
+			name: "parse error",
+			cfg:  &Config{Root: "testdata/proto", ProtoFiles: []string{"invalid.proto"}},
+			err:  "could not parse proto files: invalid.proto:6:40: method grpcexpect.testing.invalid.InvalidService.GetUser: unknown response type Usr",
+		},
+		{
+			name: "not found",
+			cfg:  &Config{Root: "testdata/proto", ProtoFiles: []string{"unknown.proto"}},
+			err:  "could not parse proto files: open testdata/proto/unknown.proto: no such file or directory",
+		},
+		{
+			name: "with protoset",
+			cfg:  &Config{ProtoSetPath: "./grpctesting/test.protoset", ProtoFiles: []string{"grpctesting/test.proto"}},
+			err:  "protoFiles can't be set with protoSetPath",
+		},
+		{
+			name: "import paths without files",
+			cfg:  &Config{ImportPaths: []string{"grpctesting"}},
+			err:  "importPaths requires protoFiles",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := New(tc.cfg); err == nil || err.Error() != tc.err {
+				t.Fatalf("new suite invalid error (got %v; want %v)", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestNewUnavailable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	cfg := &Config{
+		Address:     addr,
+		DialTimeout: json.Duration(50 * time.Millisecond),
+		Tests: []TestConfig{
+			{
+				Name:    "Empty Call",
+				Request: RequestConfig{Method: "grpcexpect.testing.TestService/EmptyCall"},
+			},
+		},
+	}
+	testsErrs := [][]string{
+		{fmt.Sprintf("could not connect to %s: context deadline exceeded", addr)},
+	}
+	checkSuite(t, cfg, testsErrs)
+}
+
+func TestConnPool(t *testing.T) {
+	l, err := newGRPCTestServer(true)
+	if err != nil {
+		t.Errorf("grpc server init: %v", err)
+	}
+	defer l.Close()
+	var suites []*Suite
+	dial := func(cfg *Config) (*conn, error) {
+		cfg.Address = l.Addr().String()
+		cfg.Tests = []TestConfig{{Name: "Empty Call"}}
+		s, err := New(cfg)
+		if err != nil {
+			t.Fatalf("new suite failed: %v", err)
+		}
+		suites = append(suites, s)
+		return s.Tests()[0].(*test).dialer.dial(context.Background())
+	}
+	c1, err := dial(&Config{Header: testsuite.Header{"hello": "world"}})
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	c2, err := dial(&Config{Auth: &auth.Config{Bearer: "token"}, PlaintextAuth: true})
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	if c1.cc != c2.cc {
+		t.Error("suites dialing the same address use different connections")
+	}
+	if c1.descSource != c2.descSource {
+		t.Error("suites dialing the same address use different descriptor sources")
+	}
+	if _, err := dial(&Config{TLS: true, Insecure: true, DialTimeout: json.Duration(50 * time.Millisecond)}); err == nil {
+		t.Error("suite with TLS reused a plaintext connection")
+	}
+	c4, err := dial(&Config{DescriptorRefresh: json.Duration(time.Nanosecond)})
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	if c4.cc != c1.cc || c4.descSource == c1.descSource {
+		t.Error("descriptors fetched by reflection were not refreshed")
+	}
+	for _, s := range suites[1:] {
+		s.Close()
+	}
+	if state := c1.cc.GetState(); state == connectivity.Shutdown {
+		t.Error("connection closed while referenced by a suite")
+	}
+	suites[0].Close()
+	suites[0].Close()
+	if state := c1.cc.GetState(); state != connectivity.Shutdown {
+		t.Errorf("connection not closed once released by every suite (state %s)", state)
+	}
+	c5, err := dial(&Config{})
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	if c5.cc == c1.cc {
+		t.Error("suite reused a closed connection")
+	}
+	suites[len(suites)-1].Close()
+}
+
+func TestConnPoolConcurrentDial(t *testing.T) {
+	// The TLS handshake with a server that never answers blocks the dial.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer l.Close()
+	cfg := &Config{
+		Address:      l.Addr().String(),
+		ProtoSetPath: "./grpctesting/test.protoset",
+		TLS:          true,
+		Insecure:     true,
+		DialTimeout:  json.Duration(2 * time.Second),
+		Tests:        []TestConfig{{Name: "Empty Call"}},
+	}
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatalf("new suite failed: %v", err)
+	}
+	defer s.Close()
+	d := s.Tests()[0].(*test).dialer
+	go d.dial(context.Background())
+	time.Sleep(50 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := d.dial(ctx); err == nil {
+		t.Fatal("dial of an unresponsive server succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("dial waited for the ongoing one for %s", elapsed)
+	}
+}
+
 func TestNewReflect(t *testing.T) {
 	l, err := newGRPCTestServer(true)
 	if err != nil {
@@ -177,7 +387,7 @@ func TestNewReflect(t *testing.T) {
 		{"missing header test"},
 		nil,
 		{`wrong value for header "hello" (got "123"; want "world")`},
-		{"wrong response\ngot: message:\"Hello test!\"\nwant: message:\"Hello world!\""},
+		{"wrong response:\n- message: got \"Hello test!\"; want \"Hello world!\""},
 		{`could not invoke method: could not parse given request body as message of type "grpcexpect.testing.SimpleRequest": Message type grpcexpect.testing.SimpleRequest has no known field named invalid_field`},
 		{`could not unmarshal expected document: Message type grpcexpect.testing.SimpleResponse has no known field named test`},
 		{"wrong number of response (got 1; want 2)"},
@@ -187,69 +397,1409 @@ func TestNewReflect(t *testing.T) {
 	checkSuite(t, cfg, testsErrs)
 }
 
-func checkSuite(t *testing.T, cfg *Config, testsErrs [][]string) {
-	s, err := New(cfg)
+func TestNewStream(t *testing.T) {
+	l, err := newGRPCTestServer(true)
 	if err != nil {
-		t.Fatalf("new suite failed: %v", err)
+		t.Errorf("grpc server init: %v", err)
 	}
-	tests := s.Tests()
-	if len(tests) != len(testsErrs) {
-		panic("len(tests) != len(testsErrs)")
+	defer l.Close()
+	hello := func(name string) map[string]interface{} {
+		return map[string]interface{}{"message": "Hello " + name + "!"}
 	}
-	ctx := context.Background()
-	for i, test := range tests {
-		test := test
-		t.Run(test.Name(), func(t *testing.T) {
-			l := &mockLogger{}
-			test.Run(ctx, l)
-			if !cmp.Equal(l.errs, testsErrs[i]) {
-				t.Errorf("unexpected errors (got %v; want %v)", l.errs, testsErrs[i])
-			}
-		})
+	usernames := func(names ...interface{}) map[string]interface{} {
+		return map[string]interface{}{"usernames": names}
+	}
+	username := func(name string) map[string]interface{} {
+		return map[string]interface{}{"username": name}
+	}
+	cfg := &Config{
+		Address: l.Addr().String(),
+		Tests: []TestConfig{
+			{
+				Name:    "Stream",
+				Request: RequestConfig{Method: "grpcexpect.testing.TestService/StreamCall", Document: usernames("a", "b", "c")},
+				Expect: ExpectConfig{Stream: &StreamConfig{
+					MinMessages: 2,
+					MaxMessages: 5,
+					Contains:    []interface{}{hello("b")},
+					At:          map[string]interface{}{"0": hello("a"), "-1": hello("c")},
+				}},
+			},
+			{
+				Name:    "Stream with unexpected messages",
+				Request: RequestConfig{Method: "grpcexpect.testing.TestService/StreamCall", Document: usernames("a", "b")},
+				Expect: ExpectConfig{Stream: &StreamConfig{
+					MinMessages: 3,
+					Contains:    []interface{}{hello("z")},
+					At:          map[string]interface{}{"5": hello("f"), "-2": hello("b")},
+				}},
+			},
+			{
+				Name:    "Stream with too many messages",
+				Request: RequestConfig{Method: "grpcexpect.testing.TestService/StreamCall", Document: usernames("a", "b")},
+				Expect:  ExpectConfig{Stream: &StreamConfig{MaxMessages: 1}},
+			},
+			{
+				Name:    "Stream ended by an error",
+				Request: RequestConfig{Method: "grpcexpect.testing.TestService/StreamCall", Document: usernames("a", "")},
+				Expect:  ExpectConfig{Code: codes.InvalidArgument, Stream: &StreamConfig{At: map[string]interface{}{"0": hello("a")}}},
+			},
+			{
+				Name:    "Script",
+				Request: RequestConfig{Method: "grpcexpect.testing.TestService/BidiCall"},
+				Script: []ScriptStep{
+					{Send: username("a")},
+					{Receive: hello("a")},
+					{Send: []interface{}{username("b"), username("c")}},
+					{Receive: []interface{}{hello("b"), hello("c")}},
+					{CloseSend: true},
+				},
+				Expect: ExpectConfig{Stream: &StreamConfig{MaxMessages: 3}},
+			},
+			{
+				Name:    "Script with remaining messages",
+				Request: RequestConfig{Method: "grpcexpect.testing.TestService/BidiCall"},
+				Script:  []ScriptStep{{Send: []interface{}{username("a"), username("b")}}},
+				Expect:  ExpectConfig{Document: []interface{}{hello("a"), hello("b")}},
+			},
+			{
+				Name:    "Script with wrong response",
+				Request: RequestConfig{Method: "grpcexpect.testing.TestService/BidiCall"},
+				Script: []ScriptStep{
+					{Send: username("a")},
+					{Receive: hello("b")},
+				},
+			},
+			{
+				Name:    "Script with stream ended",
+				Request: RequestConfig{Method: "grpcexpect.testing.TestService/BidiCall"},
+				Script: []ScriptStep{
+					{Send: username("bye")},
+					{Receive: hello("bye")},
+				},
+			},
+			{
+				Name:    "Script on unary method",
+				Request: RequestConfig{Method: "grpcexpect.testing.TestService/SimpleCall"},
+				Script:  []ScriptStep{{Send: username("a")}},
+			},
+		},
+	}
+	testsErrs := [][]string{
+		nil,
+		{
+			"too few messages (got 2; want at least 3)",
+			`no message matching message:"Hello z!"`,
+			"wrong message -2:\n- message: got \"Hello a!\"; want \"Hello b!\"",
+			"missing message 5 (got 2 messages)",
+		},
+		{"too many messages (got 2; want at most 1)"},
+		nil,
+		nil,
+		nil,
+		{"script step 1: wrong response:\n- message: got \"Hello a!\"; want \"Hello b!\""},
+		{"script step 1: stream ended before receiving a message"},
+		{"could not invoke method: a script requires a bidirectional streaming method, grpcexpect.testing.TestService.SimpleCall is not"},
 	}
+	checkSuite(t, cfg, testsErrs)
 }
 
-type mockLogger struct {
-	errs []string
+func TestNewPartial(t *testing.T) {
+	l, err := newGRPCTestServer(true)
+	if err != nil {
+		t.Errorf("grpc server init: %v", err)
+	}
+	defer l.Close()
+	user := map[string]interface{}{
+		"username":    "john",
+		"displayName": "John",
+		"addresses": []interface{}{
+			map[string]interface{}{"city": "Paris"},
+			map[string]interface{}{"city": "London"},
+		},
+	}
+	cfg := &Config{
+		Address: l.Addr().String(),
+		Tests: []TestConfig{
+			{
+				Name:    "Ignored fields",
+				Request: RequestConfig{Method: "grpcexpect.testing.TestService/GetUser", Document: map[string]interface{}{"username": "john"}},
+				Expect:  ExpectConfig{Document: user, Ignore: []string{"createdAt", "addresses.updatedAt"}},
+			},
+			{
+				Name:    "Ignored fields with wrong values",
+				Request: RequestConfig{Method: "grpcexpect.testing.TestService/GetUser", Document: map[string]interface{}{"username": "bob"}},
+				Expect: ExpectConfig{
+					Document: map[string]interface{}{
+						"username":  "bob",
+						"addresses": []interface{}{map[string]interface{}{"city": "Paris"}},
+					},
+					Ignore: []string{"createdAt", "addresses.updatedAt"},
+				},
+			},
+			{
+				Name:    "Subset",
+				Request: RequestConfig{Method: "grpcexpect.testing.TestService/GetUser", Document: map[string]interface{}{"username": "john"}},
+				Expect: ExpectConfig{
+					Document: map[string]interface{}{
+						"displayName": "John",
+						"addresses":   []interface{}{map[string]interface{}{}, map[string]interface{}{"city": "London"}},
+					},
+					Mode: "subset",
+				},
+			},
+			{
+				Name:    "Subset with wrong values",
+				Request: RequestConfig{Method: "grpcexpect.testing.TestService/GetUser", Document: map[string]interface{}{"username": "john"}},
+				Expect: ExpectConfig{
+					Document: map[string]interface{}{
+						"display_name": "Johnny",
+						"addresses":    []interface{}{map[string]interface{}{"city": "Paris"}},
+					},
+					Mode: "subset",
+				},
+			},
+			{
+				Name:    "Subset with default values",
+				Request: RequestConfig{Method: "grpcexpect.testing.TestService/GetUser", Document: map[string]interface{}{"username": "john"}},
+				Expect: ExpectConfig{
+					Document: map[string]interface{}{
+						"username":    "john",
+						"displayName": "",
+						"addresses":   []interface{}{map[string]interface{}{"city": ""}, map[string]interface{}{}},
+					},
+					Mode: "subset",
+				},
+			},
+			{
+				Name:    "Values",
+				Request: RequestConfig{Method: "grpcexpect.testing.TestService/GetUser", Document: map[string]interface{}{"username": "john"}},
+				Expect: ExpectConfig{
+					Values: map[string]interface{}{
+						"username":         "john",
+						"addresses.length": gojson.Number("2"),
+						"addresses.1.city": "Berlin",
+						"nickname":         "jo",
+					},
+				},
+			},
+			{
+				Name:    "Values of stream",
+				Request: RequestConfig{Method: "grpcexpect.testing.TestService/StreamCall", Document: map[string]interface{}{"usernames": []interface{}{"a", "b"}}},
+				Expect: ExpectConfig{
+					Stream: &StreamConfig{Contains: []interface{}{map[string]interface{}{}}},
+					Values: map[string]interface{}{"message": "Hello a!"},
+					Mode:   "subset",
+				},
+			},
+		},
+	}
+	testsErrs := [][]string{
+		nil,
+		{"wrong response:\n- addresses: wrong length (got 2; want 1)\n- displayName: got \"Bob\"; want \"\""},
+		nil,
+		{"wrong response:\n- addresses: wrong length (got 2; want 1)\n- displayName: got \"John\"; want \"Johnny\""},
+		{"wrong response:\n- addresses.0.city: got \"Paris\"; want \"\"\n- displayName: got \"John\"; want \"\""},
+		{
+			`wrong value for query "addresses.1.city" (got London; want Berlin)`,
+			`could not get value for query "nickname": nickname: object does not contain field`,
+		},
+		{`message 1: wrong value for query "message" (got Hello b!; want Hello a!)`},
+	}
+	checkSuite(t, cfg, testsErrs)
 }
 
-func (l *mockLogger) Error(args ...interface{}) {
-	l.errs = append(l.errs, fmt.Sprint(args...))
+func TestCheckValuesInt64(t *testing.T) {
+	user := &grpctesting.User{
+		CreatedAt: 1525176000,
+		Addresses: []*grpctesting.Address{{City: "Paris", UpdatedAt: 42}},
+	}
+	values := map[string]interface{}{
+		"createdAt":             gojson.Number("1525176000"),
+		"addresses.0.updatedAt": "42",
+		"addresses.length":      gojson.Number("1"),
+	}
+	logger := &mockLogger{}
+	checkValues(user, values, "", logger)
+	if len(logger.errs) != 0 {
+		t.Errorf("unexpected errors: %v", logger.errs)
+	}
+	values = map[string]interface{}{"createdAt": gojson.Number("1525176001")}
+	checkValues(user, values, "", logger)
+	want := []string{`wrong value for query "createdAt" (got 1525176000; want 1525176001)`}
+	if diff := cmp.Diff(logger.errs, want); diff != "" {
+		t.Errorf("wrong errors (-got +want):\n%s", diff)
+	}
 }
 
-func (l *mockLogger) Errorf(format string, args ...interface{}) {
-	l.errs = append(l.errs, fmt.Sprintf(format, args...))
+func TestMatcherSubsetInt64(t *testing.T) {
+	got := &grpctesting.User{Username: "john", CreatedAt: 1525176000}
+	tt := []struct {
+		doc  string
+		want string
+	}{
+		{doc: `{"created_at": 1525176000}`},
+		{doc: `{"createdAt": "1525176000", "displayName": ""}`},
+		{doc: `{"createdAt": 0}`, want: "- createdAt: got 1525176000; want 0"},
+		{doc: `{"username": ""}`, want: `- username: got "john"; want ""`},
+	}
+	m := matcher{subset: true}
+	for _, tc := range tt {
+		want := &grpctesting.User{}
+		if err := jsonpb.UnmarshalString(tc.doc, want); err != nil {
+			t.Fatalf("could not unmarshal %s: %v", tc.doc, err)
+		}
+		d, err := m.diff(got, want, []byte(tc.doc))
+		if err != nil {
+			t.Errorf("diff %s failed: %v", tc.doc, err)
+		} else if d != tc.want {
+			t.Errorf("wrong diff for %s (got %q; want %q)", tc.doc, d, tc.want)
+		}
+	}
 }
 
-type testServer struct{}
-
-func newGRPCTestServer(reflect bool) (net.Listener, error) {
-	l, err := net.Listen("tcp", "127.0.0.1:0")
+func TestNewStatus(t *testing.T) {
+	l, err := newGRPCTestServer(true)
 	if err != nil {
-		return nil, err
+		t.Errorf("grpc server init: %v", err)
 	}
-	s := grpc.NewServer()
-	grpctesting.RegisterTestServiceServer(s, testServer{})
-	if reflect {
-		reflection.Register(s)
+	defer l.Close()
+	emptyUsername := RequestConfig{
+		Method:   "grpcexpect.testing.TestService/SimpleCall",
+		Document: map[string]interface{}{"username": ""},
 	}
-	go func() {
-		s.Serve(l)
-	}()
-	return l, nil
-}
-
-func (testServer) EmptyCall(ctx context.Context, in *empty.Empty) (*empty.Empty, error) {
-	return &empty.Empty{}, nil
+	cfg := &Config{
+		Address: l.Addr().String(),
+		Tests: []TestConfig{
+			{
+				Name:    "Status",
+				Request: emptyUsername,
+				Expect: ExpectConfig{
+					Code:     codes.InvalidArgument,
+					Document: []interface{}{},
+					Message:  "invalid username: must not be empty",
+					Trailer:  testsuite.Header{"request-id": "42"},
+					Details: []interface{}{
+						map[string]interface{}{
+							"@type":           "type.googleapis.com/google.rpc.BadRequest",
+							"fieldViolations": []interface{}{map[string]interface{}{"field": "username", "description": "must not be empty"}},
+						},
+						map[string]interface{}{
+							"@type":    "google.rpc.ErrorInfo",
+							"reason":   "EMPTY_USERNAME",
+							"domain":   "grpcexpect.testing",
+							"metadata": map[string]interface{}{"field": "username"},
+						},
+						map[string]interface{}{"@type": "google.rpc.RetryInfo", "retryDelay": "5s"},
+					},
+				},
+			},
+			{
+				Name:    "Status with wrong message and details",
+				Request: emptyUsername,
+				Expect: ExpectConfig{
+					Code:         codes.InvalidArgument,
+					Document:     []interface{}{},
+					MessageRegex: "^unknown",
+					Trailer:      testsuite.Header{"request-id": "43"},
+					Details: []interface{}{
+						map[string]interface{}{"@type": "google.rpc.ErrorInfo", "reason": "INVALID_USERNAME"},
+						map[string]interface{}{"@type": "google.rpc.Help"},
+					},
+					Mode: "subset",
+				},
+			},
+			{
+				Name:    "Status with missing trailer",
+				Request: emptyUsername,
+				Expect: ExpectConfig{
+					Code:     codes.InvalidArgument,
+					Document: []interface{}{},
+					Message:  "oops",
+					Trailer:  testsuite.Header{"retry-after": "5"},
+				},
+			},
+		},
+	}
+	testsErrs := [][]string{
+		nil,
+		{
+			`status message does not match "^unknown" (got "invalid username: must not be empty")`,
+			`wrong value for trailer "request-id" (got "42"; want "43")`,
+			"wrong status detail google.rpc.ErrorInfo:\n- reason: got \"EMPTY_USERNAME\"; want \"INVALID_USERNAME\"",
+			"missing status detail google.rpc.Help",
+		},
+		{
+			`wrong status message (got "invalid username: must not be empty"; want "oops")`,
+			"missing trailer retry-after",
+		},
+	}
+	checkSuite(t, cfg, testsErrs)
 }
 
-func (testServer) SimpleCall(ctx context.Context, in *grpctesting.SimpleRequest) (*grpctesting.SimpleResponse, error) {
-	md, _ := metadata.FromIncomingContext(ctx)
-	if vs := md["hello"]; len(vs) > 0 {
-		grpc.SetHeader(ctx, metadata.Pairs("hello", vs[0]))
+func TestNewStateful(t *testing.T) {
+	l, err := newGRPCTestServer(true)
+	if err != nil {
+		t.Errorf("grpc server init: %v", err)
 	}
-	return &grpctesting.SimpleResponse{
-		Message: fmt.Sprintf("Hello %s!", in.Username),
+	defer l.Close()
+	cfg := &Config{
+		Address: l.Addr().String(),
+		Tests: []TestConfig{
+			{
+				ID:   "user",
+				Name: "Get User",
+				Request: RequestConfig{
+					Method:   "grpcexpect.testing.TestService/GetUser",
+					Document: map[string]interface{}{"username": "bob"},
+				},
+				Expect:       ExpectConfig{Values: map[string]interface{}{"displayName": "Bob"}},
+				SaveDocument: true,
+			},
+			{
+				ID:   "greetings",
+				Name: "Stream Call with saved values",
+				Request: RequestConfig{
+					Method:   "grpcexpect.testing.TestService/StreamCall",
+					Document: map[string]interface{}{"usernames": []interface{}{"{{user.displayName}}", "Mr {{user.addresses.1.city}}"}},
+				},
+				Expect: ExpectConfig{Document: []interface{}{
+					map[string]interface{}{"message": "Hello Bob!"},
+					map[string]interface{}{"message": "Hello Mr London!"},
+				}},
+				SaveDocument: true,
+			},
+			{
+				Name: "Simple Call with saved values",
+				Request: RequestConfig{
+					Method:   "grpcexpect.testing.TestService/SimpleCall",
+					Header:   testsuite.Header{"hello": "{{user.username}}"},
+					Document: map[string]interface{}{"username": "{{greetings.1.message}}"},
+				},
+				Expect: ExpectConfig{
+					Header:   testsuite.Header{"hello": "bob"},
+					Document: map[string]interface{}{"message": "Hello Hello Mr London!!"},
+				},
+			},
+			{
+				Name: "Simple Call with unknown value",
+				Request: RequestConfig{
+					Method:   "grpcexpect.testing.TestService/SimpleCall",
+					Document: map[string]interface{}{"username": "{{user.email}}"},
+				},
+			},
+		},
+	}
+	testsErrs := [][]string{
+		nil,
+		nil,
+		nil,
+		{"request: could not get value for {{user.email}}: user.email: object does not contain field"},
+	}
+	checkSuite(t, cfg, testsErrs)
+}
+
+func TestNewSmoke(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("grpc server init: %v", err)
+	}
+	defer l.Close()
+	s := grpc.NewServer()
+	grpctesting.RegisterTestServiceServer(s, testServer{})
+	reflection.Register(s)
+	hs := health.NewServer()
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	hs.SetServingStatus("grpcexpect.testing.TestService", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(s, hs)
+	go s.Serve(l)
+
+	cfg := &Config{
+		Address: l.Addr().String(),
+		Smoke:   &SmokeConfig{Health: true, InvokeUnary: true},
+	}
+	testsErrs := [][]string{
+		{"service grpcexpect.testing.TestService: not serving (got NOT_SERVING)"},
+		{`method grpcexpect.testing.TestService.ProcessFile: wrong status code (got Internal) message="invalid name: (got \"\"; want \"John Doe\")"`},
+	}
+	checkSuite(t, cfg, testsErrs)
+
+	hs.SetServingStatus("grpcexpect.testing.TestService", healthpb.HealthCheckResponse_SERVING)
+	cfg.Smoke.Exclude = []string{"grpcexpect.testing.TestService/ProcessFile"}
+	checkSuite(t, cfg, [][]string{nil, nil})
+}
+
+// hangingServer is a testServer whose EmptyCall only returns once its
+// deadline is exceeded.
+type hangingServer struct{ testServer }
+
+func (hangingServer) EmptyCall(ctx context.Context, in *empty.Empty) (*empty.Empty, error) {
+	<-ctx.Done()
+	return nil, status.Error(codes.DeadlineExceeded, ctx.Err().Error())
+}
+
+func TestNewSmokeCallOptions(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("grpc server init: %v", err)
+	}
+	defer l.Close()
+	s := grpc.NewServer()
+	grpctesting.RegisterTestServiceServer(s, hangingServer{})
+	reflection.Register(s)
+	go s.Serve(l)
+
+	cfg := &Config{
+		Address:     l.Addr().String(),
+		Smoke:       &SmokeConfig{InvokeUnary: true, Exclude: []string{"grpcexpect.testing.TestService/ProcessFile"}},
+		CallOptions: &CallConfig{Timeout: json.Duration(50 * time.Millisecond)},
+	}
+	suite, err := New(cfg)
+	if err != nil {
+		t.Fatalf("new suite failed: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	start := time.Now()
+	logger := &mockLogger{}
+	suite.Tests()[0].Run(ctx, logger)
+	if logger.errs != nil {
+		t.Errorf("unexpected errors: %v", logger.errs)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("hanging method not stopped by the call timeout (took %s)", d)
+	}
+}
+
+func TestNewSmokeWithoutHealth(t *testing.T) {
+	l, err := newGRPCTestServer(true)
+	if err != nil {
+		t.Errorf("grpc server init: %v", err)
+	}
+	defer l.Close()
+	cfg := &Config{
+		Address: l.Addr().String(),
+		Smoke:   &SmokeConfig{Health: true},
+	}
+	testsErrs := [][]string{
+		{"server: could not check health: rpc error: code = Unimplemented desc = unknown service grpc.health.v1.Health"},
+	}
+	checkSuite(t, cfg, testsErrs)
+
+	cfg.Smoke.Health = false
+	want := "smoke: at least one of health or invokeUnary must be set"
+	if _, err := New(cfg); err == nil || err.Error() != want {
+		t.Fatalf("new suite invalid error (got %v; want %v)", err, want)
+	}
+}
+
+func TestNewCallOptions(t *testing.T) {
+	l, err := newGRPCTestServer(false)
+	if err != nil {
+		t.Fatalf("grpc server init: %v", err)
+	}
+	defer l.Close()
+	simpleCall := func(opts *CallConfig) RequestConfig {
+		return RequestConfig{
+			Method:      "grpcexpect.testing.TestService/SimpleCall",
+			Document:    map[string]interface{}{"username": "world"},
+			CallOptions: opts,
+		}
+	}
+	waitForReady := true
+	cfg := &Config{
+		Address:      l.Addr().String(),
+		ProtoSetPath: "./grpctesting/test.protoset",
+		CallOptions:  &CallConfig{Compression: "gzip", Timeout: json.Duration(time.Minute)},
+		Tests: []TestConfig{
+			{
+				Name:    "Compressed",
+				Request: simpleCall(nil),
+				Expect:  ExpectConfig{Code: codes.OK, Document: map[string]interface{}{"message": "Hello world!"}},
+			},
+			{
+				Name:    "Uncompressed",
+				Request: simpleCall(&CallConfig{Compression: "identity", WaitForReady: &waitForReady}),
+				Expect:  ExpectConfig{Code: codes.OK, Document: map[string]interface{}{"message": "Hello world!"}},
+			},
+			{
+				Name:    "Deadline",
+				Request: simpleCall(&CallConfig{Timeout: json.Duration(time.Nanosecond)}),
+				Expect:  ExpectConfig{Code: codes.DeadlineExceeded, Document: []interface{}{}},
+			},
+			{
+				Name:    "Max send message size",
+				Request: simpleCall(&CallConfig{MaxSendMsgSize: 4}),
+				Expect:  ExpectConfig{Code: codes.ResourceExhausted, Document: []interface{}{}},
+			},
+			{
+				Name:    "Max receive message size",
+				Request: simpleCall(&CallConfig{MaxRecvMsgSize: 4}),
+				Expect:  ExpectConfig{Code: codes.ResourceExhausted, Document: []interface{}{}},
+			},
+		},
+	}
+	checkSuite(t, cfg, [][]string{nil, nil, nil, nil, nil})
+
+	noWait := false
+	suiteOpts := &Config{CallOptions: &CallConfig{WaitForReady: &waitForReady}}
+	for _, tc := range []struct {
+		override *CallConfig
+		want     int
+	}{
+		{override: nil, want: 1},
+		{override: &CallConfig{}, want: 1},
+		{override: &CallConfig{WaitForReady: &noWait}, want: 0},
+	} {
+		o, err := suiteOpts.newCallOptions(tc.override, false)
+		if err != nil {
+			t.Fatalf("call options: %v", err)
+		}
+		if got := len(o.opts); got != tc.want {
+			t.Errorf("invalid number of options with override %+v (got %d; want %d)", tc.override, got, tc.want)
+		}
+	}
+
+	cfg.Tests[0].Request.CallOptions = &CallConfig{Compression: "br"}
+	want := `test 0 Compressed: request: callOptions: unknown compression "br"`
+	if _, err := New(cfg); err == nil || err.Error() != want {
+		t.Errorf("new suite invalid error (got %v; want %v)", err, want)
+	}
+	cfg.CallOptions.Timeout = json.Duration(-time.Second)
+	want = "callOptions: negative timeout -1s"
+	if _, err := New(cfg); err == nil || err.Error() != want {
+		t.Errorf("new suite invalid error (got %v; want %v)", err, want)
+	}
+}
+
+func TestNewUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grpcexpect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("grpc server init: %v", err)
+	}
+	defer l.Close()
+	s := grpc.NewServer()
+	grpctesting.RegisterTestServiceServer(s, testServer{})
+	go s.Serve(l)
+
+	cfg := &Config{
+		Address:      "unix://" + path,
+		ProtoSetPath: "./grpctesting/test.protoset",
+		Authority:    "test.local",
+		Keepalive:    &KeepaliveConfig{Time: json.Duration(time.Minute), PermitWithoutStream: true},
+		Tests: []TestConfig{
+			{
+				Name: "Simple Call",
+				Request: RequestConfig{
+					Method:   "grpcexpect.testing.TestService/SimpleCall",
+					Document: map[string]interface{}{"username": "world"},
+				},
+				Expect: ExpectConfig{
+					Code:     codes.OK,
+					Header:   testsuite.Header{"authority": "test.local"},
+					Document: map[string]interface{}{"message": "Hello world!"},
+				},
+			},
+		},
+	}
+	checkSuite(t, cfg, [][]string{nil})
+}
+
+func TestNewWeb(t *testing.T) {
+	l, err := newGRPCTestServer(false)
+	if err != nil {
+		t.Fatalf("grpc server init: %v", err)
+	}
+	defer l.Close()
+	srv, err := newWebTestServer(l.Addr().String())
+	if err != nil {
+		t.Fatalf("web server init: %v", err)
+	}
+	defer srv.Close()
+	for _, protocol := range []string{protocolGRPCWeb, protocolConnect} {
+		t.Run(protocol, func(t *testing.T) {
+			cfg := &Config{
+				Address:      srv.URL,
+				ProtoSetPath: "./grpctesting/test.protoset",
+				Protocol:     protocol,
+				Header:       testsuite.Header{"hello": "world"},
+				Tests: []TestConfig{
+					{
+						Name: "Simple Call",
+						Request: RequestConfig{
+							Method:   "grpcexpect.testing.TestService/SimpleCall",
+							Document: map[string]interface{}{"username": "world"},
+						},
+						Expect: ExpectConfig{
+							Code:     codes.OK,
+							Header:   testsuite.Header{"hello": "world"},
+							Document: map[string]interface{}{"message": "Hello world!"},
+						},
+					},
+					{
+						Name: "Status",
+						Request: RequestConfig{
+							Method:   "grpcexpect.testing.TestService/SimpleCall",
+							Document: map[string]interface{}{"username": ""},
+						},
+						Expect: ExpectConfig{
+							Code:     codes.InvalidArgument,
+							Document: []interface{}{},
+							Message:  "invalid username: must not be empty",
+							Trailer:  testsuite.Header{"request-id": "42"},
+							Details: []interface{}{
+								map[string]interface{}{"@type": "google.rpc.ErrorInfo", "reason": "EMPTY_USERNAME"},
+								map[string]interface{}{"@type": "google.rpc.RetryInfo", "retryDelay": "5s"},
+							},
+							Mode: "subset",
+						},
+					},
+					{
+						Name: "Stream Call",
+						Request: RequestConfig{
+							Method:   "grpcexpect.testing.TestService/StreamCall",
+							Document: map[string]interface{}{"usernames": []interface{}{"John", "Jane"}},
+						},
+						Expect: ExpectConfig{
+							Code: codes.OK,
+							Document: []interface{}{
+								map[string]interface{}{"message": "Hello John!"},
+								map[string]interface{}{"message": "Hello Jane!"},
+							},
+						},
+					},
+					{
+						Name: "Stream Call with error",
+						Request: RequestConfig{
+							Method:   "grpcexpect.testing.TestService/StreamCall",
+							Document: map[string]interface{}{"usernames": []interface{}{"John", ""}},
+						},
+						Expect: ExpectConfig{
+							Code:     codes.InvalidArgument,
+							Message:  "empty username",
+							Document: []interface{}{map[string]interface{}{"message": "Hello John!"}},
+						},
+					},
+					{
+						Name: "Deadline",
+						Request: RequestConfig{
+							Method:      "grpcexpect.testing.TestService/SimpleCall",
+							Document:    map[string]interface{}{"username": "world"},
+							CallOptions: &CallConfig{Timeout: json.Duration(time.Nanosecond)},
+						},
+						Expect: ExpectConfig{Code: codes.DeadlineExceeded, Document: []interface{}{}},
+					},
+					{
+						Name:    "Not forwarded",
+						Request: RequestConfig{Method: "grpcexpect.testing.TestService/GetUser"},
+						Expect:  ExpectConfig{Code: codes.OK},
+					},
+					{
+						Name:    "Client streaming",
+						Request: RequestConfig{Method: "grpcexpect.testing.TestService/BidiCall"},
+						Expect:  ExpectConfig{Code: codes.OK},
+					},
+					{
+						Name: "Message too large",
+						Request: RequestConfig{
+							Method:      "grpcexpect.testing.TestService/StreamCall",
+							Document:    map[string]interface{}{"usernames": []interface{}{"John"}},
+							CallOptions: &CallConfig{MaxRecvMsgSize: 4},
+						},
+						Expect: ExpectConfig{Code: codes.OK},
+					},
+				},
+			}
+			testsErrs := [][]string{
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				{`wrong status code (got Unimplemented; want OK) message="404 Not Found"`},
+				{"could not invoke method: protocol " + protocol + " does not support the client streaming method grpcexpect.testing.TestService.BidiCall"},
+				{"could not invoke method: received message larger than maxRecvMsgSize"},
+			}
+			checkSuite(t, cfg, testsErrs)
+		})
+	}
+}
+
+func TestReadEnvelope(t *testing.T) {
+	tests := []struct {
+		name     string
+		frame    []byte
+		encoding string
+		want     string
+		err      string
+	}{
+		{name: "uncompressed", frame: envelope(0, []byte("hello")), want: "hello"},
+		{name: "gzip", frame: gzipEnvelope([]byte("hello")), encoding: "gzip", want: "hello"},
+		{name: "no encoding", frame: gzipEnvelope([]byte("hello")), err: "compressed message without encoding"},
+		{name: "unsupported encoding", frame: envelope(flagCompressed, []byte("hello")), encoding: "br", err: `compressed message with unsupported encoding "br"`},
+		{name: "invalid gzip", frame: envelope(flagCompressed, []byte("hello")), encoding: "gzip", err: "could not decompress message: unexpected EOF"},
+		{name: "truncated", frame: envelope(0, []byte("hello"))[:7], err: "truncated message"},
+		{name: "too large", frame: envelope(0, bytes.Repeat([]byte("a"), 65)), err: "received message larger than maxRecvMsgSize"},
+		{name: "too large once decompressed", frame: gzipEnvelope(bytes.Repeat([]byte("a"), 65)), encoding: "gzip", err: "received message larger than maxRecvMsgSize"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, data, err := readEnvelope(bytes.NewReader(test.frame), test.encoding, 64)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("invalid error (got %v; want %s)", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if got := string(data); got != test.want {
+				t.Errorf("invalid message (got %q; want %q)", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNewWebInvalid(t *testing.T) {
+	tests := []struct {
+		cfg  *Config
+		want string
+	}{
+		{
+			cfg:  &Config{Protocol: "http"},
+			want: `unknown protocol "http"`,
+		},
+		{
+			cfg:  &Config{Protocol: protocolConnect},
+			want: "protocol connect requires protoSetPath or protoFiles",
+		},
+		{
+			cfg: &Config{
+				Protocol:     protocolGRPCWeb,
+				ProtoSetPath: "./grpctesting/test.protoset",
+				Smoke:        &SmokeConfig{Health: true},
+			},
+			want: "smoke requires protocol grpc",
+		},
+		{
+			cfg: &Config{
+				Protocol:     protocolGRPCWeb,
+				ProtoSetPath: "./grpctesting/test.protoset",
+				Authority:    "example.com",
+			},
+			want: "authority, keepalive and unix addresses require protocol grpc",
+		},
+		{
+			cfg: &Config{
+				Protocol:     protocolConnect,
+				ProtoSetPath: "./grpctesting/test.protoset",
+				CallOptions:  &CallConfig{Compression: "gzip"},
+			},
+			want: "callOptions: only timeout and maxRecvMsgSize can be set with protocol connect",
+		},
+		{
+			cfg: &Config{
+				Protocol:     protocolGRPCWeb,
+				ProtoSetPath: "./grpctesting/test.protoset",
+				Tests: []TestConfig{
+					{
+						Name:    "Script",
+						Request: RequestConfig{Method: "grpcexpect.testing.TestService/BidiCall"},
+						Script:  []ScriptStep{{CloseSend: true}},
+					},
+				},
+			},
+			want: "test 0 Script: script requires protocol grpc",
+		},
+	}
+	for _, tt := range tests {
+		if _, err := New(tt.cfg); err == nil || err.Error() != tt.want {
+			t.Errorf("new suite invalid error (got %v; want %v)", err, tt.want)
+		}
+	}
+}
+
+func TestNewInvalidStream(t *testing.T) {
+	tt := []struct {
+		name string
+		test TestConfig
+		err  string
+	}{
+		{
+			name: "script with document",
+			test: TestConfig{
+				Request: RequestConfig{Document: map[string]interface{}{}},
+				Script:  []ScriptStep{{CloseSend: true}},
+			},
+			err: "request: document can't be set with script",
+		},
+		{
+			name: "empty step",
+			test: TestConfig{Script: []ScriptStep{{}}},
+			err:  "script: step 0: exactly one of send, receive or closeSend must be set",
+		},
+		{
+			name: "send after closeSend",
+			test: TestConfig{Script: []ScriptStep{{CloseSend: true}, {Send: map[string]interface{}{}}}},
+			err:  "script: step 1: can't send after closeSend",
+		},
+		{
+			name: "message and messageRegex",
+			test: TestConfig{Expect: ExpectConfig{Message: "oops", MessageRegex: "^oops$"}},
+			err:  "expect: messageRegex can't be set with message",
+		},
+		{
+			name: "invalid messageRegex",
+			test: TestConfig{Expect: ExpectConfig{MessageRegex: "("}},
+			err:  "expect: invalid messageRegex: error parsing regexp: missing closing ): `(`",
+		},
+		{
+			name: "detail without type",
+			test: TestConfig{Expect: ExpectConfig{Details: []interface{}{map[string]interface{}{"reason": "EMPTY"}}}},
+			err:  "expect: details 0: @type is required",
+		},
+		{
+			name: "saveDocument without id",
+			test: TestConfig{SaveDocument: true},
+			err:  "saveDocument requires an id",
+		},
+		{
+			name: "unknown mode",
+			test: TestConfig{Expect: ExpectConfig{Mode: "fuzzy"}},
+			err:  `expect: unknown mode "fuzzy"`,
+		},
+		{
+			name: "stream with document",
+			test: TestConfig{Expect: ExpectConfig{Document: map[string]interface{}{}, Stream: &StreamConfig{}}},
+			err:  "expect: document can't be set with stream",
+		},
+		{
+			name: "min greater than max",
+			test: TestConfig{Expect: ExpectConfig{Stream: &StreamConfig{MinMessages: 2, MaxMessages: 1}}},
+			err:  "expect: stream: minMessages can't be greater than maxMessages",
+		},
+		{
+			name: "invalid index",
+			test: TestConfig{Expect: ExpectConfig{Stream: &StreamConfig{At: map[string]interface{}{"first": map[string]interface{}{}}}}},
+			err:  `expect: stream: at: invalid index "first"`,
+		},
+		{
+			name: "several messages at index",
+			test: TestConfig{Expect: ExpectConfig{Stream: &StreamConfig{At: map[string]interface{}{"0": []interface{}{}}}}},
+			err:  "expect: stream: at 0: a single message is expected",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test.Name = "test"
+			cfg := &Config{
+				Address:      "localhost:0",
+				ProtoSetPath: "./grpctesting/test.protoset",
+				Tests:        []TestConfig{tc.test},
+			}
+			want := "test 0 test: " + tc.err
+			if _, err := New(cfg); err == nil || err.Error() != want {
+				t.Fatalf("new suite invalid error (got %v; want %v)", err, want)
+			}
+		})
+	}
+}
+
+func TestNewTLSClientCert(t *testing.T) {
+	cert, err := tls.LoadX509KeyPair("./testdata/tls/server.pem", "./testdata/tls/server.key")
+	if err != nil {
+		t.Fatalf("could not load server certificate: %v", err)
+	}
+	ca, err := ioutil.ReadFile("./testdata/tls/ca.pem")
+	if err != nil {
+		t.Fatalf("could not read CA: %v", err)
+	}
+	cp := x509.NewCertPool()
+	cp.AppendCertsFromPEM(ca)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer l.Close()
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    cp,
+	})))
+	grpctesting.RegisterTestServiceServer(s, testServer{})
+	go s.Serve(l)
+	defer s.Stop()
+	cfg := &Config{
+		Address:            l.Addr().String(),
+		ProtoSetPath:       "./grpctesting/test.protoset",
+		TLS:                true,
+		CAPath:             "./testdata/tls/ca.pem",
+		ClientCertPath:     "./testdata/tls/client.pem",
+		ClientKeyPath:      "./testdata/tls/client.key",
+		ServerHostOverride: "localhost",
+		Auth:               &auth.Config{Bearer: "s3cr3t"},
+		Tests: []TestConfig{
+			{
+				Name:    "Empty Call",
+				Request: RequestConfig{Method: "grpcexpect.testing.TestService/EmptyCall"},
+				Expect:  ExpectConfig{Header: testsuite.Header{"authorization": "Bearer s3cr3t"}},
+			},
+		},
+	}
+	checkSuite(t, cfg, [][]string{nil})
+}
+
+func TestNewTLSClientCertWithoutKey(t *testing.T) {
+	cfg := &Config{TLS: true, ClientCertPath: "./testdata/tls/client.pem"}
+	want := "clientCertPath and clientKeyPath must be set together"
+	if _, err := New(cfg); err == nil || err.Error() != want {
+		t.Fatalf("new suite invalid error (got %v; want %v)", err, want)
+	}
+}
+
+func TestNewAuth(t *testing.T) {
+	l, err := newGRPCTestServer(false)
+	if err != nil {
+		t.Errorf("grpc server init: %v", err)
+	}
+	defer l.Close()
+	cfg := &Config{
+		Address:       l.Addr().String(),
+		ProtoSetPath:  "./grpctesting/test.protoset",
+		Auth:          &auth.Config{Bearer: "s3cr3t"},
+		PlaintextAuth: true,
+		Tests: []TestConfig{
+			{
+				Name:    "Suite auth",
+				Request: RequestConfig{Method: "grpcexpect.testing.TestService/EmptyCall"},
+				Expect:  ExpectConfig{Header: testsuite.Header{"authorization": "Bearer s3cr3t"}},
+			},
+			{
+				Name: "Test auth",
+				Request: RequestConfig{
+					Method: "grpcexpect.testing.TestService/EmptyCall",
+					Auth:   &auth.Config{Basic: &auth.BasicConfig{Username: "john", Password: "doe"}},
+				},
+				Expect: ExpectConfig{Header: testsuite.Header{"authorization": "Basic am9objpkb2U="}},
+			},
+		},
+	}
+	checkSuite(t, cfg, [][]string{nil, nil})
+}
+
+func TestNewAuthWithoutTLS(t *testing.T) {
+	want := "auth: credentials can't be sent without TLS unless plaintextAuth is set"
+	tt := []*Config{
+		{Auth: &auth.Config{Bearer: "s3cr3t"}},
+		{Tests: []TestConfig{{Request: RequestConfig{Auth: &auth.Config{Basic: &auth.BasicConfig{Username: "john", Password: "doe"}}}}}},
+		{Protocol: protocolConnect, Address: "http://localhost:0", Auth: &auth.Config{Bearer: "s3cr3t"}},
+	}
+	for _, cfg := range tt {
+		cfg.ProtoSetPath = "./grpctesting/test.protoset"
+		if cfg.Address == "" {
+			cfg.Address = "localhost:0"
+		}
+		if _, err := New(cfg); err == nil || err.Error() != want {
+			t.Errorf("new suite invalid error (got %v; want %v)", err, want)
+		}
+	}
+	cfg := &Config{
+		Address:      "localhost:0",
+		ProtoSetPath: "./grpctesting/test.protoset",
+		Auth:         &auth.Config{HMAC: &auth.HMACConfig{Secret: "s3cr3t"}},
+		Tests:        []TestConfig{{Name: "Empty Call", Request: RequestConfig{Method: "grpcexpect.testing.TestService/EmptyCall"}}},
+	}
+	if _, err := New(cfg); err != nil {
+		t.Errorf("new suite with a signature failed: %v", err)
+	}
+}
+
+func TestMock(t *testing.T) {
+	cfg := &Config{
+		Address:      "localhost:0",
+		ProtoSetPath: "./grpctesting/test.protoset",
+		Tests: []TestConfig{
+			{
+				Name:    "Empty Call",
+				Request: RequestConfig{Method: "grpcexpect.testing.TestService/EmptyCall"},
+			},
+			{
+				Name: "Simple Call",
+				Request: RequestConfig{
+					Method:   "grpcexpect.testing.TestService.SimpleCall",
+					Document: map[string]interface{}{"username": "world"},
+				},
+				Expect: ExpectConfig{
+					Header:   testsuite.Header{"hello": "world"},
+					Document: map[string]interface{}{"message": "Hello world!"},
+				},
+			},
+			{
+				Name:    "Simple Call not found",
+				Request: RequestConfig{Method: "grpcexpect.testing.TestService/SimpleCall"},
+				Expect:  ExpectConfig{Code: codes.NotFound, Document: []interface{}{}},
+			},
+			{
+				Name:    "Get User error",
+				Request: RequestConfig{Method: "grpcexpect.testing.TestService/GetUser"},
+				Expect: ExpectConfig{
+					Code:     codes.NotFound,
+					Document: []interface{}{},
+					Message:  "user not found",
+					Trailer:  testsuite.Header{"request-id": "42"},
+					Details: []interface{}{
+						map[string]interface{}{"@type": "google.rpc.ErrorInfo", "reason": "USER_NOT_FOUND"},
+					},
+				},
+			},
+			{
+				Name:    "Stream Call",
+				Request: RequestConfig{Method: "grpcexpect.testing.TestService/StreamCall"},
+				Expect: ExpectConfig{Stream: &StreamConfig{
+					MinMessages: 2,
+					Contains: []interface{}{
+						map[string]interface{}{"message": "Hello a!"},
+						map[string]interface{}{"message": "Hello b!"},
+					},
+				}},
+			},
+		},
+	}
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatalf("new suite failed: %v", err)
+	}
+	srv, err := s.Mock()
+	if err != nil {
+		t.Fatalf("new mock failed: %v", err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer l.Close()
+	go srv.Serve(l)
+	cfg.Address = l.Addr().String()
+	checkSuite(t, cfg, [][]string{nil, nil, nil, nil, nil})
+}
+
+func TestMockProtoFiles(t *testing.T) {
+	cfg := &Config{
+		Root:       "grpctesting",
+		ProtoFiles: []string{"test.proto"},
+		Tests: []TestConfig{
+			{
+				Name:    "Empty Call",
+				Request: RequestConfig{Method: "grpcexpect.testing.TestService/EmptyCall"},
+			},
+		},
+	}
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatalf("new suite failed: %v", err)
+	}
+	if _, err := s.Mock(); err != nil {
+		t.Fatalf("new mock failed: %v", err)
+	}
+}
+
+func TestMockWithoutProtoset(t *testing.T) {
+	l, err := newGRPCTestServer(true)
+	if err != nil {
+		t.Errorf("grpc server init: %v", err)
+	}
+	defer l.Close()
+	s, err := New(&Config{Address: l.Addr().String()})
+	if err != nil {
+		t.Fatalf("new suite failed: %v", err)
+	}
+	if _, err := s.Mock(); err != errMockNoProtoSet {
+		t.Fatalf("new mock invalid error (got %v; want %v)", err, errMockNoProtoSet)
+	}
+}
+
+func checkSuite(t *testing.T, cfg *Config, testsErrs [][]string) {
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatalf("new suite failed: %v", err)
+	}
+	tests := s.Tests()
+	if len(tests) != len(testsErrs) {
+		panic("len(tests) != len(testsErrs)")
+	}
+	ctx := testsuite.NewMDContext(context.Background(), testsuite.NewMD())
+	for i, test := range tests {
+		test := test
+		t.Run(test.Name(), func(t *testing.T) {
+			l := &mockLogger{}
+			test.Run(ctx, l)
+			if !cmp.Equal(l.errs, testsErrs[i]) {
+				t.Errorf("unexpected errors (got %v; want %v)", l.errs, testsErrs[i])
+			}
+		})
+	}
+}
+
+type mockLogger struct {
+	errs []string
+}
+
+func (l *mockLogger) Error(args ...interface{}) {
+	l.errs = append(l.errs, fmt.Sprint(args...))
+}
+
+func (l *mockLogger) Errorf(format string, args ...interface{}) {
+	l.errs = append(l.errs, fmt.Sprintf(format, args...))
+}
+
+type testServer struct{}
+
+func newGRPCTestServer(reflect bool) (net.Listener, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := grpc.NewServer()
+	grpctesting.RegisterTestServiceServer(s, testServer{})
+	if reflect {
+		reflection.Register(s)
+	}
+	go func() {
+		s.Serve(l)
+	}()
+	return l, nil
+}
+
+// newWebTestServer returns a gateway forwarding the SimpleCall and StreamCall
+// methods called with the gRPC-Web or the Connect protocol to the gRPC server
+// at addr, with the hello header.
+func newWebTestServer(addr string) (*httptest.Server, error) {
+	cc, err := grpc.Dial(addr, grpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+	client := grpctesting.NewTestServiceClient(cc)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		grpcWeb := r.Header.Get("X-Grpc-Web") == "1"
+		enveloped := grpcWeb || r.Header.Get("Content-Type") == "application/connect+proto"
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if enveloped {
+			if len(body) < 5 {
+				http.Error(w, "truncated message", http.StatusBadRequest)
+				return
+			}
+			body = body[5:]
+		}
+		ctx := metadata.NewOutgoingContext(r.Context(), metadata.Pairs("hello", r.Header.Get("Hello")))
+		var (
+			header, trailer metadata.MD
+			resps           []proto.Message
+		)
+		switch r.URL.Path {
+		case "/grpcexpect.testing.TestService/SimpleCall":
+			in := &grpctesting.SimpleRequest{}
+			if err := proto.Unmarshal(body, in); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			var resp *grpctesting.SimpleResponse
+			if resp, err = client.SimpleCall(ctx, in, grpc.Header(&header), grpc.Trailer(&trailer)); err == nil {
+				resps = append(resps, resp)
+			}
+		case "/grpcexpect.testing.TestService/StreamCall":
+			in := &grpctesting.StreamRequest{}
+			if err := proto.Unmarshal(body, in); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			var stream grpctesting.TestService_StreamCallClient
+			if stream, err = client.StreamCall(ctx, in); err != nil {
+				break
+			}
+			for {
+				resp, rerr := stream.Recv()
+				if rerr != nil {
+					if rerr != io.EOF {
+						err = rerr
+					}
+					break
+				}
+				resps = append(resps, resp)
+			}
+			header, _ = stream.Header()
+			trailer = stream.Trailer()
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		st := status.Convert(err).Proto()
+		for k, vs := range header {
+			for _, v := range vs {
+				w.Header().Add(k, v)
+			}
+		}
+		switch {
+		case grpcWeb:
+			w.Header().Set("Content-Type", "application/grpc-web+proto")
+			w.Header().Set("Grpc-Encoding", r.Header.Get("Grpc-Accept-Encoding"))
+			for _, resp := range resps {
+				b, _ := proto.Marshal(resp)
+				w.Write(gzipEnvelope(b))
+			}
+			var tr bytes.Buffer
+			fmt.Fprintf(&tr, "grpc-status: %d\r\ngrpc-message: %s\r\n", st.Code, url.PathEscape(st.Message))
+			if len(st.Details) > 0 {
+				b, _ := proto.Marshal(st)
+				fmt.Fprintf(&tr, "grpc-status-details-bin: %s\r\n", base64.RawStdEncoding.EncodeToString(b))
+			}
+			for k, vs := range trailer {
+				for _, v := range vs {
+					fmt.Fprintf(&tr, "%s: %s\r\n", k, v)
+				}
+			}
+			w.Write(envelope(flagWebTrailer, tr.Bytes()))
+		case enveloped:
+			w.Header().Set("Content-Type", "application/connect+proto")
+			w.Header().Set("Connect-Content-Encoding", r.Header.Get("Connect-Accept-Encoding"))
+			for _, resp := range resps {
+				b, _ := proto.Marshal(resp)
+				w.Write(gzipEnvelope(b))
+			}
+			end := map[string]interface{}{"metadata": trailer}
+			if err != nil {
+				end["error"] = connectErrorJSON(st)
+			}
+			b, _ := gojson.Marshal(end)
+			w.Write(envelope(flagEndStream, b))
+		default:
+			for k, vs := range trailer {
+				for _, v := range vs {
+					w.Header().Add("Trailer-"+k, v)
+				}
+			}
+			if err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				gojson.NewEncoder(w).Encode(connectErrorJSON(st))
+				return
+			}
+			w.Header().Set("Content-Type", "application/proto")
+			b, _ := proto.Marshal(resps[0])
+			w.Write(b)
+		}
+	})), nil
+}
+
+// connectErrorJSON returns the JSON form of st in the Connect protocol.
+func connectErrorJSON(st *spb.Status) map[string]interface{} {
+	code := regexp.MustCompile("([a-z])([A-Z])").ReplaceAllString(codes.Code(st.Code).String(), "${1}_${2}")
+	details := make([]map[string]string, len(st.Details))
+	for i, d := range st.Details {
+		details[i] = map[string]string{
+			"type":  strings.TrimPrefix(d.TypeUrl, "type.googleapis.com/"),
+			"value": base64.RawStdEncoding.EncodeToString(d.Value),
+		}
+	}
+	return map[string]interface{}{"code": strings.ToLower(code), "message": st.Message, "details": details}
+}
+
+// gzipEnvelope returns the message compressed with gzip, prefixed by the
+// compressed flag and its length.
+func gzipEnvelope(msg []byte) []byte {
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	zw.Write(msg)
+	zw.Close()
+	return envelope(flagCompressed, b.Bytes())
+}
+
+func (testServer) EmptyCall(ctx context.Context, in *empty.Empty) (*empty.Empty, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if vs := md["authorization"]; len(vs) > 0 {
+		grpc.SetHeader(ctx, metadata.Pairs("authorization", vs[0]))
+	}
+	return &empty.Empty{}, nil
+}
+
+func (testServer) SimpleCall(ctx context.Context, in *grpctesting.SimpleRequest) (*grpctesting.SimpleResponse, error) {
+	if in.Username == "" {
+		grpc.SetTrailer(ctx, metadata.Pairs("request-id", "42"))
+		st, err := status.New(codes.InvalidArgument, "invalid username: must not be empty").WithDetails(
+			&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{
+				{Field: "username", Description: "must not be empty"},
+			}},
+			&errdetails.ErrorInfo{Reason: "EMPTY_USERNAME", Domain: "grpcexpect.testing", Metadata: map[string]string{"field": "username"}},
+			&errdetails.RetryInfo{RetryDelay: &duration.Duration{Seconds: 5}},
+		)
+		if err != nil {
+			return nil, err
+		}
+		return nil, st.Err()
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if vs := md["hello"]; len(vs) > 0 {
+		grpc.SetHeader(ctx, metadata.Pairs("hello", vs[0]))
+	}
+	if vs := md[":authority"]; len(vs) > 0 {
+		grpc.SetHeader(ctx, metadata.Pairs("authority", vs[0]))
+	}
+	return &grpctesting.SimpleResponse{
+		Message: fmt.Sprintf("Hello %s!", in.Username),
+	}, nil
+}
+
+func (testServer) StreamCall(in *grpctesting.StreamRequest, stream grpctesting.TestService_StreamCallServer) error {
+	for _, name := range in.Usernames {
+		if name == "" {
+			return status.Error(codes.InvalidArgument, "empty username")
+		}
+		if err := stream.Send(&grpctesting.SimpleResponse{Message: fmt.Sprintf("Hello %s!", name)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BidiCall greets each received username until the stream is closed or the
+// username is bye.
+func (testServer) BidiCall(stream grpctesting.TestService_BidiCallServer) error {
+	for {
+		in, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if in.Username == "bye" {
+			return nil
+		}
+		if err := stream.Send(&grpctesting.SimpleResponse{Message: fmt.Sprintf("Hello %s!", in.Username)}); err != nil {
+			return err
+		}
+	}
+}
+
+func (testServer) GetUser(ctx context.Context, in *grpctesting.SimpleRequest) (*grpctesting.User, error) {
+	now := time.Now().Unix()
+	return &grpctesting.User{
+		Username:    in.Username,
+		DisplayName: strings.Title(in.Username),
+		CreatedAt:   now,
+		Addresses: []*grpctesting.Address{
+			{City: "Paris", UpdatedAt: now},
+			{City: "London", UpdatedAt: now},
+		},
 	}, nil
 }
 
@@ -10,6 +10,9 @@ It is generated from these files:
 It has these top-level messages:
 	SimpleRequest
 	SimpleResponse
+	StreamRequest
+	User
+	Address
 	ProcessFileRequest
 	ProcessFileResponse
 */
@@ -68,6 +71,86 @@ func (m *SimpleResponse) GetMessage() string {
 	return ""
 }
 
+type StreamRequest struct {
+	Usernames []string `protobuf:"bytes,1,rep,name=usernames" json:"usernames,omitempty"`
+}
+
+func (m *StreamRequest) Reset()                    { *m = StreamRequest{} }
+func (m *StreamRequest) String() string            { return proto.CompactTextString(m) }
+func (*StreamRequest) ProtoMessage()               {}
+func (*StreamRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+func (m *StreamRequest) GetUsernames() []string {
+	if m != nil {
+		return m.Usernames
+	}
+	return nil
+}
+
+type User struct {
+	Username    string     `protobuf:"bytes,1,opt,name=username" json:"username,omitempty"`
+	DisplayName string     `protobuf:"bytes,2,opt,name=display_name,json=displayName" json:"display_name,omitempty"`
+	CreatedAt   int64      `protobuf:"varint,3,opt,name=created_at,json=createdAt" json:"created_at,omitempty"`
+	Addresses   []*Address `protobuf:"bytes,4,rep,name=addresses" json:"addresses,omitempty"`
+}
+
+func (m *User) Reset()                    { *m = User{} }
+func (m *User) String() string            { return proto.CompactTextString(m) }
+func (*User) ProtoMessage()               {}
+func (*User) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *User) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *User) GetDisplayName() string {
+	if m != nil {
+		return m.DisplayName
+	}
+	return ""
+}
+
+func (m *User) GetCreatedAt() int64 {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return 0
+}
+
+func (m *User) GetAddresses() []*Address {
+	if m != nil {
+		return m.Addresses
+	}
+	return nil
+}
+
+type Address struct {
+	City      string `protobuf:"bytes,1,opt,name=city" json:"city,omitempty"`
+	UpdatedAt int64  `protobuf:"varint,2,opt,name=updated_at,json=updatedAt" json:"updated_at,omitempty"`
+}
+
+func (m *Address) Reset()                    { *m = Address{} }
+func (m *Address) String() string            { return proto.CompactTextString(m) }
+func (*Address) ProtoMessage()               {}
+func (*Address) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *Address) GetCity() string {
+	if m != nil {
+		return m.City
+	}
+	return ""
+}
+
+func (m *Address) GetUpdatedAt() int64 {
+	if m != nil {
+		return m.UpdatedAt
+	}
+	return 0
+}
+
 type ProcessFileRequest struct {
 	Name []byte `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
 	Data []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
@@ -76,7 +159,7 @@ type ProcessFileRequest struct {
 func (m *ProcessFileRequest) Reset()                    { *m = ProcessFileRequest{} }
 func (m *ProcessFileRequest) String() string            { return proto.CompactTextString(m) }
 func (*ProcessFileRequest) ProtoMessage()               {}
-func (*ProcessFileRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+func (*ProcessFileRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
 
 func (m *ProcessFileRequest) GetName() []byte {
 	if m != nil {
@@ -98,11 +181,14 @@ type ProcessFileResponse struct {
 func (m *ProcessFileResponse) Reset()                    { *m = ProcessFileResponse{} }
 func (m *ProcessFileResponse) String() string            { return proto.CompactTextString(m) }
 func (*ProcessFileResponse) ProtoMessage()               {}
-func (*ProcessFileResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+func (*ProcessFileResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
 
 func init() {
 	proto.RegisterType((*SimpleRequest)(nil), "grpcexpect.testing.SimpleRequest")
 	proto.RegisterType((*SimpleResponse)(nil), "grpcexpect.testing.SimpleResponse")
+	proto.RegisterType((*StreamRequest)(nil), "grpcexpect.testing.StreamRequest")
+	proto.RegisterType((*User)(nil), "grpcexpect.testing.User")
+	proto.RegisterType((*Address)(nil), "grpcexpect.testing.Address")
 	proto.RegisterType((*ProcessFileRequest)(nil), "grpcexpect.testing.ProcessFileRequest")
 	proto.RegisterType((*ProcessFileResponse)(nil), "grpcexpect.testing.ProcessFileResponse")
 }
@@ -121,6 +207,9 @@ type TestServiceClient interface {
 	EmptyCall(ctx context.Context, in *google_protobuf.Empty, opts ...grpc.CallOption) (*google_protobuf.Empty, error)
 	SimpleCall(ctx context.Context, in *SimpleRequest, opts ...grpc.CallOption) (*SimpleResponse, error)
 	ProcessFile(ctx context.Context, in *ProcessFileRequest, opts ...grpc.CallOption) (*ProcessFileResponse, error)
+	StreamCall(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (TestService_StreamCallClient, error)
+	BidiCall(ctx context.Context, opts ...grpc.CallOption) (TestService_BidiCallClient, error)
+	GetUser(ctx context.Context, in *SimpleRequest, opts ...grpc.CallOption) (*User, error)
 }
 
 type testServiceClient struct {
@@ -158,12 +247,87 @@ func (c *testServiceClient) ProcessFile(ctx context.Context, in *ProcessFileRequ
 	return out, nil
 }
 
+func (c *testServiceClient) StreamCall(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (TestService_StreamCallClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_TestService_serviceDesc.Streams[0], c.cc, "/grpcexpect.testing.TestService/StreamCall", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &testServiceStreamCallClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TestService_StreamCallClient interface {
+	Recv() (*SimpleResponse, error)
+	grpc.ClientStream
+}
+
+type testServiceStreamCallClient struct {
+	grpc.ClientStream
+}
+
+func (x *testServiceStreamCallClient) Recv() (*SimpleResponse, error) {
+	m := new(SimpleResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *testServiceClient) BidiCall(ctx context.Context, opts ...grpc.CallOption) (TestService_BidiCallClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_TestService_serviceDesc.Streams[1], c.cc, "/grpcexpect.testing.TestService/BidiCall", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &testServiceBidiCallClient{stream}
+	return x, nil
+}
+
+type TestService_BidiCallClient interface {
+	Send(*SimpleRequest) error
+	Recv() (*SimpleResponse, error)
+	grpc.ClientStream
+}
+
+type testServiceBidiCallClient struct {
+	grpc.ClientStream
+}
+
+func (x *testServiceBidiCallClient) Send(m *SimpleRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *testServiceBidiCallClient) Recv() (*SimpleResponse, error) {
+	m := new(SimpleResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *testServiceClient) GetUser(ctx context.Context, in *SimpleRequest, opts ...grpc.CallOption) (*User, error) {
+	out := new(User)
+	err := grpc.Invoke(ctx, "/grpcexpect.testing.TestService/GetUser", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for TestService service
 
 type TestServiceServer interface {
 	EmptyCall(context.Context, *google_protobuf.Empty) (*google_protobuf.Empty, error)
 	SimpleCall(context.Context, *SimpleRequest) (*SimpleResponse, error)
 	ProcessFile(context.Context, *ProcessFileRequest) (*ProcessFileResponse, error)
+	StreamCall(*StreamRequest, TestService_StreamCallServer) error
+	BidiCall(TestService_BidiCallServer) error
+	GetUser(context.Context, *SimpleRequest) (*User, error)
 }
 
 func RegisterTestServiceServer(s *grpc.Server, srv TestServiceServer) {
@@ -224,6 +388,71 @@ func _TestService_ProcessFile_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TestService_StreamCall_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TestServiceServer).StreamCall(m, &testServiceStreamCallServer{stream})
+}
+
+type TestService_StreamCallServer interface {
+	Send(*SimpleResponse) error
+	grpc.ServerStream
+}
+
+type testServiceStreamCallServer struct {
+	grpc.ServerStream
+}
+
+func (x *testServiceStreamCallServer) Send(m *SimpleResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TestService_BidiCall_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TestServiceServer).BidiCall(&testServiceBidiCallServer{stream})
+}
+
+type TestService_BidiCallServer interface {
+	Send(*SimpleResponse) error
+	Recv() (*SimpleRequest, error)
+	grpc.ServerStream
+}
+
+type testServiceBidiCallServer struct {
+	grpc.ServerStream
+}
+
+func (x *testServiceBidiCallServer) Send(m *SimpleResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *testServiceBidiCallServer) Recv() (*SimpleRequest, error) {
+	m := new(SimpleRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _TestService_GetUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SimpleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TestServiceServer).GetUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/grpcexpect.testing.TestService/GetUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TestServiceServer).GetUser(ctx, req.(*SimpleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _TestService_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "grpcexpect.testing.TestService",
 	HandlerType: (*TestServiceServer)(nil),
@@ -240,31 +469,58 @@ var _TestService_serviceDesc = grpc.ServiceDesc{
 			MethodName: "ProcessFile",
 			Handler:    _TestService_ProcessFile_Handler,
 		},
+		{
+			MethodName: "GetUser",
+			Handler:    _TestService_GetUser_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamCall",
+			Handler:       _TestService_StreamCall_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "BidiCall",
+			Handler:       _TestService_BidiCall_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "grpctesting/test.proto",
 }
 
 func init() { proto.RegisterFile("grpctesting/test.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 280 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x52, 0xc1, 0x4e, 0xc3, 0x30,
-	0x0c, 0xdd, 0x26, 0x04, 0xcc, 0x65, 0x1c, 0x8c, 0x98, 0xaa, 0x72, 0x81, 0x1c, 0x00, 0x81, 0x94,
-	0x4a, 0x70, 0x85, 0x0b, 0x08, 0xce, 0xa8, 0x83, 0x0b, 0x27, 0xba, 0x62, 0xaa, 0x4a, 0x6d, 0x13,
-	0xea, 0x14, 0xc1, 0x07, 0xf0, 0xdf, 0xa8, 0x09, 0x19, 0x9b, 0x06, 0xec, 0x14, 0xfb, 0xf9, 0x3d,
-	0xdb, 0xcf, 0x0a, 0x8c, 0xf3, 0x46, 0x67, 0x86, 0xd8, 0x14, 0x75, 0x1e, 0x77, 0xaf, 0xd4, 0x8d,
-	0x32, 0x0a, 0xb1, 0xc3, 0xe9, 0x5d, 0x53, 0x66, 0xe4, 0x77, 0x39, 0xda, 0xcb, 0x95, 0xca, 0x4b,
-	0x8a, 0x2d, 0x63, 0xda, 0xbe, 0xc4, 0x54, 0x69, 0xf3, 0xe1, 0x04, 0xe2, 0x14, 0x46, 0x93, 0xa2,
-	0xd2, 0x25, 0x25, 0xf4, 0xda, 0x12, 0x1b, 0x8c, 0x60, 0xb3, 0x65, 0x6a, 0xea, 0xb4, 0xa2, 0xb0,
-	0xbf, 0xdf, 0x3f, 0x1e, 0x26, 0xb3, 0x5c, 0x9c, 0xc0, 0xb6, 0x27, 0xb3, 0x56, 0x35, 0x13, 0x86,
-	0xb0, 0x51, 0x11, 0x73, 0x9a, 0x7b, 0xb2, 0x4f, 0xc5, 0x05, 0xe0, 0x5d, 0xa3, 0x32, 0x62, 0xbe,
-	0x2d, 0x7e, 0xba, 0x23, 0xac, 0xcd, 0x3a, 0x6f, 0x25, 0x36, 0xee, 0xb0, 0xe7, 0xd4, 0xa4, 0xe1,
-	0xc0, 0x61, 0x5d, 0x2c, 0x76, 0x61, 0x67, 0x41, 0xed, 0xc6, 0x9d, 0x7d, 0x0e, 0x20, 0xb8, 0x27,
-	0x36, 0x13, 0x6a, 0xde, 0x8a, 0x8c, 0xf0, 0x12, 0x86, 0x37, 0x9d, 0x99, 0xeb, 0xb4, 0x2c, 0x71,
-	0x2c, 0x9d, 0x51, 0xe9, 0x8d, 0x4a, 0x5b, 0x8b, 0xfe, 0xc0, 0x45, 0x0f, 0x1f, 0x00, 0x9c, 0x1f,
-	0xab, 0x3f, 0x90, 0xcb, 0xc7, 0x93, 0x0b, 0xc7, 0x89, 0xc4, 0x7f, 0x14, 0xb7, 0xa3, 0xe8, 0xe1,
-	0x13, 0x04, 0x73, 0xcb, 0xe3, 0xe1, 0x6f, 0xa2, 0xe5, 0xdb, 0x44, 0x47, 0x2b, 0x79, 0x7e, 0xc2,
-	0xd5, 0xe8, 0x31, 0x98, 0xfb, 0x00, 0xd3, 0x75, 0xeb, 0xec, 0xfc, 0x2b, 0x00, 0x00, 0xff, 0xff,
-	0xfd, 0x08, 0x4d, 0x18, 0x16, 0x02, 0x00, 0x00,
+	// 451 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x54, 0x4d, 0x6f, 0xd3, 0x40,
+	0x10, 0x8d, 0x1b, 0x8b, 0xd4, 0xe3, 0x84, 0xc3, 0x20, 0x2a, 0xcb, 0x05, 0x29, 0xdd, 0x03, 0x58,
+	0x20, 0x9c, 0xaa, 0x9c, 0x90, 0xca, 0x21, 0x45, 0x7c, 0x9c, 0x10, 0x72, 0xa8, 0x2a, 0x71, 0x29,
+	0x5b, 0x7b, 0xb0, 0x2c, 0xd9, 0xb1, 0xd9, 0xd9, 0x20, 0x72, 0xe4, 0x97, 0xf0, 0x57, 0x91, 0xd7,
+	0x76, 0x92, 0xaa, 0x21, 0xe4, 0xc0, 0x29, 0xb3, 0x6f, 0xde, 0x7c, 0xe4, 0xbd, 0x91, 0xe1, 0x28,
+	0x55, 0x55, 0xac, 0x89, 0x75, 0x36, 0x4f, 0x27, 0xf5, 0x6f, 0x58, 0xa9, 0x52, 0x97, 0x88, 0x35,
+	0x4e, 0x3f, 0x2b, 0x8a, 0x75, 0xd8, 0xa6, 0xfd, 0xe3, 0xb4, 0x2c, 0xd3, 0x9c, 0x26, 0x86, 0x71,
+	0xb3, 0xf8, 0x36, 0xa1, 0xa2, 0xd2, 0xcb, 0xa6, 0x40, 0x3c, 0x87, 0xd1, 0x2c, 0x2b, 0xaa, 0x9c,
+	0x22, 0xfa, 0xbe, 0x20, 0xd6, 0xe8, 0xc3, 0xe1, 0x82, 0x49, 0xcd, 0x65, 0x41, 0x9e, 0x35, 0xb6,
+	0x02, 0x27, 0x5a, 0xbd, 0xc5, 0x33, 0xb8, 0xdf, 0x91, 0xb9, 0x2a, 0xe7, 0x4c, 0xe8, 0xc1, 0xa0,
+	0x20, 0x66, 0x99, 0x76, 0xe4, 0xee, 0x29, 0x5e, 0xc0, 0x68, 0xa6, 0x15, 0xc9, 0xa2, 0x6b, 0xfc,
+	0x08, 0x9c, 0xae, 0x11, 0x7b, 0xd6, 0xb8, 0x1f, 0x38, 0xd1, 0x1a, 0x10, 0xbf, 0x2d, 0xb0, 0x2f,
+	0x99, 0xd4, 0xae, 0xf9, 0x78, 0x02, 0xc3, 0x24, 0xe3, 0x2a, 0x97, 0xcb, 0x6b, 0x93, 0x3f, 0x30,
+	0x79, 0xb7, 0xc5, 0x3e, 0xd6, 0x94, 0xc7, 0x00, 0xb1, 0x22, 0xa9, 0x29, 0xb9, 0x96, 0xda, 0xeb,
+	0x8f, 0xad, 0xa0, 0x1f, 0x39, 0x2d, 0x32, 0xd5, 0xf8, 0x0a, 0x1c, 0x99, 0x24, 0x8a, 0x98, 0x89,
+	0x3d, 0x7b, 0xdc, 0x0f, 0xdc, 0xb3, 0xe3, 0xf0, 0xae, 0x66, 0xe1, 0xb4, 0x21, 0x45, 0x6b, 0xb6,
+	0x38, 0x87, 0x41, 0x8b, 0x22, 0x82, 0x1d, 0x67, 0x7a, 0xd9, 0xee, 0x67, 0xe2, 0x7a, 0xf0, 0xa2,
+	0x4a, 0xba, 0xc1, 0x07, 0xcd, 0xe0, 0x16, 0x99, 0x6a, 0x71, 0x0e, 0xf8, 0x49, 0x95, 0x31, 0x31,
+	0xbf, 0xcb, 0xd6, 0x62, 0x23, 0xd8, 0xab, 0x3f, 0x3a, 0x8c, 0x4c, 0x5c, 0x63, 0x89, 0xd4, 0xd2,
+	0xb4, 0x18, 0x46, 0x26, 0x16, 0x0f, 0xe1, 0xc1, 0xad, 0xea, 0x46, 0xfd, 0xb3, 0x5f, 0x36, 0xb8,
+	0x9f, 0x89, 0xf5, 0x8c, 0xd4, 0x8f, 0x2c, 0x26, 0x7c, 0x0d, 0xce, 0xdb, 0xda, 0xdb, 0x37, 0x32,
+	0xcf, 0xf1, 0x28, 0x6c, 0x7c, 0x0f, 0x3b, 0xdf, 0x43, 0x93, 0xf3, 0xff, 0x82, 0x8b, 0x1e, 0x5e,
+	0x02, 0x34, 0xf6, 0x9a, 0xfa, 0x93, 0x6d, 0xba, 0xdc, 0xba, 0x15, 0x5f, 0xec, 0xa2, 0x34, 0x3b,
+	0x8a, 0x1e, 0x7e, 0x05, 0x77, 0x63, 0x79, 0x7c, 0xb2, 0xad, 0xe8, 0xae, 0x36, 0xfe, 0xd3, 0x7f,
+	0xf2, 0x56, 0x13, 0xae, 0x00, 0x9a, 0x5b, 0xdb, 0xb1, 0xf8, 0xe6, 0x2d, 0xee, 0xb7, 0xf8, 0xa9,
+	0x85, 0x57, 0x70, 0x78, 0x91, 0x25, 0xd9, 0x7f, 0xd5, 0x23, 0xb0, 0x4e, 0x2d, 0xfc, 0x00, 0x83,
+	0xf7, 0xa4, 0xcd, 0xc1, 0xef, 0xd1, 0xd7, 0xdb, 0x46, 0xa9, 0x8b, 0x45, 0xef, 0x62, 0xf4, 0xc5,
+	0xdd, 0xf8, 0x16, 0xdc, 0xdc, 0x33, 0xae, 0xbe, 0xfc, 0x33, 0x00, 0x5b, 0xeb, 0xf8, 0x66, 0x21,
+	0x04, 0x00, 0x00,
 }
@@ -0,0 +1,322 @@
+package grpcexpect
+
+import (
+	gojson "encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/google/go-cmp/cmp"
+	"github.com/jhump/protoreflect/desc"
+
+	"github.com/blippar/aragorn/pkg/util/json"
+	"github.com/blippar/aragorn/testsuite"
+)
+
+// matcher compares the received messages with the expected ones using their
+// JSON form.
+type matcher struct {
+	subset bool       // Only compare the fields set in the expected messages.
+	ignore [][]string // Paths of the ignored fields.
+}
+
+// diff returns the differences between the fields of got and want, one per
+// line, or an empty string if they match. In subset mode, only the fields set
+// in doc, the JSON document want was decoded from, are compared, including the
+// ones set to their default value.
+func (m *matcher) diff(got, want proto.Message, doc []byte) (string, error) {
+	g, err := toJSONValue(got, true)
+	if err != nil {
+		return "", fmt.Errorf("could not marshal response: %v", err)
+	}
+	w, err := toJSONValue(want, true)
+	if err != nil {
+		return "", fmt.Errorf("could not marshal expected document: %v", err)
+	}
+	if m.subset {
+		var d interface{}
+		if err := json.Unmarshal(doc, &d); err != nil {
+			return "", fmt.Errorf("could not unmarshal expected document: %v", err)
+		}
+		w = docFields(w, d, messageDescriptor(want))
+	}
+	for _, path := range m.ignore {
+		removePath(g, path)
+		removePath(w, path)
+	}
+	var diffs []string
+	m.diffValues("", g, w, &diffs)
+	return strings.Join(diffs, "\n"), nil
+}
+
+// equal reports whether got matches want, decoded from doc.
+func (m *matcher) equal(got, want proto.Message, doc []byte) bool {
+	d, err := m.diff(got, want, doc)
+	return err == nil && d == ""
+}
+
+func (m *matcher) diffValues(path string, got, want interface{}, diffs *[]string) {
+	switch w := want.(type) {
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(w))
+		for k := range w {
+			keys = append(keys, k)
+		}
+		if !m.subset {
+			for k := range g {
+				if _, ok := w[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p := joinPath(path, k)
+			gv, gok := g[k]
+			wv, wok := w[k]
+			switch {
+			case !gok:
+				*diffs = append(*diffs, fmt.Sprintf("- %s: missing (want %s)", p, formatJSONValue(wv)))
+			case !wok:
+				*diffs = append(*diffs, fmt.Sprintf("- %s: unexpected (got %s)", p, formatJSONValue(gv)))
+			default:
+				m.diffValues(p, gv, wv, diffs)
+			}
+		}
+		return
+	case []interface{}:
+		g, ok := got.([]interface{})
+		if !ok {
+			break
+		}
+		if len(g) != len(w) {
+			*diffs = append(*diffs, fmt.Sprintf("- %s: wrong length (got %d; want %d)", path, len(g), len(w)))
+			return
+		}
+		for i := range w {
+			m.diffValues(joinPath(path, strconv.Itoa(i)), g[i], w[i], diffs)
+		}
+		return
+	}
+	if !cmp.Equal(got, want) {
+		*diffs = append(*diffs, fmt.Sprintf("- %s: got %s; want %s", path, formatJSONValue(got), formatJSONValue(want)))
+	}
+}
+
+// toJSONValue returns the decoded JSON form of msg. The fields set to their
+// default value are only present if emitDefaults is set. The 64-bit integers,
+// encoded as strings by jsonpb, are decoded as numbers like the other ones.
+func toJSONValue(msg proto.Message, emitDefaults bool) (interface{}, error) {
+	m := jsonpb.Marshaler{EmitDefaults: emitDefaults}
+	s, err := m.MarshalToString(msg)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return nil, err
+	}
+	return int64Numbers(v, messageDescriptor(msg)), nil
+}
+
+// messageDescriptor returns the descriptor of msg, or nil if it has none.
+func messageDescriptor(msg proto.Message) *desc.MessageDescriptor {
+	md, err := desc.LoadMessageDescriptorForMessage(msg)
+	if err != nil {
+		return nil
+	}
+	return md
+}
+
+// jsonFieldName returns the name of the field in the JSON form of its message,
+// as encoded by dynamic messages.
+func jsonFieldName(fd *desc.FieldDescriptor) string {
+	if name := fd.AsFieldDescriptorProto().GetJsonName(); name != "" {
+		return name
+	}
+	return fd.GetName()
+}
+
+// isWellKnownType reports whether md is one of the well-known types, whose
+// JSON forms are not objects of their fields.
+func isWellKnownType(md *desc.MessageDescriptor) bool {
+	return md.GetFile().GetPackage() == "google.protobuf"
+}
+
+// int64Numbers replaces the strings of the 64-bit integers in v, the JSON form
+// of a message of type md, with numbers.
+func int64Numbers(v interface{}, md *desc.MessageDescriptor) interface{} {
+	if md == nil {
+		return v
+	}
+	switch md.GetFullyQualifiedName() {
+	case "google.protobuf.Int64Value", "google.protobuf.UInt64Value":
+		return int64Number(v)
+	}
+	obj, ok := v.(map[string]interface{})
+	if !ok || isWellKnownType(md) {
+		return v
+	}
+	for _, fd := range md.GetFields() {
+		k := jsonFieldName(fd)
+		fv, ok := obj[k]
+		if !ok {
+			continue
+		}
+		switch fv := fv.(type) {
+		case map[string]interface{}:
+			if fd.IsMap() {
+				for mk, mv := range fv {
+					fv[mk] = int64FieldNumbers(mv, fd.GetMapValueType())
+				}
+				continue
+			}
+		case []interface{}:
+			for i, e := range fv {
+				fv[i] = int64FieldNumbers(e, fd)
+			}
+			continue
+		}
+		obj[k] = int64FieldNumbers(fv, fd)
+	}
+	return obj
+}
+
+// int64FieldNumbers replaces the strings of the 64-bit integers in v, a single
+// value of the field fd.
+func int64FieldNumbers(v interface{}, fd *desc.FieldDescriptor) interface{} {
+	if mt := fd.GetMessageType(); mt != nil {
+		return int64Numbers(v, mt)
+	}
+	switch fd.GetType() {
+	case dpb.FieldDescriptorProto_TYPE_INT64, dpb.FieldDescriptorProto_TYPE_UINT64,
+		dpb.FieldDescriptorProto_TYPE_SINT64, dpb.FieldDescriptorProto_TYPE_FIXED64,
+		dpb.FieldDescriptorProto_TYPE_SFIXED64:
+		return int64Number(v)
+	}
+	return v
+}
+
+func int64Number(v interface{}) interface{} {
+	if s, ok := v.(string); ok {
+		return gojson.Number(s)
+	}
+	return v
+}
+
+// docFields returns v, the JSON form of a message of type md with its default
+// values, restricted to the fields set in doc, the document it was decoded
+// from. The fields may be named as in the proto files or in the JSON form.
+func docFields(v, doc interface{}, md *desc.MessageDescriptor) interface{} {
+	obj, ok := v.(map[string]interface{})
+	d, dok := doc.(map[string]interface{})
+	if md == nil || !ok || !dok || isWellKnownType(md) {
+		return v
+	}
+	res := make(map[string]interface{}, len(d))
+	for _, fd := range md.GetFields() {
+		k := jsonFieldName(fd)
+		fv, ok := obj[k]
+		if !ok {
+			continue
+		}
+		dv, ok := d[k]
+		if !ok {
+			if dv, ok = d[fd.GetJSONName()]; !ok {
+				if dv, ok = d[fd.GetName()]; !ok {
+					continue
+				}
+			}
+		}
+		if mt := fd.GetMessageType(); mt != nil && !fd.IsMap() {
+			if a, ok := fv.([]interface{}); ok {
+				if da, ok := dv.([]interface{}); ok && len(da) == len(a) {
+					for i := range a {
+						a[i] = docFields(a[i], da[i], mt)
+					}
+				}
+			} else {
+				fv = docFields(fv, dv, mt)
+			}
+		}
+		res[k] = fv
+	}
+	return res
+}
+
+// removePath removes the field at path from v. The path applies to each element
+// of the arrays it goes through.
+func removePath(v interface{}, path []string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			delete(v, path[0])
+			return
+		}
+		removePath(v[path[0]], path[1:])
+	case []interface{}:
+		for _, e := range v {
+			removePath(e, path)
+		}
+	}
+}
+
+func joinPath(path, k string) string {
+	if path == "" {
+		return k
+	}
+	return path + "." + k
+}
+
+func formatJSONValue(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// checkValues checks the values of the fields of msg selected by the queries.
+// The errors are prefixed by prefix.
+func checkValues(msg proto.Message, values map[string]interface{}, prefix string, logger testsuite.Logger) {
+	v, err := toJSONValue(msg, true)
+	if err != nil {
+		logger.Errorf("%scould not marshal response: %v", prefix, err)
+		return
+	}
+	queries := make([]string, 0, len(values))
+	for query := range values {
+		queries = append(queries, query)
+	}
+	sort.Strings(queries)
+	for _, query := range queries {
+		val, err := json.Query(query, v)
+		if err != nil {
+			logger.Errorf("%scould not get value for query %q: %v", prefix, query, err)
+			continue
+		}
+		if expected := values[query]; !valueEqual(val, expected) {
+			logger.Errorf("%swrong value for query %q (got %v; want %v)", prefix, query, val, expected)
+		}
+	}
+}
+
+// valueEqual reports whether the value got of a query equals want. The numbers
+// may also be expected in their string form, as the 64-bit integers are
+// encoded by jsonpb.
+func valueEqual(got, want interface{}) bool {
+	if n, ok := got.(gojson.Number); ok {
+		if s, ok := want.(string); ok {
+			return s == n.String()
+		}
+	}
+	return cmp.Equal(got, want)
+}
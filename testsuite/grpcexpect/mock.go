@@ -0,0 +1,193 @@
+package grpcexpect
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/fullstorydev/grpcurl"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/blippar/aragorn/testsuite"
+)
+
+var _ testsuite.Mocker = (*Suite)(nil)
+
+var errMockNoProtoSet = errors.New("a protoset or proto files are required to mock a GRPC test suite")
+
+// Mock returns a GRPC server answering the calls of each test with its
+// expected code, headers and messages. The services are described by the
+// protoset or the proto files of the suite. When several tests call the same method, their
+// responses are returned in turn, the last one being repeated. The stream
+// expectations are answered by the messages of their contains field and the
+// tests running a script are not mocked.
+func (s *Suite) Mock() (testsuite.MockServer, error) {
+	if s.fileSource == nil {
+		return nil, errMockNoProtoSet
+	}
+	m := &mock{methods: make(map[string]*mockMethod)}
+	for _, st := range s.tests {
+		// The smoke tests are not mocked either.
+		t, ok := st.(*test)
+		if !ok || t.script != nil {
+			continue
+		}
+		if err := m.add(s.fileSource, t); err != nil {
+			return nil, fmt.Errorf("test %s: %v", t.Name(), err)
+		}
+	}
+	return grpc.NewServer(grpc.UnknownServiceHandler(m.handleStream)), nil
+}
+
+type mock struct {
+	methods map[string]*mockMethod // Indexed by full method name (/service/method).
+}
+
+type mockMethod struct {
+	desc *desc.MethodDescriptor
+
+	mu    sync.Mutex
+	resps []*mockResponse
+	next  int
+}
+
+type mockResponse struct {
+	code    codes.Code
+	header  metadata.MD
+	msgs    []*dynamic.Message
+	trailer metadata.MD
+	status  *spb.Status // Sent if code is not OK.
+}
+
+func (m *mock) add(descSource grpcurl.DescriptorSource, t *test) error {
+	md, err := findMethod(descSource, t.req.methodName)
+	if err != nil {
+		return err
+	}
+	resp := &mockResponse{
+		code:    t.expect.code,
+		header:  metadata.New(t.expect.header),
+		trailer: metadata.New(t.expect.trailer),
+		status:  &spb.Status{Code: int32(t.expect.code), Message: t.expect.message},
+	}
+	if resp.status.Message == "" {
+		resp.status.Message = t.expect.code.String()
+	}
+	for _, d := range t.expect.details {
+		dmd, err := findMessageType(descSource, d.typeName)
+		if err != nil {
+			return fmt.Errorf("could not find status detail type %s: %v", d.typeName, err)
+		}
+		msg, err := newMessage(dmd, d.msg)
+		if err != nil {
+			return fmt.Errorf("could not unmarshal expected status detail %s: %v", d.typeName, err)
+		}
+		b, err := msg.Marshal()
+		if err != nil {
+			return fmt.Errorf("could not marshal status detail %s: %v", d.typeName, err)
+		}
+		resp.status.Details = append(resp.status.Details, &any.Any{TypeUrl: "type.googleapis.com/" + d.typeName, Value: b})
+	}
+	raws := t.expect.msgs
+	if t.expect.stream != nil {
+		raws = t.expect.stream.contains
+	}
+	for _, raw := range raws {
+		msg := dynamic.NewMessage(md.GetOutputType())
+		if err := msg.UnmarshalJSON(raw); err != nil {
+			return fmt.Errorf("could not unmarshal expected document: %v", err)
+		}
+		resp.msgs = append(resp.msgs, msg)
+	}
+	name := "/" + md.GetService().GetFullyQualifiedName() + "/" + md.GetName()
+	mm, ok := m.methods[name]
+	if !ok {
+		mm = &mockMethod{desc: md}
+		m.methods[name] = mm
+	}
+	mm.resps = append(mm.resps, resp)
+	return nil
+}
+
+// findMethod returns the descriptor of a method named either
+// package.Service/Method or package.Service.Method.
+func findMethod(descSource grpcurl.DescriptorSource, name string) (*desc.MethodDescriptor, error) {
+	name = strings.TrimPrefix(name, "/")
+	pos := strings.LastIndex(name, "/")
+	if pos < 0 {
+		pos = strings.LastIndex(name, ".")
+	}
+	if pos < 0 {
+		return nil, fmt.Errorf("invalid method name %q", name)
+	}
+	dsc, err := descSource.FindSymbol(name[:pos])
+	if err != nil {
+		return nil, err
+	}
+	sd, ok := dsc.(*desc.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service", name[:pos])
+	}
+	md := sd.FindMethodByName(name[pos+1:])
+	if md == nil {
+		return nil, fmt.Errorf("service %s does not have a method named %s", name[:pos], name[pos+1:])
+	}
+	return md, nil
+}
+
+func (m *mock) handleStream(srv interface{}, stream grpc.ServerStream) error {
+	name, _ := grpc.MethodFromServerStream(stream)
+	mm, ok := m.methods[name]
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "no test calls method %s", name)
+	}
+	md := mm.desc
+	for {
+		req := dynamic.NewMessage(md.GetInputType())
+		if err := stream.RecvMsg(req); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if !md.IsClientStreaming() {
+			break
+		}
+	}
+	resp := mm.nextResponse()
+	if err := stream.SetHeader(resp.header); err != nil {
+		return err
+	}
+	stream.SetTrailer(resp.trailer)
+	if resp.code != codes.OK {
+		return status.FromProto(resp.status).Err()
+	}
+	for i, msg := range resp.msgs {
+		if i > 0 && !md.IsServerStreaming() {
+			break
+		}
+		if err := stream.SendMsg(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextResponse returns the response that must be sent.
+func (mm *mockMethod) nextResponse() *mockResponse {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	resp := mm.resps[mm.next]
+	if mm.next < len(mm.resps)-1 {
+		mm.next++
+	}
+	return resp
+}
@@ -0,0 +1,131 @@
+package grpcexpect
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding/gzip"
+)
+
+type callOptionsKey struct{}
+
+// callOptions are the options of the calls of a test. The options of the gRPC
+// library are passed in the context of the calls to the interceptors of the
+// connection, since the connection is shared.
+type callOptions struct {
+	timeout        time.Duration // Deadline of the calls, if set.
+	maxRecvMsgSize int           // Bound of the messages received with the web protocols.
+	opts           []grpc.CallOption
+}
+
+// newCallOptions returns the options of the calls set in the suite, overridden
+// by the ones set in override. The options of the web protocols are limited to
+// the timeout and the maximum size of the received messages.
+func (cfg *Config) newCallOptions(override *CallConfig, web bool) (*callOptions, error) {
+	var cc CallConfig
+	for _, c := range []*CallConfig{cfg.CallOptions, override} {
+		if c == nil {
+			continue
+		}
+		if c.Timeout != 0 {
+			cc.Timeout = c.Timeout
+		}
+		if c.Compression != "" {
+			cc.Compression = c.Compression
+		}
+		if c.MaxSendMsgSize != 0 {
+			cc.MaxSendMsgSize = c.MaxSendMsgSize
+		}
+		if c.MaxRecvMsgSize != 0 {
+			cc.MaxRecvMsgSize = c.MaxRecvMsgSize
+		}
+		if c.WaitForReady != nil {
+			cc.WaitForReady = c.WaitForReady
+		}
+	}
+	if cc.Timeout < 0 {
+		return nil, fmt.Errorf("callOptions: negative timeout %s", time.Duration(cc.Timeout))
+	}
+	if cc.MaxSendMsgSize < 0 || cc.MaxRecvMsgSize < 0 {
+		return nil, fmt.Errorf("callOptions: negative message size")
+	}
+	o := &callOptions{timeout: time.Duration(cc.Timeout), maxRecvMsgSize: defaultMaxRecvMsgSize}
+	switch cc.Compression {
+	case "", "identity":
+	case gzip.Name:
+		o.opts = append(o.opts, grpc.UseCompressor(gzip.Name))
+	default:
+		return nil, fmt.Errorf("callOptions: unknown compression %q", cc.Compression)
+	}
+	if cc.MaxSendMsgSize > 0 {
+		o.opts = append(o.opts, grpc.MaxCallSendMsgSize(cc.MaxSendMsgSize))
+	}
+	if cc.MaxRecvMsgSize > 0 {
+		if web {
+			o.maxRecvMsgSize = cc.MaxRecvMsgSize
+		} else {
+			o.opts = append(o.opts, grpc.MaxCallRecvMsgSize(cc.MaxRecvMsgSize))
+		}
+	}
+	if cc.WaitForReady != nil && *cc.WaitForReady {
+		o.opts = append(o.opts, grpc.FailFast(false))
+	}
+	if web && len(o.opts) > 0 {
+		return nil, fmt.Errorf("callOptions: only timeout and maxRecvMsgSize can be set with protocol %s", cfg.Protocol)
+	}
+	return o, nil
+}
+
+// context returns the context of the calls, canceled by the returned function.
+func (o *callOptions) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	cancel := func() {}
+	if o.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+	}
+	if len(o.opts) > 0 {
+		ctx = context.WithValue(ctx, callOptionsKey{}, o.opts)
+	}
+	return ctx, cancel
+}
+
+func callOptionsFromContext(ctx context.Context) []grpc.CallOption {
+	opts, _ := ctx.Value(callOptionsKey{}).([]grpc.CallOption)
+	return opts
+}
+
+// unaryCallOptions adds the options stored in the context of the calls to
+// the ones passed to next.
+func unaryCallOptions(next grpc.UnaryClientInterceptor) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return next(ctx, method, req, reply, cc, invoker, append(opts, callOptionsFromContext(ctx)...)...)
+	}
+}
+
+// streamCallOptions adds the options stored in the context of the streams to
+// the ones passed to next.
+func streamCallOptions(next grpc.StreamClientInterceptor) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return next(ctx, desc, cc, method, streamer, append(opts, callOptionsFromContext(ctx)...)...)
+	}
+}
+
+// unixSocketPath returns the path of the socket of a unix:path or
+// unix:///path address, if addr is one.
+func (cfg *Config) unixSocketPath() (string, bool) {
+	if !strings.HasPrefix(cfg.Address, "unix:") {
+		return "", false
+	}
+	path := strings.TrimPrefix(cfg.Address, "unix:")
+	if strings.HasPrefix(path, "//") {
+		return strings.TrimPrefix(path, "//"), true
+	}
+	return cfg.getFilePath(path), true
+}
+
+func dialUnix(path string, timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout("unix", path, timeout)
+}
@@ -0,0 +1,194 @@
+package grpcexpect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fullstorydev/grpcurl"
+	"github.com/jhump/protoreflect/grpcreflect"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+	reflectpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+const (
+	defaultDialTimeout       = 10 * time.Second
+	defaultDescriptorRefresh = 5 * time.Minute
+)
+
+var errConnClosed = errors.New("the suite was closed")
+
+// connKey identifies the connections that can be shared between suites: the
+// ones dialing the same address with the same transport settings. The calls
+// are authenticated and configured per call, not by the connection.
+type connKey struct {
+	address            string
+	tls                bool
+	caPath             string
+	clientCertPath     string
+	clientKeyPath      string
+	serverHostOverride string
+	insecure           bool
+	authority          string
+	keepalive          keepalive.ClientParameters
+}
+
+// connPool holds the connections shared between the suites.
+type connPool struct {
+	mu    sync.Mutex
+	conns map[connKey]*pooledConn
+}
+
+var pool = &connPool{conns: make(map[connKey]*pooledConn)}
+
+// acquire returns the connection of key, referenced by one more suite until it
+// is released.
+func (p *connPool) acquire(key connKey) *pooledConn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pc, ok := p.conns[key]
+	if !ok {
+		pc = &pooledConn{}
+		p.conns[key] = pc
+	}
+	pc.refs++
+	return pc
+}
+
+// release drops a reference to the connection of key, closing it once no suite
+// references it.
+func (p *connPool) release(key connKey) {
+	p.mu.Lock()
+	pc := p.conns[key]
+	pc.refs--
+	if pc.refs > 0 {
+		p.mu.Unlock()
+		return
+	}
+	delete(p.conns, key)
+	p.mu.Unlock()
+	pc.close()
+}
+
+// pooledConn is a connection dialed on first use, and the descriptors fetched
+// through its reflection API.
+type pooledConn struct {
+	refs int // Guarded by the mutex of the pool.
+
+	mu         sync.Mutex
+	cc         *grpc.ClientConn
+	dialing    chan struct{} // Closed when the ongoing dial is done, if any.
+	closed     bool
+	refClient  *grpcreflect.Client
+	descSource grpcurl.DescriptorSource
+	fetchedAt  time.Time
+}
+
+// clientConn returns the connection, dialed by d if it is not yet. The dial is
+// done without holding the lock, and shared by the concurrent callers.
+func (pc *pooledConn) clientConn(ctx context.Context, d *dialer) (*grpc.ClientConn, error) {
+	for {
+		pc.mu.Lock()
+		if pc.closed {
+			pc.mu.Unlock()
+			return nil, errConnClosed
+		}
+		if pc.cc != nil {
+			cc := pc.cc
+			pc.mu.Unlock()
+			return cc, nil
+		}
+		if done := pc.dialing; done != nil {
+			pc.mu.Unlock()
+			// A failed dial is tried again by the waiting callers.
+			select {
+			case <-done:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		done := make(chan struct{})
+		pc.dialing = done
+		pc.mu.Unlock()
+
+		dctx, cancel := context.WithTimeout(ctx, d.timeout)
+		cc, err := grpc.DialContext(dctx, d.key.address, append(d.opts, grpc.WithBlock())...)
+		cancel()
+
+		pc.mu.Lock()
+		pc.dialing = nil
+		if err == nil && pc.closed {
+			cc.Close()
+			err = errConnClosed
+		} else if err == nil {
+			pc.cc = cc
+		}
+		pc.mu.Unlock()
+		close(done)
+		return cc, err
+	}
+}
+
+// close closes the connection and stops its reflection client.
+func (pc *pooledConn) close() {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.closed = true
+	if pc.refClient != nil {
+		pc.refClient.Reset()
+	}
+	if pc.cc != nil {
+		pc.cc.Close()
+	}
+}
+
+// reflectionSource returns the source of the descriptors fetched from the
+// server. Its cached descriptors are dropped once older than refresh.
+func (pc *pooledConn) reflectionSource(refresh time.Duration) grpcurl.DescriptorSource {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.descSource != nil && time.Since(pc.fetchedAt) < refresh {
+		return pc.descSource
+	}
+	if pc.refClient != nil {
+		pc.refClient.Reset()
+	}
+	ctx := context.Background()
+	pc.refClient = grpcreflect.NewClient(ctx, reflectpb.NewServerReflectionClient(pc.cc))
+	pc.descSource = grpcurl.DescriptorSourceFromServer(ctx, pc.refClient)
+	pc.fetchedAt = time.Now()
+	return pc.descSource
+}
+
+// conn is the connection of a suite to its server.
+type conn struct {
+	cc         *grpc.ClientConn
+	descSource grpcurl.DescriptorSource
+}
+
+// A dialer connects the tests of a suite to their server when they run, so
+// that an unavailable server fails the tests rather than the loading of the
+// suite. A failed dial is tried again by the next test.
+type dialer struct {
+	key         connKey
+	pc          *pooledConn // Acquired from the pool when the suite is created.
+	opts        []grpc.DialOption
+	timeout     time.Duration
+	fileSource  grpcurl.DescriptorSource // Replaces the reflection API, if set.
+	descRefresh time.Duration
+}
+
+func (d *dialer) dial(ctx context.Context) (*conn, error) {
+	cc, err := d.pc.clientConn(ctx, d)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to %s: %v", d.key.address, err)
+	}
+	if d.fileSource != nil {
+		return &conn{cc: cc, descSource: d.fileSource}, nil
+	}
+	return &conn{cc: cc, descSource: d.pc.reflectionSource(d.descRefresh)}, nil
+}
@@ -0,0 +1,190 @@
+package grpcexpect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fullstorydev/grpcurl"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/jhump/protoreflect/dynamic/grpcdynamic"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/blippar/aragorn/pkg/auth"
+	"github.com/blippar/aragorn/testsuite"
+)
+
+const (
+	healthService     = "grpc.health.v1.Health"
+	reflectionService = "grpc.reflection.v1alpha.ServerReflection"
+)
+
+// smokeTest is a test generated from the services of the server, either
+// checking their health or calling their unary methods. The services are
+// listed when the test runs so that it covers the newly deployed ones.
+type smokeTest struct {
+	dialer   *dialer
+	signer   auth.Signer // Authenticates the calls, if set.
+	callOpts *callOptions
+	headers  []string
+	exclude  map[string]bool // Names of the services and methods not checked.
+
+	name        string
+	description string
+	kind        smokeKind
+}
+
+type smokeKind int
+
+const (
+	healthCheck smokeKind = iota
+	unaryCalls
+)
+
+func (t *smokeTest) Name() string        { return t.name }
+func (t *smokeTest) Description() string { return t.description }
+
+func (t *smokeTest) Run(ctx context.Context, logger testsuite.Logger) {
+	if t.signer != nil {
+		ctx = withSigner(ctx, t.signer)
+	}
+	ctx = metadata.NewOutgoingContext(ctx, grpcurl.MetadataFromHeaders(t.headers))
+	c, err := t.dialer.dial(ctx)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+	switch t.kind {
+	case healthCheck:
+		t.checkHealth(ctx, c, logger)
+	case unaryCalls:
+		t.invokeUnaryMethods(ctx, c, logger)
+	}
+}
+
+// services returns the services of the server that are not excluded, except
+// the health and reflection services.
+func (t *smokeTest) services(c *conn) ([]string, error) {
+	svcs, err := grpcurl.ListServices(c.descSource)
+	if err != nil {
+		return nil, fmt.Errorf("could not list services: %v", err)
+	}
+	res := svcs[:0]
+	for _, svc := range svcs {
+		if svc != healthService && svc != reflectionService && !t.exclude[svc] {
+			res = append(res, svc)
+		}
+	}
+	return res, nil
+}
+
+// checkHealth checks that the server and each of its services are serving.
+func (t *smokeTest) checkHealth(ctx context.Context, c *conn, logger testsuite.Logger) {
+	client := healthpb.NewHealthClient(c.cc)
+	if err := t.checkServingStatus(ctx, client, ""); err != nil {
+		logger.Errorf("server: %v", err)
+		return
+	}
+	svcs, err := t.services(c)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+	for _, svc := range svcs {
+		if err := t.checkServingStatus(ctx, client, svc); err != nil {
+			logger.Errorf("service %s: %v", svc, err)
+		}
+	}
+}
+
+func (t *smokeTest) checkServingStatus(ctx context.Context, client healthpb.HealthClient, svc string) error {
+	ctx, cancel := t.callOpts.context(ctx)
+	defer cancel()
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: svc})
+	if err != nil {
+		return fmt.Errorf("could not check health: %v", err)
+	}
+	if got := resp.GetStatus(); got != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("not serving (got %s)", got)
+	}
+	return nil
+}
+
+// invokeUnaryMethods calls each unary method of the services with an empty
+// request. The method is expected to be implemented and to handle the request,
+// any other error being accepted.
+func (t *smokeTest) invokeUnaryMethods(ctx context.Context, c *conn, logger testsuite.Logger) {
+	svcs, err := t.services(c)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+	stub := grpcdynamic.NewStub(c.cc)
+	for _, svc := range svcs {
+		dsc, err := c.descSource.FindSymbol(svc)
+		if err != nil {
+			logger.Errorf("service %s: could not resolve service: %v", svc, err)
+			continue
+		}
+		sd, ok := dsc.(*desc.ServiceDescriptor)
+		if !ok {
+			logger.Errorf("service %s: not a service", svc)
+			continue
+		}
+		for _, md := range sd.GetMethods() {
+			name := md.GetFullyQualifiedName()
+			if md.IsClientStreaming() || md.IsServerStreaming() || t.exclude[name] {
+				continue
+			}
+			cctx, cancel := t.callOpts.context(ctx)
+			_, err := stub.InvokeRpc(cctx, md, dynamic.NewMessage(md.GetInputType()))
+			cancel()
+			st, ok := status.FromError(err)
+			if !ok {
+				logger.Errorf("method %s: could not invoke method: %v", name, err)
+				continue
+			}
+			if c := st.Code(); c == codes.Unimplemented || c == codes.Internal {
+				logger.Errorf("method %s: wrong status code (got %s) message=%q", name, c, st.Message())
+			}
+		}
+	}
+}
+
+// genSmokeTests returns the smoke tests of the suite.
+func (cfg *Config) genSmokeTests(d *dialer, signer auth.Signer, callOpts *callOptions) ([]testsuite.Test, error) {
+	sc := cfg.Smoke
+	if !sc.Health && !sc.InvokeUnary {
+		return nil, errors.New("smoke: at least one of health or invokeUnary must be set")
+	}
+	exclude := make(map[string]bool, len(sc.Exclude))
+	for _, name := range sc.Exclude {
+		// Methods can be named as in the requests, e.g. package.Service/Method.
+		exclude[strings.Replace(name, "/", ".", 1)] = true
+	}
+	newTest := func(name string, kind smokeKind) *smokeTest {
+		return &smokeTest{
+			dialer:      d,
+			signer:      signer,
+			callOpts:    callOpts,
+			headers:     testsuite.MergeHeaders(cfg.Header).Slice(),
+			exclude:     exclude,
+			name:        name,
+			description: fmt.Sprintf("grpc://%s", cfg.Address),
+			kind:        kind,
+		}
+	}
+	var tests []testsuite.Test
+	if sc.Health {
+		tests = append(tests, newTest("Health", healthCheck))
+	}
+	if sc.InvokeUnary {
+		tests = append(tests, newTest("Unary methods", unaryCalls))
+	}
+	return tests, nil
+}
@@ -0,0 +1,93 @@
+package grpcexpect
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fullstorydev/grpcurl"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"google.golang.org/grpc/status"
+
+	"github.com/blippar/aragorn/testsuite"
+)
+
+// detail is an expected status detail.
+type detail struct {
+	typeName string // Fully qualified name of the message type.
+	msg      []byte
+}
+
+// checkDetails checks that each expected detail matches a detail of st of the
+// same type.
+func (t *test) checkDetails(descSource grpcurl.DescriptorSource, st *status.Status, logger testsuite.Logger) {
+	var got []*dynamic.Message
+	for _, a := range st.Proto().GetDetails() {
+		name := a.TypeUrl[strings.LastIndex(a.TypeUrl, "/")+1:]
+		md, err := findMessageType(descSource, name)
+		if err != nil {
+			logger.Errorf("could not decode status detail %s: %v", name, err)
+			continue
+		}
+		msg := dynamic.NewMessage(md)
+		if err := msg.Unmarshal(a.Value); err != nil {
+			logger.Errorf("could not decode status detail %s: %v", name, err)
+			continue
+		}
+		got = append(got, msg)
+	}
+	for _, d := range t.expect.details {
+		md, err := findMessageType(descSource, d.typeName)
+		if err != nil {
+			logger.Errorf("could not decode expected status detail %s: %v", d.typeName, err)
+			continue
+		}
+		want, err := newMessage(md, d.msg)
+		if err != nil {
+			logger.Errorf("could not unmarshal expected status detail %s: %v", d.typeName, err)
+			continue
+		}
+		found := false
+		diff := ""
+		for _, msg := range got {
+			if msg.GetMessageDescriptor().GetFullyQualifiedName() != d.typeName {
+				continue
+			}
+			dm, err := t.expect.match.diff(msg, want, d.msg)
+			if err == nil && dm == "" {
+				found = true
+				break
+			}
+			if diff == "" {
+				diff = dm
+			}
+		}
+		switch {
+		case found:
+		case diff == "":
+			logger.Errorf("missing status detail %s", d.typeName)
+		default:
+			logger.Errorf("wrong status detail %s:\n%s", d.typeName, diff)
+		}
+	}
+}
+
+// findMessageType returns the descriptor of the message type named name,
+// either linked in the binary, like the google.rpc status details, or known by
+// descSource.
+func findMessageType(descSource grpcurl.DescriptorSource, name string) (*desc.MessageDescriptor, error) {
+	if md, err := desc.LoadMessageDescriptor(name); err != nil {
+		return nil, err
+	} else if md != nil {
+		return md, nil
+	}
+	dsc, err := descSource.FindSymbol(name)
+	if err != nil {
+		return nil, err
+	}
+	md, ok := dsc.(*desc.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a message", name)
+	}
+	return md, nil
+}
@@ -0,0 +1,196 @@
+package grpcexpect
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/fullstorydev/grpcurl"
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic/grpcdynamic"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/blippar/aragorn/testsuite"
+)
+
+// streamExpect holds the expectations on the messages of a stream.
+type streamExpect struct {
+	minMsgs  int
+	maxMsgs  int // Any number of messages is expected if zero.
+	contains [][]byte
+	at       []indexedMsg // Sorted by index.
+}
+
+type indexedMsg struct {
+	index int // Negative indexes count from the end.
+	msg   []byte
+}
+
+func (e *streamExpect) check(md *desc.MessageDescriptor, resps []proto.Message, m *matcher, logger testsuite.Logger) {
+	n := len(resps)
+	if n < e.minMsgs {
+		logger.Errorf("too few messages (got %d; want at least %d)", n, e.minMsgs)
+	}
+	if e.maxMsgs > 0 && n > e.maxMsgs {
+		logger.Errorf("too many messages (got %d; want at most %d)", n, e.maxMsgs)
+	}
+	for _, raw := range e.contains {
+		want, err := newMessage(md, raw)
+		if err != nil {
+			logger.Errorf("could not unmarshal expected document: %v", err)
+			continue
+		}
+		if !containsMessage(resps, want, raw, m) {
+			logger.Errorf("no message matching %s", want)
+		}
+	}
+	for _, at := range e.at {
+		i := at.index
+		if i < 0 {
+			i += n
+		}
+		if i < 0 || i >= n {
+			logger.Errorf("missing message %d (got %d messages)", at.index, n)
+			continue
+		}
+		want, err := newMessage(md, at.msg)
+		if err != nil {
+			logger.Errorf("could not unmarshal expected document: %v", err)
+			continue
+		}
+		if d, err := m.diff(resps[i], want, at.msg); err != nil {
+			logger.Error(err)
+		} else if d != "" {
+			logger.Errorf("wrong message %d:\n%s", at.index, d)
+		}
+	}
+}
+
+func containsMessage(msgs []proto.Message, want proto.Message, doc []byte, m *matcher) bool {
+	for _, msg := range msgs {
+		if m.equal(msg, want, doc) {
+			return true
+		}
+	}
+	return false
+}
+
+type scriptStepKind int
+
+const (
+	sendStep scriptStepKind = iota
+	receiveStep
+	closeSendStep
+)
+
+// scriptStep sends or receives messages on a bidirectional stream, or closes
+// its sending side.
+type scriptStep struct {
+	kind scriptStepKind
+	msgs [][]byte
+}
+
+// runScript calls the bidirectional streaming method of the test and runs its
+// script, checking the received messages in turn. The messages received after
+// the script are collected until the stream ends. The references to the saved
+// values in the sent messages are replaced.
+func (t *test) runScript(ctx context.Context, c *conn, headers []string, h *handler, logger testsuite.Logger) error {
+	md, err := findMethod(c.descSource, t.req.methodName)
+	if err != nil {
+		return err
+	}
+	if !md.IsClientStreaming() || !md.IsServerStreaming() {
+		return fmt.Errorf("a script requires a bidirectional streaming method, %s is not", md.GetFullyQualifiedName())
+	}
+	h.OnResolveMethod(md)
+	vars, _ := testsuite.MDFromContext(ctx)
+	ctx = metadata.NewOutgoingContext(ctx, grpcurl.MetadataFromHeaders(headers))
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	str, err := grpcdynamic.NewStub(c.cc).InvokeRpcBidiStream(ctx, md)
+	if err != nil {
+		return err
+	}
+	var (
+		recvErr error // Set once the stream has ended.
+		closed  bool
+	)
+script:
+	for i, s := range t.script {
+		switch s.kind {
+		case sendStep:
+			msgs := s.msgs
+			if vars != nil {
+				if msgs, err = expandMsgs(msgs, vars); err != nil {
+					return fmt.Errorf("script step %d: %v", i, err)
+				}
+			}
+			for _, raw := range msgs {
+				req, err := newMessage(md.GetInputType(), raw)
+				if err != nil {
+					return fmt.Errorf("script step %d: could not parse message as %q: %v", i, md.GetInputType().GetFullyQualifiedName(), err)
+				}
+				// The stream was ended by the server, the status is returned
+				// by the next receive.
+				if err := str.SendMsg(req); err == io.EOF {
+					break script
+				} else if err != nil {
+					return err
+				}
+			}
+		case closeSendStep:
+			if err := str.CloseSend(); err != nil {
+				return err
+			}
+			closed = true
+		case receiveStep:
+			for _, raw := range s.msgs {
+				resp, err := str.RecvMsg()
+				if err != nil {
+					recvErr = err
+					logger.Errorf("script step %d: stream ended before receiving a message", i)
+					break script
+				}
+				h.OnReceiveResponse(resp)
+				want, err := newMessage(md.GetOutputType(), raw)
+				if err != nil {
+					logger.Errorf("script step %d: could not unmarshal expected document: %v", i, err)
+					continue
+				}
+				if d, err := t.expect.match.diff(resp, want, raw); err != nil {
+					logger.Errorf("script step %d: %v", i, err)
+				} else if d != "" {
+					logger.Errorf("script step %d: wrong response:\n%s", i, d)
+				}
+			}
+		}
+	}
+	if !closed && recvErr == nil {
+		if err := str.CloseSend(); err != nil {
+			return err
+		}
+	}
+	for recvErr == nil {
+		resp, err := str.RecvMsg()
+		if err != nil {
+			recvErr = err
+			break
+		}
+		h.OnReceiveResponse(resp)
+	}
+	st := status.New(codes.OK, "")
+	if recvErr != io.EOF {
+		var ok bool
+		if st, ok = status.FromError(recvErr); !ok {
+			return fmt.Errorf("grpc call for %q failed: %v", md.GetFullyQualifiedName(), recvErr)
+		}
+	}
+	if hdr, err := str.Header(); err == nil {
+		h.OnReceiveHeaders(hdr)
+	}
+	h.OnReceiveTrailers(st, str.Trailer())
+	return nil
+}
@@ -0,0 +1,125 @@
+package grpcexpect
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/blippar/aragorn/pkg/util/json"
+	"github.com/blippar/aragorn/testsuite"
+)
+
+// varsTmpl matches the {{id.field}} references to the documents saved by the
+// previous tests.
+var varsTmpl = regexp.MustCompile(`{{[0-9A-Za-z._-]+}}`)
+
+// expandMsgs returns the messages with their references replaced by the saved
+// values. A string only made of a reference is replaced by the value itself,
+// keeping its type.
+func expandMsgs(msgs [][]byte, md testsuite.MD) ([][]byte, error) {
+	res := make([][]byte, len(msgs))
+	for i, raw := range msgs {
+		if !varsTmpl.Match(raw) {
+			res[i] = raw
+			continue
+		}
+		var v interface{}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+		v, err := expandValue(v, md)
+		if err != nil {
+			return nil, err
+		}
+		if res[i], err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func expandValue(v interface{}, md testsuite.MD) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		if q := varsTmpl.FindString(v); q == v {
+			return queryMD(q, md)
+		}
+		return expandString(v, md)
+	case []interface{}:
+		for i, e := range v {
+			nv, err := expandValue(e, md)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = nv
+		}
+	case map[string]interface{}:
+		for k, e := range v {
+			nv, err := expandValue(e, md)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = nv
+		}
+	}
+	return v, nil
+}
+
+// expandString replaces the references in s by the saved values.
+func expandString(s string, md testsuite.MD) (string, error) {
+	var err error
+	s = varsTmpl.ReplaceAllStringFunc(s, func(q string) string {
+		v, qerr := queryMD(q, md)
+		if qerr != nil {
+			if err == nil {
+				err = qerr
+			}
+			return q
+		}
+		return fmt.Sprint(v)
+	})
+	return s, err
+}
+
+// expandHeaders returns the key:value pairs with the references in their
+// values replaced by the saved values.
+func expandHeaders(headers []string, md testsuite.MD) ([]string, error) {
+	res := make([]string, len(headers))
+	for i, h := range headers {
+		s, err := expandString(h, md)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = s
+	}
+	return res, nil
+}
+
+func queryMD(q string, md testsuite.MD) (interface{}, error) {
+	v, err := json.Query(q[2:len(q)-2], map[string]interface{}(md))
+	if err != nil {
+		return nil, fmt.Errorf("could not get value for %s: %v", q, err)
+	}
+	return v, nil
+}
+
+// saveDocument saves the JSON form of the received messages in md under id:
+// the message itself, or the array of messages for a server streaming method.
+func saveDocument(md testsuite.MD, id string, resps []proto.Message, serverStreaming bool) error {
+	docs := make([]interface{}, len(resps))
+	for i, resp := range resps {
+		v, err := toJSONValue(resp, true)
+		if err != nil {
+			return err
+		}
+		docs[i] = v
+	}
+	switch {
+	case serverStreaming:
+		md[id] = docs
+	case len(docs) > 0:
+		md[id] = docs[0]
+	}
+	return nil
+}
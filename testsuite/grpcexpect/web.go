@@ -0,0 +1,459 @@
+package grpcexpect
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/fullstorydev/grpcurl"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/blippar/aragorn/pkg/auth"
+	"github.com/blippar/aragorn/pkg/util/json"
+)
+
+// Protocols used to call the methods.
+const (
+	protocolGRPC    = "grpc"
+	protocolGRPCWeb = "grpc-web"
+	protocolConnect = "connect"
+)
+
+// defaultMaxRecvMsgSize bounds the messages received with the web protocols,
+// like the grpc-go default.
+const defaultMaxRecvMsgSize = 4 << 20
+
+var errMsgSize = errors.New("received message larger than maxRecvMsgSize")
+
+// Flags of the length-prefixed messages.
+const (
+	flagCompressed = 0x01 // Message compressed with the encoding of the response.
+	flagEndStream  = 0x02 // Connect end of stream message.
+	flagWebTrailer = 0x80 // gRPC-Web trailers.
+)
+
+// webClient calls the unary and server streaming methods over HTTP/1.1 with
+// the gRPC-Web or the Connect protocol, using the binary encoding of the
+// messages.
+type webClient struct {
+	protocol   string
+	baseURL    string // Scheme and address of the server, without trailing slash.
+	client     *http.Client
+	descSource grpcurl.DescriptorSource
+}
+
+// invoke calls the method and reports the call to h like grpcurl.InvokeRpc.
+// The received messages larger than maxMsgSize are rejected.
+func (c *webClient) invoke(ctx context.Context, methodName string, headers []string, signer auth.Signer, maxMsgSize int, h *handler) error {
+	md, err := findMethod(c.descSource, methodName)
+	if err != nil {
+		return err
+	}
+	if md.IsClientStreaming() {
+		return fmt.Errorf("protocol %s does not support the client streaming method %s", c.protocol, md.GetFullyQualifiedName())
+	}
+	h.OnResolveMethod(md)
+	req := dynamic.NewMessage(md.GetInputType())
+	if data, err := h.getRequestData(); err == nil {
+		if err := req.UnmarshalJSON(data); err != nil {
+			return fmt.Errorf("could not parse request as %q: %v", md.GetInputType().GetFullyQualifiedName(), err)
+		}
+	} else if err != io.EOF {
+		return err
+	}
+	body, err := req.Marshal()
+	if err != nil {
+		return err
+	}
+	var contentType string
+	switch {
+	case c.protocol == protocolGRPCWeb:
+		contentType = "application/grpc-web+proto"
+		body = envelope(0, body)
+	case md.IsServerStreaming():
+		contentType = "application/connect+proto"
+		body = envelope(0, body)
+	default:
+		contentType = "application/proto"
+	}
+	u := c.baseURL + "/" + md.GetService().GetFullyQualifiedName() + "/" + md.GetName()
+	hreq, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for _, kv := range headers {
+		p := strings.SplitN(kv, ":", 2)
+		if len(p) == 1 {
+			p = append(p, "")
+		}
+		hreq.Header.Add(strings.TrimSpace(p[0]), strings.TrimSpace(p[1]))
+	}
+	hreq.Header.Set("Content-Type", contentType)
+	if c.protocol == protocolGRPCWeb {
+		hreq.Header.Set("X-Grpc-Web", "1")
+		hreq.Header.Set("Grpc-Accept-Encoding", "gzip")
+	} else {
+		hreq.Header.Set("Connect-Protocol-Version", "1")
+		hreq.Header.Set("Connect-Accept-Encoding", "gzip")
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		// Propagates the deadline to the server, in milliseconds.
+		ms := int64(time.Until(deadline)/time.Millisecond) + 1
+		if c.protocol == protocolGRPCWeb {
+			hreq.Header.Set("Grpc-Timeout", strconv.FormatInt(ms, 10)+"m")
+		} else {
+			hreq.Header.Set("Connect-Timeout-Ms", strconv.FormatInt(ms, 10))
+		}
+	}
+	if signer != nil {
+		if err := signer.Sign(hreq, body); err != nil {
+			return fmt.Errorf("could not authenticate request: %v", err)
+		}
+	}
+	resp, err := c.client.Do(hreq.WithContext(ctx))
+	if err != nil {
+		// Reports the expired deadline like the grpc protocol.
+		if ctx.Err() == context.DeadlineExceeded {
+			h.OnReceiveHeaders(metadata.MD{})
+			h.OnReceiveTrailers(status.New(codes.DeadlineExceeded, ctx.Err().Error()), metadata.MD{})
+			return nil
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case c.protocol == protocolGRPCWeb:
+		return readGRPCWebResponse(resp, md, maxMsgSize, h)
+	case md.IsServerStreaming():
+		return readConnectStream(resp, md, maxMsgSize, h)
+	default:
+		return readConnectUnary(resp, md, maxMsgSize, h)
+	}
+}
+
+// readGRPCWebResponse reads the messages and the trailers of a gRPC-Web
+// response. The status is sent in the headers of a response without messages.
+func readGRPCWebResponse(resp *http.Response, md *desc.MethodDescriptor, maxMsgSize int, h *handler) error {
+	if resp.StatusCode != http.StatusOK {
+		h.OnReceiveHeaders(headerMetadata(resp.Header, ""))
+		h.OnReceiveTrailers(status.New(httpStatusCode(resp.StatusCode), resp.Status), metadata.MD{})
+		return nil
+	}
+	if resp.Header.Get("Grpc-Status") != "" {
+		h.OnReceiveHeaders(metadata.MD{})
+		return onGRPCWebTrailers(resp.Header, h)
+	}
+	h.OnReceiveHeaders(headerMetadata(resp.Header, ""))
+	r := bufio.NewReader(resp.Body)
+	encoding := resp.Header.Get("Grpc-Encoding")
+	for {
+		flags, data, err := readEnvelope(r, encoding, maxMsgSize)
+		if err == io.EOF {
+			return errors.New("response ended without trailers")
+		} else if err != nil {
+			return err
+		}
+		if flags&flagWebTrailer != 0 {
+			tr, err := textproto.NewReader(bufio.NewReader(bytes.NewReader(append(data, "\r\n"...)))).ReadMIMEHeader()
+			if err != nil {
+				return fmt.Errorf("could not read trailers: %v", err)
+			}
+			return onGRPCWebTrailers(http.Header(tr), h)
+		}
+		if err := onResponse(data, md, h); err != nil {
+			return err
+		}
+	}
+}
+
+func onGRPCWebTrailers(tr http.Header, h *handler) error {
+	code, err := strconv.Atoi(tr.Get("Grpc-Status"))
+	if err != nil {
+		return fmt.Errorf("invalid grpc-status %q", tr.Get("Grpc-Status"))
+	}
+	msg, _ := url.PathUnescape(tr.Get("Grpc-Message"))
+	st := &spb.Status{Code: int32(code), Message: msg}
+	if v := tr.Get("Grpc-Status-Details-Bin"); v != "" {
+		b, err := decodeBase64(v)
+		if err != nil {
+			return fmt.Errorf("invalid grpc-status-details-bin: %v", err)
+		}
+		if err := proto.Unmarshal(b, st); err != nil {
+			return fmt.Errorf("invalid grpc-status-details-bin: %v", err)
+		}
+	}
+	for _, k := range []string{"Grpc-Status", "Grpc-Message", "Grpc-Status-Details-Bin"} {
+		tr.Del(k)
+	}
+	h.OnReceiveTrailers(status.FromProto(st), headerMetadata(tr, ""))
+	return nil
+}
+
+// readConnectUnary reads the message of a Connect unary response, or its error
+// and the trailers sent as headers prefixed by Trailer-.
+func readConnectUnary(resp *http.Response, md *desc.MethodDescriptor, maxMsgSize int, h *handler) error {
+	hdr := make(http.Header)
+	for k, vs := range resp.Header {
+		if !strings.HasPrefix(k, "Trailer-") {
+			hdr[k] = vs
+		}
+	}
+	h.OnReceiveHeaders(headerMetadata(hdr, ""))
+	trailer := headerMetadata(resp.Header, "Trailer-")
+	data, err := readAll(resp.Body, maxMsgSize)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		h.OnReceiveTrailers(connectErrorStatus(resp, data), trailer)
+		return nil
+	}
+	if err := onResponse(data, md, h); err != nil {
+		return err
+	}
+	h.OnReceiveTrailers(status.New(codes.OK, ""), trailer)
+	return nil
+}
+
+// readConnectStream reads the messages of a Connect streaming response until
+// its end of stream message, holding its error and trailers.
+func readConnectStream(resp *http.Response, md *desc.MethodDescriptor, maxMsgSize int, h *handler) error {
+	h.OnReceiveHeaders(headerMetadata(resp.Header, ""))
+	if resp.StatusCode != http.StatusOK {
+		data, err := readAll(resp.Body, maxMsgSize)
+		if err != nil {
+			return err
+		}
+		h.OnReceiveTrailers(connectErrorStatus(resp, data), metadata.MD{})
+		return nil
+	}
+	r := bufio.NewReader(resp.Body)
+	encoding := resp.Header.Get("Connect-Content-Encoding")
+	for {
+		flags, data, err := readEnvelope(r, encoding, maxMsgSize)
+		if err == io.EOF {
+			return errors.New("stream ended without end of stream message")
+		} else if err != nil {
+			return err
+		}
+		if flags&flagEndStream != 0 {
+			var end struct {
+				Error    *connectError       `json:"error"`
+				Metadata map[string][]string `json:"metadata"`
+			}
+			if err := json.Unmarshal(data, &end); err != nil {
+				return fmt.Errorf("invalid end of stream message: %v", err)
+			}
+			st := status.New(codes.OK, "")
+			if end.Error != nil {
+				st = end.Error.status()
+			}
+			tr := make(http.Header, len(end.Metadata))
+			for k, vs := range end.Metadata {
+				tr[http.CanonicalHeaderKey(k)] = vs
+			}
+			h.OnReceiveTrailers(st, headerMetadata(tr, ""))
+			return nil
+		}
+		if err := onResponse(data, md, h); err != nil {
+			return err
+		}
+	}
+}
+
+// connectError is the JSON form of a Connect error.
+type connectError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details []struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	} `json:"details"`
+}
+
+func (e *connectError) status() *status.Status {
+	st := &spb.Status{Code: int32(connectCode(e.Code)), Message: e.Message}
+	for _, d := range e.Details {
+		b, err := decodeBase64(d.Value)
+		if err != nil {
+			continue
+		}
+		st.Details = append(st.Details, &any.Any{TypeUrl: "type.googleapis.com/" + d.Type, Value: b})
+	}
+	return status.FromProto(st)
+}
+
+// connectErrorStatus returns the status of a Connect error response, from its
+// JSON body or else from its HTTP status code.
+func connectErrorStatus(resp *http.Response, data []byte) *status.Status {
+	var e connectError
+	if err := json.Unmarshal(data, &e); err == nil && e.Code != "" {
+		return e.status()
+	}
+	return status.New(httpStatusCode(resp.StatusCode), resp.Status)
+}
+
+// connectCode returns the code named name in the Connect protocol, such as
+// invalid_argument.
+func connectCode(name string) codes.Code {
+	for c := codes.Canceled; c <= codes.Unauthenticated; c++ {
+		var b strings.Builder
+		for i, r := range c.String() {
+			if unicode.IsUpper(r) {
+				if i > 0 {
+					b.WriteByte('_')
+				}
+				r = unicode.ToLower(r)
+			}
+			b.WriteRune(r)
+		}
+		if b.String() == name {
+			return c
+		}
+	}
+	return codes.Unknown
+}
+
+// httpStatusCode returns the code of a call failed with an HTTP status code,
+// as mapped by the gRPC specification.
+func httpStatusCode(code int) codes.Code {
+	switch code {
+	case http.StatusBadRequest:
+		return codes.Internal
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.Unimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return codes.Unavailable
+	}
+	return codes.Unknown
+}
+
+func onResponse(data []byte, md *desc.MethodDescriptor, h *handler) error {
+	msg := dynamic.NewMessage(md.GetOutputType())
+	if err := msg.Unmarshal(data); err != nil {
+		return fmt.Errorf("could not decode response: %v", err)
+	}
+	h.OnReceiveResponse(msg)
+	return nil
+}
+
+// envelope returns the message prefixed by its flags and length.
+func envelope(flags byte, msg []byte) []byte {
+	b := make([]byte, 5, 5+len(msg))
+	b[0] = flags
+	binary.BigEndian.PutUint32(b[1:], uint32(len(msg)))
+	return append(b, msg...)
+}
+
+// readEnvelope reads a length-prefixed message, decompressed with encoding if
+// its compressed flag is set. Messages larger than maxSize are rejected before
+// being read.
+func readEnvelope(r io.Reader, encoding string, maxSize int) (byte, []byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, errors.New("truncated message")
+		}
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(prefix[1:])
+	if uint64(n) > uint64(maxSize) {
+		return 0, nil, errMsgSize
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, errors.New("truncated message")
+	}
+	if prefix[0]&flagCompressed != 0 {
+		var err error
+		if data, err = decompress(data, encoding, maxSize); err != nil {
+			return 0, nil, err
+		}
+	}
+	return prefix[0], data, nil
+}
+
+// decompress returns the message compressed with encoding, as advertised by
+// the grpc-encoding or connect-content-encoding header of the response, if it
+// doesn't exceed maxSize once decompressed.
+func decompress(data []byte, encoding string, maxSize int) ([]byte, error) {
+	switch encoding {
+	case "gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("could not decompress message: %v", err)
+		}
+		defer zr.Close()
+		b, err := readAll(zr, maxSize)
+		if err != nil && err != errMsgSize {
+			return nil, fmt.Errorf("could not decompress message: %v", err)
+		}
+		return b, err
+	case "", "identity":
+		return nil, errors.New("compressed message without encoding")
+	}
+	return nil, fmt.Errorf("compressed message with unsupported encoding %q", encoding)
+}
+
+// readAll reads r until EOF, failing if it has more than maxSize bytes.
+func readAll(r io.Reader, maxSize int) ([]byte, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(r, int64(maxSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxSize {
+		return nil, errMsgSize
+	}
+	return b, nil
+}
+
+// headerMetadata returns the metadata of the HTTP header fields prefixed by
+// prefix, without it. The values of the binary fields are decoded.
+func headerMetadata(hdr http.Header, prefix string) metadata.MD {
+	md := make(metadata.MD)
+	for k, vs := range hdr {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		k = strings.ToLower(k[len(prefix):])
+		for _, v := range vs {
+			if strings.HasSuffix(k, "-bin") {
+				if b, err := decodeBase64(v); err == nil {
+					v = string(b)
+				}
+			}
+			md[k] = append(md[k], v)
+		}
+	}
+	return md
+}
+
+// decodeBase64 decodes the standard base64 encoding, padded or not.
+func decodeBase64(s string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+}
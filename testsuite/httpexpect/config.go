@@ -1,6 +1,8 @@
 package httpexpect
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -8,14 +10,19 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"strings"
 
 	"github.com/xeipuuv/gojsonschema"
+	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/clientcredentials"
 
+	"github.com/blippar/aragorn/pkg/auth"
+	"github.com/blippar/aragorn/pkg/openapi"
 	"github.com/blippar/aragorn/pkg/util/json"
 	"github.com/blippar/aragorn/testsuite"
+	"github.com/blippar/aragorn/testsuite/sqlexpect"
 )
 
 var varsTmpl = regexp.MustCompile(`{{[0-9A-Za-z._-]+}}`)
@@ -31,15 +38,31 @@ type Base struct {
 	URL      string                    `json:"url,omitempty"`    // Base URL prepended to all requests' path.
 	Header   testsuite.Header          `json:"header,omitempty"` // Base set of headers added to all requests.
 	OAUTH2   *clientcredentials.Config `json:"oauth2,omitempty"`
+	Auth     *auth.Config              `json:"auth,omitempty"`
 	Insecure bool                      `json:"insecure,omitempty"`
+	TLS      *TLSConfig                `json:"tls,omitempty"`
+	Proxy    string                    `json:"proxy,omitempty"`   // URL of an HTTP, HTTPS or SOCKS5 proxy.
+	OpenAPI  string                    `json:"openapi,omitempty"` // Path to an OpenAPI specification every response is validated against.
+
+	FollowRedirects *RedirectLimit `json:"followRedirects,omitempty"` // Maximum number of redirects followed by the requests.
+
+	Databases map[string]*sqlexpect.Database `json:"databases,omitempty"` // Databases queried by the SQL steps by name.
 }
 
 type Test struct {
-	ID           string  `json:"id,omitempty"`
-	Name         string  `json:"name,omitempty"`    // Name used to identify this test.
-	Request      Request `json:"request,omitempty"` // Request describes the HTTP request.
-	Expect       Expect  `json:"expect,omitempty"`  // Expect describes the expected result of the HTTP request.
-	SaveDocument bool    `json:"saveDocument,omitempty"`
+	ID           string   `json:"id,omitempty"`
+	Name         string   `json:"name,omitempty"`    // Name used to identify this test.
+	Request      Request  `json:"request,omitempty"` // Request describes the HTTP request.
+	Expect       Expect   `json:"expect,omitempty"`  // Expect describes the expected result of the HTTP request.
+	SaveDocument bool     `json:"saveDocument,omitempty"`
+	SQL          *SQLStep `json:"sql,omitempty"` // If set, the test runs a query instead of a request.
+}
+
+// SQLStep describes a query checking the side effects of the previous
+// requests.
+type SQLStep struct {
+	Database string `json:"database,omitempty"` // Name of the database in the base (default: the only one).
+	sqlexpect.Query
 }
 
 type Request struct {
@@ -48,6 +71,14 @@ type Request struct {
 	Method string           `json:"method,omitempty"`
 	Header testsuite.Header `json:"header,omitempty"`
 
+	Cookies      map[string]string `json:"cookies,omitempty"`      // Cookies sent with the request only.
+	ClearCookies bool              `json:"clearCookies,omitempty"` // Remove the cookies set by the previous tests.
+
+	FollowRedirects *RedirectLimit `json:"followRedirects,omitempty"` // If set, will overwrite the base followRedirects.
+	Auth            *auth.Config   `json:"auth,omitempty"`            // If set, will overwrite the base auth.
+
+	Stream string `json:"stream,omitempty"` // Reads the response body as a stream of sse or ndjson events.
+
 	// Only one of the three following must be set.
 	Body      interface{}       `json:"body,omitempty"`
 	Multipart map[string]string `json:"multipart,omitempty"`
@@ -55,12 +86,16 @@ type Request struct {
 }
 
 type Expect struct {
-	StatusCode int              `json:"statusCode,omitempty"`
-	Header     testsuite.Header `json:"header,omitempty"`
+	StatusCode int                      `json:"statusCode,omitempty"`
+	Header     testsuite.Header         `json:"header,omitempty"`
+	Cookies    map[string]*ExpectCookie `json:"cookies,omitempty"`   // Attributes of the cookies set by the response.
+	Redirects  []ExpectRedirect         `json:"redirects,omitempty"` // Redirects followed before the response.
 
 	Document   interface{}            `json:"document,omitempty"`   // Exact document to match. Exclusive with JSONSchema.
 	JSONSchema map[string]interface{} `json:"jsonSchema,omitempty"` // Exact JSON schema to match. Exclusive with Document.
 	JSONValues map[string]interface{} `json:"jsonValues,omitempty"` // Required JSON values. Optional, if JSONSchema is set.
+
+	Events []ExpectEvent `json:"events,omitempty"` // First events of a streamed response.
 }
 
 func (*Config) Example() interface{} {
@@ -94,39 +129,103 @@ func (*Config) Example() interface{} {
 
 // genTest verifies that an HTTP test suite is valid. It also create the HTTP requests,
 // compiles JSON schemas and unmarshal JSON documents.
-func (cfg *Config) genTests(client *http.Client) ([]testsuite.Test, error) {
+func (cfg *Config) genTests(client *http.Client) ([]testsuite.Test, map[string]*sql.DB, error) {
 	if cfg.Base.URL == "" {
-		return nil, errors.New("base: URL is required")
+		return nil, nil, errors.New("base: URL is required")
 	}
 	if _, err := url.Parse(cfg.Base.URL); err != nil {
-		return nil, fmt.Errorf("base: URL is invalid: %v", err)
+		return nil, nil, fmt.Errorf("base: URL is invalid: %v", err)
 	}
 	if len(cfg.Tests) == 0 {
-		return nil, errors.New("a test suite must contain at least one test")
+		return nil, nil, errors.New("a test suite must contain at least one test")
+	}
+	if cfg.Base.OAUTH2 != nil && cfg.Base.Auth != nil {
+		return nil, nil, errors.New("base: only one of oauth2 or auth can be set at once")
+	}
+	var signer auth.Signer
+	if cfg.Base.OAUTH2 != nil {
+		// The token is set by the signer rather than by the transport of the
+		// client so that the auth of the tests can overwrite it.
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: client.Transport})
+		signer = auth.TokenSigner(cfg.Base.OAUTH2.TokenSource(ctx))
+	}
+	if cfg.Base.Auth != nil {
+		var err error
+		if signer, err = cfg.Base.Auth.NewSigner(cfg.Root, &http.Client{Transport: client.Transport}); err != nil {
+			return nil, nil, fmt.Errorf("base: auth: %v", err)
+		}
+	}
+	var spec *openapi.Spec
+	if cfg.Base.OpenAPI != "" {
+		var err error
+		if spec, err = openapi.Load(cfg.getFilePath(cfg.Base.OpenAPI)); err != nil {
+			return nil, nil, fmt.Errorf("base: could not load OpenAPI specification: %v", err)
+		}
+	}
+	dbs := make(map[string]*sql.DB, len(cfg.Base.Databases))
+	for name, d := range cfg.Base.Databases {
+		db, err := d.Open()
+		if err != nil {
+			closeDatabases(dbs)
+			return nil, nil, fmt.Errorf("base: database %q: %v", name, err)
+		}
+		dbs[name] = db
 	}
 	ts := make([]testsuite.Test, len(cfg.Tests))
 	var errs []string
 	for i, testcfg := range cfg.Tests {
-		t, err := testcfg.prepare(cfg, client)
+		var (
+			t   testsuite.Test
+			err error
+		)
+		if testcfg.SQL != nil {
+			t, err = testcfg.prepareSQL(dbs)
+		} else {
+			t, err = testcfg.prepare(cfg, client, signer, spec)
+		}
 		if err != nil {
 			errs = append(errs, fmt.Sprintf("test %q:\n%v", testcfg.Name, err))
 		}
 		ts[i] = t
 	}
 	if err := concatErrors(errs); err != nil {
-		return nil, err
+		closeDatabases(dbs)
+		return nil, nil, err
+	}
+	return ts, dbs, nil
+}
+
+// closeDatabases closes the connections to the databases and returns the first
+// error.
+func closeDatabases(dbs map[string]*sql.DB) error {
+	var err error
+	for _, db := range dbs {
+		if cerr := db.Close(); err == nil {
+			err = cerr
+		}
 	}
-	return ts, nil
+	return err
 }
 
-func (t *Test) prepare(cfg *Config, client *http.Client) (*test, error) {
+func (t *Test) prepare(cfg *Config, client *http.Client, signer auth.Signer, spec *openapi.Spec) (*test, error) {
 	test := &test{
 		id:         t.ID,
 		name:       t.Name,
 		client:     client,
 		statusCode: t.Expect.StatusCode,
 		header:     testsuite.MergeHeaders(t.Expect.Header),
+		cookies:    t.Expect.Cookies,
+		redirects:  t.Expect.Redirects,
 		saveDoc:    t.SaveDocument,
+
+		clearCookies: t.Request.ClearCookies,
+		maxRedirects: -1,
+		signer:       signer,
+	}
+	if limit := t.Request.FollowRedirects; limit != nil {
+		test.maxRedirects = int(*limit)
+	} else if limit := cfg.Base.FollowRedirects; limit != nil {
+		test.maxRedirects = int(*limit)
 	}
 	var errs []string
 
@@ -144,13 +243,30 @@ func (t *Test) prepare(cfg *Config, client *http.Client) (*test, error) {
 		errs = append(errs, "- request: at most one of body, multipart or formData can be set at once")
 	}
 
+	if t.Request.Auth != nil {
+		if s, err := t.Request.Auth.NewSigner(cfg.Root, &http.Client{Transport: client.Transport}); err != nil {
+			errs = append(errs, fmt.Sprintf("- request: auth: %v", err))
+		} else {
+			test.signer = s
+		}
+	}
+
 	if httpReq, err := t.Request.toHTTPRequest(cfg); err != nil {
 		errs = append(errs, fmt.Sprintf("- request: could not create HTTP request: %v", err))
 	} else {
 		test.req = httpReq
+		if httpReq.GetBody != nil {
+			body, _ := httpReq.GetBody()
+			test.body, _ = ioutil.ReadAll(body)
+		}
 		test.description = httpReq.Method + " " + httpReq.URL.String()
 		test.urlPathQueries = varsTmpl.FindAllString(httpReq.URL.RawPath, -1)
 		test.urlQueryQueries = varsTmpl.FindAllString(httpReq.URL.RawQuery, -1)
+		if spec != nil {
+			if err := test.prepareOpenAPI(spec); err != nil {
+				errs = append(errs, fmt.Sprintf("- openapi: %v", err))
+			}
+		}
 	}
 
 	if test.statusCode == 0 {
@@ -197,6 +313,12 @@ func (t *Test) prepare(cfg *Config, client *http.Client) (*test, error) {
 		expectJSONBody = true
 	}
 
+	if t.Request.Stream != "" || t.Expect.Events != nil {
+		if err := test.prepareStream(cfg, t); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
 	if err := concatErrors(errs); err != nil {
 		return nil, err
 	}
@@ -204,9 +326,106 @@ func (t *Test) prepare(cfg *Config, client *http.Client) (*test, error) {
 	if expectJSONBody && test.req.Header.Get("Accept") == "" {
 		test.req.Header.Set("Accept", "application/json")
 	}
+	if test.stream != "" && test.req.Header.Get("Accept") == "" {
+		test.req.Header.Set("Accept", streamAccept[test.stream])
+	}
 	return test, nil
 }
 
+// prepareSQL returns the test running the query of the SQL step.
+func (t *Test) prepareSQL(dbs map[string]*sql.DB) (testsuite.Test, error) {
+	var errs []string
+	if !reflect.DeepEqual(t.Request, Request{}) || !reflect.DeepEqual(t.Expect, Expect{}) || t.SaveDocument {
+		errs = append(errs, "- sql: request, expect and saveDocument can't be set with sql")
+	}
+	name := t.SQL.Database
+	if name == "" && len(dbs) == 1 {
+		for name = range dbs {
+		}
+	}
+	db, ok := dbs[name]
+	switch {
+	case name == "":
+		errs = append(errs, "- sql: database is required")
+	case !ok:
+		errs = append(errs, fmt.Sprintf("- sql: unknown database %q", name))
+	}
+	test, err := sqlexpect.NewTest(t.Name, db, &t.SQL.Query)
+	if err != nil {
+		for _, line := range strings.Split(err.Error(), "\n") {
+			errs = append(errs, "- sql: "+strings.TrimPrefix(line, "- "))
+		}
+	}
+	if err := concatErrors(errs); err != nil {
+		return nil, err
+	}
+	return test, nil
+}
+
+// prepareStream checks the stream mode of the request and loads the expected
+// events.
+func (t *test) prepareStream(cfg *Config, tc *Test) error {
+	var errs []string
+	switch tc.Request.Stream {
+	case streamSSE, streamNDJSON:
+		t.stream = tc.Request.Stream
+	case "":
+		errs = append(errs, "- expect: events require a stream request")
+	default:
+		errs = append(errs, fmt.Sprintf("- request: unknown stream %q", tc.Request.Stream))
+	}
+	if tc.Expect.Document != nil || tc.Expect.JSONSchema != nil || tc.Expect.JSONValues != nil || tc.SaveDocument {
+		errs = append(errs, "- expect: document, jsonSchema, jsonValues and saveDocument can't be set with a stream")
+	}
+	if cfg.Base.OpenAPI != "" {
+		errs = append(errs, "- expect: a stream can't be validated against an OpenAPI specification")
+	}
+	for i, e := range tc.Expect.Events {
+		ee := &expectEvent{typ: e.Type, id: e.ID, jsonValues: e.JSONValues}
+		if t.stream == streamNDJSON && (e.Type != "" || e.ID != "") {
+			errs = append(errs, fmt.Sprintf("- expect: event %d: type and id can only be set for sse streams", i))
+		}
+		if e.Document != nil && e.JSONValues != nil {
+			errs = append(errs, fmt.Sprintf("- expect: event %d: jsonValues can't be set with document", i))
+		}
+		if e.Document != nil {
+			doc, err := cfg.getDocumentField(e.Document)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("- expect: event %d: could get Document: %v", i, err))
+			}
+			ee.document = doc
+		}
+		t.events = append(t.events, ee)
+	}
+	return concatErrors(errs)
+}
+
+// prepareOpenAPI finds the operation of the specification matching the test
+// request and compiles the JSON schemas of its documented responses.
+func (t *test) prepareOpenAPI(spec *openapi.Spec) error {
+	op := spec.FindOperation(t.req.Method, t.req.URL.Path)
+	if op == nil {
+		return fmt.Errorf("no operation matches %s %s", t.req.Method, t.req.URL.Path)
+	}
+	t.openAPIOp = op
+	t.openAPISchemas = make(map[string]*gojsonschema.Schema)
+	for k, resp := range op.Responses {
+		if resp.Schema == nil {
+			continue
+		}
+		schema, err := spec.Schema(resp.Schema)
+		if err != nil {
+			return fmt.Errorf("%s response %s: %v", op.Name(), k, err)
+		}
+		jsonSchema, err := gojsonschema.NewSchema(newJSONGoLoader(schema))
+		if err != nil {
+			return fmt.Errorf("%s response %s: could not load JSON schema: %v", op.Name(), k, err)
+		}
+		t.openAPISchemas[k] = jsonSchema
+	}
+	return nil
+}
+
 func (cfg *Config) getDocumentField(v interface{}) (interface{}, error) {
 	m, ok := v.(map[string]interface{})
 	if !ok {
@@ -254,7 +473,7 @@ func (cfg *Config) fixRefInDoc(m map[string]interface{}) {
 	for k, v := range m {
 		if k == "$ref" {
 			if ref, ok := v.(string); ok {
-				if !strings.Contains(ref, "://") {
+				if !strings.Contains(ref, "://") && !strings.HasPrefix(ref, "#") {
 					relRef := cfg.getFilePath(ref)
 					if absRef, err := filepath.Abs(relRef); err == nil {
 						m[k] = "file://" + absRef
@@ -279,10 +498,14 @@ func (cfg *Config) fixRefInInterface(i interface{}) {
 }
 
 func (cfg *Config) getFilePath(path string) string {
+	return filePath(cfg.Root, path)
+}
+
+func filePath(root, path string) string {
 	if filepath.IsAbs(path) {
 		return path
 	}
-	return filepath.Join(cfg.Root, path)
+	return filepath.Join(root, path)
 }
 
 func concatErrors(errs []string) error {
@@ -0,0 +1,149 @@
+package httpexpect
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blippar/aragorn/pkg/util/json"
+)
+
+// ExpectCookie describes the expected attributes of a cookie set by a response.
+// Empty fields are not checked.
+type ExpectCookie struct {
+	Value    string        `json:"value,omitempty"`
+	Path     string        `json:"path,omitempty"`
+	Domain   string        `json:"domain,omitempty"`
+	Secure   *bool         `json:"secure,omitempty"`
+	HTTPOnly *bool         `json:"httpOnly,omitempty"`
+	SameSite string        `json:"sameSite,omitempty"` // Strict, Lax or None.
+	MinAge   json.Duration `json:"minAge,omitempty"`   // Minimum lifetime of the cookie.
+	MaxAge   json.Duration `json:"maxAge,omitempty"`   // Maximum lifetime of the cookie.
+	Expired  bool          `json:"expired,omitempty"`  // The cookie is deleted.
+}
+
+// sessionJar is a cookie jar that can be emptied, so the cookies are only
+// kept during a run of a suite.
+type sessionJar struct {
+	mu  sync.Mutex
+	jar *cookiejar.Jar
+}
+
+func newSessionJar() *sessionJar {
+	j := &sessionJar{}
+	j.Reset()
+	return j
+}
+
+// Reset removes all the cookies of the jar.
+func (j *sessionJar) Reset() {
+	jar, _ := cookiejar.New(nil)
+	j.mu.Lock()
+	j.jar = jar
+	j.mu.Unlock()
+}
+
+func (j *sessionJar) current() *cookiejar.Jar {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.jar
+}
+
+func (j *sessionJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.current().SetCookies(u, cookies)
+}
+
+func (j *sessionJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.current().Cookies(u)
+}
+
+// checkCookies checks the attributes of the cookies set by the response.
+func (r *response) checkCookies() {
+	cookies := make(map[string]*http.Cookie)
+	for _, c := range r.resp.Cookies() {
+		cookies[c.Name] = c
+	}
+	now := time.Now()
+	if date, err := http.ParseTime(r.resp.Header.Get("Date")); err == nil {
+		now = date
+	}
+	for name, want := range r.test.cookies {
+		c, ok := cookies[name]
+		if !ok {
+			r.logger.Errorf("missing cookie %s", name)
+			continue
+		}
+		r.checkCookie(c, want, now)
+	}
+}
+
+func (r *response) checkCookie(c *http.Cookie, want *ExpectCookie, now time.Time) {
+	checkString := func(attr, got, want string) {
+		if want != "" && got != want {
+			r.logger.Errorf("wrong %s for cookie %s (got %q; want %q)", attr, c.Name, got, want)
+		}
+	}
+	checkString("value", c.Value, want.Value)
+	checkString("path", c.Path, want.Path)
+	checkString("domain", strings.TrimPrefix(c.Domain, "."), strings.TrimPrefix(want.Domain, "."))
+	if got := sameSiteName(c.SameSite); want.SameSite != "" && !strings.EqualFold(got, want.SameSite) {
+		r.logger.Errorf("wrong sameSite attribute for cookie %s (got %q; want %q)", c.Name, got, want.SameSite)
+	}
+	if want.Secure != nil && c.Secure != *want.Secure {
+		r.logger.Errorf("wrong secure attribute for cookie %s (got %t; want %t)", c.Name, c.Secure, *want.Secure)
+	}
+	if want.HTTPOnly != nil && c.HttpOnly != *want.HTTPOnly {
+		r.logger.Errorf("wrong httpOnly attribute for cookie %s (got %t; want %t)", c.Name, c.HttpOnly, *want.HTTPOnly)
+	}
+
+	var (
+		age     time.Duration
+		session bool
+	)
+	switch {
+	case c.MaxAge > 0:
+		age = time.Duration(c.MaxAge) * time.Second
+	case c.MaxAge < 0:
+	case !c.Expires.IsZero():
+		age = c.Expires.Sub(now)
+	default:
+		session = true
+	}
+	expired := !session && age <= 0
+	if expired != want.Expired {
+		if expired {
+			r.logger.Errorf("cookie %s is expired", c.Name)
+		} else {
+			r.logger.Errorf("cookie %s is not expired", c.Name)
+		}
+		return
+	}
+	if want.MinAge == 0 && want.MaxAge == 0 {
+		return
+	}
+	if session {
+		r.logger.Errorf("cookie %s has no expiry", c.Name)
+		return
+	}
+	if min := time.Duration(want.MinAge); age < min {
+		r.logger.Errorf("cookie %s expires too soon (in %s; want at least %s)", c.Name, age, min)
+	}
+	if max := time.Duration(want.MaxAge); max > 0 && age > max {
+		r.logger.Errorf("cookie %s expires too late (in %s; want at most %s)", c.Name, age, max)
+	}
+}
+
+func sameSiteName(s http.SameSite) string {
+	switch s {
+	case http.SameSiteLaxMode:
+		return "Lax"
+	case http.SameSiteStrictMode:
+		return "Strict"
+	case http.SameSiteNoneMode:
+		return "None"
+	}
+	return ""
+}
@@ -2,8 +2,9 @@ package httpexpect
 
 import (
 	"context"
-	"crypto/tls"
+	"database/sql"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -12,75 +13,104 @@ import (
 	"github.com/opentracing-contrib/go-stdlib/nethttp"
 	ot "github.com/opentracing/opentracing-go"
 	"github.com/xeipuuv/gojsonschema"
-	"golang.org/x/oauth2"
 
+	"github.com/blippar/aragorn/pkg/auth"
+	"github.com/blippar/aragorn/pkg/openapi"
+	"github.com/blippar/aragorn/pkg/util/json"
 	"github.com/blippar/aragorn/plugin"
 	"github.com/blippar/aragorn/testsuite"
 )
 
-var _ testsuite.Suite = (*Suite)(nil)
+var (
+	_ testsuite.Suite    = (*Suite)(nil)
+	_ testsuite.Resetter = (*Suite)(nil)
+	_ testsuite.Closer   = (*Suite)(nil)
+)
 
 // Suite describes an HTTP test suite.
 type Suite struct {
 	tests []testsuite.Test
+	jar   *sessionJar
+	dbs   map[string]*sql.DB // Databases queried by the SQL steps.
 }
 
 // New returns a Suite.
 func New(cfg *Config) (*Suite, error) {
 	client := &http.Client{}
-	if cfg.Base.Insecure {
-		client.Transport = &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
-		}
+	t, err := cfg.transport()
+	if err != nil {
+		return nil, err
 	}
-	client.Transport = &nethttp.Transport{RoundTripper: client.Transport}
-	if cfg.Base.OAUTH2 != nil {
-		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, client)
-		client = cfg.Base.OAUTH2.Client(ctx)
+	if t != nil {
+		client.Transport = t
 	}
-	tests, err := cfg.genTests(client)
+	client.Transport = &nethttp.Transport{RoundTripper: client.Transport}
+	jar := newSessionJar()
+	client.Jar = jar
+	client.CheckRedirect = checkRedirect
+	tests, dbs, err := cfg.genTests(client)
 	if err != nil {
 		return nil, err
 	}
-	return &Suite{tests: tests}, nil
+	return &Suite{tests: tests, jar: jar, dbs: dbs}, nil
 }
 
 func (s *Suite) Tests() []testsuite.Test { return s.tests }
 
+// Close closes the connections to the databases of the SQL steps.
+func (s *Suite) Close() error { return closeDatabases(s.dbs) }
+
+// Reset removes the cookies set during the previous run.
+func (s *Suite) Reset() { s.jar.Reset() }
+
 type test struct {
-	id          string
-	name        string
-	description string
-	saveDoc     bool
+	id           string
+	name         string
+	description  string
+	saveDoc      bool
+	clearCookies bool
+	maxRedirects int // Negative to follow the redirects as the http.Client does.
 
 	client *http.Client
 	req    *http.Request // Raw HTTP request generated from the request description.
+	body   []byte        // Body of req.
+	signer auth.Signer   // Authenticates req, if set.
 
 	urlPathQueries  []string
 	urlQueryQueries []string
 
 	statusCode int
 	header     testsuite.Header
+	cookies    map[string]*ExpectCookie
+	redirects  []ExpectRedirect
 
 	document   interface{}
 	jsonSchema *gojsonschema.Schema   // Compiled jsonschema.
 	jsonValues map[string]interface{} // Decoded JSONValues.
+
+	openAPIOp      *openapi.Operation              // Operation of the OpenAPI specification matching the request.
+	openAPISchemas map[string]*gojsonschema.Schema // Compiled response schemas of openAPIOp by response key.
+
+	stream string         // sse or ndjson if the response body is read as a stream.
+	events []*expectEvent // Expected first events of the stream.
 }
 
 func (t *test) Name() string        { return t.name }
 func (t *test) Description() string { return t.description }
 
 func (t *test) Run(ctx context.Context, l testsuite.Logger) {
-	req := t.cloneRequest().WithContext(ctx)
+	rc := &redirectChain{max: t.maxRedirects}
+	req := t.cloneRequest().WithContext(withRedirectChain(ctx, rc))
+	if jar, ok := t.client.Jar.(*sessionJar); ok && t.clearCookies {
+		jar.Reset()
+	}
 
 	md, ok := testsuite.MDFromContext(ctx)
 	if ok {
 		if len(t.urlPathQueries) > 0 {
 			oldnew := make([]string, 0, len(t.urlPathQueries)*2)
 			for _, q := range t.urlPathQueries {
-				v, err := queryJSONData(q[2:len(q)-2], map[string]interface{}(md))
+				v, err := json.Query(q[2:len(q)-2], map[string]interface{}(md))
 				if err != nil {
 					continue
 				}
@@ -92,7 +122,7 @@ func (t *test) Run(ctx context.Context, l testsuite.Logger) {
 		if len(t.urlQueryQueries) > 0 {
 			oldnew := make([]string, 0, len(t.urlQueryQueries)*2)
 			for _, q := range t.urlQueryQueries {
-				v, err := queryJSONData(q[2:len(q)-2], map[string]interface{}(md))
+				v, err := json.Query(q[2:len(q)-2], map[string]interface{}(md))
 				if err != nil {
 					continue
 				}
@@ -101,6 +131,12 @@ func (t *test) Run(ctx context.Context, l testsuite.Logger) {
 			req.URL.RawQuery = strings.NewReplacer(oldnew...).Replace(req.URL.RawQuery)
 		}
 	}
+	if t.signer != nil {
+		if err := t.signer.Sign(req, t.body); err != nil {
+			l.Errorf("could not authenticate request: %v", err)
+			return
+		}
+	}
 	opName := "HTTP: " + t.Name()
 	req, ht := nethttp.TraceRequest(ot.GlobalTracer(), req, nethttp.OperationName(opName))
 	defer ht.Finish()
@@ -111,12 +147,28 @@ func (t *test) Run(ctx context.Context, l testsuite.Logger) {
 		return
 	}
 	defer resp.Body.Close()
+	if t.stream != "" {
+		t.checkStream(l, resp, rc)
+		return
+	}
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		l.Errorf("could not read body: %v", err)
 		return
 	}
-	checkResponse(t, l, md, resp, body)
+	checkResponse(t, l, md, resp, body, rc)
+}
+
+// checkStream checks a streamed response. The body is read until the
+// expected events are received, unless the status code is unexpected.
+func (t *test) checkStream(l testsuite.Logger, resp *http.Response, rc *redirectChain) {
+	if resp.StatusCode != t.statusCode && t.statusCode >= 0 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		checkResponse(t, l, nil, resp, body, rc)
+		return
+	}
+	checkResponse(t, l, nil, resp, nil, rc)
+	checkEvents(t, l, resp.Body)
 }
 
 // cloneRequest returns a clone of the provided *http.Request.
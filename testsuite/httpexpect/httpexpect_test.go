@@ -3,17 +3,29 @@ package httpexpect
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	_ "github.com/mattn/go-sqlite3"
+	"golang.org/x/oauth2/clientcredentials"
 
+	"github.com/blippar/aragorn/pkg/auth"
+	ajson "github.com/blippar/aragorn/pkg/util/json"
 	"github.com/blippar/aragorn/testsuite"
+	"github.com/blippar/aragorn/testsuite/sqlexpect"
 )
 
 var cfgTest = &Config{
@@ -349,86 +361,715 @@ func TestSuiteRunTestJSON(t *testing.T) {
 	}
 }
 
-func TestQueryJSONData(t *testing.T) {
-	m := map[string]interface{}{
-		"hello": "world",
-		"arr":   []interface{}{42, 0, 1},
-		"sub": map[string]interface{}{
-			"a": "b",
-			"c": "d",
+func TestSuiteRunTestOpenAPI(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/todos/1":
+			fmt.Fprint(w, `{"id": 1, "title": "Buy milk"}`)
+		case "/v1/todos/2":
+			fmt.Fprint(w, `{"id": 2}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	cfg := &Config{
+		Root: "./testdata/",
+		Base: Base{
+			URL:     ts.URL + "/v1",
+			OpenAPI: "todo.openapi.yaml",
+		},
+		Tests: []*Test{
+			{Name: "valid", Request: Request{Path: "/todos/1"}},
+			{Name: "invalid", Request: Request{Path: "/todos/2"}},
+			{Name: "undocumented status", Request: Request{Path: "/todos/3"}, Expect: Expect{StatusCode: http.StatusNotFound}},
+		},
+	}
+	suite, err := New(cfg)
+	if err != nil {
+		t.Fatalf("can't create suite: %v", err)
+	}
+	testsErrs := [][]string{
+		nil,
+		{"OpenAPI schema validation failed:\n\t- title: title is required"},
+		{"OpenAPI operation GET /todos/{id} does not document status code 404"},
+	}
+	for i, test := range suite.Tests() {
+		tr := &mockLogger{}
+		test.Run(context.Background(), tr)
+		if !cmp.Equal(tr.errs, testsErrs[i]) {
+			t.Errorf("%s: unexpected errors (got %q; want %q)", test.Name(), tr.errs, testsErrs[i])
+		}
+	}
+}
+
+func TestNewWithUndocumentedOperation(t *testing.T) {
+	cfg := &Config{
+		Root: "./testdata/",
+		Base: Base{
+			URL:     "http://localhost/v1",
+			OpenAPI: "todo.openapi.yaml",
+		},
+		Tests: []*Test{
+			{Name: "delete", Request: Request{Method: "DELETE", Path: "/todos/1"}},
+		},
+	}
+	want := "test \"delete\":\n- openapi: no operation matches DELETE /v1/todos/1"
+	if _, err := New(cfg); err == nil || err.Error() != want {
+		t.Fatalf("invalid error (got %v; want %v)", err, want)
+	}
+}
+
+func TestSuiteMock(t *testing.T) {
+	cfg := &Config{
+		Base: Base{URL: "http://localhost:3000/api"},
+		Tests: []*Test{
+			{
+				Name:    "create",
+				Request: Request{Method: "POST", Path: "/todos", Body: map[string]interface{}{"title": "write tests"}},
+				Expect: Expect{
+					StatusCode: http.StatusCreated,
+					Header:     testsuite.Header{"Location": "/api/todos/1"},
+					Document:   map[string]interface{}{"id": json.Number("1"), "title": "write tests"},
+				},
+			},
+			{
+				Name:    "get",
+				Request: Request{Path: "/todos/{{create.id}}"},
+				Expect:  Expect{Document: map[string]interface{}{"$raw": "write tests"}},
+			},
+			{
+				Name:    "get deleted",
+				Request: Request{Path: "/todos/{{create.id}}"},
+				Expect:  Expect{StatusCode: http.StatusNotFound},
+			},
+		},
+	}
+	suite, err := New(cfg)
+	if err != nil {
+		t.Fatalf("can't create suite: %v", err)
+	}
+	srv, err := suite.Mock()
+	if err != nil {
+		t.Fatalf("can't create mock: %v", err)
+	}
+	ts := httptest.NewServer(srv.(*http.Server).Handler)
+	defer ts.Close()
+
+	cfg.Base.URL = ts.URL + "/api"
+	suite, err = New(cfg)
+	if err != nil {
+		t.Fatalf("can't create suite: %v", err)
+	}
+	ctx := testsuite.NewMDContext(context.Background(), testsuite.NewMD())
+	for _, test := range suite.Tests() {
+		l := &mockLogger{}
+		test.Run(ctx, l)
+		if len(l.errs) > 0 {
+			t.Errorf("test %s: unexpected errors: %v", test.Name(), l.errs)
+		}
+	}
+	resp, err := http.Get(ts.URL + "/api/users")
+	if err != nil {
+		t.Fatalf("could not do HTTP request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("wrong status code for unknown route (got %d; want %d)", resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestSuiteRunTestCookies(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cr3t", Path: "/", MaxAge: 3600, Secure: true, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+		case "/logout":
+			http.SetCookie(w, &http.Cookie{Name: "session", Path: "/", MaxAge: -1})
+		default:
+			c, err := r.Cookie("session")
+			if err != nil || c.Value != "s3cr3t" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+			if c, err := r.Cookie("lang"); err == nil {
+				fmt.Fprint(w, c.Value)
+			}
+		}
+	}))
+	defer ts.Close()
+	secure, httpOnly := true, false
+	cfg := &Config{
+		Base: Base{URL: ts.URL},
+		Tests: []*Test{
+			{
+				Name:    "login",
+				Request: Request{Path: "/login"},
+				Expect: Expect{Cookies: map[string]*ExpectCookie{
+					"session": {Value: "s3cr3t", Path: "/", Secure: &secure, SameSite: "lax", MinAge: ajson.Duration(time.Hour)},
+				}},
+			},
+			{
+				Name:    "login with wrong cookie expectations",
+				Request: Request{Path: "/login"},
+				Expect: Expect{Cookies: map[string]*ExpectCookie{
+					"session": {HTTPOnly: &httpOnly, SameSite: "Strict", MaxAge: ajson.Duration(time.Minute)},
+					"missing": {},
+				}},
+			},
+			{
+				Name:    "session",
+				Request: Request{Path: "/me", Cookies: map[string]string{"lang": "en"}},
+				Expect:  Expect{Document: map[string]interface{}{"$raw": "en"}},
+			},
+			{
+				Name:    "logout",
+				Request: Request{Path: "/logout"},
+				Expect:  Expect{Cookies: map[string]*ExpectCookie{"session": {Expired: true}}},
+			},
+			{
+				Name:    "logged out",
+				Request: Request{Path: "/me"},
+				Expect:  Expect{StatusCode: http.StatusUnauthorized},
+			},
+			{
+				Name:    "cleared",
+				Request: Request{Path: "/me", ClearCookies: true},
+				Expect:  Expect{StatusCode: http.StatusUnauthorized},
+			},
 		},
 	}
-	arr := []interface{}{1, 3, 6, "test", "123", map[string]interface{}{"abc": "def"}}
+	suite, err := New(cfg)
+	if err != nil {
+		t.Fatalf("can't create suite: %v", err)
+	}
+	testsErrs := [][]string{
+		nil,
+		{
+			"missing cookie missing",
+			`wrong sameSite attribute for cookie session (got "Lax"; want "Strict")`,
+			"wrong httpOnly attribute for cookie session (got true; want false)",
+			"cookie session expires too late (in 1h0m0s; want at most 1m0s)",
+		},
+		nil,
+		nil,
+		nil,
+		nil,
+	}
+	ctx := context.Background()
+	for i, test := range suite.Tests() {
+		l := &mockLogger{}
+		test.Run(ctx, l)
+		sort.Strings(l.errs)
+		sort.Strings(testsErrs[i])
+		if !cmp.Equal(l.errs, testsErrs[i]) {
+			t.Errorf("test %s: unexpected errors (got %q; want %q)", test.Name(), l.errs, testsErrs[i])
+		}
+	}
+
+	// The session of the previous run must not be kept.
+	suite.Tests()[0].Run(ctx, &mockLogger{})
+	suite.Reset()
+	l := &mockLogger{}
+	suite.Tests()[4].Run(ctx, l)
+	if len(l.errs) > 0 {
+		t.Errorf("unexpected errors after reset: %v", l.errs)
+	}
+}
+
+func TestRedirectLimitUnmarshalJSON(t *testing.T) {
 	tt := []struct {
-		name   string
-		val    interface{}
-		query  string
-		want   interface{}
-		errStr string
+		in   string
+		want RedirectLimit
+		err  bool
 	}{
-		{"obj simple string", m, "hello", "world", ""},
-		{"obj field not found", m, "invalid_key", nil, "invalid_key: object does not contain field"},
-		{"obj sub obj field a", m, "sub.a", "b", ""},
-		{"obj sub obj field not found", m, "sub.d", "", "sub.d: object does not contain field"},
-		{"obj sub arr", m, "arr.0", 42, ""},
-		{"obj sub arr length check", m, "arr.length", json.Number("3"), ""},
-		{"obj sub arr out of bounds", m, "arr.125", 42, "arr.125: array index out of bounds"},
-		{"arr simple int", arr, "0", 1, ""},
-		{"arr simple string", arr, "3", "test", ""},
-		{"arr simple length check", arr, "length", json.Number("6"), ""},
-		{"arr invalid index", arr, "invalid_index", nil, "invalid_index: invalid array index"},
-		{"arr out of bounds", arr, "1234", nil, "1234: array index out of bounds"},
-		{"arr sub obj field a", arr, "5.abc", "def", ""},
-		{"arr sub obj field not found", arr, "5.d", "", "5.d: object does not contain field"},
-		{"invalid type", nil, "key", nil, "key: invalid type"},
+		{in: "true", want: 10},
+		{in: "false", want: 0},
+		{in: "3", want: 3},
+		{in: "-1", err: true},
+		{in: `"yes"`, err: true},
 	}
 	for _, tc := range tt {
-		t.Run(tc.name, func(t *testing.T) {
-			got, err := queryJSONData(tc.query, tc.val)
-			if err != nil {
-				if errStr := err.Error(); errStr != tc.errStr {
-					t.Fatalf("invalid error (got %v; want %v)", errStr, tc.errStr)
-				}
-				return
-			}
-			if got != tc.want {
-				t.Fatalf("invalid lookup value (got %v; want %v)", got, tc.want)
-			}
-		})
+		var l RedirectLimit
+		err := ajson.Unmarshal([]byte(tc.in), &l)
+		if (err != nil) != tc.err {
+			t.Errorf("%s: unexpected error: %v", tc.in, err)
+			continue
+		}
+		if l != tc.want {
+			t.Errorf("%s: wrong limit (got %d; want %d)", tc.in, l, tc.want)
+		}
 	}
 }
 
-func TestLookupJSONData(t *testing.T) {
-	m := map[string]interface{}{"hello": "world"}
-	arr := []interface{}{1, 3, 6, "test", "123"}
+func TestSuiteRunTestRedirects(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+		case "/new":
+			http.Redirect(w, r, "/final?x=1", http.StatusFound)
+		default:
+			fmt.Fprint(w, "ok")
+		}
+	}))
+	defer ts.Close()
+	noFollow, followOne := RedirectLimit(0), RedirectLimit(1)
+	cfg := &Config{
+		Base: Base{URL: ts.URL},
+		Tests: []*Test{
+			{
+				Name:    "no follow",
+				Request: Request{Path: "/old", FollowRedirects: &noFollow},
+				Expect: Expect{
+					StatusCode: http.StatusMovedPermanently,
+					Header:     testsuite.Header{"Location": "/new"},
+					Redirects:  []ExpectRedirect{},
+				},
+			},
+			{
+				Name:    "follow",
+				Request: Request{Path: "/old"},
+				Expect: Expect{
+					Redirects: []ExpectRedirect{
+						{StatusCode: http.StatusMovedPermanently, Location: "/new"},
+						{StatusCode: http.StatusFound, Location: ts.URL + "/final?x=1"},
+					},
+					Document: map[string]interface{}{"$raw": "ok"},
+				},
+			},
+			{
+				Name:    "follow one",
+				Request: Request{Path: "/old", FollowRedirects: &followOne},
+				Expect: Expect{
+					StatusCode: http.StatusFound,
+					Redirects:  []ExpectRedirect{{Location: "/new"}},
+				},
+			},
+			{
+				Name:    "wrong redirects",
+				Request: Request{Path: "/old"},
+				Expect: Expect{
+					Redirects: []ExpectRedirect{
+						{StatusCode: http.StatusFound, Location: "/new"},
+						{Location: "/final"},
+					},
+				},
+			},
+			{
+				Name:    "wrong number of redirects",
+				Request: Request{Path: "/new"},
+				Expect:  Expect{Redirects: []ExpectRedirect{}},
+			},
+		},
+	}
+	suite, err := New(cfg)
+	if err != nil {
+		t.Fatalf("can't create suite: %v", err)
+	}
+	testsErrs := [][]string{
+		nil,
+		nil,
+		nil,
+		{
+			"wrong status code for redirect 1 (got 301; want 302)",
+			`wrong location for redirect 2 (got "/final?x=1"; want "/final")`,
+		},
+		{"wrong number of redirects (got 1; want 0)"},
+	}
+	ctx := context.Background()
+	for i, test := range suite.Tests() {
+		l := &mockLogger{}
+		test.Run(ctx, l)
+		if !cmp.Equal(l.errs, testsErrs[i]) {
+			t.Errorf("test %s: unexpected errors (got %q; want %q)", test.Name(), l.errs, testsErrs[i])
+		}
+	}
+}
+
+func TestSuiteRunTestTLS(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	cert, err := tls.LoadX509KeyPair("./testdata/tls/server.pem", "./testdata/tls/server.key")
+	if err != nil {
+		t.Fatalf("could not load server certificate: %v", err)
+	}
+	ca, err := ioutil.ReadFile("./testdata/tls/ca.pem")
+	if err != nil {
+		t.Fatalf("could not read CA: %v", err)
+	}
+	cp := x509.NewCertPool()
+	cp.AppendCertsFromPEM(ca)
+	ts.TLS = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    cp,
+	}
+	ts.StartTLS()
+	defer ts.Close()
+	cfg := &Config{
+		Root: "./testdata/",
+		Base: Base{
+			URL: ts.URL,
+			TLS: &TLSConfig{
+				CAPath:     "tls/ca.pem",
+				CertPath:   "tls/client.pem",
+				KeyPath:    "tls/client.key",
+				ServerName: "localhost",
+				MinVersion: "1.2",
+			},
+		},
+		Tests: []*Test{
+			{
+				Name:   "index",
+				Expect: Expect{Document: map[string]interface{}{"$raw": "aragorn"}},
+			},
+		},
+	}
+	suite, err := New(cfg)
+	if err != nil {
+		t.Fatalf("can't create suite: %v", err)
+	}
+	l := &mockLogger{}
+	suite.Tests()[0].Run(context.Background(), l)
+	if len(l.errs) > 0 {
+		t.Fatalf("unexpected test report errors: %v", l.errs)
+	}
+}
+
+func TestSuiteRunTestProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "proxied %s", r.URL)
+	}))
+	defer proxy.Close()
+	cfg := &Config{
+		Base: Base{
+			URL:   "http://api.internal",
+			Proxy: proxy.URL,
+		},
+		Tests: []*Test{
+			{
+				Name:    "index",
+				Request: Request{Path: "/users"},
+				Expect:  Expect{Document: map[string]interface{}{"$raw": "proxied http://api.internal/users"}},
+			},
+		},
+	}
+	suite, err := New(cfg)
+	if err != nil {
+		t.Fatalf("can't create suite: %v", err)
+	}
+	l := &mockLogger{}
+	suite.Tests()[0].Run(context.Background(), l)
+	if len(l.errs) > 0 {
+		t.Fatalf("unexpected test report errors: %v", l.errs)
+	}
+}
+
+func TestNewWithInvalidTransportConfig(t *testing.T) {
+	tt := []struct {
+		base Base
+		err  string
+	}{
+		{
+			base: Base{TLS: &TLSConfig{CertPath: "tls/client.pem"}},
+			err:  "base: tls: certPath and keyPath must be set together",
+		},
+		{
+			base: Base{TLS: &TLSConfig{MinVersion: "1.4"}},
+			err:  `base: tls: invalid minVersion "1.4"`,
+		},
+		{
+			base: Base{TLS: &TLSConfig{CAPath: "tls/client.key"}},
+			err:  "base: tls: could not append CA certificates",
+		},
+		{
+			base: Base{Proxy: "ftp://proxy"},
+			err:  `base: proxy: unsupported scheme "ftp"`,
+		},
+	}
+	for _, tc := range tt {
+		tc.base.URL = "https://localhost"
+		cfg := &Config{Root: "./testdata/", Base: tc.base}
+		if _, err := New(cfg); err == nil || err.Error() != tc.err {
+			t.Errorf("wrong error (got %v; want %s)", err, tc.err)
+		}
+	}
+}
+
+func TestSuiteRunTestAuth(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Header.Get("Authorization"))
+	}))
+	defer ts.Close()
+	cfg := &Config{
+		Base: Base{
+			URL:  ts.URL,
+			Auth: &auth.Config{Bearer: "s3cr3t"},
+		},
+		Tests: []*Test{
+			{
+				Name:   "base",
+				Expect: Expect{Document: map[string]interface{}{"$raw": "Bearer s3cr3t"}},
+			},
+			{
+				Name:    "override",
+				Request: Request{Auth: &auth.Config{Basic: &auth.BasicConfig{Username: "john", Password: "doe"}}},
+				Expect:  Expect{Document: map[string]interface{}{"$raw": "Basic am9objpkb2U="}},
+			},
+			{
+				Name:    "disabled",
+				Request: Request{Auth: &auth.Config{}},
+				Expect:  Expect{Document: map[string]interface{}{"$raw": ""}},
+			},
+		},
+	}
+	suite, err := New(cfg)
+	if err != nil {
+		t.Fatalf("can't create suite: %v", err)
+	}
+	for _, test := range suite.Tests() {
+		l := &mockLogger{}
+		test.Run(context.Background(), l)
+		if len(l.errs) > 0 {
+			t.Errorf("test %s: unexpected errors: %v", test.Name(), l.errs)
+		}
+	}
+}
+
+func TestSuiteRunTestAuthOAuth2(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"access_token":"t0k3n","token_type":"bearer","expires_in":3600}`)
+			return
+		}
+		fmt.Fprint(w, r.Header.Get("Authorization"))
+	}))
+	defer ts.Close()
+	cfg := &Config{
+		Base: Base{
+			URL:    ts.URL,
+			OAUTH2: &clientcredentials.Config{ClientID: "id", ClientSecret: "secret", TokenURL: ts.URL + "/token"},
+		},
+		Tests: []*Test{
+			{
+				Name:   "base",
+				Expect: Expect{Document: map[string]interface{}{"$raw": "Bearer t0k3n"}},
+			},
+			{
+				Name:    "override",
+				Request: Request{Auth: &auth.Config{Basic: &auth.BasicConfig{Username: "john", Password: "doe"}}},
+				Expect:  Expect{Document: map[string]interface{}{"$raw": "Basic am9objpkb2U="}},
+			},
+			{
+				Name:    "disabled",
+				Request: Request{Auth: &auth.Config{}},
+				Expect:  Expect{Document: map[string]interface{}{"$raw": ""}},
+			},
+		},
+	}
+	suite, err := New(cfg)
+	if err != nil {
+		t.Fatalf("can't create suite: %v", err)
+	}
+	for _, test := range suite.Tests() {
+		l := &mockLogger{}
+		test.Run(context.Background(), l)
+		if len(l.errs) > 0 {
+			t.Errorf("test %s: unexpected errors: %v", test.Name(), l.errs)
+		}
+	}
+}
+
+func TestSuiteRunTestStream(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sse":
+			if got, want := r.Header.Get("Accept"), "text/event-stream"; got != want {
+				t.Errorf("invalid request Accept (got %v; want %v)", got, want)
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, ": comment\n\nevent: hello\ndata: world\n\nid: 2\ndata: {\"n\":\ndata: 2}\n\nevent: ping\n\ndata: 3\n\n")
+		case "/ndjson":
+			fmt.Fprint(w, "{\"n\":1}\n\n{\"n\":2,\"items\":[1,2]}\n")
+		}
+		w.(http.Flusher).Flush()
+		// The stream never ends, the client must close it.
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
 	tt := []struct {
 		name string
-		val  interface{}
-		key  string
-		want interface{}
+		req  Request
+		want []ExpectEvent
+		errs []string
 	}{
-		{"obj simple string", m, "hello", "world"},
-		{"obj field not found", m, "invalid_key", errObjectFieldNotFound},
-		{"arr simple int", arr, "0", 1},
-		{"arr simple string", arr, "3", "test"},
-		{"arr simple length check", arr, "length", json.Number("5")},
-		{"arr invalid index", arr, "invalid_index", errInvalidArrayIndex},
-		{"arr out of bounds", arr, "1234", errIndexOutOfBounds},
-		{"invalid type", nil, "key", errInvalidType},
+		{
+			name: "sse",
+			req:  Request{Path: "/sse", Stream: "sse"},
+			want: []ExpectEvent{
+				{Type: "hello", Document: map[string]interface{}{"$raw": "world"}},
+				{Type: "message", ID: "2", Document: map[string]interface{}{"n": json.Number("2")}},
+				{Type: "message", ID: "2", Document: json.Number("3")},
+			},
+		},
+		{
+			name: "ndjson",
+			req:  Request{Path: "/ndjson", Stream: "ndjson"},
+			want: []ExpectEvent{
+				{Document: map[string]interface{}{"n": json.Number("1")}},
+				{JSONValues: map[string]interface{}{"n": json.Number("2"), "items.length": json.Number("2")}},
+			},
+		},
+		{
+			name: "sse mismatch",
+			req:  Request{Path: "/sse", Stream: "sse"},
+			want: []ExpectEvent{
+				{Type: "bye", Document: map[string]interface{}{"$raw": "world!"}},
+				{ID: "3", JSONValues: map[string]interface{}{"n": json.Number("3")}},
+			},
+			errs: []string{
+				`event 0: wrong type (got "hello"; want "bye")`,
+				"event 0: data does not match document",
+				`event 1: wrong id (got "2"; want "3")`,
+				`event 1: wrong value for query "n" (got 2; want 3)`,
+			},
+		},
 	}
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
-			got, err := lookupJSONData(tc.key, tc.val)
+			cfg := &Config{
+				Base:  Base{URL: ts.URL},
+				Tests: []*Test{{Name: tc.name, Request: tc.req, Expect: Expect{Events: tc.want}}},
+			}
+			suite, err := New(cfg)
 			if err != nil {
-				e, _ := tc.want.(error)
-				if err != e {
-					t.Fatalf("invalid error (got %v; want %v)", err, e)
-				}
-				return
+				t.Fatalf("can't create suite: %v", err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			l := &mockLogger{}
+			suite.Tests()[0].Run(ctx, l)
+			if ctx.Err() != nil {
+				t.Fatal("stream not closed once the events were received")
 			}
-			if got != tc.want {
-				t.Fatalf("invalid lookup value (got %v; want %v)", got, tc.want)
+			if !cmp.Equal(l.errs, tc.errs) {
+				t.Fatalf("wrong errors (got %q; want %q)", l.errs, tc.errs)
 			}
 		})
 	}
 }
+
+func TestSuiteStreamInvalidConfig(t *testing.T) {
+	cfg := &Config{
+		Base: Base{URL: "http://localhost"},
+		Tests: []*Test{
+			{
+				Name:    "test",
+				Request: Request{Stream: "ndjson"},
+				Expect: Expect{
+					JSONValues: map[string]interface{}{"a": "b"},
+					Events:     []ExpectEvent{{Type: "message"}},
+				},
+			},
+			{
+				Name:   "events",
+				Expect: Expect{Events: []ExpectEvent{{}}},
+			},
+		},
+	}
+	want := "test \"test\":\n" +
+		"- expect: document, jsonSchema, jsonValues and saveDocument can't be set with a stream\n" +
+		"- expect: event 0: type and id can only be set for sse streams\n" +
+		"test \"events\":\n" +
+		"- expect: events require a stream request"
+	if _, err := New(cfg); err == nil || err.Error() != want {
+		t.Fatalf("wrong error (got %v; want %v)", err, want)
+	}
+}
+
+func TestSuiteRunTestSQL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpexpect")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	dsn := filepath.Join(dir, "shop.db")
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE orders (id INTEGER PRIMARY KEY, status TEXT)"); err != nil {
+		t.Fatalf("could not create orders: %v", err)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res, err := db.Exec("INSERT INTO orders (status) VALUES ('pending')")
+		if err != nil {
+			t.Errorf("could not insert order: %v", err)
+		}
+		id, _ := res.LastInsertId()
+		fmt.Fprintf(w, `{"id":%d}`, id)
+	}))
+	defer ts.Close()
+	one := 1
+	cfg := &Config{
+		Base: Base{
+			URL:       ts.URL,
+			Databases: map[string]*sqlexpect.Database{"shop": {Driver: "sqlite3", DSN: dsn}},
+		},
+		Tests: []*Test{
+			{ID: "order", Name: "create", Request: Request{Method: "POST", Path: "/orders"}, SaveDocument: true},
+			{
+				Name: "pending",
+				SQL: &SQLStep{Query: sqlexpect.Query{
+					Query:  "SELECT status FROM orders WHERE id = ?",
+					Args:   []interface{}{"{{order.id}}"},
+					Expect: sqlexpect.Expect{RowCount: &one, Rows: []map[string]interface{}{{"status": "shipped"}}},
+				}},
+			},
+		},
+	}
+	suite, err := New(cfg)
+	if err != nil {
+		t.Fatalf("can't create suite: %v", err)
+	}
+	defer suite.Close()
+	ctx := testsuite.NewMDContext(context.Background(), testsuite.NewMD())
+	var errs []string
+	for _, test := range suite.Tests() {
+		l := &mockLogger{}
+		test.Run(ctx, l)
+		errs = append(errs, l.errs...)
+	}
+	want := []string{`row 0: wrong value for column "status" (got pending; want shipped)`}
+	if !cmp.Equal(errs, want) {
+		t.Fatalf("wrong errors (got %q; want %q)", errs, want)
+	}
+}
+
+func TestSuiteSQLInvalidConfig(t *testing.T) {
+	cfg := &Config{
+		Base: Base{
+			URL: "http://localhost",
+			Databases: map[string]*sqlexpect.Database{
+				"a": {Driver: "sqlite3", DSN: ":memory:"},
+				"b": {Driver: "sqlite3", DSN: ":memory:"},
+			},
+		},
+		Tests: []*Test{
+			{Name: "no database", SQL: &SQLStep{}},
+			{Name: "unknown", Request: Request{Path: "/"}, SQL: &SQLStep{Database: "c", Query: sqlexpect.Query{Query: "SELECT 1"}}},
+		},
+	}
+	want := "test \"no database\":\n" +
+		"- sql: database is required\n" +
+		"- sql: query: a query is required\n" +
+		"test \"unknown\":\n" +
+		"- sql: request, expect and saveDocument can't be set with sql\n" +
+		"- sql: unknown database \"c\""
+	if _, err := New(cfg); err == nil || err.Error() != want {
+		t.Fatalf("wrong error (got %v; want %v)", err, want)
+	}
+}
@@ -0,0 +1,122 @@
+package httpexpect
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+
+	"github.com/blippar/aragorn/pkg/util/json"
+	"github.com/blippar/aragorn/testsuite"
+)
+
+var _ testsuite.Mocker = (*Suite)(nil)
+
+// Mock returns an HTTP server answering the request of each test with its
+// expected status code, headers and document. Requests are matched on their
+// method and path, templated path segments matching any value. When several
+// tests match the same request, their responses are returned in turn, the
+// last one being repeated.
+func (s *Suite) Mock() (testsuite.MockServer, error) {
+	m := &mock{}
+	for _, t := range s.tests {
+		// The SQL steps don't send requests.
+		if t, ok := t.(*test); ok {
+			m.add(t)
+		}
+	}
+	return &http.Server{Handler: m}, nil
+}
+
+type mock struct {
+	routes []*mockRoute
+}
+
+type mockRoute struct {
+	method  string
+	pattern string
+	path    *regexp.Regexp
+
+	mu    sync.Mutex
+	tests []*test
+	next  int
+}
+
+func (m *mock) add(t *test) {
+	method, pattern := t.req.Method, t.req.URL.Path
+	for _, r := range m.routes {
+		if r.method == method && r.pattern == pattern {
+			r.tests = append(r.tests, t)
+			return
+		}
+	}
+	m.routes = append(m.routes, &mockRoute{
+		method:  method,
+		pattern: pattern,
+		path:    pathRegexp(pattern),
+		tests:   []*test{t},
+	})
+}
+
+// pathRegexp returns a regexp matching path where the templates match any
+// path segment.
+func pathRegexp(path string) *regexp.Regexp {
+	var expr bytes.Buffer
+	expr.WriteString("^")
+	last := 0
+	for _, loc := range varsTmpl.FindAllStringIndex(path, -1) {
+		expr.WriteString(regexp.QuoteMeta(path[last:loc[0]]))
+		expr.WriteString("[^/]*")
+		last = loc[1]
+	}
+	expr.WriteString(regexp.QuoteMeta(path[last:]))
+	expr.WriteString("$")
+	return regexp.MustCompile(expr.String())
+}
+
+func (m *mock) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	for _, r := range m.routes {
+		if r.method == req.Method && r.path.MatchString(req.URL.Path) {
+			r.nextTest().writeMockResponse(w)
+			return
+		}
+	}
+	http.Error(w, fmt.Sprintf("no test matches %s %s", req.Method, req.URL.Path), http.StatusNotFound)
+}
+
+// nextTest returns the test whose response must be sent.
+func (r *mockRoute) nextTest() *test {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := r.tests[r.next]
+	if r.next < len(r.tests)-1 {
+		r.next++
+	}
+	return t
+}
+
+func (t *test) writeMockResponse(w http.ResponseWriter) {
+	var body []byte
+	switch doc := t.document.(type) {
+	case nil:
+	case []byte:
+		body = doc
+	default:
+		body, _ = json.Marshal(doc)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	}
+	if t.stream != "" {
+		body = t.mockEvents()
+		w.Header().Set("Content-Type", streamAccept[t.stream])
+	}
+	for k, v := range t.header {
+		w.Header().Set(k, v)
+	}
+	code := t.statusCode
+	if code < 0 {
+		code = http.StatusOK
+	}
+	w.WriteHeader(code)
+	w.Write(body)
+}
@@ -0,0 +1,105 @@
+package httpexpect
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// defaultMaxRedirects is the number of redirects followed by default, as
+// the http.Client does.
+const defaultMaxRedirects = 10
+
+var errTooManyRedirects = errors.New("stopped after 10 redirects")
+
+// RedirectLimit is the maximum number of redirects to follow. It can also be
+// set from a boolean: true follows up to 10 redirects, false none.
+type RedirectLimit int
+
+// UnmarshalJSON unmarshals b from a boolean or an integer.
+func (l *RedirectLimit) UnmarshalJSON(b []byte) error {
+	switch s := string(b); s {
+	case "true":
+		*l = defaultMaxRedirects
+	case "false":
+		*l = 0
+	default:
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return errors.New("followRedirects must be a boolean or a positive integer")
+		}
+		*l = RedirectLimit(n)
+	}
+	return nil
+}
+
+// ExpectRedirect describes a redirect expected before the final response.
+type ExpectRedirect struct {
+	StatusCode int    `json:"statusCode,omitempty"`
+	Location   string `json:"location,omitempty"`
+}
+
+// redirect is a redirect followed by the client.
+type redirect struct {
+	statusCode int
+	location   string // Value of the Location header.
+	url        string // Resolved URL of the location.
+}
+
+// redirectChain records the redirects followed for a request.
+type redirectChain struct {
+	max  int // Negative if not set.
+	hops []redirect
+}
+
+type redirectChainKey struct{}
+
+// checkRedirect is the CheckRedirect function of the suites' clients. It
+// records the redirects of the chain stored in the context of the request
+// and stops following them after its limit.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	rc, ok := req.Context().Value(redirectChainKey{}).(*redirectChain)
+	if !ok {
+		if len(via) >= defaultMaxRedirects {
+			return errTooManyRedirects
+		}
+		return nil
+	}
+	if rc.max >= 0 && len(via) > rc.max {
+		return http.ErrUseLastResponse
+	}
+	if rc.max < 0 && len(via) >= defaultMaxRedirects {
+		return errTooManyRedirects
+	}
+	if resp := req.Response; resp != nil {
+		rc.hops = append(rc.hops, redirect{
+			statusCode: resp.StatusCode,
+			location:   resp.Header.Get("Location"),
+			url:        req.URL.String(),
+		})
+	}
+	return nil
+}
+
+func withRedirectChain(ctx context.Context, rc *redirectChain) context.Context {
+	return context.WithValue(ctx, redirectChainKey{}, rc)
+}
+
+// checkRedirects checks the redirects followed before the response.
+func (r *response) checkRedirects() {
+	hops := r.redirects.hops
+	if got, want := len(hops), len(r.test.redirects); got != want {
+		r.logger.Errorf("wrong number of redirects (got %d; want %d)", got, want)
+		return
+	}
+	for i, want := range r.test.redirects {
+		got := hops[i]
+		if want.StatusCode != 0 && got.statusCode != want.StatusCode {
+			r.logger.Errorf("wrong status code for redirect %d (got %d; want %d)", i+1, got.statusCode, want.StatusCode)
+		}
+		if want.Location != "" && got.location != want.Location && got.url != want.Location {
+			r.logger.Errorf("wrong location for redirect %d (got %q; want %q)", i+1, got.location, want.Location)
+		}
+	}
+}
@@ -40,6 +40,9 @@ func (req *Request) toHTTPRequest(cfg *Config) (*http.Request, error) {
 	}
 	setHeaderinHTTPRequest(cfg.Base.Header, httpReq)
 	setHeaderinHTTPRequest(req.Header, httpReq)
+	for name, value := range req.Cookies {
+		httpReq.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
 	if cntType != "" && httpReq.Header.Get("Content-Type") == "" {
 		httpReq.Header.Set("Content-Type", cntType)
 	}
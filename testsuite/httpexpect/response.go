@@ -2,14 +2,11 @@ package httpexpect
 
 import (
 	"bytes"
-	gojson "encoding/json"
-	"errors"
 	"fmt"
 	"net/http"
-	"strconv"
-	"strings"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/xeipuuv/gojsonschema"
 
 	"github.com/blippar/aragorn/pkg/util/json"
 	"github.com/blippar/aragorn/testsuite"
@@ -17,13 +14,6 @@ import (
 
 const maxErrorBodySize = 512
 
-var (
-	errInvalidArrayIndex   = errors.New("invalid array index")
-	errInvalidType         = errors.New("invalid type")
-	errIndexOutOfBounds    = errors.New("array index out of bounds")
-	errObjectFieldNotFound = errors.New("object does not contain field")
-)
-
 // response wraps an http.Response and allows you to have expectations on it.
 type response struct {
 	test          *test
@@ -32,11 +22,12 @@ type response struct {
 	body          []byte
 	dataJSON      interface{}
 	dataJSONError bool
+	redirects     *redirectChain
 }
 
 // checkResponse checks a response on which you can have expectations.
 // Any failed expectation will be logged on the logger.
-func checkResponse(test *test, logger testsuite.Logger, md testsuite.MD, resp *http.Response, body []byte) {
+func checkResponse(test *test, logger testsuite.Logger, md testsuite.MD, resp *http.Response, body []byte, rc *redirectChain) {
 	if resp.StatusCode != test.statusCode && test.statusCode >= 0 {
 		str := string(body)
 		if len(str) > maxErrorBodySize {
@@ -46,17 +37,27 @@ func checkResponse(test *test, logger testsuite.Logger, md testsuite.MD, resp *h
 		return
 	}
 	r := response{
-		test:   test,
-		logger: logger,
-		resp:   resp,
-		body:   body,
+		test:      test,
+		logger:    logger,
+		resp:      resp,
+		body:      body,
+		redirects: rc,
+	}
+	if test.redirects != nil {
+		r.checkRedirects()
 	}
 	r.checkHeader()
+	if test.cookies != nil {
+		r.checkCookies()
+	}
 	if test.document != nil {
 		r.matchDocument()
 	}
 	if test.jsonSchema != nil {
-		r.matchJSONSchema()
+		r.matchJSONSchema(test.jsonSchema, "JSON schema")
+	}
+	if test.openAPIOp != nil {
+		r.matchOpenAPISchema()
 	}
 	if test.jsonValues != nil {
 		r.containsJSONValues()
@@ -97,14 +98,14 @@ func (r *response) matchDocument() {
 }
 
 // matchJSONSchema checks whether the JSON formated response body matches the given JSON schema.
-func (r *response) matchJSONSchema() {
+func (r *response) matchJSONSchema(schema *gojsonschema.Schema, name string) {
 	if !r.unmarshalJSONBody() {
 		return
 	}
 	data := newJSONGoLoader(r.dataJSON)
-	result, err := r.test.jsonSchema.Validate(data)
+	result, err := schema.Validate(data)
 	if err != nil {
-		r.logger.Errorf("JSON schema validation failed: %v", err)
+		r.logger.Errorf("%s validation failed: %v", name, err)
 		return
 	}
 	if !result.Valid() {
@@ -114,7 +115,21 @@ func (r *response) matchJSONSchema() {
 			b.WriteString("\n\t- ")
 			b.WriteString(err.String())
 		}
-		r.logger.Errorf("JSON schema validation failed:%s", b.String())
+		r.logger.Errorf("%s validation failed:%s", name, b.String())
+	}
+}
+
+// matchOpenAPISchema checks whether the response is documented by the OpenAPI
+// operation and matches its schema.
+func (r *response) matchOpenAPISchema() {
+	op := r.test.openAPIOp
+	k, ok := op.ResponseKey(r.resp.StatusCode)
+	if !ok {
+		r.logger.Errorf("OpenAPI operation %s does not document status code %d", op.Name(), r.resp.StatusCode)
+		return
+	}
+	if schema, ok := r.test.openAPISchemas[k]; ok {
+		r.matchJSONSchema(schema, "OpenAPI schema")
 	}
 }
 
@@ -125,7 +140,7 @@ func (r *response) containsJSONValues() {
 		return
 	}
 	for query, expected := range r.test.jsonValues {
-		val, err := queryJSONData(query, r.dataJSON)
+		val, err := json.Query(query, r.dataJSON)
 		if err != nil {
 			r.logger.Errorf("could not get value for query %q: %v", query, err)
 			continue
@@ -150,42 +165,3 @@ func (r *response) unmarshalJSONBody() bool {
 	}
 	return true
 }
-
-// queryJSONData returns the value for the given query in the decoded json data.
-func queryJSONData(q string, v interface{}) (interface{}, error) {
-	var err error
-	ks := strings.Split(q, ".")
-	for i, k := range ks {
-		v, err = lookupJSONData(k, v)
-		if err != nil {
-			pq := strings.Join(ks[:i+1], ".")
-			return nil, fmt.Errorf("%s: %v", pq, err)
-		}
-	}
-	return v, nil
-}
-
-// lookupJSONData returns the value for the key in the decoded json data.
-func lookupJSONData(k string, i interface{}) (interface{}, error) {
-	switch v := i.(type) {
-	case []interface{}:
-		if k == "length" {
-			return gojson.Number(strconv.Itoa(len(v))), nil
-		}
-		i, err := strconv.Atoi(k)
-		if err != nil {
-			return nil, errInvalidArrayIndex
-		}
-		if i >= len(v) {
-			return nil, errIndexOutOfBounds
-		}
-		return v[i], nil
-	case map[string]interface{}:
-		val, ok := v[k]
-		if !ok {
-			return nil, errObjectFieldNotFound
-		}
-		return val, nil
-	}
-	return nil, errInvalidType
-}
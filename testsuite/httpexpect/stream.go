@@ -0,0 +1,218 @@
+package httpexpect
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/blippar/aragorn/pkg/util/json"
+	"github.com/blippar/aragorn/testsuite"
+)
+
+const (
+	streamSSE    = "sse"
+	streamNDJSON = "ndjson"
+)
+
+var streamAccept = map[string]string{
+	streamSSE:    "text/event-stream",
+	streamNDJSON: "application/x-ndjson",
+}
+
+// ExpectEvent describes an event of a streamed response.
+type ExpectEvent struct {
+	Type       string                 `json:"type,omitempty"`       // Type of an SSE event (default: message).
+	ID         string                 `json:"id,omitempty"`         // ID of an SSE event, if set.
+	Document   interface{}            `json:"document,omitempty"`   // Exact data to match.
+	JSONValues map[string]interface{} `json:"jsonValues,omitempty"` // Required JSON values of the data. Exclusive with Document.
+}
+
+type event struct {
+	typ  string
+	id   string
+	data []byte
+}
+
+type expectEvent struct {
+	typ        string
+	id         string
+	document   interface{}
+	jsonValues map[string]interface{}
+}
+
+// eventReader reads the events of a stream.
+type eventReader struct {
+	s      *bufio.Scanner
+	stream string
+	lastID string // Last SSE event ID, kept by the events without id field.
+}
+
+func newEventReader(r io.Reader, stream string) *eventReader {
+	return &eventReader{s: bufio.NewScanner(r), stream: stream}
+}
+
+// next returns the next event of the stream or io.EOF at the end of the
+// stream.
+func (er *eventReader) next() (*event, error) {
+	if er.stream == streamNDJSON {
+		for er.s.Scan() {
+			if line := bytes.TrimSpace(er.s.Bytes()); len(line) > 0 {
+				return &event{data: append([]byte(nil), line...)}, nil
+			}
+		}
+		return nil, scanErr(er.s)
+	}
+	ev := &event{}
+	var data [][]byte
+	for er.s.Scan() {
+		line := er.s.Bytes()
+		if len(line) == 0 {
+			// A block without data is not dispatched and its type is
+			// discarded.
+			if data == nil {
+				ev.typ = ""
+				continue
+			}
+			if ev.typ == "" {
+				ev.typ = "message"
+			}
+			ev.id = er.lastID
+			ev.data = bytes.Join(data, []byte("\n"))
+			return ev, nil
+		}
+		if line[0] == ':' {
+			continue
+		}
+		field, value := string(line), ""
+		if i := bytes.IndexByte(line, ':'); i >= 0 {
+			field, value = string(line[:i]), strings.TrimPrefix(string(line[i+1:]), " ")
+		}
+		switch field {
+		case "event":
+			ev.typ = value
+		case "data":
+			data = append(data, []byte(value))
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				er.lastID = value
+			}
+		}
+	}
+	return nil, scanErr(er.s)
+}
+
+func scanErr(s *bufio.Scanner) error {
+	if err := s.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+// checkEvents reads the expected number of events from the body and checks
+// them. Any failed expectation will be logged on the logger.
+func checkEvents(test *test, logger testsuite.Logger, body io.Reader) {
+	er := newEventReader(body, test.stream)
+	for i, e := range test.events {
+		ev, err := er.next()
+		if err == io.EOF {
+			logger.Errorf("stream ended after %d events (want %d)", i, len(test.events))
+			return
+		} else if err != nil {
+			logger.Errorf("could not read event %d: %v", i, err)
+			return
+		}
+		e.check(ev, prefixLogger{logger, fmt.Sprintf("event %d: ", i)})
+	}
+}
+
+func (e *expectEvent) check(ev *event, l testsuite.Logger) {
+	if e.typ != "" && ev.typ != e.typ {
+		l.Errorf("wrong type (got %q; want %q)", ev.typ, e.typ)
+	}
+	if e.id != "" && ev.id != e.id {
+		l.Errorf("wrong id (got %q; want %q)", ev.id, e.id)
+	}
+	if raw, ok := e.document.([]byte); ok {
+		if !bytes.Equal(ev.data, raw) {
+			l.Error("data does not match document")
+		}
+		return
+	}
+	if e.document == nil && e.jsonValues == nil {
+		return
+	}
+	var v interface{}
+	if err := json.Unmarshal(ev.data, &v); err != nil {
+		l.Errorf("could not decode json data: %v", err)
+		return
+	}
+	if e.document != nil && !cmp.Equal(e.document, v) {
+		l.Error("data does not match document")
+	}
+	queries := make([]string, 0, len(e.jsonValues))
+	for query := range e.jsonValues {
+		queries = append(queries, query)
+	}
+	sort.Strings(queries)
+	for _, query := range queries {
+		val, err := json.Query(query, v)
+		if err != nil {
+			l.Errorf("could not get value for query %q: %v", query, err)
+			continue
+		}
+		if expected := e.jsonValues[query]; !cmp.Equal(val, expected) {
+			l.Errorf("wrong value for query %q (got %v; want %v)", query, val, expected)
+		}
+	}
+}
+
+// mockEvents returns a stream of the expected events. Their data is their
+// document, if any.
+func (t *test) mockEvents() []byte {
+	var b bytes.Buffer
+	for _, e := range t.events {
+		var data []byte
+		switch doc := e.document.(type) {
+		case nil:
+		case []byte:
+			data = doc
+		default:
+			data, _ = json.Marshal(doc)
+		}
+		if t.stream == streamNDJSON {
+			b.Write(data)
+			b.WriteByte('\n')
+			continue
+		}
+		if e.typ != "" {
+			fmt.Fprintf(&b, "event: %s\n", e.typ)
+		}
+		if e.id != "" {
+			fmt.Fprintf(&b, "id: %s\n", e.id)
+		}
+		for _, line := range bytes.Split(data, []byte("\n")) {
+			fmt.Fprintf(&b, "data: %s\n", line)
+		}
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+// prefixLogger prefixes the errors logged on a testsuite.Logger.
+type prefixLogger struct {
+	testsuite.Logger
+	prefix string
+}
+
+func (l prefixLogger) Error(args ...interface{}) {
+	l.Logger.Error(l.prefix + fmt.Sprint(args...))
+}
+
+func (l prefixLogger) Errorf(format string, args ...interface{}) {
+	l.Logger.Error(l.prefix + fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,105 @@
+package httpexpect
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSConfig describes the TLS settings of the requests.
+type TLSConfig struct {
+	CAPath     string `json:"caPath,omitempty"`     // PEM bundle of the CAs trusted instead of the system ones.
+	CertPath   string `json:"certPath,omitempty"`   // PEM client certificate.
+	KeyPath    string `json:"keyPath,omitempty"`    // PEM client private key.
+	ServerName string `json:"serverName,omitempty"` // Overrides the server name used for SNI and verification.
+	MinVersion string `json:"minVersion,omitempty"` // Minimum TLS version: 1.0, 1.1, 1.2 or 1.3.
+}
+
+// transport returns the transport of the suite's client or nil if the
+// default one can be used.
+func (cfg *Config) transport() (*http.Transport, error) {
+	if !cfg.Base.Insecure && cfg.Base.TLS == nil && cfg.Base.Proxy == "" {
+		return nil, nil
+	}
+	t := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+	}
+	if cfg.Base.Proxy != "" {
+		u, err := url.Parse(cfg.Base.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("base: proxy: invalid URL: %v", err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("base: proxy: unsupported scheme %q", u.Scheme)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+	tlsCfg, err := cfg.tlsConfig()
+	if err != nil {
+		return nil, fmt.Errorf("base: tls: %v", err)
+	}
+	t.TLSClientConfig = tlsCfg
+	return t, nil
+}
+
+func (cfg *Config) tlsConfig() (*tls.Config, error) {
+	tlsCfg := &tls.Config{}
+	if cfg.Base.TLS != nil {
+		var err error
+		if tlsCfg, err = cfg.Base.TLS.ClientConfig(cfg.Root); err != nil {
+			return nil, err
+		}
+	}
+	tlsCfg.InsecureSkipVerify = cfg.Base.Insecure
+	return tlsCfg, nil
+}
+
+// ClientConfig returns the TLS configuration of a client described by c.
+// Relative paths are resolved from root.
+func (c *TLSConfig) ClientConfig(root string) (*tls.Config, error) {
+	tlsCfg := &tls.Config{
+		ServerName: c.ServerName,
+	}
+	if c.CAPath != "" {
+		b, err := ioutil.ReadFile(filePath(root, c.CAPath))
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA file: %v", err)
+		}
+		cp := x509.NewCertPool()
+		if !cp.AppendCertsFromPEM(b) {
+			return nil, errors.New("could not append CA certificates")
+		}
+		tlsCfg.RootCAs = cp
+	}
+	if (c.CertPath == "") != (c.KeyPath == "") {
+		return nil, errors.New("certPath and keyPath must be set together")
+	}
+	if c.CertPath != "" {
+		cert, err := tls.LoadX509KeyPair(filePath(root, c.CertPath), filePath(root, c.KeyPath))
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %v", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	if c.MinVersion != "" {
+		v, ok := tlsVersions[c.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid minVersion %q", c.MinVersion)
+		}
+		tlsCfg.MinVersion = v
+	}
+	return tlsCfg, nil
+}
@@ -0,0 +1,101 @@
+package sqlexpect
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/blippar/aragorn/testsuite"
+)
+
+type Config struct {
+	Path string `json:"path,omitempty"`
+	Root string `json:"root,omitempty"`
+	Database
+	Tests []*Test `json:"tests,omitempty"`
+}
+
+// Database describes how to connect to a database.
+type Database struct {
+	Driver string `json:"driver,omitempty"` // Name of a database/sql driver: postgres or sqlite3.
+	DSN    string `json:"dsn,omitempty"`    // Data source name, in the format of the driver.
+}
+
+type Test struct {
+	Name string `json:"name,omitempty"`
+	Query
+}
+
+// Query describes an SQL query and the expectations on its result.
+type Query struct {
+	Query  string        `json:"query,omitempty"`
+	Args   []interface{} `json:"args,omitempty"` // Values of the placeholders of the query.
+	Expect Expect        `json:"expect,omitempty"`
+}
+
+type Expect struct {
+	RowCount *int                     `json:"rowCount,omitempty"` // Number of rows returned.
+	Rows     []map[string]interface{} `json:"rows,omitempty"`     // Column values of the first rows, the other columns are ignored.
+}
+
+func (*Config) Example() interface{} {
+	rowCount := 1
+	return &Config{
+		Database: Database{
+			Driver: "postgres",
+			DSN:    "postgres://localhost/shop?sslmode=disable",
+		},
+		Tests: []*Test{
+			{
+				Name: "Pending orders",
+				Query: Query{
+					Query: "SELECT id, status FROM orders WHERE created_at > $1",
+					Args:  []interface{}{time.Now().Add(-time.Hour).Format(time.RFC3339)},
+					Expect: Expect{
+						RowCount: &rowCount,
+						Rows:     []map[string]interface{}{{"status": "pending"}},
+					},
+				},
+			},
+		},
+	}
+}
+
+// Open returns a handle to the database. The connections are opened when the
+// queries are run.
+func (d *Database) Open() (*sql.DB, error) {
+	if d.Driver == "" {
+		return nil, errors.New("driver is required")
+	}
+	if d.DSN == "" {
+		return nil, errors.New("dsn is required")
+	}
+	return sql.Open(d.Driver, d.DSN)
+}
+
+func (cfg *Config) genTests() ([]testsuite.Test, *sql.DB, error) {
+	db, err := cfg.Database.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(cfg.Tests) == 0 {
+		db.Close()
+		return nil, nil, errors.New("a test suite must contain at least one test")
+	}
+	tests := make([]testsuite.Test, len(cfg.Tests))
+	var errs []string
+	for i, tcfg := range cfg.Tests {
+		t, err := NewTest(tcfg.Name, db, &tcfg.Query)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("test %q:\n%v", tcfg.Name, err))
+		}
+		tests[i] = t
+	}
+	if len(errs) > 0 {
+		db.Close()
+		return nil, nil, errors.New(strings.Join(errs, "\n"))
+	}
+	return tests, db, nil
+}
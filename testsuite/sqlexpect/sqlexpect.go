@@ -0,0 +1,264 @@
+package sqlexpect
+
+import (
+	"context"
+	"database/sql"
+	gojson "encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blippar/aragorn/pkg/util/json"
+	"github.com/blippar/aragorn/plugin"
+	"github.com/blippar/aragorn/testsuite"
+)
+
+var (
+	_ testsuite.Suite  = (*Suite)(nil)
+	_ testsuite.Closer = (*Suite)(nil)
+)
+
+// argTmpl matches the arguments replaced by a value saved by a previous test.
+var argTmpl = regexp.MustCompile(`^{{([0-9A-Za-z._-]+)}}$`)
+
+// Suite describes an SQL test suite.
+type Suite struct {
+	tests []testsuite.Test
+	db    *sql.DB
+}
+
+// New returns a Suite.
+func New(cfg *Config) (*Suite, error) {
+	tests, db, err := cfg.genTests()
+	if err != nil {
+		return nil, err
+	}
+	return &Suite{tests: tests, db: db}, nil
+}
+
+func (s *Suite) Tests() []testsuite.Test { return s.tests }
+
+// Close closes the connections to the database.
+func (s *Suite) Close() error { return s.db.Close() }
+
+type test struct {
+	name        string
+	description string
+	db          *sql.DB
+	query       string
+	args        []interface{}
+	argQueries  map[int]string // Queries of the saved values replacing args by index.
+
+	rowCount int // Any number of rows is expected if negative.
+	rows     []map[string]interface{}
+}
+
+// NewTest returns a test running q on db. It can be used by the other suites
+// to check the side effects of their tests. The string arguments of the form
+// {{id.field}} are replaced by the values saved by the previous tests.
+func NewTest(name string, db *sql.DB, q *Query) (testsuite.Test, error) {
+	t := &test{
+		name:       name,
+		db:         db,
+		query:      strings.TrimSpace(q.Query),
+		args:       q.Args,
+		argQueries: make(map[int]string),
+		rowCount:   -1,
+		rows:       q.Expect.Rows,
+	}
+	t.description = "SQL " + t.query
+	var errs []string
+	if t.query == "" {
+		errs = append(errs, "- query: a query is required")
+	}
+	for i, arg := range q.Args {
+		if s, ok := arg.(string); ok {
+			if m := argTmpl.FindStringSubmatch(s); m != nil {
+				t.argQueries[i] = m[1]
+			}
+		}
+	}
+	if rc := q.Expect.RowCount; rc != nil {
+		if *rc < 0 {
+			errs = append(errs, "- expect: rowCount can't be negative")
+		} else if *rc < len(q.Expect.Rows) {
+			errs = append(errs, "- expect: rowCount can't be lower than the number of rows")
+		}
+		t.rowCount = *rc
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "\n"))
+	}
+	return t, nil
+}
+
+func (t *test) Name() string        { return t.name }
+func (t *test) Description() string { return t.description }
+
+func (t *test) Run(ctx context.Context, l testsuite.Logger) {
+	args, err := t.queryArgs(ctx)
+	if err != nil {
+		l.Error(err)
+		return
+	}
+	rows, err := t.db.QueryContext(ctx, t.query, args...)
+	if err != nil {
+		l.Errorf("could not run query: %v", err)
+		return
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		l.Errorf("could not get columns: %v", err)
+		return
+	}
+	n := 0
+	for ; rows.Next(); n++ {
+		if n >= len(t.rows) {
+			continue
+		}
+		vals := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			l.Errorf("row %d: could not scan: %v", n, err)
+			return
+		}
+		row := make(map[string]interface{}, len(cols))
+		for i, col := range cols {
+			row[col] = vals[i]
+		}
+		checkRow(n, row, t.rows[n], l)
+	}
+	if err := rows.Err(); err != nil {
+		l.Errorf("could not read rows: %v", err)
+		return
+	}
+	if t.rowCount >= 0 && n != t.rowCount {
+		l.Errorf("wrong row count (got %d; want %d)", n, t.rowCount)
+	} else if n < len(t.rows) {
+		l.Errorf("wrong row count (got %d; want at least %d)", n, len(t.rows))
+	}
+}
+
+// queryArgs returns the arguments of the query with the saved values.
+func (t *test) queryArgs(ctx context.Context) ([]interface{}, error) {
+	md, _ := testsuite.MDFromContext(ctx)
+	args := make([]interface{}, len(t.args))
+	for i, arg := range t.args {
+		if q, ok := t.argQueries[i]; ok {
+			v, err := json.Query(q, map[string]interface{}(md))
+			if err != nil {
+				return nil, fmt.Errorf("could not get value for arg %d %q: %v", i, q, err)
+			}
+			arg = v
+		}
+		// The drivers don't support the JSON numbers.
+		if n, ok := arg.(gojson.Number); ok {
+			if v, err := n.Int64(); err == nil {
+				arg = v
+			} else if f, err := n.Float64(); err == nil {
+				arg = f
+			}
+		}
+		args[i] = arg
+	}
+	return args, nil
+}
+
+// checkRow checks the values of the columns of the row.
+func checkRow(n int, row, want map[string]interface{}, l testsuite.Logger) {
+	cols := make([]string, 0, len(want))
+	for col := range want {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	for _, col := range cols {
+		got, ok := row[col]
+		if !ok {
+			l.Errorf("row %d: missing column %q", n, col)
+			continue
+		}
+		if !equalValue(got, want[col]) {
+			l.Errorf("row %d: wrong value for column %q (got %v; want %v)", n, col, formatValue(got), want[col])
+		}
+	}
+}
+
+// equalValue reports whether the value scanned from a column is equal to the
+// expected JSON value. Numbers are compared numerically, whatever the type
+// chosen by the driver.
+func equalValue(got, want interface{}) bool {
+	got = normalizeValue(got)
+	switch w := want.(type) {
+	case nil:
+		return got == nil
+	case gojson.Number:
+		wf, err := w.Float64()
+		if err != nil {
+			return false
+		}
+		switch g := got.(type) {
+		case int64:
+			return float64(g) == wf
+		case float64:
+			return g == wf
+		case string:
+			gf, err := strconv.ParseFloat(g, 64)
+			return err == nil && gf == wf
+		case bool:
+			return (g && wf == 1) || (!g && wf == 0)
+		}
+		return false
+	case bool:
+		switch g := got.(type) {
+		case bool:
+			return g == w
+		case int64:
+			return (g != 0) == w
+		}
+		return false
+	case string:
+		g, ok := got.(string)
+		return ok && g == w
+	}
+	return false
+}
+
+// normalizeValue returns the bytes as a string and the times in RFC 3339.
+func normalizeValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return v
+}
+
+func formatValue(v interface{}) interface{} {
+	if v == nil {
+		return "NULL"
+	}
+	return normalizeValue(v)
+}
+
+func init() {
+	plugin.Register(&plugin.Registration{
+		Type:   plugin.TestSuitePlugin,
+		ID:     "SQL",
+		Config: (*Config)(nil),
+		InitFn: func(ctx *plugin.InitContext) (interface{}, error) {
+			cfg := ctx.Config.(*Config)
+			cfg.Path = ctx.Path
+			cfg.Root = ctx.Root
+			return New(cfg)
+		},
+	})
+}
@@ -0,0 +1,198 @@
+package sqlexpect
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/blippar/aragorn/testsuite"
+)
+
+type mockLogger struct {
+	errs []string
+}
+
+func (tr *mockLogger) Error(args ...interface{}) {
+	tr.errs = append(tr.errs, fmt.Sprint(args...))
+}
+
+func (tr *mockLogger) Errorf(format string, args ...interface{}) {
+	tr.errs = append(tr.errs, fmt.Sprintf(format, args...))
+}
+
+// newDatabase creates an SQLite database with an orders table and returns
+// its DSN.
+func newDatabase(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "sqlexpect")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	dsn := filepath.Join(dir, "shop.db")
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`
+		CREATE TABLE orders (id INTEGER PRIMARY KEY, status TEXT, total REAL, paid BOOLEAN, note TEXT);
+		INSERT INTO orders VALUES (1, 'pending', 12.5, 0, NULL), (2, 'shipped', 30, 1, 'gift');
+	`)
+	if err != nil {
+		t.Fatalf("could not create orders: %v", err)
+	}
+	return dsn, func() { os.RemoveAll(dir) }
+}
+
+func TestSuiteRunTest(t *testing.T) {
+	dsn, cleanup := newDatabase(t)
+	defer cleanup()
+	one, two := 1, 2
+	tt := []struct {
+		name  string
+		query Query
+		errs  []string
+	}{
+		{
+			name: "rows",
+			query: Query{
+				Query: "SELECT * FROM orders ORDER BY id",
+				Expect: Expect{
+					RowCount: &two,
+					Rows: []map[string]interface{}{
+						{"id": json.Number("1"), "status": "pending", "total": json.Number("12.50"), "paid": false, "note": nil},
+						{"id": json.Number("2"), "paid": true, "note": "gift"},
+					},
+				},
+			},
+		},
+		{
+			name: "args",
+			query: Query{
+				Query:  "SELECT status FROM orders WHERE id = ? AND total > ?",
+				Args:   []interface{}{json.Number("2"), json.Number("29.9")},
+				Expect: Expect{RowCount: &one, Rows: []map[string]interface{}{{"status": "shipped"}}},
+			},
+		},
+		{
+			name: "saved args",
+			query: Query{
+				Query:  "SELECT status FROM orders WHERE id = ?",
+				Args:   []interface{}{"{{create.id}}"},
+				Expect: Expect{Rows: []map[string]interface{}{{"status": "pending"}}},
+			},
+		},
+		{
+			name: "wrong values",
+			query: Query{
+				Query: "SELECT id, status, note FROM orders WHERE id = 1",
+				Expect: Expect{
+					Rows: []map[string]interface{}{{"status": "shipped", "note": "gift", "total": json.Number("1")}},
+				},
+			},
+			errs: []string{
+				`row 0: wrong value for column "note" (got NULL; want gift)`,
+				`row 0: wrong value for column "status" (got pending; want shipped)`,
+				`row 0: missing column "total"`,
+			},
+		},
+		{
+			name: "wrong row count",
+			query: Query{
+				Query:  "SELECT id FROM orders WHERE status = 'cancelled'",
+				Expect: Expect{RowCount: &one},
+			},
+			errs: []string{"wrong row count (got 0; want 1)"},
+		},
+		{
+			name: "missing rows",
+			query: Query{
+				Query:  "SELECT id FROM orders",
+				Expect: Expect{Rows: []map[string]interface{}{{}, {}, {}}},
+			},
+			errs: []string{"wrong row count (got 2; want at least 3)"},
+		},
+		{
+			name:  "invalid query",
+			query: Query{Query: "SELECT * FROM customers"},
+			errs:  []string{"could not run query: no such table: customers"},
+		},
+	}
+	md := testsuite.MD{"create": map[string]interface{}{"id": json.Number("1")}}
+	ctx := testsuite.NewMDContext(context.Background(), md)
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &Config{
+				Database: Database{Driver: "sqlite3", DSN: dsn},
+				Tests:    []*Test{{Name: tc.name, Query: tc.query}},
+			}
+			suite, err := New(cfg)
+			if err != nil {
+				t.Fatalf("can't create suite: %v", err)
+			}
+			defer suite.Close()
+			l := &mockLogger{}
+			suite.Tests()[0].Run(ctx, l)
+			if !cmp.Equal(l.errs, tc.errs) {
+				t.Fatalf("wrong errors (got %q; want %q)", l.errs, tc.errs)
+			}
+		})
+	}
+}
+
+func TestSuiteClose(t *testing.T) {
+	cfg := &Config{
+		Database: Database{Driver: "sqlite3", DSN: ":memory:"},
+		Tests:    []*Test{{Name: "select", Query: Query{Query: "SELECT 1"}}},
+	}
+	suite, err := New(cfg)
+	if err != nil {
+		t.Fatalf("can't create suite: %v", err)
+	}
+	if err := suite.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	l := &mockLogger{}
+	suite.Tests()[0].Run(context.Background(), l)
+	want := []string{"could not run query: sql: database is closed"}
+	if !cmp.Equal(l.errs, want) {
+		t.Fatalf("wrong errors (got %q; want %q)", l.errs, want)
+	}
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	negative, zero := -1, 0
+	cfg := &Config{
+		Database: Database{Driver: "sqlite3", DSN: ":memory:"},
+		Tests: []*Test{
+			{Name: "empty"},
+			{Name: "negative", Query: Query{Query: "SELECT 1", Expect: Expect{RowCount: &negative}}},
+			{Name: "too few", Query: Query{Query: "SELECT 1", Expect: Expect{RowCount: &zero, Rows: []map[string]interface{}{{}}}}},
+		},
+	}
+	want := "test \"empty\":\n" +
+		"- query: a query is required\n" +
+		"test \"negative\":\n" +
+		"- expect: rowCount can't be negative\n" +
+		"test \"too few\":\n" +
+		"- expect: rowCount can't be lower than the number of rows"
+	_, err := New(cfg)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if diff := cmp.Diff(want, err.Error()); diff != "" {
+		t.Fatalf("wrong error (-want +got):\n%s", diff)
+	}
+	cfg.Driver = "mysql"
+	want = `sql: unknown driver "mysql" (forgotten import?)`
+	if _, err := New(cfg); err == nil || err.Error() != want {
+		t.Fatalf("wrong error (got %v; want %v)", err, want)
+	}
+}
@@ -0,0 +1,321 @@
+package tcpexpect
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/blippar/aragorn/pkg/util/json"
+	"github.com/blippar/aragorn/testsuite"
+	"github.com/blippar/aragorn/testsuite/httpexpect"
+)
+
+type Config struct {
+	Path  string  `json:"path,omitempty"`
+	Root  string  `json:"root,omitempty"`
+	Base  Base    `json:"base,omitempty"`
+	Tests []*Test `json:"tests,omitempty"`
+}
+
+type Base struct {
+	Address        string                `json:"address,omitempty"`        // host:port of the endpoint.
+	Network        string                `json:"network,omitempty"`        // tcp, tls or udp (default: tcp).
+	Framing        string                `json:"framing,omitempty"`        // raw, line or length (default: raw).
+	LineEnding     string                `json:"lineEnding,omitempty"`     // Appended to the sent lines (default: \r\n).
+	LengthSize     int                   `json:"lengthSize,omitempty"`     // Size of the big-endian length prefix: 1, 2 or 4 (default: 4).
+	MaxMessageSize int                   `json:"maxMessageSize,omitempty"` // Size above which the length-prefixed messages received are rejected (default: 1MiB).
+	Insecure       bool                  `json:"insecure,omitempty"`
+	TLS            *httpexpect.TLSConfig `json:"tls,omitempty"`     // TLS settings of the tls network and the startTLS steps.
+	Timeout        json.Duration         `json:"timeout,omitempty"` // Default time waiting for each expected data.
+}
+
+type Test struct {
+	Name    string `json:"name,omitempty"`
+	Address string `json:"address,omitempty"` // If set, will overwrite the base address.
+	Steps   []Step `json:"steps,omitempty"`   // Data sent and received in order.
+}
+
+// A Step either sends data, receives data or upgrades the connection to TLS.
+type Step struct {
+	Send     *Data   `json:"send,omitempty"`
+	Receive  *Expect `json:"receive,omitempty"`
+	StartTLS bool    `json:"startTLS,omitempty"`
+}
+
+// Data describes bytes. Only one of its fields must be set.
+type Data struct {
+	Text   string `json:"text,omitempty"`
+	Hex    string `json:"hex,omitempty"`
+	Base64 string `json:"base64,omitempty"`
+	Ref    string `json:"$ref,omitempty"` // Path of a file containing the bytes.
+}
+
+// Expect describes expected data. Exactly one of the Data fields or Regex must
+// be set.
+type Expect struct {
+	Data
+	Prefix  bool          `json:"prefix,omitempty"`  // The message must only start with the expected bytes.
+	Regex   string        `json:"regex,omitempty"`   // Regular expression the data must match.
+	Timeout json.Duration `json:"timeout,omitempty"` // If set, will overwrite the base timeout.
+	Skip    bool          `json:"skip,omitempty"`    // Skip the messages not matching until the timeout.
+}
+
+func (*Config) Example() interface{} {
+	return &Config{
+		Base: Base{
+			Address: "localhost:25",
+			Framing: "line",
+			Timeout: json.Duration(5 * time.Second),
+		},
+		Tests: []*Test{
+			{
+				Name: "EHLO",
+				Steps: []Step{
+					{Receive: &Expect{Regex: "^220 "}},
+					{Send: &Data{Text: "EHLO aragorn"}},
+					{Receive: &Expect{Regex: "^250 ", Skip: true}},
+					{Send: &Data{Text: "QUIT"}},
+				},
+			},
+		},
+	}
+}
+
+func (cfg *Config) genTests() ([]testsuite.Test, error) {
+	switch cfg.Base.Network {
+	case "":
+		cfg.Base.Network = "tcp"
+	case "tcp", "tls", "udp":
+	default:
+		return nil, fmt.Errorf("base: unknown network %q", cfg.Base.Network)
+	}
+	switch cfg.Base.Framing {
+	case "":
+		cfg.Base.Framing = "raw"
+	case "raw", "line", "length":
+	default:
+		return nil, fmt.Errorf("base: unknown framing %q", cfg.Base.Framing)
+	}
+	if cfg.Base.Network == "udp" && cfg.Base.Framing != "raw" {
+		return nil, errors.New("base: udp datagrams can't be framed")
+	}
+	if cfg.Base.LineEnding == "" {
+		cfg.Base.LineEnding = "\r\n"
+	}
+	switch cfg.Base.LengthSize {
+	case 0:
+		cfg.Base.LengthSize = 4
+	case 1, 2, 4:
+	default:
+		return nil, fmt.Errorf("base: invalid length size %d", cfg.Base.LengthSize)
+	}
+	switch {
+	case cfg.Base.MaxMessageSize == 0:
+		cfg.Base.MaxMessageSize = defaultMaxMessageSize
+	case cfg.Base.MaxMessageSize < 0:
+		return nil, fmt.Errorf("base: negative max message size %d", cfg.Base.MaxMessageSize)
+	}
+	var tlsCfg *tls.Config
+	if cfg.Base.Network != "udp" {
+		tlsCfg = &tls.Config{}
+		if cfg.Base.TLS != nil {
+			var err error
+			if tlsCfg, err = cfg.Base.TLS.ClientConfig(cfg.Root); err != nil {
+				return nil, fmt.Errorf("base: tls: %v", err)
+			}
+		}
+		tlsCfg.InsecureSkipVerify = cfg.Base.Insecure
+	}
+	if len(cfg.Tests) == 0 {
+		return nil, errors.New("a test suite must contain at least one test")
+	}
+	ts := make([]testsuite.Test, len(cfg.Tests))
+	var errs []string
+	for i, testcfg := range cfg.Tests {
+		t, err := testcfg.prepare(cfg, tlsCfg)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("test %q:\n%v", testcfg.Name, err))
+		}
+		ts[i] = t
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "\n"))
+	}
+	return ts, nil
+}
+
+func (t *Test) prepare(cfg *Config, tlsCfg *tls.Config) (*test, error) {
+	test := &test{
+		name:       t.Name,
+		network:    cfg.Base.Network,
+		addr:       cfg.Base.Address,
+		framing:    cfg.Base.Framing,
+		lengthSize: cfg.Base.LengthSize,
+		maxMsgSize: cfg.Base.MaxMessageSize,
+	}
+	if t.Address != "" {
+		test.addr = t.Address
+	}
+	test.description = test.network + "://" + test.addr
+	var errs []string
+	host, _, err := net.SplitHostPort(test.addr)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("- address: %v", err))
+	}
+	if tlsCfg != nil {
+		test.tlsConfig = tlsCfg.Clone()
+		if test.tlsConfig.ServerName == "" {
+			test.tlsConfig.ServerName = host
+		}
+	}
+	if len(t.Steps) == 0 {
+		errs = append(errs, "- steps: at least one step is required")
+	}
+	for i, s := range t.Steps {
+		st, err := s.prepare(cfg)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("- step %d: %v", i, err))
+			continue
+		}
+		test.steps = append(test.steps, st)
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "\n"))
+	}
+	return test, nil
+}
+
+func (s *Step) prepare(cfg *Config) (*step, error) {
+	set := 0
+	for _, ok := range []bool{s.Send != nil, s.Receive != nil, s.StartTLS} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, errors.New("exactly one of send, receive or startTLS must be set")
+	}
+	switch {
+	case s.StartTLS:
+		if cfg.Base.Network != "tcp" {
+			return nil, fmt.Errorf("startTLS is not supported by the %s network", cfg.Base.Network)
+		}
+		return &step{kind: startTLSStep}, nil
+	case s.Send != nil:
+		b, err := s.Send.bytes(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Base.Framing == "length" && cfg.Base.LengthSize < 4 && len(b) >= 1<<uint(8*cfg.Base.LengthSize) {
+			return nil, fmt.Errorf("data too long for a %d bytes length prefix", cfg.Base.LengthSize)
+		}
+		return &step{kind: sendStep, data: cfg.frame(b)}, nil
+	}
+	return s.Receive.prepare(cfg)
+}
+
+func (e *Expect) prepare(cfg *Config) (*step, error) {
+	st := &step{
+		kind:    receiveStep,
+		timeout: time.Duration(cfg.Base.Timeout),
+		prefix:  e.Prefix,
+		skip:    e.Skip,
+	}
+	if e.Timeout != 0 {
+		st.timeout = time.Duration(e.Timeout)
+	}
+	if cfg.Base.Network != "udp" && cfg.Base.Framing == "raw" {
+		if e.Skip {
+			return nil, errors.New("skip requires line or length framing")
+		}
+		if e.Prefix {
+			return nil, errors.New("prefix requires line or length framing")
+		}
+	}
+	if e.Regex != "" {
+		if e.Data != (Data{}) {
+			return nil, errors.New("regex can't be set with text, hex, base64 or $ref")
+		}
+		if e.Prefix {
+			return nil, errors.New("prefix can't be set with regex")
+		}
+		re, err := regexp.Compile(e.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %v", err)
+		}
+		st.regex = re
+		return st, nil
+	}
+	b, err := e.Data.bytes(cfg)
+	if err != nil {
+		return nil, err
+	}
+	st.data = b
+	return st, nil
+}
+
+// bytes returns the described bytes.
+func (d *Data) bytes(cfg *Config) ([]byte, error) {
+	var (
+		b   []byte
+		err error
+		set int
+	)
+	if d.Text != "" {
+		set++
+		b = []byte(d.Text)
+	}
+	if d.Hex != "" {
+		set++
+		if b, err = hex.DecodeString(d.Hex); err != nil {
+			return nil, fmt.Errorf("invalid hex: %v", err)
+		}
+	}
+	if d.Base64 != "" {
+		set++
+		if b, err = base64.StdEncoding.DecodeString(d.Base64); err != nil {
+			return nil, fmt.Errorf("invalid base64: %v", err)
+		}
+	}
+	if d.Ref != "" {
+		set++
+		if b, err = ioutil.ReadFile(cfg.getFilePath(d.Ref)); err != nil {
+			return nil, fmt.Errorf("could not read $ref: %v", err)
+		}
+	}
+	if set != 1 {
+		return nil, errors.New("exactly one of text, hex, base64 or $ref must be set")
+	}
+	return b, nil
+}
+
+// frame returns b framed as a message of the suite.
+func (cfg *Config) frame(b []byte) []byte {
+	switch cfg.Base.Framing {
+	case "line":
+		return append(b, cfg.Base.LineEnding...)
+	case "length":
+		n := cfg.Base.LengthSize
+		f := make([]byte, n, n+len(b))
+		for i := 0; i < n; i++ {
+			f[i] = byte(len(b) >> uint(8*(n-1-i)))
+		}
+		return append(f, b...)
+	}
+	return b
+}
+
+func (cfg *Config) getFilePath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(cfg.Root, path)
+}
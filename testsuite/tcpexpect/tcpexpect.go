@@ -0,0 +1,290 @@
+package tcpexpect
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"time"
+
+	"github.com/blippar/aragorn/plugin"
+	"github.com/blippar/aragorn/testsuite"
+)
+
+var _ testsuite.Suite = (*Suite)(nil)
+
+const (
+	// maxDatagramSize is the size of the buffer receiving UDP datagrams.
+	maxDatagramSize = 64 * 1024
+	// defaultMaxMessageSize bounds the buffer allocated for a length-prefixed
+	// message, whose prefix is not trusted.
+	defaultMaxMessageSize = 1 << 20
+)
+
+// Suite describes a TCP test suite.
+type Suite struct {
+	tests []testsuite.Test
+}
+
+// New returns a Suite.
+func New(cfg *Config) (*Suite, error) {
+	tests, err := cfg.genTests()
+	if err != nil {
+		return nil, err
+	}
+	return &Suite{tests: tests}, nil
+}
+
+func (s *Suite) Tests() []testsuite.Test { return s.tests }
+
+type test struct {
+	name        string
+	description string
+	network     string
+	addr        string
+	tlsConfig   *tls.Config // Nil for udp.
+	framing     string
+	lengthSize  int
+	maxMsgSize  int
+	steps       []*step
+}
+
+type stepKind int
+
+const (
+	sendStep stepKind = iota
+	receiveStep
+	startTLSStep
+)
+
+// step either sends data, receives data matching its expectations or
+// upgrades the connection to TLS.
+type step struct {
+	kind stepKind
+	data []byte // Sent data, already framed, or expected data.
+
+	timeout time.Duration
+	prefix  bool
+	regex   *regexp.Regexp
+	skip    bool
+}
+
+func (t *test) Name() string        { return t.name }
+func (t *test) Description() string { return t.description }
+
+func (t *test) Run(ctx context.Context, l testsuite.Logger) {
+	c, err := t.dial(ctx)
+	if err != nil {
+		l.Errorf("could not connect: %v", err)
+		return
+	}
+	defer c.Close()
+	for i, s := range t.steps {
+		switch s.kind {
+		case sendStep:
+			if err := c.send(ctx, s.data); err != nil {
+				l.Errorf("step %d: could not send data: %v", i, err)
+				return
+			}
+		case startTLSStep:
+			if err := c.startTLS(ctx, t.tlsConfig); err != nil {
+				l.Errorf("step %d: could not start TLS: %v", i, err)
+				return
+			}
+		case receiveStep:
+			if !s.receive(ctx, c, i, l) {
+				return
+			}
+		}
+	}
+}
+
+// conn is a connection reading the messages of the suite's framing.
+type conn struct {
+	net.Conn
+	r          *bufio.Reader
+	framing    string
+	lengthSize int
+	maxMsgSize int // Of the length-prefixed messages.
+	datagrams  bool
+	buf        []byte // Raw data received but not consumed yet.
+}
+
+func (t *test) dial(ctx context.Context) (*conn, error) {
+	network := t.network
+	if network == "tls" {
+		network = "tcp"
+	}
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, network, t.addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &conn{
+		Conn:       nc,
+		r:          bufio.NewReader(nc),
+		framing:    t.framing,
+		lengthSize: t.lengthSize,
+		maxMsgSize: t.maxMsgSize,
+		datagrams:  t.network == "udp",
+	}
+	if t.network == "tls" {
+		if err := c.startTLS(ctx, t.tlsConfig); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *conn) startTLS(ctx context.Context, cfg *tls.Config) error {
+	if c.r.Buffered() > 0 || len(c.buf) > 0 {
+		return fmt.Errorf("unexpected data received before the handshake")
+	}
+	tc := tls.Client(c.Conn, cfg)
+	if err := tc.HandshakeContext(ctx); err != nil {
+		return err
+	}
+	c.Conn = tc
+	c.r = bufio.NewReader(tc)
+	return nil
+}
+
+func (c *conn) send(ctx context.Context, b []byte) error {
+	deadline, _ := ctx.Deadline()
+	c.SetWriteDeadline(deadline)
+	_, err := c.Write(b)
+	return err
+}
+
+// readMessage reads the next line, length-prefixed message or datagram.
+func (c *conn) readMessage() ([]byte, error) {
+	switch {
+	case c.datagrams:
+		b := make([]byte, maxDatagramSize)
+		n, err := c.Read(b)
+		return b[:n], err
+	case c.framing == "line":
+		b, err := c.r.ReadBytes('\n')
+		if err != nil {
+			return nil, err
+		}
+		b = bytes.TrimSuffix(b, []byte("\n"))
+		return bytes.TrimSuffix(b, []byte("\r")), nil
+	}
+	prefix := make([]byte, c.lengthSize)
+	if _, err := io.ReadFull(c.r, prefix); err != nil {
+		return nil, err
+	}
+	var n uint64
+	for _, v := range prefix {
+		n = n<<8 | uint64(v)
+	}
+	if n > uint64(c.maxMsgSize) {
+		return nil, fmt.Errorf("message of %d bytes exceeds maxMessageSize (%d bytes)", n, c.maxMsgSize)
+	}
+	b := make([]byte, n)
+	_, err := io.ReadFull(c.r, b)
+	return b, err
+}
+
+// readRaw appends the next received bytes to c.buf.
+func (c *conn) readRaw() error {
+	b := make([]byte, 4096)
+	n, err := c.r.Read(b)
+	c.buf = append(c.buf, b[:n]...)
+	return err
+}
+
+// receive reads the next message, or the next matching one if s.skip is
+// set, and checks it. Without framing, the expected bytes are the next ones of
+// the stream and a regex consumes the stream up to the end of its match. It
+// returns false if no data could be read.
+func (s *step) receive(ctx context.Context, c *conn, i int, l testsuite.Logger) bool {
+	var deadline time.Time
+	if s.timeout > 0 {
+		deadline = time.Now().Add(s.timeout)
+	}
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+	c.SetReadDeadline(deadline)
+	if c.datagrams || c.framing != "raw" {
+		for {
+			msg, err := c.readMessage()
+			if err != nil {
+				if s.skip {
+					l.Errorf("step %d: no matching data received: %v", i, err)
+				} else {
+					l.Errorf("step %d: could not receive data: %v", i, err)
+				}
+				return false
+			}
+			if s.match(msg) {
+				return true
+			}
+			if !s.skip {
+				l.Errorf("step %d: %s", i, s.mismatch(msg))
+				return true
+			}
+		}
+	}
+	for {
+		if s.regex != nil {
+			if loc := s.regex.FindIndex(c.buf); loc != nil {
+				c.buf = c.buf[loc[1]:]
+				return true
+			}
+		} else if len(c.buf) >= len(s.data) {
+			got := c.buf[:len(s.data)]
+			c.buf = c.buf[len(s.data):]
+			if !bytes.Equal(got, s.data) {
+				l.Errorf("step %d: %s", i, s.mismatch(got))
+			}
+			return true
+		}
+		if err := c.readRaw(); err != nil {
+			l.Errorf("step %d: %s: %v", i, s.mismatch(c.buf), err)
+			return false
+		}
+	}
+}
+
+// match reports whether the message matches the expectations.
+func (s *step) match(msg []byte) bool {
+	switch {
+	case s.regex != nil:
+		return s.regex.Match(msg)
+	case s.prefix:
+		return bytes.HasPrefix(msg, s.data)
+	}
+	return bytes.Equal(msg, s.data)
+}
+
+func (s *step) mismatch(b []byte) string {
+	if s.regex != nil {
+		return fmt.Sprintf("data does not match %q (got %q)", s.regex, b)
+	}
+	if s.prefix {
+		return fmt.Sprintf("wrong data prefix (got %q; want %q)", b, s.data)
+	}
+	return fmt.Sprintf("wrong data (got %q; want %q)", b, s.data)
+}
+
+func init() {
+	plugin.Register(&plugin.Registration{
+		Type:   plugin.TestSuitePlugin,
+		ID:     "TCP",
+		Config: (*Config)(nil),
+		InitFn: func(ctx *plugin.InitContext) (interface{}, error) {
+			cfg := ctx.Config.(*Config)
+			cfg.Path = ctx.Path
+			cfg.Root = ctx.Root
+			return New(cfg)
+		},
+	})
+}
@@ -0,0 +1,274 @@
+package tcpexpect
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/blippar/aragorn/pkg/util/json"
+	"github.com/blippar/aragorn/testsuite/httpexpect"
+)
+
+type mockLogger struct {
+	errs []string
+}
+
+func (tr *mockLogger) Error(args ...interface{}) {
+	tr.errs = append(tr.errs, fmt.Sprint(args...))
+}
+
+func (tr *mockLogger) Errorf(format string, args ...interface{}) {
+	tr.errs = append(tr.errs, fmt.Sprintf(format, args...))
+}
+
+// newLineServer starts a server speaking a line protocol loosely based on
+// SMTP, including the STARTTLS upgrade.
+func newLineServer(t *testing.T) net.Listener {
+	cert, err := tls.LoadX509KeyPair("./testdata/server.pem", "./testdata/server.key")
+	if err != nil {
+		t.Fatalf("could not load server certificate: %v", err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				fmt.Fprint(conn, "220 ready\r\n")
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					switch strings.TrimSpace(line) {
+					case "EHLO aragorn":
+						fmt.Fprint(conn, "250-hello aragorn\r\n250-STARTTLS\r\n250 OK\r\n")
+					case "STARTTLS":
+						fmt.Fprint(conn, "220 go ahead\r\n")
+						tc := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{cert}})
+						if err := tc.Handshake(); err != nil {
+							return
+						}
+						conn, r = tc, bufio.NewReader(tc)
+					case "QUIT":
+						fmt.Fprint(conn, "221 bye\r\n")
+						return
+					default:
+						fmt.Fprint(conn, "500 unknown command\r\n")
+					}
+				}
+			}(conn)
+		}
+	}()
+	return l
+}
+
+// newLengthServer starts a server echoing the messages prefixed by their
+// 2 bytes length.
+func newLengthServer(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(conn, conn)
+		}
+	}()
+	return l
+}
+
+// newUDPServer starts a server echoing the datagrams.
+func newUDPServer(t *testing.T) net.PacketConn {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	go func() {
+		b := make([]byte, 1024)
+		for {
+			n, addr, err := pc.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			pc.WriteTo(b[:n], addr)
+		}
+	}()
+	return pc
+}
+
+func TestSuiteRunTest(t *testing.T) {
+	ll := newLineServer(t)
+	defer ll.Close()
+	lengthl := newLengthServer(t)
+	defer lengthl.Close()
+	pc := newUDPServer(t)
+	defer pc.Close()
+	line := Base{
+		Address: ll.Addr().String(),
+		Framing: "line",
+		TLS:     &httpexpect.TLSConfig{CAPath: "ca.pem"},
+		Timeout: json.Duration(time.Second),
+	}
+	tt := []struct {
+		name  string
+		base  Base
+		steps []Step
+		errs  []string
+	}{
+		{
+			name: "line",
+			base: line,
+			steps: []Step{
+				{Receive: &Expect{Regex: "^220 "}},
+				{Send: &Data{Text: "EHLO aragorn"}},
+				{Receive: &Expect{Data: Data{Text: "250-hello"}, Prefix: true}},
+				{Receive: &Expect{Data: Data{Text: "250 OK"}, Skip: true}},
+				{Send: &Data{Text: "STARTTLS"}},
+				{Receive: &Expect{Data: Data{Text: "220 go ahead"}}},
+				{StartTLS: true},
+				{Send: &Data{Text: "QUIT"}},
+				{Receive: &Expect{Data: Data{Text: "221 bye"}}},
+			},
+		},
+		{
+			name: "line errors",
+			base: line,
+			steps: []Step{
+				{Receive: &Expect{Regex: "^250 "}},
+				{Send: &Data{Hex: "4e4f4f500d0a"}},
+				{Receive: &Expect{Data: Data{Text: "250 OK"}}},
+				{Receive: &Expect{Regex: "^250 ", Skip: true, Timeout: json.Duration(50 * time.Millisecond)}},
+			},
+			errs: []string{
+				`step 0: data does not match "^250 " (got "220 ready")`,
+				`step 2: wrong data (got "500 unknown command"; want "250 OK")`,
+				"step 3: no matching data received: read tcp " + "%s",
+			},
+		},
+		{
+			name: "raw",
+			base: Base{Address: ll.Addr().String(), Timeout: json.Duration(time.Second)},
+			steps: []Step{
+				{Receive: &Expect{Data: Data{Text: "220 "}}},
+				{Receive: &Expect{Data: Data{Base64: "cmVhZHkNCg=="}}},
+				{Send: &Data{Ref: "ehlo.txt"}},
+				{Receive: &Expect{Regex: "STARTTLS\r\n"}},
+				{Receive: &Expect{Data: Data{Text: "250 KO\r\n"}}},
+			},
+			errs: []string{`step 4: wrong data (got "250 OK\r\n"; want "250 KO\r\n")`},
+		},
+		{
+			name: "length",
+			base: Base{Address: lengthl.Addr().String(), Framing: "length", LengthSize: 2},
+			steps: []Step{
+				{Send: &Data{Text: "hello"}},
+				{Receive: &Expect{Data: Data{Text: "hello"}}},
+				{Send: &Data{Hex: "000102"}},
+				{Receive: &Expect{Data: Data{Hex: "0001"}, Prefix: true}},
+			},
+		},
+		{
+			name: "length too long",
+			base: Base{Address: lengthl.Addr().String(), Framing: "length", LengthSize: 2, MaxMessageSize: 4},
+			steps: []Step{
+				{Send: &Data{Text: "hello"}},
+				{Receive: &Expect{Data: Data{Text: "hello"}}},
+			},
+			errs: []string{"step 1: could not receive data: message of 5 bytes exceeds maxMessageSize (4 bytes)"},
+		},
+		{
+			name: "udp",
+			base: Base{Address: pc.LocalAddr().String(), Network: "udp"},
+			steps: []Step{
+				{Send: &Data{Text: "ping"}},
+				{Receive: &Expect{Data: Data{Text: "ping"}}},
+				{Send: &Data{Text: "pong"}},
+				{Receive: &Expect{Regex: "^pi"}},
+			},
+			errs: []string{`step 3: data does not match "^pi" (got "pong")`},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &Config{
+				Root:  "testdata",
+				Base:  tc.base,
+				Tests: []*Test{{Name: tc.name, Steps: tc.steps}},
+			}
+			suite, err := New(cfg)
+			if err != nil {
+				t.Fatalf("can't create suite: %v", err)
+			}
+			l := &mockLogger{}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			suite.Tests()[0].Run(ctx, l)
+			if len(l.errs) != len(tc.errs) {
+				t.Fatalf("wrong errors (got %q; want %q)", l.errs, tc.errs)
+			}
+			for i, err := range tc.errs {
+				if prefix := strings.TrimSuffix(err, "%s"); prefix != err {
+					if !strings.HasPrefix(l.errs[i], prefix) {
+						t.Errorf("wrong error %d (got %q; want prefix %q)", i, l.errs[i], prefix)
+					}
+				} else if l.errs[i] != err {
+					t.Errorf("wrong error %d (got %q; want %q)", i, l.errs[i], err)
+				}
+			}
+		})
+	}
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	cfg := &Config{
+		Base: Base{Address: "localhost", Framing: "length", LengthSize: 1},
+		Tests: []*Test{
+			{
+				Name: "test",
+				Steps: []Step{
+					{},
+					{Send: &Data{Text: "a", Hex: "61"}},
+					{Send: &Data{Text: strings.Repeat("a", 256)}},
+					{Receive: &Expect{Data: Data{Text: "a"}, Regex: "a"}},
+					{Receive: &Expect{Regex: "("}},
+					{StartTLS: true, Send: &Data{Text: "a"}},
+				},
+			},
+		},
+	}
+	want := "test \"test\":\n" +
+		"- address: address localhost: missing port in address\n" +
+		"- step 0: exactly one of send, receive or startTLS must be set\n" +
+		"- step 1: exactly one of text, hex, base64 or $ref must be set\n" +
+		"- step 2: data too long for a 1 bytes length prefix\n" +
+		"- step 3: regex can't be set with text, hex, base64 or $ref\n" +
+		"- step 4: invalid regex: error parsing regexp: missing closing ): `(`\n" +
+		"- step 5: exactly one of send, receive or startTLS must be set"
+	_, err := New(cfg)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if diff := cmp.Diff(want, err.Error()); diff != "" {
+		t.Fatalf("wrong error (-want +got):\n%s", diff)
+	}
+}
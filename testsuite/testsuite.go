@@ -1,6 +1,9 @@
 package testsuite
 
-import "context"
+import (
+	"context"
+	"net"
+)
 
 type Suite interface {
 	Tests() []Test
@@ -12,6 +15,29 @@ type Test interface {
 	Run(context.Context, Logger)
 }
 
+// A Resetter is implemented by the suites keeping a state between their tests,
+// such as cookies, that must be reset before each run.
+type Resetter interface {
+	Reset()
+}
+
+// A Closer is implemented by the suites holding resources, such as
+// connections, to release once the suite is no longer run.
+type Closer interface {
+	Close() error
+}
+
+// Mocker is implemented by the suites able to mock the tested service by
+// answering the requests of their tests with the expected responses.
+type Mocker interface {
+	Mock() (MockServer, error)
+}
+
+// A MockServer serves a mock of a tested service.
+type MockServer interface {
+	Serve(l net.Listener) error
+}
+
 type Logger interface {
 	Error(args ...interface{})
 	Errorf(format string, args ...interface{})
@@ -0,0 +1,166 @@
+package tlsexpect
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/blippar/aragorn/pkg/util/json"
+	"github.com/blippar/aragorn/testsuite"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+type Config struct {
+	Path   string       `json:"path,omitempty"`
+	Root   string       `json:"root,omitempty"`
+	CAPath string       `json:"caPath,omitempty"` // PEM bundle of the CAs trusted instead of the system ones.
+	Expect ExpectConfig `json:"expect,omitempty"` // Expectations of every test, each test can overwrite them.
+	Tests  []TestConfig `json:"tests,omitempty"`
+}
+
+type TestConfig struct {
+	Name       string       `json:"name,omitempty"`
+	Address    string       `json:"address,omitempty"`    // host:port of the endpoint.
+	ServerName string       `json:"serverName,omitempty"` // Name sent for SNI and verified (default: the address host).
+	Expect     ExpectConfig `json:"expect,omitempty"`
+}
+
+type ExpectConfig struct {
+	MinValidity  json.Duration `json:"minValidity,omitempty"`  // Minimum remaining validity of the certificates.
+	SkipVerify   bool          `json:"skipVerify,omitempty"`   // Do not verify the chain and the hostname.
+	Versions     []string      `json:"versions,omitempty"`     // Allowed protocol versions: 1.0, 1.1, 1.2 or 1.3.
+	CipherSuites []string      `json:"cipherSuites,omitempty"` // Allowed cipher suites (e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256).
+	OCSPStapling bool          `json:"ocspStapling,omitempty"` // An OCSP response must be stapled.
+}
+
+func (*Config) Example() interface{} {
+	return &Config{
+		Expect: ExpectConfig{
+			MinValidity: json.Duration(14 * 24 * time.Hour),
+			Versions:    []string{"1.2", "1.3"},
+		},
+		Tests: []TestConfig{
+			{
+				Name:    "Example",
+				Address: "example.com:443",
+			},
+		},
+	}
+}
+
+func (cfg *Config) genTests() ([]testsuite.Test, error) {
+	if len(cfg.Tests) == 0 {
+		return nil, errors.New("a test suite must contain at least one test")
+	}
+	var roots *x509.CertPool
+	if cfg.CAPath != "" {
+		b, err := ioutil.ReadFile(cfg.getFilePath(cfg.CAPath))
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA file: %v", err)
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(b) {
+			return nil, errors.New("could not append CA certificates")
+		}
+	}
+	tests := make([]testsuite.Test, len(cfg.Tests))
+	var errs []string
+	for i, tcfg := range cfg.Tests {
+		t, err := tcfg.prepare(cfg, roots)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("test %q:\n%v", tcfg.Name, err))
+		}
+		tests[i] = t
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "\n"))
+	}
+	return tests, nil
+}
+
+func (tcfg *TestConfig) prepare(cfg *Config, roots *x509.CertPool) (*test, error) {
+	e := cfg.Expect.merge(tcfg.Expect)
+	t := &test{
+		name:         tcfg.Name,
+		description:  "tls://" + tcfg.Address,
+		addr:         tcfg.Address,
+		serverName:   tcfg.ServerName,
+		roots:        roots,
+		minValidity:  time.Duration(e.MinValidity),
+		skipVerify:   e.SkipVerify,
+		ocspStapling: e.OCSPStapling,
+	}
+	var errs []string
+	host, _, err := net.SplitHostPort(tcfg.Address)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("- address: %v", err))
+	}
+	if t.serverName == "" {
+		t.serverName = host
+	}
+	if e.Versions != nil {
+		t.versions = make(map[uint16]bool)
+		for _, v := range e.Versions {
+			id, ok := tlsVersions[v]
+			if !ok {
+				errs = append(errs, fmt.Sprintf("- expect: unknown TLS version %q", v))
+				continue
+			}
+			t.versions[id] = true
+		}
+	}
+	if e.CipherSuites != nil {
+		t.cipherSuites = make(map[uint16]bool)
+		for _, name := range e.CipherSuites {
+			id, ok := cipherSuiteIDs[name]
+			if !ok {
+				errs = append(errs, fmt.Sprintf("- expect: unknown cipher suite %q", name))
+				continue
+			}
+			t.cipherSuites[id] = true
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "\n"))
+	}
+	return t, nil
+}
+
+// merge returns the expectations e overwritten by the ones set in o.
+func (e ExpectConfig) merge(o ExpectConfig) ExpectConfig {
+	if o.MinValidity != 0 {
+		e.MinValidity = o.MinValidity
+	}
+	if o.SkipVerify {
+		e.SkipVerify = true
+	}
+	if o.Versions != nil {
+		e.Versions = o.Versions
+	}
+	if o.CipherSuites != nil {
+		e.CipherSuites = o.CipherSuites
+	}
+	if o.OCSPStapling {
+		e.OCSPStapling = true
+	}
+	return e
+}
+
+func (cfg *Config) getFilePath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(cfg.Root, path)
+}
@@ -0,0 +1,205 @@
+package tlsexpect
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"time"
+
+	"github.com/blippar/aragorn/plugin"
+	"github.com/blippar/aragorn/testsuite"
+)
+
+var _ testsuite.Suite = (*Suite)(nil)
+
+// cipherSuiteIDs maps the names of the cipher suites implemented by
+// crypto/tls, secure or not, to their IDs.
+var cipherSuiteIDs = make(map[string]uint16)
+
+// versionNames lists the names of tlsVersions in order.
+var versionNames = []string{"1.0", "1.1", "1.2", "1.3"}
+
+// Suite describes a TLS test suite.
+type Suite struct {
+	tests []testsuite.Test
+}
+
+// New returns a Suite.
+func New(cfg *Config) (*Suite, error) {
+	tests, err := cfg.genTests()
+	if err != nil {
+		return nil, err
+	}
+	return &Suite{tests: tests}, nil
+}
+
+func (s *Suite) Tests() []testsuite.Test { return s.tests }
+
+type test struct {
+	name        string
+	description string
+	addr        string
+	serverName  string
+	roots       *x509.CertPool // System roots if nil.
+
+	minValidity  time.Duration
+	skipVerify   bool
+	versions     map[uint16]bool // Any version is allowed if nil.
+	cipherSuites map[uint16]bool // Any cipher suite is allowed if nil.
+	ocspStapling bool
+}
+
+func (t *test) Name() string        { return t.name }
+func (t *test) Description() string { return t.description }
+
+func (t *test) Run(ctx context.Context, l testsuite.Logger) {
+	state, err := t.handshake(ctx, 0, nil)
+	if err != nil {
+		l.Errorf("could not establish TLS connection: %v", err)
+		return
+	}
+	if len(state.PeerCertificates) == 0 {
+		l.Error("no certificate presented by the server")
+		return
+	}
+	if !t.skipVerify {
+		t.checkChain(state.PeerCertificates, l)
+	}
+	if t.minValidity > 0 {
+		t.checkValidity(state.PeerCertificates, l)
+	}
+	if t.ocspStapling && len(state.OCSPResponse) == 0 {
+		l.Error("no OCSP response stapled")
+	}
+	if t.versions != nil {
+		t.checkVersions(ctx, state.Version, l)
+	}
+	if t.cipherSuites != nil {
+		t.checkCipherSuites(ctx, state.CipherSuite, l)
+	}
+}
+
+// handshake connects to the endpoint and returns the state of the TLS
+// connection. When version is not 0, only this version is offered and when
+// cipherSuites is not nil, only these cipher suites are offered.
+func (t *test) handshake(ctx context.Context, version uint16, cipherSuites []uint16) (*tls.ConnectionState, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", t.addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	cfg := &tls.Config{
+		ServerName:         t.serverName,
+		InsecureSkipVerify: true, // The chain is verified by checkChain.
+		MinVersion:         tls.VersionTLS10,
+		MaxVersion:         tls.VersionTLS13,
+		CipherSuites:       cipherSuites,
+	}
+	if cipherSuites == nil {
+		cfg.CipherSuites = allCipherSuites()
+	}
+	if version != 0 {
+		cfg.MinVersion, cfg.MaxVersion = version, version
+	}
+	tc := tls.Client(conn, cfg)
+	if err := tc.Handshake(); err != nil {
+		return nil, err
+	}
+	state := tc.ConnectionState()
+	return &state, nil
+}
+
+func (t *test) checkChain(certs []*x509.Certificate, l testsuite.Logger) {
+	opts := x509.VerifyOptions{
+		DNSName:       t.serverName,
+		Roots:         t.roots,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	if _, err := certs[0].Verify(opts); err != nil {
+		l.Errorf("invalid certificate chain: %v", err)
+	}
+}
+
+func (t *test) checkValidity(certs []*x509.Certificate, l testsuite.Logger) {
+	now := time.Now()
+	for _, cert := range certs {
+		if left := cert.NotAfter.Sub(now); left < t.minValidity {
+			l.Errorf("certificate %q expires too soon (got %v; want at least %v)",
+				cert.Subject.CommonName, left.Truncate(time.Second), t.minValidity)
+		}
+	}
+}
+
+func (t *test) checkVersions(ctx context.Context, negotiated uint16, l testsuite.Logger) {
+	for _, name := range versionNames {
+		v := tlsVersions[name]
+		if t.versions[v] {
+			continue
+		}
+		if v == negotiated {
+			l.Errorf("TLS %s negotiated but not allowed", name)
+			continue
+		}
+		if _, err := t.handshake(ctx, v, nil); err == nil {
+			l.Errorf("TLS %s supported but not allowed", name)
+		}
+	}
+}
+
+func (t *test) checkCipherSuites(ctx context.Context, negotiated uint16, l testsuite.Logger) {
+	if !t.cipherSuites[negotiated] {
+		l.Errorf("cipher suite %s negotiated but not allowed", tls.CipherSuiteName(negotiated))
+	}
+	// The TLS 1.3 cipher suites can not be chosen by the client, only the
+	// ones of the previous versions are probed.
+	for _, cs := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if t.cipherSuites[cs.ID] || cs.ID == negotiated || !supportsTLS12(cs) {
+			continue
+		}
+		if _, err := t.handshake(ctx, tls.VersionTLS12, []uint16{cs.ID}); err == nil {
+			l.Errorf("cipher suite %s supported but not allowed", cs.Name)
+		}
+	}
+}
+
+func allCipherSuites() []uint16 {
+	var ids []uint16
+	for _, cs := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		ids = append(ids, cs.ID)
+	}
+	return ids
+}
+
+func supportsTLS12(cs *tls.CipherSuite) bool {
+	for _, v := range cs.SupportedVersions {
+		if v == tls.VersionTLS12 {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	for _, cs := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		cipherSuiteIDs[cs.Name] = cs.ID
+	}
+	plugin.Register(&plugin.Registration{
+		Type:   plugin.TestSuitePlugin,
+		ID:     "TLS",
+		Config: (*Config)(nil),
+		InitFn: func(ctx *plugin.InitContext) (interface{}, error) {
+			cfg := ctx.Config.(*Config)
+			cfg.Path = ctx.Path
+			cfg.Root = ctx.Root
+			return New(cfg)
+		},
+	})
+}
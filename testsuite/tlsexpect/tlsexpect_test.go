@@ -0,0 +1,171 @@
+package tlsexpect
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blippar/aragorn/pkg/util/json"
+)
+
+type mockLogger struct {
+	errs []string
+}
+
+func (tr *mockLogger) Error(args ...interface{}) {
+	tr.errs = append(tr.errs, fmt.Sprint(args...))
+}
+
+func (tr *mockLogger) Errorf(format string, args ...interface{}) {
+	tr.errs = append(tr.errs, fmt.Sprintf(format, args...))
+}
+
+// newServer starts a TLS server completing the handshakes then closing the
+// connections.
+func newServer(t *testing.T) net.Listener {
+	cert, err := tls.LoadX509KeyPair("./testdata/server.pem", "./testdata/server.key")
+	if err != nil {
+		t.Fatalf("could not load server certificate: %v", err)
+	}
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		},
+	})
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+	return l
+}
+
+func TestSuiteRunTest(t *testing.T) {
+	l := newServer(t)
+	defer l.Close()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	addr := "localhost:" + port
+	tt := []struct {
+		name   string
+		caPath string
+		test   TestConfig
+		errs   []string // Prefixes of the expected errors.
+	}{
+		{
+			name:   "valid",
+			caPath: "ca.pem",
+			test: TestConfig{Expect: ExpectConfig{
+				MinValidity: json.Duration(14 * 24 * time.Hour),
+				Versions:    []string{"1.2", "1.3"},
+				CipherSuites: []string{
+					"TLS_AES_128_GCM_SHA256",
+					"TLS_AES_256_GCM_SHA384",
+					"TLS_CHACHA20_POLY1305_SHA256",
+					"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+					"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+				},
+			}},
+		},
+		{
+			name: "unknown CA",
+			errs: []string{"invalid certificate chain: "},
+		},
+		{
+			name:   "wrong hostname",
+			caPath: "ca.pem",
+			test:   TestConfig{ServerName: "example.com"},
+			errs:   []string{"invalid certificate chain: "},
+		},
+		{
+			name: "skip verify",
+			test: TestConfig{ServerName: "example.com", Expect: ExpectConfig{SkipVerify: true}},
+		},
+		{
+			name:   "expiry",
+			caPath: "ca.pem",
+			test:   TestConfig{Expect: ExpectConfig{MinValidity: json.Duration(200 * 365 * 24 * time.Hour)}},
+			errs: []string{
+				`certificate "localhost" expires too soon (got `,
+			},
+		},
+		{
+			name:   "versions",
+			caPath: "ca.pem",
+			test:   TestConfig{Expect: ExpectConfig{Versions: []string{"1.3"}}},
+			errs:   []string{"TLS 1.2 supported but not allowed"},
+		},
+		{
+			name:   "cipher suites",
+			caPath: "ca.pem",
+			test: TestConfig{Expect: ExpectConfig{
+				CipherSuites: []string{
+					"TLS_AES_128_GCM_SHA256",
+					"TLS_AES_256_GCM_SHA384",
+					"TLS_CHACHA20_POLY1305_SHA256",
+					"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+				},
+			}},
+			errs: []string{"cipher suite TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384 supported but not allowed"},
+		},
+		{
+			name:   "OCSP stapling",
+			caPath: "ca.pem",
+			test:   TestConfig{Expect: ExpectConfig{OCSPStapling: true}},
+			errs:   []string{"no OCSP response stapled"},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test.Name = tc.name
+			tc.test.Address = addr
+			cfg := &Config{Root: "./testdata/", CAPath: tc.caPath, Tests: []TestConfig{tc.test}}
+			suite, err := New(cfg)
+			if err != nil {
+				t.Fatalf("can't create suite: %v", err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			l := &mockLogger{}
+			suite.Tests()[0].Run(ctx, l)
+			if len(l.errs) != len(tc.errs) {
+				t.Fatalf("wrong errors (got %q; want %q)", l.errs, tc.errs)
+			}
+			for i, err := range l.errs {
+				if !strings.HasPrefix(err, tc.errs[i]) {
+					t.Errorf("wrong error (got %q; want prefix %q)", err, tc.errs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	cfg := &Config{
+		Expect: ExpectConfig{Versions: []string{"1.4"}},
+		Tests: []TestConfig{
+			{Name: "test", Address: "localhost", Expect: ExpectConfig{CipherSuites: []string{"TLS_FOO"}}},
+		},
+	}
+	want := "test \"test\":\n" +
+		"- address: address localhost: missing port in address\n" +
+		"- expect: unknown TLS version \"1.4\"\n" +
+		"- expect: unknown cipher suite \"TLS_FOO\""
+	if _, err := New(cfg); err == nil || err.Error() != want {
+		t.Fatalf("wrong error (got %v; want %v)", err, want)
+	}
+}
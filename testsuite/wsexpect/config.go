@@ -0,0 +1,258 @@
+package wsexpect
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/oauth2/clientcredentials"
+
+	"github.com/blippar/aragorn/pkg/util/json"
+	"github.com/blippar/aragorn/testsuite"
+	"github.com/blippar/aragorn/testsuite/httpexpect"
+)
+
+type Config struct {
+	Path  string  `json:"path,omitempty"`
+	Root  string  `json:"root,omitempty"`
+	Base  Base    `json:"base,omitempty"`
+	Tests []*Test `json:"tests,omitempty"`
+}
+
+type Base struct {
+	URL      string                    `json:"url,omitempty"`    // Base ws:// or wss:// URL prepended to all tests' path.
+	Header   testsuite.Header          `json:"header,omitempty"` // Base set of headers added to all handshakes.
+	OAUTH2   *clientcredentials.Config `json:"oauth2,omitempty"`
+	Insecure bool                      `json:"insecure,omitempty"`
+	TLS      *httpexpect.TLSConfig     `json:"tls,omitempty"`
+	Proxy    string                    `json:"proxy,omitempty"`   // URL of an HTTP proxy.
+	Timeout  json.Duration             `json:"timeout,omitempty"` // Default time waiting for each expected message.
+}
+
+type Test struct {
+	Name   string           `json:"name,omitempty"`
+	URL    string           `json:"url,omitempty"` // If set, will overwrite the base URL.
+	Path   string           `json:"path,omitempty"`
+	Header testsuite.Header `json:"header,omitempty"`
+	Steps  []Step           `json:"steps,omitempty"` // Messages sent and received in order.
+}
+
+// A Step either sends or receives a message.
+type Step struct {
+	Send    *Message `json:"send,omitempty"`
+	Receive *Expect  `json:"receive,omitempty"`
+}
+
+// Message describes a sent message. Only one of its fields must be set.
+type Message struct {
+	Text   string      `json:"text,omitempty"`
+	JSON   interface{} `json:"json,omitempty"`   // Sent as a text message.
+	Binary string      `json:"binary,omitempty"` // Base64 encoded content.
+}
+
+// Expect describes an expected message.
+type Expect struct {
+	Type       string                 `json:"type,omitempty"`       // text or binary (default: any).
+	Document   interface{}            `json:"document,omitempty"`   // Exact document to match.
+	JSONValues map[string]interface{} `json:"jsonValues,omitempty"` // Required JSON values. Exclusive with Document.
+	Timeout    json.Duration          `json:"timeout,omitempty"`    // If set, will overwrite the base timeout.
+	Skip       bool                   `json:"skip,omitempty"`       // Skip the messages not matching until the timeout.
+}
+
+func (*Config) Example() interface{} {
+	return &Config{
+		Base: Base{
+			URL:     "ws://localhost:8000",
+			Timeout: json.Duration(5 * time.Second),
+		},
+		Tests: []*Test{
+			{
+				Name: "Subscribe",
+				Path: "/notifications",
+				Steps: []Step{
+					{Send: &Message{JSON: map[string]interface{}{"subscribe": "news"}}},
+					{Receive: &Expect{JSONValues: map[string]interface{}{"subscribed": true}}},
+				},
+			},
+		},
+	}
+}
+
+func (cfg *Config) genTests(d *dialer) ([]testsuite.Test, error) {
+	if cfg.Base.URL == "" {
+		return nil, errors.New("base: URL is required")
+	}
+	if _, err := url.Parse(cfg.Base.URL); err != nil {
+		return nil, fmt.Errorf("base: URL is invalid: %v", err)
+	}
+	if len(cfg.Tests) == 0 {
+		return nil, errors.New("a test suite must contain at least one test")
+	}
+	ts := make([]testsuite.Test, len(cfg.Tests))
+	var errs []string
+	for i, testcfg := range cfg.Tests {
+		t, err := testcfg.prepare(cfg, d)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("test %q:\n%v", testcfg.Name, err))
+		}
+		ts[i] = t
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "\n"))
+	}
+	return ts, nil
+}
+
+func (t *Test) prepare(cfg *Config, d *dialer) (*test, error) {
+	test := &test{
+		name:   t.Name,
+		dialer: d,
+		header: testsuite.MergeHeaders(cfg.Base.Header, t.Header),
+	}
+	var errs []string
+	rawURL := cfg.Base.URL
+	if t.URL != "" {
+		rawURL = t.URL
+	}
+	if u, err := url.Parse(rawURL + t.Path); err != nil {
+		errs = append(errs, fmt.Sprintf("- url: %v", err))
+	} else if u.Scheme != "ws" && u.Scheme != "wss" {
+		errs = append(errs, fmt.Sprintf("- url: unsupported scheme %q", u.Scheme))
+	} else {
+		test.url = u.String()
+		test.description = test.url
+	}
+	if len(t.Steps) == 0 {
+		errs = append(errs, "- steps: at least one step is required")
+	}
+	for i, s := range t.Steps {
+		st, err := s.prepare(cfg)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("- step %d: %v", i, err))
+			continue
+		}
+		test.steps = append(test.steps, st)
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "\n"))
+	}
+	return test, nil
+}
+
+func (s *Step) prepare(cfg *Config) (*step, error) {
+	if (s.Send == nil) == (s.Receive == nil) {
+		return nil, errors.New("exactly one of send or receive must be set")
+	}
+	if s.Send != nil {
+		return s.Send.prepare(cfg)
+	}
+	return s.Receive.prepare(cfg)
+}
+
+func (m *Message) prepare(cfg *Config) (*step, error) {
+	set := 0
+	st := &step{send: true}
+	if m.Text != "" {
+		set++
+		st.msgType, st.data = websocket.TextMessage, []byte(m.Text)
+	}
+	if m.JSON != nil {
+		set++
+		doc, err := cfg.getDocumentField(m.JSON)
+		if err != nil {
+			return nil, fmt.Errorf("could not get JSON: %v", err)
+		}
+		st.msgType = websocket.TextMessage
+		if raw, ok := doc.([]byte); ok {
+			st.data = raw
+		} else if st.data, err = json.Marshal(doc); err != nil {
+			return nil, fmt.Errorf("could not encode JSON: %v", err)
+		}
+	}
+	if m.Binary != "" {
+		set++
+		b, err := base64.StdEncoding.DecodeString(m.Binary)
+		if err != nil {
+			return nil, fmt.Errorf("invalid binary: %v", err)
+		}
+		st.msgType, st.data = websocket.BinaryMessage, b
+	}
+	if set != 1 {
+		return nil, errors.New("exactly one of text, json or binary must be set")
+	}
+	return st, nil
+}
+
+func (e *Expect) prepare(cfg *Config) (*step, error) {
+	st := &step{
+		timeout: time.Duration(cfg.Base.Timeout),
+		skip:    e.Skip,
+	}
+	if e.Timeout != 0 {
+		st.timeout = time.Duration(e.Timeout)
+	}
+	switch e.Type {
+	case "":
+	case "text":
+		st.msgType = websocket.TextMessage
+	case "binary":
+		st.msgType = websocket.BinaryMessage
+	default:
+		return nil, fmt.Errorf("unknown message type %q", e.Type)
+	}
+	if e.Document != nil && e.JSONValues != nil {
+		return nil, errors.New("jsonValues can't be set with document")
+	}
+	if e.Document != nil {
+		doc, err := cfg.getDocumentField(e.Document)
+		if err != nil {
+			return nil, fmt.Errorf("could not get document: %v", err)
+		}
+		st.document = doc
+	}
+	st.jsonValues = e.JSONValues
+	return st, nil
+}
+
+// getDocumentField loads the document v. Like in HTTP suites, {"$raw": "..."}
+// is a raw document and {"$ref": "path"} loads the document from a file, raw
+// if "$raw" is true.
+func (cfg *Config) getDocumentField(v interface{}) (interface{}, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v, nil
+	}
+	if raw, ok := m["$raw"].(string); ok {
+		return []byte(raw), nil
+	}
+	ref, ok := m["$ref"].(string)
+	if !ok {
+		return v, nil
+	}
+	path := cfg.getFilePath(ref)
+	if raw, _ := m["$raw"].(bool); raw {
+		return ioutil.ReadFile(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var newVal interface{}
+	err = json.Decode(f, &newVal)
+	return newVal, err
+}
+
+func (cfg *Config) getFilePath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(cfg.Root, path)
+}
@@ -0,0 +1,248 @@
+package wsexpect
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/websocket"
+	"golang.org/x/oauth2"
+
+	"github.com/blippar/aragorn/pkg/util/json"
+	"github.com/blippar/aragorn/plugin"
+	"github.com/blippar/aragorn/testsuite"
+)
+
+var _ testsuite.Suite = (*Suite)(nil)
+
+// Suite describes a WebSocket test suite.
+type Suite struct {
+	tests []testsuite.Test
+}
+
+// New returns a Suite.
+func New(cfg *Config) (*Suite, error) {
+	d, err := cfg.newDialer()
+	if err != nil {
+		return nil, err
+	}
+	tests, err := cfg.genTests(d)
+	if err != nil {
+		return nil, err
+	}
+	return &Suite{tests: tests}, nil
+}
+
+func (s *Suite) Tests() []testsuite.Test { return s.tests }
+
+// dialer opens the connections of the tests.
+type dialer struct {
+	ws     websocket.Dialer
+	tokens oauth2.TokenSource // Authenticates the handshakes, if set.
+}
+
+func (cfg *Config) newDialer() (*dialer, error) {
+	d := &dialer{ws: websocket.Dialer{Proxy: http.ProxyFromEnvironment}}
+	if cfg.Base.Proxy != "" {
+		u, err := url.Parse(cfg.Base.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("base: proxy: invalid URL: %v", err)
+		}
+		if u.Scheme != "http" {
+			return nil, fmt.Errorf("base: proxy: unsupported scheme %q", u.Scheme)
+		}
+		d.ws.Proxy = http.ProxyURL(u)
+	}
+	tlsCfg := &tls.Config{}
+	if cfg.Base.TLS != nil {
+		var err error
+		if tlsCfg, err = cfg.Base.TLS.ClientConfig(cfg.Root); err != nil {
+			return nil, fmt.Errorf("base: tls: %v", err)
+		}
+	}
+	tlsCfg.InsecureSkipVerify = cfg.Base.Insecure
+	d.ws.TLSClientConfig = tlsCfg
+	if cfg.Base.OAUTH2 != nil {
+		client := &http.Client{Transport: &http.Transport{Proxy: d.ws.Proxy, TLSClientConfig: tlsCfg}}
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, client)
+		d.tokens = cfg.Base.OAUTH2.TokenSource(ctx)
+	}
+	return d, nil
+}
+
+func (d *dialer) dial(ctx context.Context, url string, header testsuite.Header) (*websocket.Conn, error) {
+	h := make(http.Header)
+	for k, v := range header {
+		h.Set(k, v)
+	}
+	if d.tokens != nil {
+		tok, err := d.tokens.Token()
+		if err != nil {
+			return nil, fmt.Errorf("could not get OAuth2 token: %v", err)
+		}
+		h.Set("Authorization", tok.Type()+" "+tok.AccessToken)
+	}
+	wd := d.ws
+	wd.NetDial = func(network, addr string) (net.Conn, error) {
+		var nd net.Dialer
+		return nd.DialContext(ctx, network, addr)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		wd.HandshakeTimeout = time.Until(deadline)
+	}
+	conn, resp, err := wd.Dial(url, h)
+	if err == websocket.ErrBadHandshake && resp != nil {
+		return nil, fmt.Errorf("%v (status code %d)", err, resp.StatusCode)
+	}
+	return conn, err
+}
+
+type test struct {
+	name        string
+	description string
+	url         string
+	header      testsuite.Header
+	dialer      *dialer
+	steps       []*step
+}
+
+// step either sends data or receives a message matching its expectations.
+type step struct {
+	send    bool
+	msgType int // Any type is expected if 0.
+	data    []byte
+
+	timeout    time.Duration
+	skip       bool
+	document   interface{}
+	jsonValues map[string]interface{}
+}
+
+func (t *test) Name() string        { return t.name }
+func (t *test) Description() string { return t.description }
+
+func (t *test) Run(ctx context.Context, l testsuite.Logger) {
+	conn, err := t.dialer.dial(ctx, t.url, t.header)
+	if err != nil {
+		l.Errorf("could not connect: %v", err)
+		return
+	}
+	defer conn.Close()
+	for i, s := range t.steps {
+		if s.send {
+			if err := conn.WriteMessage(s.msgType, s.data); err != nil {
+				l.Errorf("step %d: could not send message: %v", i, err)
+				return
+			}
+			continue
+		}
+		if !s.receive(ctx, conn, i, l) {
+			return
+		}
+	}
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+}
+
+// receive reads the next message, or the next matching one if s.skip is
+// set, and checks it. It returns false if no message could be read.
+func (s *step) receive(ctx context.Context, conn *websocket.Conn, i int, l testsuite.Logger) bool {
+	var deadline time.Time
+	if s.timeout > 0 {
+		deadline = time.Now().Add(s.timeout)
+	}
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+	conn.SetReadDeadline(deadline)
+	for {
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			if s.skip {
+				l.Errorf("step %d: no matching message received: %v", i, err)
+			} else {
+				l.Errorf("step %d: could not receive message: %v", i, err)
+			}
+			return false
+		}
+		errs := s.check(msgType, data)
+		if len(errs) == 0 || !s.skip {
+			for _, err := range errs {
+				l.Errorf("step %d: %s", i, err)
+			}
+			return true
+		}
+	}
+}
+
+// check returns the expectations not met by the message.
+func (s *step) check(msgType int, data []byte) []string {
+	if s.msgType != 0 && msgType != s.msgType {
+		return []string{fmt.Sprintf("wrong message type (got %s; want %s)", messageTypeName(msgType), messageTypeName(s.msgType))}
+	}
+	if raw, ok := s.document.([]byte); ok {
+		if !bytes.Equal(data, raw) {
+			return []string{"message does not match document"}
+		}
+		return nil
+	}
+	if s.document == nil && s.jsonValues == nil {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return []string{fmt.Sprintf("could not decode json message: %v", err)}
+	}
+	if s.document != nil && !cmp.Equal(s.document, v) {
+		return []string{"message does not match document"}
+	}
+	queries := make([]string, 0, len(s.jsonValues))
+	for query := range s.jsonValues {
+		queries = append(queries, query)
+	}
+	sort.Strings(queries)
+	var errs []string
+	for _, query := range queries {
+		expected := s.jsonValues[query]
+		val, err := json.Query(query, v)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("could not get value for query %q: %v", query, err))
+			continue
+		}
+		if !cmp.Equal(val, expected) {
+			errs = append(errs, fmt.Sprintf("wrong value for query %q (got %v; want %v)", query, val, expected))
+		}
+	}
+	return errs
+}
+
+func messageTypeName(t int) string {
+	switch t {
+	case websocket.TextMessage:
+		return "text"
+	case websocket.BinaryMessage:
+		return "binary"
+	}
+	return fmt.Sprint(t)
+}
+
+func init() {
+	plugin.Register(&plugin.Registration{
+		Type:   plugin.TestSuitePlugin,
+		ID:     "WS",
+		Config: (*Config)(nil),
+		InitFn: func(ctx *plugin.InitContext) (interface{}, error) {
+			cfg := ctx.Config.(*Config)
+			cfg.Path = ctx.Path
+			cfg.Root = ctx.Root
+			return New(cfg)
+		},
+	})
+}
@@ -0,0 +1,168 @@
+package wsexpect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/websocket"
+	"golang.org/x/oauth2/clientcredentials"
+
+	ajson "github.com/blippar/aragorn/pkg/util/json"
+)
+
+type mockLogger struct {
+	errs []string
+}
+
+func (tr *mockLogger) Error(args ...interface{}) {
+	tr.errs = append(tr.errs, fmt.Sprint(args...))
+}
+
+func (tr *mockLogger) Errorf(format string, args ...interface{}) {
+	tr.errs = append(tr.errs, fmt.Sprintf(format, args...))
+}
+
+// newServer starts a WebSocket server greeting the client with the value of
+// its Authorization header, sending two notifications and then echoing the
+// received messages.
+func newServer(t *testing.T) *httptest.Server {
+	var upgrader websocket.Upgrader
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("could not upgrade: %v", err)
+			return
+		}
+		defer conn.Close()
+		conn.WriteMessage(websocket.TextMessage, []byte(r.Header.Get("Authorization")))
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"event":"notification","id":1}`))
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"event":"notification","id":2}`))
+		for {
+			msgType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(msgType, data)
+		}
+	}))
+}
+
+func TestSuiteRunTest(t *testing.T) {
+	ts := newServer(t)
+	defer ts.Close()
+	tt := []struct {
+		name  string
+		steps []Step
+		errs  []string
+	}{
+		{
+			name: "in order",
+			steps: []Step{
+				{Receive: &Expect{Type: "text", Document: map[string]interface{}{"$raw": "Bearer s3cr3t"}}},
+				{Receive: &Expect{Document: map[string]interface{}{"event": "notification", "id": json.Number("1")}}},
+				{Receive: &Expect{JSONValues: map[string]interface{}{"id": json.Number("2")}}},
+				{Send: &Message{JSON: map[string]interface{}{"hello": "world"}}},
+				{Receive: &Expect{Document: map[string]interface{}{"hello": "world"}}},
+				{Send: &Message{Binary: "AAEC"}},
+				{Receive: &Expect{Type: "binary", Document: map[string]interface{}{"$raw": "\x00\x01\x02"}}},
+			},
+		},
+		{
+			name: "skip",
+			steps: []Step{
+				{Receive: &Expect{Skip: true, JSONValues: map[string]interface{}{"id": json.Number("2")}}},
+				{Send: &Message{Text: "ping"}},
+				{Receive: &Expect{Document: map[string]interface{}{"$raw": "ping"}}},
+			},
+		},
+		{
+			name: "mismatch",
+			steps: []Step{
+				{Receive: &Expect{Type: "binary"}},
+				{Receive: &Expect{Document: map[string]interface{}{"event": "notification", "id": json.Number("2")}}},
+				{Receive: &Expect{JSONValues: map[string]interface{}{"id": json.Number("1"), "data": "x"}}},
+			},
+			errs: []string{
+				"step 0: wrong message type (got text; want binary)",
+				"step 1: message does not match document",
+				`step 2: could not get value for query "data": data: object does not contain field`,
+				`step 2: wrong value for query "id" (got 2; want 1)`,
+			},
+		},
+		{
+			name: "timeout",
+			steps: []Step{
+				{Receive: &Expect{Skip: true, Timeout: ajson.Duration(100 * time.Millisecond), JSONValues: map[string]interface{}{"id": json.Number("3")}}},
+				{Send: &Message{Text: "ping"}},
+			},
+			errs: []string{"step 0: no matching message received: "},
+		},
+	}
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"s3cr3t","token_type":"bearer","expires_in":3600}`)
+	}))
+	defer tokens.Close()
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &Config{
+				Base: Base{
+					URL:    "ws" + strings.TrimPrefix(ts.URL, "http"),
+					OAUTH2: &clientcredentials.Config{TokenURL: tokens.URL, ClientID: "aragorn"},
+				},
+				Tests: []*Test{{Name: tc.name, Path: "/", Steps: tc.steps}},
+			}
+			suite, err := New(cfg)
+			if err != nil {
+				t.Fatalf("can't create suite: %v", err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			l := &mockLogger{}
+			suite.Tests()[0].Run(ctx, l)
+			if len(l.errs) != len(tc.errs) {
+				t.Fatalf("wrong errors (got %q; want %q)", l.errs, tc.errs)
+			}
+			for i, err := range l.errs {
+				if !strings.HasPrefix(err, tc.errs[i]) {
+					t.Errorf("wrong error (got %q; want prefix %q)", err, tc.errs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	cfg := &Config{
+		Base: Base{URL: "http://localhost"},
+		Tests: []*Test{
+			{
+				Name: "test",
+				Steps: []Step{
+					{},
+					{Send: &Message{Text: "a", Binary: "AA=="}},
+					{Receive: &Expect{Type: "json"}},
+				},
+			},
+		},
+	}
+	want := "test \"test\":\n" +
+		"- url: unsupported scheme \"http\"\n" +
+		"- step 0: exactly one of send or receive must be set\n" +
+		"- step 1: exactly one of text, json or binary must be set\n" +
+		"- step 2: unknown message type \"json\""
+	_, err := New(cfg)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if diff := cmp.Diff(want, err.Error()); diff != "" {
+		t.Fatalf("wrong error (-want +got):\n%s", diff)
+	}
+}